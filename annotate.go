@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/pkg/estimator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/retry"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"gopkg.in/ini.v1"
+	"k8s.io/client-go/kubernetes"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// RunAnnotate writes the estimated Autopilot monthly cost of every controller
+// in the cluster to an annotation on the controller, eg. `annotate -dry-run`.
+func RunAnnotate(cfg *ini.File, args []string) error {
+	flags := flag.NewFlagSet("annotate", flag.ExitOnError)
+	annotationFlag := flags.String("annotation", "cost.gke.io/estimate", "Annotation that holds the estimated monthly cost")
+	dryRunFlag := flags.Bool("dry-run", false, "Only print the annotations, don't patch the controllers")
+	namespaceFlag, selectorFlag := addKubectlFlags(flags)
+	addConfigFlags(flags, cfg)
+	logging.AddFlags(flags)
+	flags.Parse(args)
+
+	if err := checkConfig(cfg); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	kubeConfig, kubeConfigPath, err := cluster.GetKubeConfig()
+	if err != nil {
+		return err
+	}
+
+	kubeConfig.Wrap(retry.Transport(usage.Kubernetes))
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error setting kubernetes config: %v", err)
+	}
+
+	metricsClientset, err := metricsv.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("error setting kubernetes metrics config: %v", err)
+	}
+
+	var currentContext []string
+	if kubeConfigPath == "" {
+		currentContext, err = cluster.GetInClusterContext()
+	} else {
+		currentContext, err = cluster.GetCurrentContext()
+	}
+	if err != nil {
+		return err
+	}
+	if len(currentContext) != 4 {
+		return fmt.Errorf("current context is not a GKE context")
+	}
+
+	pricingService, err := calculator.NewService(ctx, estimator.GetPricingSKUs(cfg), currentContext[2], clientset, metricsClientset, cfg)
+	if err != nil {
+		return fmt.Errorf("error initializing pricing service: %v", err)
+	}
+	pricingService.Namespaces = *namespaceFlag
+	pricingService.LabelSelector = *selectorFlag
+
+	nodes, err := cluster.GetClusterNodes(ctx, clientset)
+	if err != nil {
+		return err
+	}
+
+	workloads, err := pricingService.PopulateWorkloads(ctx, nodes)
+	if err != nil {
+		return err
+	}
+
+	owners, err := cluster.GetOwnerCosts(ctx, clientset, workloads)
+	if err != nil {
+		return err
+	}
+
+	updated := time.Now().UTC().Format(time.RFC3339)
+	for _, owner := range owners {
+		if owner.Owner.Kind == "Pod" {
+			logging.Info("Pod %s/%s has no controller, skipping.", owner.Namespace, owner.Owner.Name)
+			continue
+		}
+
+		monthlyCost := owner.Cost.Mul(calculator.HOURS_PER_MONTH)
+		annotations := map[string]string{
+			*annotationFlag:              monthlyCost.String(),
+			*annotationFlag + "-updated": updated,
+		}
+
+		fmt.Printf("%s %s/%s (%d pods): %s=%s\n", owner.Owner.Kind, owner.Namespace, owner.Owner.Name, owner.Replicas, *annotationFlag, monthlyCost)
+		if *dryRunFlag {
+			continue
+		}
+
+		if err := cluster.AnnotateOwner(ctx, clientset, owner.Namespace, owner.Owner, annotations); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// browseWorkload is a workload of the interactive browser with the cluster and node it runs on.
+type browseWorkload struct {
+	Cluster  string
+	Node     string
+	Spot     bool
+	Workload cluster.Workload
+}
+
+// browseColumn is a column of the interactive browser, Less orders the workloads by the column.
+type browseColumn struct {
+	Title string
+	Width int
+	Value func(workload browseWorkload) string
+	Less  func(a, b browseWorkload) bool
+}
+
+var browseColumns = []browseColumn{
+	{"Cluster", 20, func(w browseWorkload) string { return w.Cluster }, func(a, b browseWorkload) bool { return a.Cluster < b.Cluster }},
+	{"Namespace", 20, func(w browseWorkload) string { return w.Workload.Namespace }, func(a, b browseWorkload) bool { return a.Workload.Namespace < b.Workload.Namespace }},
+	{"Workload", 40, func(w browseWorkload) string { return w.Workload.Name }, func(a, b browseWorkload) bool { return a.Workload.Name < b.Workload.Name }},
+	{"Node", 30, func(w browseWorkload) string { return w.Node }, func(a, b browseWorkload) bool { return a.Node < b.Node }},
+	{"Spot", 5, func(w browseWorkload) string { return strconv.FormatBool(w.Spot) }, func(a, b browseWorkload) bool { return !a.Spot && b.Spot }},
+	{"mCPU", 8, func(w browseWorkload) string { return strconv.FormatInt(w.Workload.Cpu, 10) }, func(a, b browseWorkload) bool { return a.Workload.Cpu < b.Workload.Cpu }},
+	{"Memory MiB", 10, func(w browseWorkload) string { return strconv.FormatInt(w.Workload.Memory, 10) }, func(a, b browseWorkload) bool { return a.Workload.Memory < b.Workload.Memory }},
+	{"Storage MiB", 11, func(w browseWorkload) string { return strconv.FormatInt(w.Workload.Storage, 10) }, func(a, b browseWorkload) bool { return a.Workload.Storage < b.Workload.Storage }},
+	{"Compute Class", 15, func(w browseWorkload) string { return cluster.ComputeClasses[w.Workload.ComputeClass] }, func(a, b browseWorkload) bool { return a.Workload.ComputeClass < b.Workload.ComputeClass }},
+}
+
+// browseModel lets the user scroll, sort and filter the workloads of the reports, and switch
+// the price between hourly and monthly.
+type browseModel struct {
+	workloads []browseWorkload
+	shown     []browseWorkload
+
+	table      table.Model
+	filter     textinput.Model
+	filtering  bool
+	sortColumn int
+	descending bool
+	monthly    bool
+}
+
+func newBrowseModel(reports []*clusterReport) browseModel {
+	m := browseModel{
+		// Most expensive workloads first
+		sortColumn: len(browseColumns),
+		descending: true,
+		monthly:    timeBasis.Period == "month",
+	}
+	for _, report := range reports {
+		for _, node := range cluster.SortedNodes(report.nodes) {
+			for _, workload := range node.Workloads {
+				m.workloads = append(m.workloads, browseWorkload{Cluster: report.Name, Node: node.Name, Spot: node.Spot || workload.Spot, Workload: workload})
+			}
+		}
+	}
+
+	m.filter = textinput.New()
+	m.filter.Prompt = "Filter: "
+	m.filter.Placeholder = "namespace or workload name"
+
+	m.table = table.New(table.WithFocused(true), table.WithHeight(20))
+	stl := table.DefaultStyles()
+	stl.Header = stl.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("255")).
+		BorderBottom(true).
+		Bold(false)
+	m.table.SetStyles(stl)
+
+	m.refresh()
+
+	return m
+}
+
+// price is the hourly or monthly cost of the workload.
+func (m browseModel) price(workload browseWorkload) cluster.Money {
+	if m.monthly {
+		return workload.Workload.Cost.Mul(calculator.HOURS_PER_MONTH)
+	}
+	return workload.Workload.Cost
+}
+
+// refresh filters and sorts the workloads and rebuilds the table. The price is the column after browseColumns.
+func (m *browseModel) refresh() {
+	filter := strings.ToLower(m.filter.Value())
+
+	m.shown = []browseWorkload{}
+	for _, workload := range m.workloads {
+		name := strings.ToLower(workload.Workload.Namespace + "/" + workload.Workload.Name)
+		if filter == "" || strings.Contains(name, filter) {
+			m.shown = append(m.shown, workload)
+		}
+	}
+
+	less := func(a, b browseWorkload) bool { return a.Workload.Cost < b.Workload.Cost }
+	if m.sortColumn < len(browseColumns) {
+		less = browseColumns[m.sortColumn].Less
+	}
+	sort.SliceStable(m.shown, func(i, j int) bool {
+		if m.descending {
+			return less(m.shown[j], m.shown[i])
+		}
+		return less(m.shown[i], m.shown[j])
+	})
+
+	unit := "H"
+	if m.monthly {
+		unit = "M"
+	}
+	titles := []table.Column{}
+	for _, column := range browseColumns {
+		titles = append(titles, table.Column{Title: column.Title, Width: column.Width})
+	}
+	titles = append(titles, table.Column{Title: "Price " + priceUnit(unit), Width: 12})
+
+	indicator := " ▲"
+	if m.descending {
+		indicator = " ▼"
+	}
+	titles[m.sortColumn].Title += indicator
+
+	rows := []table.Row{}
+	for _, workload := range m.shown {
+		row := table.Row{}
+		for _, column := range browseColumns {
+			row = append(row, column.Value(workload))
+		}
+		rows = append(rows, append(row, m.price(workload).String()))
+	}
+
+	// The rows have to match the new columns when they are set
+	m.table.SetRows([]table.Row{})
+	m.table.SetColumns(titles)
+	m.table.SetRows(rows)
+	if len(rows) > 0 && m.table.Cursor() >= len(rows) {
+		m.table.SetCursor(len(rows) - 1)
+	}
+}
+
+func (m browseModel) Init() tea.Cmd { return nil }
+
+func (m browseModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the header, the filter and the footer
+		if height := msg.Height - 7; height > 0 {
+			m.table.SetHeight(height)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.filtering {
+			switch msg.String() {
+			case "enter":
+				m.filtering = false
+				m.filter.Blur()
+				m.table.Focus()
+				return m, nil
+			case "esc":
+				m.filtering = false
+				m.filter.Blur()
+				m.filter.SetValue("")
+				m.table.Focus()
+				m.refresh()
+				return m, nil
+			}
+
+			var cmd tea.Cmd
+			m.filter, cmd = m.filter.Update(msg)
+			m.refresh()
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "/":
+			m.filtering = true
+			m.table.Blur()
+			return m, m.filter.Focus()
+		case "esc":
+			m.filter.SetValue("")
+			m.refresh()
+			return m, nil
+		case "s", "right":
+			m.sortColumn = (m.sortColumn + 1) % (len(browseColumns) + 1)
+			m.refresh()
+			return m, nil
+		case "left":
+			m.sortColumn = (m.sortColumn + len(browseColumns)) % (len(browseColumns) + 1)
+			m.refresh()
+			return m, nil
+		case "r":
+			m.descending = !m.descending
+			m.refresh()
+			return m, nil
+		case "m":
+			m.monthly = !m.monthly
+			m.refresh()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m browseModel) View() string {
+	var total cluster.Money
+	for _, workload := range m.shown {
+		total += m.price(workload)
+	}
+	period := "hour"
+	if m.monthly {
+		period = "month"
+	}
+
+	var view strings.Builder
+	view.WriteString(baseStyle.Render(m.table.View()) + "\n")
+	if m.filtering || m.filter.Value() != "" {
+		view.WriteString(m.filter.View() + "\n")
+	}
+	view.WriteString(fmt.Sprintf("%d of %d workloads, %s %s per %s\n", len(m.shown), len(m.workloads), total, cluster.CurrencySymbol(), period))
+	view.WriteString("↑/↓ scroll • ←/→ or s sort column • r reverse • / filter • m hourly/monthly • q quit\n")
+
+	return view.String()
+}
+
+// browseWorkloads runs the interactive workload browser until the user quits.
+func browseWorkloads(reports []*clusterReport) error {
+	_, err := tea.NewProgram(newBrowseModel(reports), tea.WithAltScreen()).Run()
+	return err
+}
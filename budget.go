@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// BUDGET_EXCEEDED_EXIT_CODE is the exit code of a run whose estimate is above -max-hourly-cost or
+// -max-monthly-cost, apart from the exit code 1 of errors.
+const BUDGET_EXCEEDED_EXIT_CODE = 3
+
+// BUDGET_INCOMPLETE_EXIT_CODE is the exit code of a budget gate whose estimate is missing clusters or
+// samples, a cost below the thresholds can't be trusted then.
+const BUDGET_INCOMPLETE_EXIT_CODE = 4
+
+// budgetSummary is the single line json document a budget gate writes to stderr.
+type budgetSummary struct {
+	Currency       string
+	HourlyCost     cluster.Money
+	MonthlyCost    cluster.Money
+	MaxHourlyCost  cluster.Money `json:",omitempty"`
+	MaxMonthlyCost cluster.Money `json:",omitempty"`
+	Exceeded       bool
+	Incomplete     bool
+}
+
+// checkBudget compares the estimated Autopilot hourly cost to the thresholds, a threshold of 0 is not checked.
+// An incomplete estimate, eg. with skipped clusters, is reported as such whatever its cost.
+func checkBudget(hourlyCost cluster.Money, incomplete bool, maxHourlyCost float64, maxMonthlyCost float64) budgetSummary {
+	summary := budgetSummary{
+		Currency:       cluster.DisplayCurrency,
+		HourlyCost:     hourlyCost,
+		MonthlyCost:    hourlyCost.Mul(calculator.HOURS_PER_MONTH),
+		MaxHourlyCost:  cluster.NewMoney(maxHourlyCost),
+		MaxMonthlyCost: cluster.NewMoney(maxMonthlyCost),
+		Incomplete:     incomplete,
+	}
+
+	if summary.MaxHourlyCost > 0 && summary.HourlyCost > summary.MaxHourlyCost {
+		summary.Exceeded = true
+	}
+	if summary.MaxMonthlyCost > 0 && summary.MonthlyCost > summary.MaxMonthlyCost {
+		summary.Exceeded = true
+	}
+
+	return summary
+}
+
+// enforceBudget writes the budget summary to stderr, so it doesn't mix with the report on stdout,
+// and exits if the estimate is above a threshold or incomplete.
+func enforceBudget(hourlyCost cluster.Money, incomplete bool, maxHourlyCost float64, maxMonthlyCost float64) {
+	if maxHourlyCost <= 0 && maxMonthlyCost <= 0 {
+		return
+	}
+
+	summary := checkBudget(hourlyCost, incomplete, maxHourlyCost, maxMonthlyCost)
+	contents, _ := json.Marshal(summary)
+	fmt.Fprintf(os.Stderr, "%s\n", contents)
+
+	if summary.Exceeded {
+		os.Exit(BUDGET_EXCEEDED_EXIT_CODE)
+	}
+	if summary.Incomplete {
+		os.Exit(BUDGET_INCOMPLETE_EXIT_CODE)
+	}
+}
+
+// isIncomplete reports if any of the contexts was skipped or not estimated, or if any estimate was interrupted.
+func isIncomplete(reports []*clusterReport, contexts int) bool {
+	if len(reports) < contexts {
+		return true
+	}
+	for _, report := range reports {
+		if report.partial {
+			return true
+		}
+	}
+
+	return false
+}
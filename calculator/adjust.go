@@ -0,0 +1,74 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// AdjustResources returns the mCPU and memory Autopilot bills for Pod requests in the compute class. Requests
+// below the <class>_mcpu_min and <class>_memory_min of [limits] are raised, mCPU is rounded up to the step of the class and, if the memory to vCPU ratio
+// is outside the [ratios] of the class, memory is raised up to the minimum ratio or mCPU up to the maximum ratio.
+// The memory minimum is applied last, so the 1 GiB of a 250 mCPU Scale-Out Pod doesn't add another mCPU step.
+// GPU Pods are raised to the minimums of [limits] for their GPU model.
+func (service *PricingService) AdjustResources(class cluster.ComputeClass, gpuModel string, mCPU int64, memory int64) (int64, int64) {
+	limits := service.getClassLimits(class, gpuModel)
+
+	if mCPU < limits.mCPUMin {
+		mCPU = limits.mCPUMin
+	}
+
+	// Too much memory per vCPU, more vCPU is added
+	if limits.ratioMax > 0 && float64(memory) > float64(mCPU)*limits.ratioMax {
+		mCPU = int64(math.Ceil(float64(memory) / limits.ratioMax))
+	}
+
+	mCPU = roundUp(mCPU, limits.mCPUStep)
+
+	// Too little memory per vCPU, more memory is added
+	if limits.ratioMin > 0 && float64(memory) < float64(mCPU)*limits.ratioMin {
+		memory = int64(math.Ceil(float64(mCPU) * limits.ratioMin))
+	}
+
+	if memory < limits.memoryMin {
+		memory = limits.memoryMin
+	}
+
+	return mCPU, memory
+}
+
+// roundUp rounds the value up to a multiple of the step, a step below 2 leaves it as is.
+func roundUp(value int64, step int64) int64 {
+	if step < 2 {
+		return value
+	}
+	if missing := value % step; missing != 0 {
+		value += step - missing
+	}
+	return value
+}
+
+// CanBurst reports if Pods of the compute class can burst above their requests. Classes that are billed
+// by Pod requests support bursting, Performance and Accelerator Pods already have the whole node and
+// GPU Pods have fixed sizes per GPU.
+func CanBurst(class cluster.ComputeClass) bool {
+	switch class {
+	case cluster.ComputeClassGeneralPurpose, cluster.ComputeClassBalanced, cluster.ComputeClassScaleout, cluster.ComputeClassScaleoutArm:
+		return true
+	}
+	return false
+}
@@ -17,43 +17,127 @@ package calculator
 import (
 	"context"
 	"fmt"
-	"log"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"google.golang.org/api/cloudbilling/v1"
 	"gopkg.in/ini.v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 const CLUSTER_FEE = 0.1
 
+// Periods hourly prices are projected to, a month is HOURS_PER_MONTH
+const (
+	HOURS_PER_DAY  = 24
+	HOURS_PER_YEAR = 8760
+)
+
+// Sizing modes decide what a container is billed for: its requests (how Autopilot bills), its usage
+// (to quantify rightsizing) or the larger of both.
+const (
+	SIZING_REQUESTS = "requests"
+	SIZING_USAGE    = "usage"
+	SIZING_MAX      = "max"
+)
+
 type PricingService struct {
 	AutopilotPricing AutopilotPriceList
 	GCEPricing       GCEPriceList
 	Config           *ini.File
-	clientset        *kubernetes.Clientset
-	metricsClientset *metricsv.Clientset
+	clientset        kubernetes.Interface
+	metricsClientset metricsv.Interface
+
+	// IncludeCompletedPods prices Succeeded and Failed (eg. Evicted) pods as well, for audit purposes
+	IncludeCompletedPods bool
+
+	// Namespaces limits the workloads to the included namespaces, all but the excluded ones by default
+	Namespaces cluster.NamespaceFilter
+
+	// LabelSelector limits the workloads to the pods matching it, empty for all pods
+	LabelSelector string
+
+	// SystemWorkloads are the workloads of the system namespaces priced by the last PopulateWorkloads
+	// when Namespaces includes them. GKE runs them and doesn't bill them on Autopilot, so they are kept
+	// out of the nodes and the workloads of the estimate.
+	SystemWorkloads []cluster.Workload
+
+	// SizingMode is one of SIZING_REQUESTS, SIZING_USAGE or SIZING_MAX, the default
+	SizingMode string
+
+	// SidecarMode is one of SIDECARS_INCLUDE, the default, SIDECARS_EXCLUDE or SIDECARS_SEPARATE
+	SidecarMode string
+
+	// Bursting bills containers with limits above their requests by their requests, usage above the requests
+	// is burst into unused capacity. Autopilot clusters support bursting from GKE 1.30.2.
+	Bursting bool
+
+	// UsageHistory replaces the current usage from metrics-server, eg. with a percentile from Cloud Monitoring
+	UsageHistory cluster.UsageHistory
+
+	// ComputeClasses are the ComputeClass objects of the cluster by name, pods that select them are priced by their priorities
+	ComputeClasses map[string]cluster.CustomComputeClass
+
+	// JobRuns are how often the Jobs of the cluster run by namespace/name, their pods are priced for the share
+	// of the month they run. Without them pods of Jobs are priced as running all month.
+	JobRuns map[string]cluster.JobRuns
+
+	// warnings raised while pricing the current workload
+	warnings []cluster.Warning
 }
 
-func NewService(sku map[string]string, region string, clientset *kubernetes.Clientset, metricsClientset *metricsv.Clientset, config *ini.File) (*PricingService, error) {
-	apPricing, err := GetAutopilotPricing(sku["autopilot"], region)
-	if err != nil {
-		return nil, err
+func NewService(ctx context.Context, sku map[string]string, region string, clientset kubernetes.Interface, metricsClientset metricsv.Interface, config *ini.File) (*PricingService, error) {
+	var mapping SkuMapping
+	if path := config.Section("").Key("sku_mapping_file").String(); path != "" {
+		var err error
+		mapping, err = LoadSkuMapping(path)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	gcePricing, err := GetGCEPricing(sku["gce"], region)
+	// Prices are fetched in USD unless another currency is configured
+	currency := config.Section("").Key("currency").MustString("USD")
+
+	// Service IDs that are not configured are looked up in the billing catalog
+	sku, err := resolveServiceIds(ctx, sku)
 	if err != nil {
 		return nil, err
 	}
 
+	// Both catalogs are downloaded at the same time, and only once per run
+	var autopilotSkus, gceSkus []*cloudbilling.Sku
+	var autopilotErr, gceErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		autopilotSkus, autopilotErr = FetchSkus(ctx, sku["autopilot"], currency)
+	}()
+	go func() {
+		defer wg.Done()
+		gceSkus, gceErr = FetchSkus(ctx, sku["gce"], currency)
+	}()
+	wg.Wait()
+
+	if autopilotErr != nil {
+		return nil, fmt.Errorf("unable to fetch autopilot cloud billing information: %v", autopilotErr)
+	}
+	if gceErr != nil {
+		return nil, fmt.Errorf("unable to fetch gce cloud billing information: %v", gceErr)
+	}
+
 	service := &PricingService{
-		AutopilotPricing: apPricing,
-		GCEPricing:       gcePricing,
+		AutopilotPricing: GetAutopilotPricing(autopilotSkus, region, mapping.Autopilot),
+		GCEPricing:       GetGCEPricing(gceSkus, region, mapping.GCE),
 		clientset:        clientset,
 		metricsClientset: metricsClientset,
 		Config:           config,
@@ -62,355 +146,832 @@ func NewService(sku map[string]string, region string, clientset *kubernetes.Clie
 	return service, nil
 }
 
-func (service *PricingService) CalculatePricing(cpu int64, memory int64, storage int64, gpu int64, gpuModel string, class cluster.ComputeClass, instanceType string, spot bool) float64 {
+// SetClientsets replaces the clients the pods and their metrics are listed with, eg. with fake clientsets.
+func (service *PricingService) SetClientsets(clientset kubernetes.Interface, metricsClientset metricsv.Interface) {
+	service.clientset = clientset
+	service.metricsClientset = metricsClientset
+}
+
+// warn logs the message and records it against the workload that is currently being priced.
+func (service *PricingService) warn(warningType cluster.WarningType, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	logging.Warn("%s", message)
+	service.warnings = append(service.warnings, cluster.Warning{Type: warningType, Message: message})
+}
+
+// PriceBreakdown is the hourly price of a workload split by the billed resource.
+type PriceBreakdown struct {
+	Cpu         float64
+	Memory      float64
+	Storage     float64
+	Accelerator float64
+	// Machine is the price of the underlying GCE machine for classes that are billed per node
+	Machine float64
+	// Disk is the price of the persistent disks claimed by the workload
+	Disk float64
+	// Network is the price of the estimated internet and inter-zone egress
+	Network float64
+}
+
+func (breakdown PriceBreakdown) Total() float64 {
+	return breakdown.Cpu + breakdown.Memory + breakdown.Storage + breakdown.Accelerator + breakdown.Machine + breakdown.Disk + breakdown.Network
+}
+
+// CostBreakdown converts the breakdown into the money amounts stored on a workload.
+func (breakdown PriceBreakdown) CostBreakdown() cluster.CostBreakdown {
+	return cluster.CostBreakdown{
+		Cpu:         cluster.NewMoney(breakdown.Cpu),
+		Memory:      cluster.NewMoney(breakdown.Memory),
+		Storage:     cluster.NewMoney(breakdown.Storage),
+		Accelerator: cluster.NewMoney(breakdown.Accelerator),
+		Machine:     cluster.NewMoney(breakdown.Machine),
+		Disk:        cluster.NewMoney(breakdown.Disk),
+		Network:     cluster.NewMoney(breakdown.Network),
+	}
+}
+
+// PriceRequest is what a workload is priced for: its billed resources, accelerators, compute class
+// and the machine it runs on.
+type PriceRequest struct {
+	// Billed mCPU, memory and ephemeral storage in MB
+	Cpu     int64
+	Memory  int64
+	Storage int64
+
+	GPU      int64
+	GPUModel string
+	TPU      int64
+	TPUType  string
+
+	Class cluster.ComputeClass
+	// InstanceType is the machine of Performance and Accelerator Pods, that are billed by the node
+	InstanceType string
+	Spot         bool
+}
+
+func (service *PricingService) CalculatePricing(request PriceRequest) float64 {
+	return service.CalculatePriceBreakdown(request).Total()
+}
+
+func (service *PricingService) CalculatePriceBreakdown(request PriceRequest) PriceBreakdown {
+	cpu, memory, storage := request.Cpu, request.Memory, request.Storage
+	gpu, gpuModel, tpu, tpuType := request.GPU, request.GPUModel, request.TPU, request.TPUType
+	class, instanceType, spot := request.Class, request.InstanceType, request.Spot
+
+	if tpu > 0 {
+		return service.getTPUPriceBreakdown(cpu, memory, storage, tpu, tpuType, spot)
+	}
+
 	// If spot, calculations are done based on spot pricing
 	if spot {
 		switch class {
 		case cluster.ComputeClassPerformance:
-			perfPrice := service.AutopilotPricing.SpotPerformanceCpuPricePremium*float64(cpu)/1000 + service.AutopilotPricing.SpotPerformanceMemoryPricePremium*float64(memory)/1000 + service.AutopilotPricing.SpotPerformanceLocalSSDPricePremium*float64(storage)/1000
-			if perfPrice == 0 {
-				log.Printf("Requested Spot Performance (%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
+			perfPrice := PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotPerformanceCpuPricePremium * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotPerformanceMemoryPricePremium * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.SpotPerformanceLocalSSDPricePremium * float64(storage) / 1000,
+			}
+			if perfPrice.Total() == 0 {
+				service.warn(cluster.WarningPricingUnavailable, "Requested Spot Performance (%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
 			}
 
-			gcePrice, _ := service.GetGCEMachinePrice(instanceType, spot)
+			perfPrice.Machine = service.getPerformanceMachinePrice(instanceType, cpu, memory, spot)
 
-			return perfPrice + gcePrice
+			return perfPrice
 		case cluster.ComputeClassAccelerator:
-			// TODO lookup machine type and add to the price
-			acceleratorPrice := service.AutopilotPricing.SpotAcceleratorCpuPricePremium*float64(cpu)/1000 + service.AutopilotPricing.SpotAcceleratorMemoryGPUPricePremium*float64(memory)/1000 + service.AutopilotPricing.AcceleratorLocalSSDPricePremium*float64(storage)/1000
-			switch gpuModel {
-			case "nvidia-tesla-t4":
-				acceleratorPrice += service.AutopilotPricing.SpotAcceleratorT4GPUPricePremium * float64(gpu)
-			case "nvidia-l4":
-				acceleratorPrice += service.AutopilotPricing.SpotAcceleratorL4GPUPricePremium * float64(gpu)
-			case "nvidia-tesla-a100":
-				acceleratorPrice += service.AutopilotPricing.SpotAcceleratorA10040GGPUPricePremium * float64(gpu)
-			case "nvidia-a100-80gb":
-				acceleratorPrice += service.AutopilotPricing.SpotAcceleratorA10080GGPUPricePremium * float64(gpu)
-			case "nvidia-h100-80gb":
-				acceleratorPrice += service.AutopilotPricing.SpotAcceleratorH100GPUPricePremium * float64(gpu)
-			default:
-				acceleratorPrice = 0
-				log.Printf("Requested Spot GPU (%s) pricing for Accelerator compute class (%s) is not available in %s region.", gpuModel, instanceType, service.AutopilotPricing.Region)
+			acceleratorPrice := PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotAcceleratorCpuPricePremium * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotAcceleratorMemoryGPUPricePremium * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.AcceleratorLocalSSDPricePremium * float64(storage) / 1000,
+			}
+			if gpuPrice, ok := service.getGPUPrice(gpuModel, class, spot); ok {
+				acceleratorPrice.Accelerator = gpuPrice * float64(gpu)
+			} else {
+				acceleratorPrice = PriceBreakdown{}
+				service.warn(cluster.WarningPricingUnavailable, "Requested Spot GPU (%s) pricing for Accelerator compute class (%s) is not available in %s region.", gpuModel, instanceType, service.AutopilotPricing.Region)
 			}
 
-			gcePrice, _ := service.GetGCEMachinePrice(instanceType, spot)
-			return acceleratorPrice + gcePrice
+			machinePrice, err := service.GetGCEMachinePrice(instanceType, spot)
+			if err != nil {
+				service.warn(cluster.WarningPricingUnavailable, "Spot machine (%s) of the Accelerator compute class can't be priced: %v", instanceType, err)
+			}
+			acceleratorPrice.Machine = machinePrice
+			return acceleratorPrice
 
 		case cluster.ComputeClassGPUPod:
-			acceleratorPrice := service.AutopilotPricing.SpotGPUPodvCPUPrice*float64(cpu)/1000 + service.AutopilotPricing.SpotGPUPodMemoryPrice*float64(memory)/1000 + service.AutopilotPricing.SpotGPUPodLocalSSDPrice*float64(storage)/1000
-			switch gpuModel {
-			case "nvidia-tesla-t4":
-				acceleratorPrice += service.AutopilotPricing.SpotNVIDIAT4PodGPUPrice * float64(gpu)
-			case "nvidia-l4":
-				acceleratorPrice += service.AutopilotPricing.SpotNVIDIAL4PodGPUPrice * float64(gpu)
-			case "nvidia-tesla-a100":
-				acceleratorPrice += service.AutopilotPricing.SpotNVIDIAA10040GPodGPUPrice * float64(gpu)
-			case "nvidia-a100-80gb":
-				acceleratorPrice += service.AutopilotPricing.SpotNVIDIAA10080GPodGPUPrice * float64(gpu)
-			default:
-				acceleratorPrice = 0
-				log.Printf("Requested Spot GPU (%s) pricing is not available in %s region.", gpuModel, service.AutopilotPricing.Region)
+			acceleratorPrice := PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotGPUPodvCPUPrice * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotGPUPodMemoryPrice * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.SpotGPUPodLocalSSDPrice * float64(storage) / 1000,
+			}
+			if gpuPrice, ok := service.getGPUPrice(gpuModel, class, spot); ok {
+				acceleratorPrice.Accelerator = gpuPrice * float64(gpu)
+			} else {
+				acceleratorPrice = PriceBreakdown{}
+				service.warn(cluster.WarningPricingUnavailable, "Requested Spot GPU (%s) pricing is not available in %s region.", gpuModel, service.AutopilotPricing.Region)
 			}
 			return acceleratorPrice
 
 		case cluster.ComputeClassBalanced:
-			return service.AutopilotPricing.SpotCpuPrice*float64(cpu)/1000 + service.AutopilotPricing.SpotMemoryPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
+			return PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotCpuPrice * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotMemoryPrice * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+			}
 
 		case cluster.ComputeClassScaleout:
-			return service.AutopilotPricing.SpotCpuScaleoutPrice*float64(cpu)/1000 + service.AutopilotPricing.SpotMemoryScaleoutPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
+			return PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotCpuScaleoutPrice * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotMemoryScaleoutPrice * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+			}
 
 		case cluster.ComputeClassScaleoutArm:
-			armPrice := service.AutopilotPricing.SpotArmCpuScaleoutPrice*float64(cpu)/1000 + service.AutopilotPricing.SpotArmMemoryScaleoutPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
-			if armPrice == 0 {
-				log.Printf("Request Spot ARM (%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
+			armPrice := PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotArmCpuScaleoutPrice * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotArmMemoryScaleoutPrice * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+			}
+			if armPrice.Total() == 0 {
+				service.warn(cluster.WarningPricingUnavailable, "Request Spot ARM (%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
 			}
 			return armPrice
 
 		default:
-			return service.AutopilotPricing.SpotCpuPrice*float64(cpu)/1000 + service.AutopilotPricing.SpotMemoryPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
+			return PriceBreakdown{
+				Cpu:     service.AutopilotPricing.SpotCpuPrice * float64(cpu) / 1000,
+				Memory:  service.AutopilotPricing.SpotMemoryPrice * float64(memory) / 1000,
+				Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+			}
 		}
 	}
 
 	switch class {
 	case cluster.ComputeClassPerformance:
-		perfPrice := service.AutopilotPricing.PerformanceCpuPricePremium*float64(cpu)/1000 + service.AutopilotPricing.PerformanceMemoryPricePremium*float64(memory)/1000 + service.AutopilotPricing.PerformanceLocalSSDPricePremium*float64(storage)/1000
-		if perfPrice == 0 {
-			log.Printf("Requested Performance(%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
+		perfPrice := PriceBreakdown{
+			Cpu:     service.AutopilotPricing.PerformanceCpuPricePremium * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.PerformanceMemoryPricePremium * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.PerformanceLocalSSDPricePremium * float64(storage) / 1000,
+		}
+		if perfPrice.Total() == 0 {
+			service.warn(cluster.WarningPricingUnavailable, "Requested Performance(%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
 		}
 
-		gcePrice, _ := service.GetGCEMachinePrice(instanceType, spot)
-		return perfPrice + gcePrice
+		perfPrice.Machine = service.getPerformanceMachinePrice(instanceType, cpu, memory, spot)
+		return perfPrice
 	case cluster.ComputeClassAccelerator:
-		acceleratorPrice := service.AutopilotPricing.AcceleratorCpuPricePremium*float64(cpu)/1000 + service.AutopilotPricing.AcceleratorMemoryGPUPricePremium*float64(memory)/1000 + service.AutopilotPricing.AcceleratorLocalSSDPricePremium*float64(storage)/1000
-		switch gpuModel {
-		case "nvidia-tesla-t4":
-			acceleratorPrice += service.AutopilotPricing.AcceleratorT4GPUPricePremium * float64(gpu)
-		case "nvidia-l4":
-			acceleratorPrice += service.AutopilotPricing.AcceleratorL4GPUPricePremium * float64(gpu)
-		case "nvidia-tesla-a100":
-			acceleratorPrice += service.AutopilotPricing.AcceleratorA10040GGPUPricePremium * float64(gpu)
-		case "nvidia-a100-80gb":
-			acceleratorPrice += service.AutopilotPricing.AcceleratorA10080GGPUPricePremium * float64(gpu)
-		case "nvidia-h100-80gb":
-			acceleratorPrice += service.AutopilotPricing.AcceleratorH100GPUPricePremium * float64(gpu)
-		default:
-			acceleratorPrice = 0
-			log.Printf("Requested spot GPU (%s) pricing for Accelerator compute class (%s) is not available in %s region.", gpuModel, instanceType, service.AutopilotPricing.Region)
+		acceleratorPrice := PriceBreakdown{
+			Cpu:     service.AutopilotPricing.AcceleratorCpuPricePremium * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.AcceleratorMemoryGPUPricePremium * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.AcceleratorLocalSSDPricePremium * float64(storage) / 1000,
+		}
+		if gpuPrice, ok := service.getGPUPrice(gpuModel, class, spot); ok {
+			acceleratorPrice.Accelerator = gpuPrice * float64(gpu)
+		} else {
+			acceleratorPrice = PriceBreakdown{}
+			service.warn(cluster.WarningPricingUnavailable, "Requested spot GPU (%s) pricing for Accelerator compute class (%s) is not available in %s region.", gpuModel, instanceType, service.AutopilotPricing.Region)
 		}
 
-		gcePrice, _ := service.GetGCEMachinePrice(instanceType, spot)
+		machinePrice, err := service.GetGCEMachinePrice(instanceType, spot)
+		if err != nil {
+			service.warn(cluster.WarningPricingUnavailable, "Machine (%s) of the Accelerator compute class can't be priced: %v", instanceType, err)
+		}
+		acceleratorPrice.Machine = machinePrice
 
-		return acceleratorPrice + gcePrice
+		return acceleratorPrice
 	case cluster.ComputeClassGPUPod:
-		acceleratorPrice := service.AutopilotPricing.GPUPodvCPUPrice*float64(cpu)/1000 + service.AutopilotPricing.GPUPodMemoryPrice*float64(memory)/1000 + service.AutopilotPricing.GPUPodLocalSSDPrice*float64(storage)/1000
-		switch gpuModel {
-		case "nvidia-tesla-t4":
-			acceleratorPrice += service.AutopilotPricing.NVIDIAT4PodGPUPrice * float64(gpu)
-		case "nvidia-l4":
-			acceleratorPrice += service.AutopilotPricing.NVIDIAL4PodGPUPrice * float64(gpu)
-		case "nvidia-tesla-a100":
-			acceleratorPrice += service.AutopilotPricing.NVIDIAA10040GPodGPUPrice * float64(gpu)
-		case "nvidia-a100-80gb":
-			acceleratorPrice += service.AutopilotPricing.NVIDIAA10080GPodGPUPrice * float64(gpu)
-		default:
-			acceleratorPrice = 0
-			log.Printf("Requested GPU (%s) pricing is not available in %s region.", gpuModel, service.AutopilotPricing.Region)
+		acceleratorPrice := PriceBreakdown{
+			Cpu:     service.AutopilotPricing.GPUPodvCPUPrice * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.GPUPodMemoryPrice * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.GPUPodLocalSSDPrice * float64(storage) / 1000,
+		}
+		if gpuPrice, ok := service.getGPUPrice(gpuModel, class, spot); ok {
+			acceleratorPrice.Accelerator = gpuPrice * float64(gpu)
+		} else {
+			acceleratorPrice = PriceBreakdown{}
+			service.warn(cluster.WarningPricingUnavailable, "Requested GPU (%s) pricing is not available in %s region.", gpuModel, service.AutopilotPricing.Region)
 		}
 		return acceleratorPrice
 	case cluster.ComputeClassBalanced:
-		return service.AutopilotPricing.CpuBalancedPrice*float64(cpu)/1000 + service.AutopilotPricing.MemoryBalancedPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
+		return PriceBreakdown{
+			Cpu:     service.AutopilotPricing.CpuBalancedPrice * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.MemoryBalancedPrice * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+		}
 	case cluster.ComputeClassScaleout:
-		return service.AutopilotPricing.CpuScaleoutPrice*float64(cpu)/1000 + service.AutopilotPricing.MemoryScaleoutPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
+		return PriceBreakdown{
+			Cpu:     service.AutopilotPricing.CpuScaleoutPrice * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.MemoryScaleoutPrice * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+		}
 	case cluster.ComputeClassScaleoutArm:
-		armPrice := service.AutopilotPricing.CpuArmScaleoutPrice*float64(cpu)/1000 + service.AutopilotPricing.MemoryArmScaleoutPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
-		if armPrice == 0 {
-			log.Printf("Request ARM (%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
+		armPrice := PriceBreakdown{
+			Cpu:     service.AutopilotPricing.CpuArmScaleoutPrice * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.MemoryArmScaleoutPrice * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+		}
+		if armPrice.Total() == 0 {
+			service.warn(cluster.WarningPricingUnavailable, "Request ARM (%s) pricing is not available in %s region.", instanceType, service.AutopilotPricing.Region)
 		}
 		return armPrice
 	default:
-		return service.AutopilotPricing.CpuPrice*float64(cpu)/1000 + service.AutopilotPricing.MemoryPrice*float64(memory)/1000 + service.AutopilotPricing.StoragePrice*float64(storage)/1000
+		return PriceBreakdown{
+			Cpu:     service.AutopilotPricing.CpuPrice * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.MemoryPrice * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.StoragePrice * float64(storage) / 1000,
+		}
 	}
 }
 
-func (service *PricingService) GetGCEMachinePrice(instanceType string, spot bool) (float64, error) {
+// sharedCoreMachineTypes are the machine types that bill a fraction of a vCPU.
+var sharedCoreMachineTypes = map[string]struct {
+	cpus float64
+	ram  float64
+}{
+	"e2-micro":  {0.25, 1},
+	"e2-small":  {0.5, 2},
+	"e2-medium": {1, 4},
+}
+
+// memoryPerCpu is the GB of memory per vCPU of the machine families that differ from the
+// usual 4 GB for standard, 2 GB for highcpu and 8 GB for highmem machine types.
+var memoryPerCpu = map[string]float64{
+	"n1-standard":  3.75,
+	"n1-highcpu":   0.9,
+	"n1-highmem":   6.5,
+	"e2-highcpu":   1,
+	"n2-highcpu":   1,
+	"n2d-highcpu":  1,
+	"c4-standard":  3.75,
+	"c4-highmem":   7.75,
+	"m1-megamem":   14.9333,
+	"m1-ultramem":  24.025,
+	"m2-megamem":   14.2,
+	"m2-ultramem":  28.3,
+	"m2-hypermem":  21.23,
+	"m3-megamem":   15.25,
+	"m3-ultramem":  30.5,
+	"a2-highgpu":   7.0833,
+	"a2-megagpu":   14.1666,
+	"a2-ultragpu":  14.1666,
+	"a3-highgpu":   9,
+	"a3-megagpu":   9,
+	"g2-standard":  4,
+	"h3-standard":  4,
+	"c2-standard":  4,
+	"c2d-standard": 4,
+}
+
+// gpuMachineCpus is the number of vCPUs per attached GPU of the machine types named after
+// their GPU count, eg. a2-highgpu-2g.
+var gpuMachineCpus = map[string]int{
+	"a2-highgpu":  12,
+	"a2-megagpu":  6,
+	"a2-ultragpu": 12,
+	"a3-highgpu":  26,
+	"a3-megagpu":  26,
+	"a3-edgegpu":  26,
+}
+
+// customMemoryLimit is the GB of memory per vCPU of custom machine types above which
+// memory is billed as extended memory.
+var customMemoryLimit = map[string]float64{
+	"n1":  6.5,
+	"n2":  8,
+	"n2d": 8,
+}
+
+// machineType is a parsed GCE machine type.
+type machineType struct {
+	Family string
+	Cpus   float64
+	RamGb  float64
+	Custom bool
+	// Part of RamGb that is billed as extended memory
+	ExtendedRamGb float64
+}
+
+// parseMachineType splits a GCE machine type, eg. c2-standard-8, n2-custom-8-32768 or
+// a2-highgpu-2g, into the machine family, the number of vCPUs and the GB of memory.
+// It reports false if the name can't be parsed.
+func parseMachineType(instanceType string) (machineType, bool) {
+	if sharedCore, ok := sharedCoreMachineTypes[instanceType]; ok {
+		return machineType{Family: strings.Split(instanceType, "-")[0], Cpus: sharedCore.cpus, RamGb: sharedCore.ram}, true
+	}
 
 	instanceInfo := strings.Split(instanceType, "-")
-	cpus, _ := strconv.Atoi(instanceInfo[2])
-	ram := 0.0
+
+	// Custom machine types are custom-CPUS-MB for N1 and FAMILY-custom-CPUS-MB otherwise,
+	// with an -ext suffix for extended memory
+	if instanceInfo[0] == "custom" {
+		instanceInfo = append([]string{"n1"}, instanceInfo...)
+	}
+	if len(instanceInfo) >= 4 && instanceInfo[1] == "custom" {
+		cpus, err := strconv.Atoi(instanceInfo[2])
+		if err != nil {
+			return machineType{}, false
+		}
+		memory, err := strconv.Atoi(instanceInfo[3])
+		if err != nil {
+			return machineType{}, false
+		}
+
+		machine := machineType{
+			Family: instanceInfo[0],
+			Cpus:   float64(cpus),
+			RamGb:  float64(memory) / 1024,
+			Custom: true,
+		}
+		if len(instanceInfo) > 4 && instanceInfo[4] == "ext" {
+			machine.ExtendedRamGb = math.Max(machine.RamGb-machine.Cpus*customMemoryLimit[machine.Family], 0)
+		}
+
+		return machine, true
+	}
+
+	if len(instanceInfo) < 3 {
+		return machineType{}, false
+	}
 	classType := instanceInfo[1]
-	machineType := instanceInfo[0]
+	family := instanceInfo[0]
+
+	var cpus int
+	var err error
+	if perGpu, ok := gpuMachineCpus[family+"-"+classType]; ok && strings.HasSuffix(instanceInfo[2], "g") {
+		var gpus int
+		gpus, err = strconv.Atoi(strings.TrimSuffix(instanceInfo[2], "g"))
+		cpus = gpus * perGpu
+	} else {
+		cpus, err = strconv.Atoi(instanceInfo[2])
+	}
+	if err != nil {
+		return machineType{}, false
+	}
+
+	ratio, ok := memoryPerCpu[family+"-"+classType]
+	if !ok {
+		switch classType {
+		case "standard":
+			ratio = 4
+		case "highcpu":
+			ratio = 2
+		case "highmem":
+			ratio = 8
+		}
+	}
+
+	return machineType{Family: family, Cpus: float64(cpus), RamGb: float64(cpus) * ratio}, true
+}
+
+// getCustomMachinePrice returns the vCPU, GB of memory and GB of extended memory price of a
+// custom machine family. It reports false if the family isn't priced.
+func (service *PricingService) getCustomMachinePrice(family string, spot bool) (float64, float64, float64, bool) {
+	pricing := service.GCEPricing
+
+	if spot {
+		switch family {
+		case "n1":
+			return pricing.SpotN1CustomCpuPrice, pricing.SpotN1CustomMemoryPrice, pricing.SpotN1CustomExtendedMemoryPrice, true
+		case "n2":
+			return pricing.SpotN2CustomCpuPrice, pricing.SpotN2CustomMemoryPrice, pricing.SpotN2CustomExtendedMemoryPrice, true
+		case "n2d":
+			return pricing.SpotN2DCustomCpuPrice, pricing.SpotN2DCustomMemoryPrice, pricing.SpotN2DCustomExtendedMemoryPrice, true
+		}
+	}
 
-	switch classType {
-	case "standard":
-		ram = float64(cpus) * 4
-	case "highcpu":
-		ram = float64(cpus) * 2
-	case "highmem":
-		ram = float64(cpus) * 4
-	case "highgpu":
-		ram = float64(cpus) * 7.0833
-	case "ultragpu":
-		ram = float64(cpus) * 14.1666
+	switch family {
+	case "n1":
+		return pricing.N1CustomCpuPrice, pricing.N1CustomMemoryPrice, pricing.N1CustomExtendedMemoryPrice, true
+	case "n2":
+		return pricing.N2CustomCpuPrice, pricing.N2CustomMemoryPrice, pricing.N2CustomExtendedMemoryPrice, true
+	case "n2d":
+		return pricing.N2DCustomCpuPrice, pricing.N2DCustomMemoryPrice, pricing.N2DCustomExtendedMemoryPrice, true
+	case "e2":
+		// E2 custom machine types cost the same as the predefined ones
+		cpuPrice, memoryPrice, ok := service.getMachineFamilyPrice(family, spot)
+		return cpuPrice, memoryPrice, 0, ok
 	}
 
-	ram = math.Ceil(ram)
-	fmt.Printf("Parsing %s - %d %f %s %s", instanceType, cpus, ram, machineType, classType)
+	return 0, 0, 0, false
+}
+
+// getMachineFamilyPrice returns the vCPU and GB of memory price of a machine family.
+// It reports false if the family isn't priced.
+func (service *PricingService) getMachineFamilyPrice(machineType string, spot bool) (float64, float64, bool) {
+	pricing := service.GCEPricing
 
 	if spot {
 		switch machineType {
 		case "a2":
-			return service.GCEPricing.SpotA2CpuPrice*float64(cpus) + service.GCEPricing.SpotA2MemoryPrice*ram, nil
+			return pricing.SpotA2CpuPrice, pricing.SpotA2MemoryPrice, true
 		case "a3":
-			return service.GCEPricing.SpotA3CpuPrice*float64(cpus) + service.GCEPricing.SpotA3MemoryPrice*ram, nil
+			return pricing.SpotA3CpuPrice, pricing.SpotA3MemoryPrice, true
 		case "g2":
-			return service.GCEPricing.SpotG2DCpuPrice*float64(cpus) + service.GCEPricing.SpotG2DMemoryPrice*ram, nil
-		case "h3":
-			fmt.Printf("H3 Machine type is not available in Preemptible Spot format. Defaulting to a regular price.")
-			return service.GCEPricing.H3CpuPrice*float64(cpus) + service.GCEPricing.H3MemoryPrice*ram, nil
+			return pricing.SpotG2DCpuPrice, pricing.SpotG2DMemoryPrice, true
 		case "c2":
-			return service.GCEPricing.SpotC2CpuPrice*float64(cpus) + service.GCEPricing.SpotC2MemoryPrice*ram, nil
+			return pricing.SpotC2CpuPrice, pricing.SpotC2MemoryPrice, true
 		case "c2d":
-			return service.GCEPricing.SpotC2DCpuPrice*float64(cpus) + service.GCEPricing.SpotC2DMemoryPrice*ram, nil
-		default:
-			fmt.Printf("GCE Machine type %s is not implemented for price querying. Only supported ones are A2, A3, G2, H3, C2 and C2D", instanceType)
+			return pricing.SpotC2DCpuPrice, pricing.SpotC2DMemoryPrice, true
+		case "e2":
+			return pricing.SpotE2CpuPrice, pricing.SpotE2MemoryPrice, true
+		case "n1":
+			return pricing.SpotN1CpuPrice, pricing.SpotN1MemoryPrice, true
+		case "n2":
+			return pricing.SpotN2CpuPrice, pricing.SpotN2MemoryPrice, true
+		case "n2d":
+			return pricing.SpotN2DCpuPrice, pricing.SpotN2DMemoryPrice, true
+		case "n4":
+			return pricing.SpotN4CpuPrice, pricing.SpotN4MemoryPrice, true
+		case "c3":
+			return pricing.SpotC3CpuPrice, pricing.SpotC3MemoryPrice, true
+		case "c3d":
+			return pricing.SpotC3DCpuPrice, pricing.SpotC3DMemoryPrice, true
+		case "c4":
+			return pricing.SpotC4CpuPrice, pricing.SpotC4MemoryPrice, true
+		case "t2d":
+			return pricing.SpotT2DCpuPrice, pricing.SpotT2DMemoryPrice, true
+		case "t2a":
+			return pricing.SpotT2ACpuPrice, pricing.SpotT2AMemoryPrice, true
+		case "c4a":
+			return pricing.SpotC4ACpuPrice, pricing.SpotC4AMemoryPrice, true
+		case "h3", "m1", "m2", "m3":
+			service.warn(cluster.WarningPricingUnavailable, "%s Machine type is not available in Preemptible Spot format. Defaulting to a regular price.", strings.ToUpper(machineType))
 		}
-		return 0, nil
 	}
 
-	fmt.Printf("%#v", service.GCEPricing)
-
 	switch machineType {
 	case "a2":
-		return service.GCEPricing.A2CpuPrice*float64(cpus) + service.GCEPricing.A2MemoryPrice*ram, nil
+		return pricing.A2CpuPrice, pricing.A2MemoryPrice, true
 	case "a3":
-		return service.GCEPricing.A3CpuPrice*float64(cpus) + service.GCEPricing.A3MemoryPrice*ram, nil
+		return pricing.A3CpuPrice, pricing.A3MemoryPrice, true
 	case "g2":
-		return service.GCEPricing.G2CpuPrice*float64(cpus) + service.GCEPricing.G2MemoryPrice*ram, nil
+		return pricing.G2CpuPrice, pricing.G2MemoryPrice, true
 	case "h3":
-		return service.GCEPricing.H3CpuPrice*float64(cpus) + service.GCEPricing.H3MemoryPrice*ram, nil
+		return pricing.H3CpuPrice, pricing.H3MemoryPrice, true
 	case "c2":
-		return service.GCEPricing.C2CpuPrice*float64(cpus) + service.GCEPricing.C2MemoryPrice*ram, nil
+		return pricing.C2CpuPrice, pricing.C2MemoryPrice, true
 	case "c2d":
-		return service.GCEPricing.C2DCpuPrice*float64(cpus) + service.GCEPricing.C2DMemoryPrice*ram, nil
+		return pricing.C2DCpuPrice, pricing.C2DMemoryPrice, true
+	case "e2":
+		return pricing.E2CpuPrice, pricing.E2MemoryPrice, true
+	case "n1":
+		return pricing.N1CpuPrice, pricing.N1MemoryPrice, true
+	case "n2":
+		return pricing.N2CpuPrice, pricing.N2MemoryPrice, true
+	case "n2d":
+		return pricing.N2DCpuPrice, pricing.N2DMemoryPrice, true
+	case "n4":
+		return pricing.N4CpuPrice, pricing.N4MemoryPrice, true
+	case "c3":
+		return pricing.C3CpuPrice, pricing.C3MemoryPrice, true
+	case "c3d":
+		return pricing.C3DCpuPrice, pricing.C3DMemoryPrice, true
+	case "c4":
+		return pricing.C4CpuPrice, pricing.C4MemoryPrice, true
+	case "t2d":
+		return pricing.T2DCpuPrice, pricing.T2DMemoryPrice, true
+	case "t2a":
+		return pricing.T2ACpuPrice, pricing.T2AMemoryPrice, true
+	case "c4a":
+		return pricing.C4ACpuPrice, pricing.C4AMemoryPrice, true
+	case "m1", "m2":
+		// The upgrade premium of M2 machines is not included
+		return pricing.M1CpuPrice, pricing.M1MemoryPrice, true
+	case "m3":
+		return pricing.M3CpuPrice, pricing.M3MemoryPrice, true
+	}
+
+	return 0, 0, false
+}
+
+func (service *PricingService) GetGCEMachinePrice(instanceType string, spot bool) (float64, error) {
+	machine, ok := parseMachineType(instanceType)
+	if !ok {
+		service.warn(cluster.WarningUnsupportedMachineType, "GCE Machine type (%s) can't be parsed for price querying.", instanceType)
+		return 0, nil
+	}
+
+	var cpuPrice, memoryPrice, extendedMemoryPrice float64
+	if machine.Custom {
+		cpuPrice, memoryPrice, extendedMemoryPrice, ok = service.getCustomMachinePrice(machine.Family, spot)
+	} else {
+		cpuPrice, memoryPrice, ok = service.getMachineFamilyPrice(machine.Family, spot)
+	}
+	if !ok {
+		service.warn(cluster.WarningUnsupportedMachineType, "GCE Machine type %s is not implemented for price querying. Supported families are A2, A3, G2, H3, C2, C2D, C3, C3D, C4, C4A, E2, N1, N2, N2D, N4, T2A, T2D and M1-M3, custom machine types of E2, N1, N2 and N2D", instanceType)
+		return 0, nil
+	}
+
+	price := cpuPrice*machine.Cpus + memoryPrice*(machine.RamGb-machine.ExtendedRamGb) + extendedMemoryPrice*machine.ExtendedRamGb
+	if price == 0 {
+		service.warn(cluster.WarningPricingUnavailable, "GCE Machine type %s pricing is not available in %s region.", instanceType, service.GCEPricing.Region)
+	}
+
+	return price, nil
+}
+
+// GetSandboxOverhead returns the mCPU and MiB of memory added to a Pod that runs in GKE Sandbox, and if it does.
+// Autopilot bills the requests of sandboxed Pods like any others, so the overhead of the gVisor kernel is only
+// added when it is set in the [sandbox] section.
+func (service *PricingService) GetSandboxOverhead(name string, spec corev1.PodSpec) (int64, int64, bool) {
+	if spec.RuntimeClassName == nil || *spec.RuntimeClassName != "gvisor" {
+		return 0, 0, false
+	}
+
+	service.warn(cluster.WarningSandbox, "Workload (%s) runs in GKE Sandbox, sandboxed Pods have additional constraints in Autopilot.", name)
+
+	mcpuOverhead := service.Config.Section("sandbox").Key("mcpu_overhead").MustInt64(0)
+	memoryOverhead := service.Config.Section("sandbox").Key("memory_overhead").MustInt64(0)
+
+	return mcpuOverhead, memoryOverhead, true
+}
+
+// GetConfidentialPrice adds the Confidential Computing premium of a workload on Confidential GKE Nodes to its price.
+// Confidential GKE Nodes are only available for some compute classes in Autopilot, the others keep their price.
+func (service *PricingService) GetConfidentialPrice(name string, class cluster.ComputeClass, cpu int64, memory int64, spot bool, price PriceBreakdown) PriceBreakdown {
+	switch class {
+	case cluster.ComputeClassGeneralPurpose, cluster.ComputeClassBalanced:
+		premium := service.GetConfidentialPremium(cpu, memory, spot)
+		price.Cpu += premium.Cpu
+		price.Memory += premium.Memory
+		service.warn(cluster.WarningConfidentialNodes, "Workload (%s) runs on Confidential GKE Nodes, the Autopilot cluster needs Confidential GKE Nodes enabled.", name)
 	default:
-		fmt.Printf("GCE Machine type %s is not implemented for price querying. Only supported ones are A2, A3, G2, H3, C2 and C2D", instanceType)
+		service.warn(cluster.WarningConfidentialNodes, "Workload (%s) runs on Confidential GKE Nodes, which are not supported for %s compute class in Autopilot.", name, cluster.ComputeClasses[class])
+	}
+
+	return price
+}
+
+// GetConfidentialPremium returns the Confidential Computing premium for the given resources,
+// which is charged per vCPU and GB of memory on top of the regular price.
+func (service *PricingService) GetConfidentialPremium(cpu int64, memory int64, spot bool) PriceBreakdown {
+	if spot {
+		return PriceBreakdown{
+			Cpu:    service.GCEPricing.SpotConfidentialCpuPrice * float64(cpu) / 1000,
+			Memory: service.GCEPricing.SpotConfidentialMemoryPrice * float64(memory) / 1000,
+		}
 	}
 
-	return 0, nil
+	return PriceBreakdown{
+		Cpu:    service.GCEPricing.ConfidentialCpuPrice * float64(cpu) / 1000,
+		Memory: service.GCEPricing.ConfidentialMemoryPrice * float64(memory) / 1000,
+	}
 }
 
-func (service *PricingService) PopulateWorkloads(nodes map[string]cluster.Node) ([]cluster.Workload, error) {
+// SizeResource returns the billed amount of a resource of a container from its usage and request.
+func (service *PricingService) SizeResource(usage int64, request int64) int64 {
+	switch service.SizingMode {
+	case SIZING_REQUESTS:
+		return request
+	case SIZING_USAGE:
+		return usage
+	}
+
+	// Autopilot bills the requests, so usage below the requests costs as much as the requests
+	if usage < request {
+		return request
+	}
+	return usage
+}
+
+// listPodMetrics lists the metrics of the pods page by page and maps them by cluster.PodKey.
+func (service *PricingService) listPodMetrics(ctx context.Context) (map[string]metricsv1beta1.PodMetrics, error) {
+	podMetrics := make(map[string]metricsv1beta1.PodMetrics)
+
+	options := metav1.ListOptions{FieldSelector: service.Namespaces.FieldSelector(), LabelSelector: service.LabelSelector, Limit: cluster.POD_PAGE_SIZE}
+	for {
+		page, err := service.metricsClientset.MetricsV1beta1().PodMetricses(service.Namespaces.ListNamespace()).List(ctx, options)
+		if err != nil {
+			return nil, fmt.Errorf("error getting pod metrics: %v", err)
+		}
+
+		for _, metrics := range page.Items {
+			podMetrics[cluster.PodKey(metrics.Namespace, metrics.Name)] = metrics
+		}
+
+		if page.Continue == "" {
+			return podMetrics, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+func (service *PricingService) PopulateWorkloads(ctx context.Context, nodes map[string]cluster.Node) ([]cluster.Workload, error) {
 	var workloads []cluster.Workload
+	service.SystemWorkloads = nil
 
-	podMetricsList, err := service.metricsClientset.MetricsV1beta1().PodMetricses("").List(context.TODO(), metav1.ListOptions{FieldSelector: "metadata.namespace!=kube-system,metadata.namespace!=gke-gmp-system,metadata.namespace!=gmp-system"})
+	podMetrics, err := service.listPodMetrics(ctx)
 	if err != nil {
-		log.Fatalf(err.Error())
+		return nil, err
 	}
 
-	for _, v := range podMetricsList.Items {
-		pod, err := cluster.DescribePod(service.clientset, v.Name, v.Namespace)
-		if err != nil {
-			return nil, err
+	volumes, err := cluster.GetVolumes(ctx, service.clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pods are priced page by page, so only the metrics and the workloads are held for the whole cluster
+	err = cluster.EachPod(ctx, service.clientset, service.Namespaces.ListNamespace(), metav1.ListOptions{FieldSelector: service.Namespaces.FieldSelector(), LabelSelector: service.LabelSelector}, func(pod *corev1.Pod) error {
+		if !service.Namespaces.Matches(pod.Namespace) {
+			return nil
+		}
+
+		// Pods without metrics have not been scraped yet, eg. they just started
+		v, ok := podMetrics[cluster.PodKey(pod.Namespace, pod.Name)]
+		if !ok {
+			logging.Debug("Pod %s/%s has no metrics yet, skipping", pod.Namespace, pod.Name)
+			return nil
 		}
 
-		var cpu int64 = 0
-		var memory int64 = 0
-		var storage int64 = 0
-		var gpu int64 = 0
-		podContainerCount := 0
+		// Metrics can still be reported for pods that finished, those would be priced as if running forever
+		completed := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+		if completed && !service.IncludeCompletedPods {
+			return nil
+		}
+
+		resources := podResources{
+			name:        v.Name,
+			namespace:   v.Namespace,
+			owner:       cluster.GetPodOwner(pod),
+			labels:      pod.Labels,
+			annotations: pod.Annotations,
+			spec:        pod.Spec,
+			node:        nodes[pod.Spec.NodeName],
+			disks:       volumes.PodDisks(pod),
+		}
+		var missingHistory []string
 
-		gpuModel := pod.Spec.NodeSelector["cloud.google.com/gke-accelerator"]
+		// Native sidecars are init containers that keep running, they are reported with the containers
+		specContainers := append(append([]corev1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...)
 
 		// Sum used resources from the Pod
 		for _, container := range v.Containers {
-
 			cpuUsage := container.Usage.Cpu().MilliValue()
 			memoryUsage := container.Usage.Memory().MilliValue() / 1000000000            // Division to get MiB
 			storageUsage := container.Usage.StorageEphemeral().MilliValue() / 1000000000 // Division to get MiB
 			gpuUsage := int64(0)
+			tpuUsage := int64(0)
+
+			if service.UsageHistory != nil {
+				if history, ok := service.UsageHistory[cluster.UsageKey(v.Namespace, v.Name, container.Name)]; ok {
+					cpuUsage = history.Cpu
+					memoryUsage = history.Memory
+				} else {
+					missingHistory = append(missingHistory, container.Name)
+				}
+			}
 
-			for _, specContainer := range pod.Spec.Containers {
+			for _, specContainer := range specContainers {
 				if container.Name == specContainer.Name {
-					cpuRequest := specContainer.Resources.Requests[corev1.ResourceCPU]
-					memoryRequest := specContainer.Resources.Requests[corev1.ResourceMemory]
-					storageRequest := specContainer.Resources.Requests[corev1.ResourceStorage]
-					gpuRequests := specContainer.Resources.Requests["nvidia.com/gpu"]
-
-					// Usage is less than requests, so we set request as usage since the billing works like that
-					if cpuUsage < cpuRequest.MilliValue() {
-						cpuUsage = cpuRequest.MilliValue()
+					cpuRequest, memoryRequest, storageRequest, gpuRequest, tpuRequest := getContainerRequests(specContainer)
+
+					containerBurstable := cluster.IsBurstable(specContainer)
+					resources.burstable = resources.burstable || containerBurstable
+
+					if containerBurstable && service.Bursting && service.SizingMode != SIZING_USAGE {
+						// Autopilot bills the requests, bursting above them is free
+						cpuUsage = cpuRequest
+						memoryUsage = memoryRequest
+					} else {
+						cpuUsage = service.SizeResource(cpuUsage, cpuRequest)
+						memoryUsage = service.SizeResource(memoryUsage, memoryRequest)
 					}
+					storageUsage = service.SizeResource(storageUsage, storageRequest)
 
-					if memoryUsage < memoryRequest.MilliValue()/1000000000 {
-						memoryUsage = memoryRequest.MilliValue() / 1000000000
-					}
-
-					if storageUsage < storageRequest.MilliValue()/1000000000 {
-						storageUsage = memoryRequest.MilliValue() / 1000000000
-					}
+					gpuUsage = gpuRequest
+					tpuUsage = tpuRequest
+				}
+			}
 
-					gpuUsage = gpuRequests.Value()
+			if service.IsSidecar(container.Name) {
+				resources.sidecarCpu += cpuUsage
+				resources.sidecarMemory += memoryUsage
+				resources.sidecarStorage += storageUsage
+				if service.SidecarMode == SIDECARS_EXCLUDE {
+					continue
 				}
 			}
 
-			cpu += cpuUsage
-			memory += memoryUsage
-			storage += storageUsage
-			gpu += gpuUsage
-			podContainerCount++
-		}
-
-		// Check and modify the limits of summed workloads from the Pod
-		cpu, memory, storage = ValidateAndRoundResources(cpu, memory, storage)
-
-		computeClass := service.DecideComputeClass(
-			v.Name,
-			nodes[pod.Spec.NodeName].InstanceType,
-			cpu,
-			memory,
-			gpu,
-			gpuModel,
-			strings.Contains(nodes[pod.Spec.NodeName].InstanceType, service.Config.Section("").Key("gce_arm64_prefix").String()),
-		)
-
-		cost := service.CalculatePricing(cpu, memory, storage, gpu, gpuModel, computeClass, nodes[pod.Spec.NodeName].InstanceType, nodes[pod.Spec.NodeName].Spot)
-
-		workloadObject := cluster.Workload{
-			Name:              v.Name,
-			Containers:        podContainerCount,
-			Node_name:         pod.Spec.NodeName,
-			Cpu:               cpu,
-			Memory:            memory,
-			Storage:           storage,
-			AcceleratorType:   gpuModel,
-			AcceleratorAmount: gpu,
-			Cost:              cost,
-			ComputeClass:      computeClass,
+			resources.cpu += cpuUsage
+			resources.memory += memoryUsage
+			resources.storage += storageUsage
+			resources.gpu += gpuUsage
+			resources.tpu += tpuUsage
+			resources.containers++
+		}
+
+		service.warnings = nil
+
+		if len(missingHistory) > 0 {
+			service.warn(cluster.WarningNoUsageHistory, "Workload (%s) has no usage history for %s, the current usage is used.", v.Name, strings.Join(missingHistory, ", "))
+		}
+
+		if completed {
+			service.warn(cluster.WarningCompletedPod, "Workload (%s) is not running anymore (%s %s).", v.Name, pod.Status.Phase, pod.Status.Reason)
+		}
+
+		workloadObject := service.priceWorkload(resources)
+
+		if cluster.IsSystemNamespace(v.Namespace) {
+			service.SystemWorkloads = append(service.SystemWorkloads, workloadObject)
+			return nil
 		}
 
 		workloads = append(workloads, workloadObject)
 
 		if entry, ok := nodes[pod.Spec.NodeName]; ok {
 			entry.Workloads = append(entry.Workloads, workloadObject)
-			entry.Cost += cost
+			entry.Cost += workloadObject.Cost
 			nodes[pod.Spec.NodeName] = entry
 		}
 
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the output stable between runs, pods are listed in no particular order
+	cluster.SortWorkloads(workloads)
+	cluster.SortWorkloads(service.SystemWorkloads)
+	for name, node := range nodes {
+		cluster.SortWorkloads(node.Workloads)
+		nodes[name] = node
 	}
 
 	return workloads, nil
 
 }
 
-func (service *PricingService) DecideComputeClass(workloadName string, machineType string, mCPU int64, memory int64, gpu int64, gpuModel string, arm64 bool) cluster.ComputeClass {
-	ratio := math.Ceil(float64(memory) / float64(mCPU))
-
-	ratioRegularMin, _ := service.Config.Section("ratios").Key("generalpurpose_min").Float64()
-	ratioRegularMax, _ := service.Config.Section("ratios").Key("generalpurpose_max").Float64()
-	ratioBalancedMin, _ := service.Config.Section("ratios").Key("balanced_min").Float64()
-	ratioBalancedMax, _ := service.Config.Section("ratios").Key("balanced_max").Float64()
-	ratioScaleoutMin, _ := service.Config.Section("ratios").Key("scaleout_min").Float64()
-	ratioScaleoutMax, _ := service.Config.Section("ratios").Key("scaleout_max").Float64()
-	ratioPerformanceMin, _ := service.Config.Section("ratios").Key("performance_min").Float64()
-	ratioPerformanceMax, _ := service.Config.Section("ratios").Key("performance_max").Float64()
-
-	scaleoutMcpuMax, _ := service.Config.Section("limits").Key("scaleout_mcpu_max").Int64()
-	scaleoutMemoryMax, _ := service.Config.Section("limits").Key("scaleout_memory_max").Int64()
-	scaleoutArmMcpuMax, _ := service.Config.Section("limits").Key("scaleout_arm_mcpu_max").Int64()
-	scaleoutArmMemoryMax, _ := service.Config.Section("limits").Key("scaleout_arm_memory_max").Int64()
-	regularMcpuMax, _ := service.Config.Section("limits").Key("generalpurpose_mcpu_max").Int64()
-	regularMemoryMax, _ := service.Config.Section("limits").Key("generalpurpose_memory_max").Int64()
-	balancedMcpuMax, _ := service.Config.Section("limits").Key("balanced_mcpu_max").Int64()
-	balancedMemoryMax, _ := service.Config.Section("limits").Key("balanced_mcpu_max").Int64()
-	performanceMcpuMax, _ := service.Config.Section("limits").Key("performance_mcpu_max").Int64()
-	performanceMemoryMax, _ := service.Config.Section("limits").Key("performance_memory_max").Int64()
-
-	gpupodT4McpuMin, _ := service.Config.Section("limits").Key("gpupod_t4_mcpu_min").Int64()
-	gpupodT4McpuMax, _ := service.Config.Section("limits").Key("gpupod_t4_mcpu_max").Int64()
-	gpupodT4MemoryMin, _ := service.Config.Section("limits").Key("gpupod_t4_memory_min").Int64()
-	gpupodT4MemoryMax, _ := service.Config.Section("limits").Key("gpupod_t4_memory_max").Int64()
-
-	gpupodL4McpuMin, _ := service.Config.Section("limits").Key("gpupod_l4_mcpu_min").Int64()
-	gpupodL4McpuMax, _ := service.Config.Section("limits").Key("gpupod_l4_mcpu_max").Int64()
-	gpupodL4MemoryMin, _ := service.Config.Section("limits").Key("gpupod_l4_memory_min").Int64()
-	gpupodL4MemoryMax, _ := service.Config.Section("limits").Key("gpupod_l4_memory_max").Int64()
-
-	gpupodA10040McpuMin, _ := service.Config.Section("limits").Key("gpupod_a100_40_mcpu_min").Int64()
-	gpupodA10040McpuMax, _ := service.Config.Section("limits").Key("gpupod_a100_40_mcpu_max").Int64()
-	gpupodA10040MemoryMin, _ := service.Config.Section("limits").Key("gpupod_a100_40_memory_min").Int64()
-	gpupodA10040MemoryMax, _ := service.Config.Section("limits").Key("gpupod_a100_40_memory_max").Int64()
-
-	gpupodA10080McpuMin, _ := service.Config.Section("limits").Key("gpupod_a100_80_mcpu_min").Int64()
-	gpupodA10080McpuMax, _ := service.Config.Section("limits").Key("gpupod_a100_80_mcpu_max").Int64()
-	gpupodA10080MemoryMin, _ := service.Config.Section("limits").Key("gpupod_a100_80_memory_min").Int64()
-	gpupodA10080MemoryMax, _ := service.Config.Section("limits").Key("gpupod_a100_80_memory_max").Int64()
-
-	accelerator_mcpu_min, _ := service.Config.Section("limits").Key("accelerator_mcpu_min").Int64()
-	accelerator_memory_min, _ := service.Config.Section("limits").Key("accelerator_memory_min").Int64()
-	accelerator_h100_80_mcpu_max, _ := service.Config.Section("limits").Key("accelerator_h100_80_mcpu_max").Int64()
-	accelerator_h100_80_memory_max, _ := service.Config.Section("limits").Key("accelerator_h100_80_memory_max").Int64()
+// AmortizeClusterFee spreads the hourly cluster fee across all workloads
+// proportionally to their cost and stores the result as their effective cost.
+func AmortizeClusterFee(nodes map[string]cluster.Node, clusterFee cluster.Money) {
+	var totalCost cluster.Money
+	for _, node := range nodes {
+		for _, workload := range node.Workloads {
+			totalCost += workload.Cost
+		}
+	}
+
+	if totalCost == 0 {
+		return
+	}
+
+	// Walk the workloads in a stable order and give the rounding remainder to
+	// the last one, so the effective costs add up to the total plus the fee.
+	// Workloads slices share their backing arrays with the nodes map.
+	remainingFee := clusterFee
+	var last *cluster.Workload
+	for _, node := range cluster.SortedNodes(nodes) {
+		for i := range node.Workloads {
+			share := clusterFee.Mul(node.Workloads[i].Cost.Float64() / totalCost.Float64())
+			remainingFee -= share
+
+			node.Workloads[i].EffectiveCost = node.Workloads[i].Cost + share
+			last = &node.Workloads[i]
+		}
+	}
+
+	last.EffectiveCost += remainingFee
+}
+
+// IsArm64MachineType checks the machine type against the comma separated gce_arm64_prefix list, eg. t2a- and c4a-.
+func (service *PricingService) IsArm64MachineType(machineType string) bool {
+	for _, prefix := range strings.Split(service.Config.Section("").Key("gce_arm64_prefix").String(), ",") {
+		if prefix != "" && strings.HasPrefix(machineType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
 
+// GetMachineFamilyComputeClass maps a machine family a workload is pinned to onto the compute class
+// configured for it in the [machine_families] section. It reports false if the family is not configured.
+func (service *PricingService) GetMachineFamilyComputeClass(workloadName string, family string) (cluster.ComputeClass, bool) {
+	if family == "" {
+		return cluster.ComputeClassGeneralPurpose, false
+	}
+
+	if class, ok := service.machineFamilyClass(family); ok {
+		return class, true
+	}
+
+	service.warn(cluster.WarningNoComputeClass, "Workload (%s) is pinned to %s machine family, which has no compute class configured. Deciding by resources.", workloadName, family)
+
+	return cluster.ComputeClassGeneralPurpose, false
+}
+
+// machineFamilyClass looks the compute class of a machine family up in the [machine_families] section.
+func (service *PricingService) machineFamilyClass(family string) (cluster.ComputeClass, bool) {
+	return getComputeClassByKey(service.Config.Section("machine_families").Key(family).String())
+}
+
+// DecideComputeClass maps a workload to the compute class it would run in by its node and requests. Compute and
+// accelerator optimized machines, GPUs and Arm have their class, the requests are only checked against its maximums,
+// as AdjustResources raises them to the minimums and ratio. Other workloads get the first class of the [classes] order
+// whose limits allow their requests, or else the first class that fits them once their ratio is corrected.
+func (service *PricingService) DecideComputeClass(workloadName string, machineType string, mCPU int64, memory int64, gpu int64, gpuModel string, arm64 bool) cluster.ComputeClass {
 	computeOptimizedMachineTypes := strings.Split(service.Config.Section("").Key("gce_compute_optimized_prefixed").String(), ",")
 	for _, computeOptimizedMachineType := range computeOptimizedMachineTypes {
 		if strings.Contains(machineType, computeOptimizedMachineType) {
@@ -420,8 +981,8 @@ func (service *PricingService) DecideComputeClass(workloadName string, machineTy
 
 	// check if GPU is H100, then return ComputeClassAccelerator since it's the only one supporting these GPUs
 	if gpuModel == service.Config.Section("").Key("nvidia_h100_identifier").String() {
-		if ratio < ratioPerformanceMin || ratio > ratioPerformanceMax || mCPU > performanceMcpuMax || memory > performanceMemoryMax {
-			log.Printf("Requested memory or CPU out of acceptable range for Performance compute class (%s) workload (%s).\n", machineType, workloadName)
+		if service.getClassLimits(cluster.ComputeClassPerformance, "").exceeds(mCPU, memory) {
+			service.warn(cluster.WarningOutOfRange, "Requested memory or CPU out of acceptable range for Performance compute class (%s) workload (%s).", machineType, workloadName)
 		}
 
 		return cluster.ComputeClassPerformance
@@ -430,93 +991,66 @@ func (service *PricingService) DecideComputeClass(workloadName string, machineTy
 	acceleratorOptimizedMachineTypes := strings.Split(service.Config.Section("").Key("gce_accelerator_optimized_prefixed").String(), ",")
 	for _, acceleratorOptimizedMachineType := range acceleratorOptimizedMachineTypes {
 		if strings.Contains(machineType, acceleratorOptimizedMachineType) {
-			switch gpuModel {
-			case "nvidia-tesla-t4":
-				if mCPU > gpupodT4McpuMax || mCPU < accelerator_mcpu_min || memory > gpupodT4MemoryMax || memory < accelerator_memory_min {
-					log.Printf("Requested memory or CPU out of acceptable range for %s Accelerator compute class (%s) workload (%s).\n", machineType, gpuModel, workloadName)
-				}
-			case "nvidia-l4":
-				if mCPU > gpupodL4McpuMax || mCPU < accelerator_mcpu_min || memory > gpupodL4MemoryMax || memory < accelerator_memory_min {
-					log.Printf("Requested memory or CPU out of acceptable range for %s Accelerator compute class (%s) workload (%s).\n", machineType, gpuModel, workloadName)
-				}
-			case "nvidia-tesla-a100":
-				if mCPU > gpupodA10040McpuMax || mCPU < accelerator_mcpu_min || memory > gpupodA10040MemoryMax || memory < accelerator_memory_min {
-					log.Printf("Requested memory or CPU out of acceptable range for %s Accelerator compute class (%s) workload (%s).\n", machineType, gpuModel, workloadName)
-				}
-			case "nvidia-a100-80gb":
-				if mCPU > gpupodA10080McpuMax || mCPU < accelerator_mcpu_min || memory > gpupodA10080MemoryMax || memory < accelerator_memory_min {
-					log.Printf("Requested memory or CPU out of acceptable range for %s Accelerator compute class (%s) workload (%s).\n", machineType, gpuModel, workloadName)
-				}
-			case "nvidia-h100-80gb":
-				if mCPU > accelerator_h100_80_mcpu_max || mCPU < accelerator_mcpu_min || memory > accelerator_h100_80_memory_max || memory < accelerator_memory_min {
-					log.Printf("Requested memory or CPU out of acceptable range for %s Accelerator compute class (%s) workload (%s).\n", machineType, gpuModel, workloadName)
-				}
+			if _, ok := gpuLimitKeys[gpuModel]; ok && service.getClassLimits(cluster.ComputeClassAccelerator, gpuModel).exceeds(mCPU, memory) {
+				service.warn(cluster.WarningOutOfRange, "Requested memory or CPU out of acceptable range for %s Accelerator compute class (%s) workload (%s).", machineType, gpuModel, workloadName)
 			}
 
 			return cluster.ComputeClassAccelerator
 		}
 	}
 
+	// Newer GPUs have no GPU Pod pricing and run in the Accelerator compute class only
+	if gpu > 0 && IsAcceleratorOnlyGPU(gpuModel) {
+		return cluster.ComputeClassAccelerator
+	}
+
 	// Ok, not an accelerator based workload nor is H100, so we can get a regular GPU Pod type
 	if gpu > 0 {
-		switch gpuModel {
-		case "nvidia-tesla-t4":
-			if mCPU > gpupodT4McpuMax || mCPU < gpupodT4McpuMin || memory > gpupodT4MemoryMax || memory < gpupodT4MemoryMin {
-				log.Printf("Requested memory or CPU out of acceptable range for %s GPU workload (%s).\n", gpuModel, workloadName)
-			}
-		case "nvidia-l4":
-			if mCPU > gpupodL4McpuMax || mCPU < gpupodL4McpuMin || memory > gpupodL4MemoryMax || memory < gpupodL4MemoryMin {
-				log.Printf("Requested memory or CPU out of acceptable range for %s GPU workload (%s).\n", gpuModel, workloadName)
-			}
-		case "nvidia-tesla-a100":
-			if mCPU > gpupodA10040McpuMax || mCPU < gpupodA10040McpuMin || memory > gpupodA10040MemoryMax || memory < gpupodA10040MemoryMin {
-				log.Printf("Requested memory or CPU out of acceptable range for %s GPU workload (%s).\n", gpuModel, workloadName)
-			}
-		case "nvidia-a100-80gb":
-			if mCPU > gpupodA10080McpuMax || mCPU < gpupodA10080McpuMin || memory > gpupodA10080MemoryMax || memory < gpupodA10080MemoryMin {
-				log.Printf("Requested memory or CPU out of acceptable range for %s GPU workload (%s).\n", gpuModel, workloadName)
-			}
+		if _, ok := gpuLimitKeys[gpuModel]; ok && service.getClassLimits(cluster.ComputeClassGPUPod, gpuModel).exceeds(mCPU, memory) {
+			service.warn(cluster.WarningOutOfRange, "Requested memory or CPU out of acceptable range for %s GPU workload (%s).", gpuModel, workloadName)
 		}
 		return cluster.ComputeClassGPUPod
 	}
 
 	// ARM64 is still experimental
 	if arm64 {
-		if ratio < ratioScaleoutMin || ratio > ratioScaleoutMax || mCPU > scaleoutArmMcpuMax || memory > scaleoutArmMemoryMax {
-			log.Printf("Requesting arm64 but requested mCPU () or memory or ratio are out of accepted range(%s).\n", workloadName)
+		if service.getClassLimits(cluster.ComputeClassScaleoutArm, "").exceeds(mCPU, memory) {
+			service.warn(cluster.WarningOutOfRange, "Requesting arm64 but requested mCPU or memory are out of accepted range (%s).", workloadName)
 		}
 
 		return cluster.ComputeClassScaleoutArm
 	}
 
-	// For T2a machines, default to scale-out compute class, since it's the only one supporting it
-	if ratio >= ratioRegularMin && ratio <= ratioRegularMax && mCPU <= regularMcpuMax && memory <= regularMemoryMax {
-		return cluster.ComputeClassGeneralPurpose
-	}
-
-	// If we are out of Regular range, suggest Scale-Out
-	if ratio >= ratioScaleoutMin && ratio <= ratioScaleoutMax && mCPU <= scaleoutMcpuMax && memory <= scaleoutMemoryMax {
-		return cluster.ComputeClassScaleout
+	for _, class := range service.getClassOrder() {
+		if service.getClassLimits(class, "").allows(mCPU, memory) {
+			return class
+		}
 	}
 
-	// If usage is more than general-purpose limits, default to balanced
-	if ratio >= ratioBalancedMin && ratio <= ratioBalancedMax && mCPU <= balancedMcpuMax && memory <= balancedMemoryMax {
-		return cluster.ComputeClassBalanced
+	// Autopilot adds memory or vCPU to a ratio outside the range of the class instead of rejecting the Pod,
+	// so the first class the corrected requests aren't too large for is picked
+	for _, class := range service.getClassOrder() {
+		adjustedMcpu, adjustedMemory := service.AdjustResources(class, "", mCPU, memory)
+		if !service.getClassLimits(class, "").exceeds(adjustedMcpu, adjustedMemory) {
+			logging.Debug("Workload (%s) fits %s once its memory to vCPU ratio is corrected to %d mCPU and %d MiB.", workloadName, cluster.ComputeClasses[class], adjustedMcpu, adjustedMemory)
+			return class
+		}
 	}
 
-	log.Printf("Couldn't find a matching compute class for %s. Defaulting to 'General-purpose'. Please check the pricing manually.\n", workloadName)
+	service.warn(cluster.WarningNoComputeClass, "Couldn't find a matching compute class for %s. Defaulting to 'General-purpose'. Please check the pricing manually.", workloadName)
 
 	return cluster.ComputeClassGeneralPurpose
 }
 
-// TODO: implement ini file minimums
-func ValidateAndRoundResources(mCPU int64, memory int64, storage int64) (int64, int64, int64) {
+// ApplyMinimumResources raises the requests to the lowest minimums of any compute class, before the class
+// is decided. The minimums and the mCPU step of the class are applied by AdjustResources once it is.
+func ApplyMinimumResources(mCPU int64, memory int64, storage int64) (int64, int64, int64) {
 	// Lowest possible mCPU request, but this is different for DaemonSets that are not yet implemented
 	if mCPU < 50 {
 		mCPU = 50
 	}
 
-	// Minumum memory request, however it's 1G for Scaleout, we don't yet account for this
+	// Minimum memory request of General-purpose, the other classes have higher minimums
 	if memory < 52 {
 		memory = 52
 	}
@@ -525,14 +1059,5 @@ func ValidateAndRoundResources(mCPU int64, memory int64, storage int64) (int64,
 		storage = 10
 	}
 
-	mCPUMissing := (50 - (mCPU % 50))
-	if mCPUMissing == 50 {
-		// Nothing to do here, return original values
-		return mCPU, memory, storage
-	}
-
-	// Add missing value to reach nearst 250mCPU step
-	mCPU += mCPUMissing
-
 	return mCPU, memory, storage
 }
@@ -0,0 +1,91 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"google.golang.org/api/cloudbilling/v1"
+)
+
+// CATALOG_TTL is how long the SKUs of a service are reused, so a long running `serve` picks up price changes.
+const CATALOG_TTL = 24 * time.Hour
+
+type catalogKey struct {
+	service  string
+	currency string
+}
+
+// catalogEntry holds the SKUs of a service in a currency. Its mutex makes concurrent estimates of clusters
+// wait for a single download.
+type catalogEntry struct {
+	mutex   sync.Mutex
+	skus    []*cloudbilling.Sku
+	fetched time.Time
+}
+
+var (
+	catalogsMutex sync.Mutex
+	catalogs      = make(map[catalogKey]*catalogEntry)
+)
+
+func getCatalogEntry(key catalogKey) *catalogEntry {
+	catalogsMutex.Lock()
+	defer catalogsMutex.Unlock()
+
+	if catalogs[key] == nil {
+		catalogs[key] = &catalogEntry{}
+	}
+	return catalogs[key]
+}
+
+// FetchSkus returns the SKUs of the billing service in the currency. The catalog lists the SKUs of all
+// regions, so it is paged through once and every cluster and region of the run is priced from the same list.
+func FetchSkus(ctx context.Context, service string, currency string) ([]*cloudbilling.Sku, error) {
+	entry := getCatalogEntry(catalogKey{service: service, currency: currency})
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if entry.skus != nil && time.Since(entry.fetched) < CATALOG_TTL {
+		logging.Debug("Reusing the %d SKUs of service %s", len(entry.skus), service)
+		return entry.skus, nil
+	}
+
+	cloudbillingService, err := newBillingService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var skus []*cloudbilling.Sku
+	err = cloudbillingService.Services.Skus.List("services/"+service).CurrencyCode(currency).Pages(ctx, func(response *cloudbilling.ListSkusResponse) error {
+		usage.Count(usage.Billing)
+		skus = append(skus, response.Skus...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the SKUs of billing service %s: %v", service, err)
+	}
+
+	entry.skus = skus
+	entry.fetched = time.Now()
+
+	return skus, nil
+}
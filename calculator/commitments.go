@@ -0,0 +1,236 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/location"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	compute "google.golang.org/api/compute/v1"
+)
+
+// ExistingCapacity is Compute Engine capacity the project already pays for,
+// regardless of what runs on the nodes of the cluster.
+type ExistingCapacity struct {
+	// Active resource-based commitments in the region
+	Commitments       []string
+	CommittedCpus     int64
+	CommittedMemoryGb float64
+	// Committed is the committed capacity by the machine family it covers, eg. n2
+	Committed map[string]CommittedCapacity
+	// CommitmentCost keeps being billed until the commitments end, whether the cluster migrates or not
+	CommitmentCost cluster.Money
+
+	// Reserved VMs that are not in use, by machine type
+	UnusedReservations map[string]int64
+	// ReservationCost is what the unused reserved VMs add to the current bill
+	ReservationCost cluster.Money
+}
+
+// CommittedCapacity is the vCPUs and GB of memory committed for a machine family.
+type CommittedCapacity struct {
+	Cpus     float64
+	MemoryGb float64
+}
+
+// commitmentTypeFamilies are the machine families of the commitment types without the family in their name,
+// the others end with it, eg. GENERAL_PURPOSE_N2 or COMPUTE_OPTIMIZED_C2D.
+var commitmentTypeFamilies = map[string]string{
+	"GENERAL_PURPOSE":       "n1",
+	"COMPUTE_OPTIMIZED":     "c2",
+	"MEMORY_OPTIMIZED":      "m1",
+	"ACCELERATOR_OPTIMIZED": "a2",
+	"GRAPHICS_OPTIMIZED":    "g2",
+}
+
+// getCommitmentFamily returns the machine family a commitment type covers, eg. n2 for GENERAL_PURPOSE_N2.
+func getCommitmentFamily(commitmentType string) string {
+	if family, ok := commitmentTypeFamilies[commitmentType]; ok {
+		return family
+	}
+	parts := strings.Split(commitmentType, "_")
+	return strings.ToLower(parts[len(parts)-1])
+}
+
+// getFamilyCommitmentPrices returns the price of a vCPU and of a GB of memory committed for a machine family
+// for one or three years, false if the family has no commitment price.
+func (service *PricingService) getFamilyCommitmentPrices(family string, threeYears bool) (float64, float64, bool) {
+	pricing := service.GCEPricing
+	switch family {
+	case "n1":
+		if threeYears {
+			return pricing.CommitmentThreeYearCpuPrice, pricing.CommitmentThreeYearMemoryPrice, true
+		}
+		return pricing.CommitmentOneYearCpuPrice, pricing.CommitmentOneYearMemoryPrice, true
+	case "n2":
+		if threeYears {
+			return pricing.CommitmentThreeYearN2CpuPrice, pricing.CommitmentThreeYearN2MemoryPrice, true
+		}
+		return pricing.CommitmentOneYearN2CpuPrice, pricing.CommitmentOneYearN2MemoryPrice, true
+	case "n2d":
+		if threeYears {
+			return pricing.CommitmentThreeYearN2DCpuPrice, pricing.CommitmentThreeYearN2DMemoryPrice, true
+		}
+		return pricing.CommitmentOneYearN2DCpuPrice, pricing.CommitmentOneYearN2DMemoryPrice, true
+	case "e2":
+		if threeYears {
+			return pricing.CommitmentThreeYearE2CpuPrice, pricing.CommitmentThreeYearE2MemoryPrice, true
+		}
+		return pricing.CommitmentOneYearE2CpuPrice, pricing.CommitmentOneYearE2MemoryPrice, true
+	case "c2":
+		if threeYears {
+			return pricing.CommitmentThreeYearC2CpuPrice, pricing.CommitmentThreeYearC2MemoryPrice, true
+		}
+		return pricing.CommitmentOneYearC2CpuPrice, pricing.CommitmentOneYearC2MemoryPrice, true
+	case "c2d":
+		if threeYears {
+			return pricing.CommitmentThreeYearC2DCpuPrice, pricing.CommitmentThreeYearC2DMemoryPrice, true
+		}
+		return pricing.CommitmentOneYearC2DCpuPrice, pricing.CommitmentOneYearC2DMemoryPrice, true
+	case "c3":
+		if threeYears {
+			return pricing.CommitmentThreeYearC3CpuPrice, pricing.CommitmentThreeYearC3MemoryPrice, true
+		}
+		return pricing.CommitmentOneYearC3CpuPrice, pricing.CommitmentOneYearC3MemoryPrice, true
+	case "c3d":
+		if threeYears {
+			return pricing.CommitmentThreeYearC3DCpuPrice, pricing.CommitmentThreeYearC3DMemoryPrice, true
+		}
+		return pricing.CommitmentOneYearC3DCpuPrice, pricing.CommitmentOneYearC3DMemoryPrice, true
+	case "t2d":
+		if threeYears {
+			return pricing.CommitmentThreeYearT2DCpuPrice, pricing.CommitmentThreeYearT2DMemoryPrice, true
+		}
+		return pricing.CommitmentOneYearT2DCpuPrice, pricing.CommitmentOneYearT2DMemoryPrice, true
+	case "n4":
+		if threeYears {
+			return pricing.CommitmentThreeYearN4CpuPrice, pricing.CommitmentThreeYearN4MemoryPrice, true
+		}
+		return pricing.CommitmentOneYearN4CpuPrice, pricing.CommitmentOneYearN4MemoryPrice, true
+	}
+	return 0, 0, false
+}
+
+// GetExistingCapacity lists the active commitments and the specific reservations of the project in the region
+// and prices the capacity that is billed on top of the cluster nodes.
+func (service *PricingService) GetExistingCapacity(ctx context.Context, project string, region string) (ExistingCapacity, error) {
+	computeService, err := newComputeService(ctx)
+	if err != nil {
+		return ExistingCapacity{}, err
+	}
+
+	return service.ListExistingCapacity(ctx, computeService, project, region)
+}
+
+// ListExistingCapacity is GetExistingCapacity with the Compute Engine client to list them with.
+func (service *PricingService) ListExistingCapacity(ctx context.Context, computeService *compute.Service, project string, region string) (ExistingCapacity, error) {
+	capacity := ExistingCapacity{
+		Commitments:        []string{},
+		Committed:          make(map[string]CommittedCapacity),
+		UnusedReservations: make(map[string]int64),
+	}
+
+	// Commitments are regional, reservations are zonal
+	region = location.Region(region)
+
+	var commitmentCost float64
+	err := computeService.RegionCommitments.List(project, region).Pages(ctx, func(commitments *compute.CommitmentList) error {
+		usage.Count(usage.Compute)
+
+		for _, commitment := range commitments.Items {
+			if commitment.Status != "ACTIVE" {
+				continue
+			}
+			capacity.Commitments = append(capacity.Commitments, fmt.Sprintf("%s (%s, ends %s)", commitment.Name, commitment.Plan, commitment.EndTimestamp))
+
+			family := getCommitmentFamily(commitment.Type)
+			cpuPrice, memoryPrice, ok := service.getFamilyCommitmentPrices(family, commitment.Plan == "THIRTY_SIX_MONTH")
+			if !ok {
+				logging.Warn("Commitment %s covers the %s machine family which is not priced.", commitment.Name, family)
+			}
+
+			for _, resource := range commitment.Resources {
+				committed := capacity.Committed[family]
+				switch resource.Type {
+				case "VCPU":
+					capacity.CommittedCpus += resource.Amount
+					committed.Cpus += float64(resource.Amount)
+					commitmentCost += cpuPrice * float64(resource.Amount)
+				case "MEMORY":
+					// Memory is committed in MB
+					memory := float64(resource.Amount) / 1024
+					capacity.CommittedMemoryGb += memory
+					committed.MemoryGb += memory
+					commitmentCost += memoryPrice * memory
+				default:
+					logging.Warn("Commitment %s includes %s which is not priced.", commitment.Name, resource.Type)
+				}
+				capacity.Committed[family] = committed
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("unable to list commitments: %v", err)
+		return ExistingCapacity{}, err
+	}
+	capacity.CommitmentCost = cluster.NewMoney(commitmentCost)
+
+	// Keep the workload warnings clear of the reservation pricing
+	warnings := service.warnings
+	defer func() { service.warnings = warnings }()
+
+	var reservationCost float64
+	err = computeService.Reservations.AggregatedList(project).Pages(ctx, func(reservations *compute.ReservationAggregatedList) error {
+		usage.Count(usage.Compute)
+
+		for zone, scopedList := range reservations.Items {
+			if !strings.HasPrefix(zone, "zones/"+region+"-") {
+				continue
+			}
+
+			for _, reservation := range scopedList.Reservations {
+				if reservation.Status != "READY" || reservation.SpecificReservation == nil || reservation.SpecificReservation.InstanceProperties == nil {
+					continue
+				}
+
+				unused := reservation.SpecificReservation.Count - reservation.SpecificReservation.InUseCount
+				if unused <= 0 {
+					continue
+				}
+
+				machineType := path.Base(reservation.SpecificReservation.InstanceProperties.MachineType)
+				capacity.UnusedReservations[machineType] += unused
+
+				price, _ := service.GetGCEMachinePrice(machineType, false)
+				reservationCost += price * float64(unused)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("unable to list reservations: %v", err)
+		return ExistingCapacity{}, err
+	}
+	capacity.ReservationCost = cluster.NewMoney(reservationCost)
+
+	return capacity, nil
+}
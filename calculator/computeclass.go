@@ -0,0 +1,78 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// autopilotComputeClasses are the compute class selector values of the Autopilot compute classes.
+var autopilotComputeClasses = map[string]cluster.ComputeClass{
+	"Balanced":    cluster.ComputeClassBalanced,
+	"Scale-Out":   cluster.ComputeClassScaleout,
+	"Performance": cluster.ComputeClassPerformance,
+	"Accelerator": cluster.ComputeClassAccelerator,
+}
+
+// GetSelectedComputeClass maps the compute class a pod selects onto the compute class it is priced in, and
+// returns the priority of a custom compute class it runs on, eg. for its Spot capacity or GPU type. A custom
+// compute class is priced by its first priority that maps to a compute class, as GKE tries that one first.
+// It reports false if the pod selects no compute class, or one that is neither an Autopilot compute class
+// nor a ComputeClass object of the cluster.
+func (service *PricingService) GetSelectedComputeClass(workloadName string, name string) (cluster.ComputeClass, cluster.ComputeClassPriority, bool) {
+	if name == "" {
+		return cluster.ComputeClassGeneralPurpose, cluster.ComputeClassPriority{}, false
+	}
+
+	if class, ok := autopilotComputeClasses[name]; ok {
+		return class, cluster.ComputeClassPriority{}, true
+	}
+
+	custom, ok := service.ComputeClasses[name]
+	if !ok {
+		service.warn(cluster.WarningNoComputeClass, "Workload (%s) selects compute class %s, which is not in the cluster. Deciding by resources.", workloadName, name)
+		return cluster.ComputeClassGeneralPurpose, cluster.ComputeClassPriority{}, false
+	}
+
+	for _, priority := range custom.Priorities {
+		if class, ok := service.priorityClass(priority); ok {
+			return class, priority, true
+		}
+	}
+
+	service.warn(cluster.WarningNoComputeClass, "Workload (%s) selects compute class %s, which has no priority with a compute class configured. Deciding by resources.", workloadName, name)
+
+	return cluster.ComputeClassGeneralPurpose, cluster.ComputeClassPriority{}, false
+}
+
+// priorityClass maps a priority of a custom compute class onto a compute class: GPUs and TPUs run in the Accelerator
+// compute class, machine families and types by the [machine_families] section, and pod families by their name.
+func (service *PricingService) priorityClass(priority cluster.ComputeClassPriority) (cluster.ComputeClass, bool) {
+	switch {
+	case priority.GPUType != "" || priority.TPUType != "":
+		return cluster.ComputeClassAccelerator, true
+	case priority.MachineFamily != "":
+		return service.machineFamilyClass(priority.MachineFamily)
+	case priority.MachineType != "":
+		family, _, _ := strings.Cut(priority.MachineType, "-")
+		return service.machineFamilyClass(family)
+	case priority.PodFamily == "general-purpose":
+		return cluster.ComputeClassGeneralPurpose, true
+	}
+
+	return cluster.ComputeClassGeneralPurpose, false
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// CoverageScenario is the cost of the workloads when a flexible committed use discount covers
+// a share of the eligible on-demand spend and the remainder is billed at on-demand rates.
+type CoverageScenario struct {
+	Term string
+	// Coverage is the share of the eligible spend covered by the commitment, eg. 0.6
+	Coverage float64
+	Discount float64
+	// CommittedCost is what the commitment is billed, OnDemandCost is the spend it doesn't cover
+	CommittedCost cluster.Money
+	OnDemandCost  cluster.Money
+	Cost          cluster.Money
+	Savings       cluster.Money
+}
+
+// GetFlexibleCommitDiscount returns the price multiplier of a flexible (spend-based) commitment
+// of the term from the [discounts] section, eg. oneyear_flex_commit.
+func (service *PricingService) GetFlexibleCommitDiscount(term string) float64 {
+	return service.Config.Section("discounts").Key(term + "_flex_commit").MustFloat64(1)
+}
+
+// GetCoverageScenario applies the flexible commitment discount of the term to the covered share of the
+// eligible spend. Like resource commitments, flexible commitments don't cover Spot and flex-start
+// workloads, persistent disks or network egress.
+func (service *PricingService) GetCoverageScenario(nodes map[string]cluster.Node, term string, coverage float64) CoverageScenario {
+	scenario := CoverageScenario{
+		Term:     term,
+		Coverage: coverage,
+		Discount: service.GetFlexibleCommitDiscount(term),
+	}
+
+	var total, eligible cluster.Money
+	for _, node := range nodes {
+		for _, workload := range node.Workloads {
+			total += workload.Cost
+			if node.Spot || workload.Spot || workload.FlexStart {
+				continue
+			}
+
+			eligible += workload.Cost - workload.CostBreakdown.Disk - workload.CostBreakdown.Network
+		}
+	}
+
+	covered := eligible.Mul(coverage)
+	scenario.CommittedCost = covered.Mul(scenario.Discount)
+	scenario.OnDemandCost = total - covered
+	scenario.Cost = scenario.CommittedCost + scenario.OnDemandCost
+	scenario.Savings = total - scenario.Cost
+
+	return scenario
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// Commitment terms as they are named in the [discounts] section of config.ini.
+const (
+	CommitOneYear   = "oneyear"
+	CommitThreeYear = "threeyear"
+)
+
+// getCommitmentPrices returns the committed and the on-demand price of a resource of the compute class
+// from the Autopilot committed use SKUs. The prices are 0 if the class has no commitment SKU for the resource.
+func (service *PricingService) getCommitmentPrices(term string, class cluster.ComputeClass, resource string) (float64, float64) {
+	pricing := service.AutopilotPricing
+
+	var oneYear, threeYear, onDemand float64
+	switch {
+	case class == cluster.ComputeClassGeneralPurpose && resource == "cpu":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearCpuPrice, pricing.CommitmentThreeYearCpuPrice, pricing.CpuPrice
+	case class == cluster.ComputeClassGeneralPurpose && resource == "memory":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearMemoryPrice, pricing.CommitmentThreeYearMemoryPrice, pricing.MemoryPrice
+	case class == cluster.ComputeClassBalanced && resource == "cpu":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearCpuBalancedPrice, pricing.CommitmentThreeYearCpuBalancedPrice, pricing.CpuBalancedPrice
+	case class == cluster.ComputeClassBalanced && resource == "memory":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearMemoryBalancedPrice, pricing.CommitmentThreeYearMemoryBalancedPrice, pricing.MemoryBalancedPrice
+	case class == cluster.ComputeClassScaleout && resource == "cpu":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearCpuScaleoutPrice, pricing.CommitmentThreeYearCpuScaleoutPrice, pricing.CpuScaleoutPrice
+	case class == cluster.ComputeClassScaleout && resource == "memory":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearMemoryScaleoutPrice, pricing.CommitmentThreeYearMemoryScaleoutPrice, pricing.MemoryScaleoutPrice
+	case class == cluster.ComputeClassGPUPod && resource == "cpu":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearGPUPodvCPUPrice, pricing.CommitmentThreeYearGPUPodvCPUPrice, pricing.GPUPodvCPUPrice
+	case class == cluster.ComputeClassGPUPod && resource == "memory":
+		oneYear, threeYear, onDemand = pricing.CommitmentOneYearGPUPodMemoryPrice, pricing.CommitmentThreeYearGPUPodMemoryPrice, pricing.GPUPodMemoryPrice
+	}
+
+	if term == CommitOneYear {
+		return oneYear, onDemand
+	}
+	return threeYear, onDemand
+}
+
+// GetCommitDiscount returns the price multiplier of a commitment term for a compute class and a
+// billed resource (cpu, memory, storage, accelerator or machine). Keys of the class in the [discounts]
+// section win, eg. oneyear_commit_scaleout_cpu, then oneyear_commit_scaleout. Otherwise the discount
+// is the ratio of the committed use SKU to the on-demand price, as discounts differ between compute
+// classes, and finally the flat oneyear_commit.
+func (service *PricingService) GetCommitDiscount(term string, class cluster.ComputeClass, resource string) float64 {
+	keys := []string{
+		fmt.Sprintf("%s_commit_%s_%s", term, cluster.ComputeClassKeys[class], resource),
+		fmt.Sprintf("%s_commit_%s", term, cluster.ComputeClassKeys[class]),
+	}
+
+	for _, key := range keys {
+		if discount, err := service.Config.Section("discounts").Key(key).Float64(); err == nil {
+			return discount
+		}
+	}
+
+	if committed, onDemand := service.getCommitmentPrices(term, class, resource); committed > 0 && onDemand > 0 {
+		return committed / onDemand
+	}
+
+	if discount, err := service.Config.Section("discounts").Key(term + "_commit").Float64(); err == nil {
+		return discount
+	}
+
+	return 1
+}
+
+// GetCommittedWorkloadCost applies the commitment discounts of the term to every resource of the workload.
+// Persistent disks and network egress are not covered by commitments.
+func (service *PricingService) GetCommittedWorkloadCost(workload cluster.Workload, term string) cluster.Money {
+	breakdown := workload.CostBreakdown
+
+	return breakdown.Cpu.Mul(service.GetCommitDiscount(term, workload.ComputeClass, "cpu")) +
+		breakdown.Memory.Mul(service.GetCommitDiscount(term, workload.ComputeClass, "memory")) +
+		breakdown.Storage.Mul(service.GetCommitDiscount(term, workload.ComputeClass, "storage")) +
+		breakdown.Accelerator.Mul(service.GetCommitDiscount(term, workload.ComputeClass, "accelerator")) +
+		breakdown.Machine.Mul(service.GetCommitDiscount(term, workload.ComputeClass, "machine")) +
+		breakdown.Disk +
+		breakdown.Network
+}
+
+// GetCommittedCost returns the hourly cost of all workloads with the commitment discounts of the term.
+// Workloads on spot nodes or flex-start capacity don't qualify for commitments, so they are added at their regular price.
+func (service *PricingService) GetCommittedCost(nodes map[string]cluster.Node, term string) cluster.Money {
+	var total cluster.Money
+	for _, node := range nodes {
+		for _, workload := range node.Workloads {
+			if node.Spot || workload.Spot || workload.FlexStart {
+				total += workload.Cost
+				continue
+			}
+
+			total += service.GetCommittedWorkloadCost(workload, term)
+		}
+	}
+
+	return total
+}
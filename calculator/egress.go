@@ -0,0 +1,68 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"strconv"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// Pod annotations that override the configured egress estimate, in GB per month
+const (
+	INTERNET_EGRESS_ANNOTATION   = "cost.gke.io/internet-egress-gb-month"
+	INTER_ZONE_EGRESS_ANNOTATION = "cost.gke.io/inter-zone-egress-gb-month"
+)
+
+// getEgressGb returns the monthly egress of a pod from its annotation, or the [egress] estimate per workload.
+func (service *PricingService) getEgressGb(workloadName string, annotations map[string]string, annotation string, key string) float64 {
+	if value, ok := annotations[annotation]; ok {
+		gb, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return gb
+		}
+		service.warn(cluster.WarningOutOfRange, "Workload (%s) has an invalid %s annotation (%s), using the configured estimate.", workloadName, annotation, value)
+	}
+
+	gb, err := service.Config.Section("egress").Key(key).Float64()
+	if err != nil {
+		return 0
+	}
+
+	return gb
+}
+
+// GetEgressPrice returns the hourly price of the internet and inter-zone egress of a pod. Egress is billed
+// the same way in Standard and Autopilot, but it's often a material part of the bill after migrating.
+func (service *PricingService) GetEgressPrice(workloadName string, annotations map[string]string) float64 {
+	internetGb := service.getEgressGb(workloadName, annotations, INTERNET_EGRESS_ANNOTATION, "internet_gb_month")
+	interZoneGb := service.getEgressGb(workloadName, annotations, INTER_ZONE_EGRESS_ANNOTATION, "inter_zone_gb_month")
+	if internetGb == 0 && interZoneGb == 0 {
+		return 0
+	}
+
+	// Internet egress depends on the destination, so its price is configured
+	internetPrice := service.Config.Section("egress").Key("internet_price").MustFloat64(0)
+
+	interZonePrice := service.GCEPricing.InterZoneEgressPrice
+	if price, err := service.Config.Section("egress").Key("inter_zone_price").Float64(); err == nil {
+		interZonePrice = price
+	}
+	if interZoneGb > 0 && interZonePrice == 0 {
+		service.warn(cluster.WarningPricingUnavailable, "Inter-zone egress pricing is not available in %s region.", service.GCEPricing.Region)
+	}
+
+	return (internetGb*internetPrice + interZoneGb*interZonePrice) / HOURS_PER_MONTH
+}
@@ -0,0 +1,241 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podResources are the summed resources of the containers of a pod, from their requests for a manifest or
+// from their usage for a running pod, with the node it runs on.
+type podResources struct {
+	name        string
+	namespace   string
+	owner       cluster.Owner
+	labels      map[string]string
+	annotations map[string]string
+	spec        corev1.PodSpec
+	// node is zero for manifests, they are priced by their node selector only
+	node  cluster.Node
+	disks []cluster.Disk
+
+	cpu        int64
+	memory     int64
+	storage    int64
+	gpu        int64
+	tpu        int64
+	containers int
+	burstable  bool
+
+	sidecarCpu     int64
+	sidecarMemory  int64
+	sidecarStorage int64
+}
+
+// getContainerRequests returns the mCPU, the memory and ephemeral storage in MiB, the GPUs and the TPUs a container requests.
+func getContainerRequests(container corev1.Container) (int64, int64, int64, int64, int64) {
+	cpuRequest := container.Resources.Requests[corev1.ResourceCPU]
+	memoryRequest := container.Resources.Requests[corev1.ResourceMemory]
+	storageRequest := container.Resources.Requests[corev1.ResourceEphemeralStorage]
+	gpuRequest := container.Resources.Requests["nvidia.com/gpu"]
+	tpuRequest := container.Resources.Requests[cluster.TPU_RESOURCE]
+
+	// Division to get MiB
+	return cpuRequest.MilliValue(), memoryRequest.MilliValue() / 1000000000, storageRequest.MilliValue() / 1000000000, gpuRequest.Value(), tpuRequest.Value()
+}
+
+// EstimatePodTemplate prices a single replica of a pod template from its resource requests, without a running cluster.
+func (service *PricingService) EstimatePodTemplate(template *cluster.PodTemplate) cluster.Workload {
+	service.warnings = nil
+
+	pod := podResources{name: template.Name, namespace: template.Namespace, spec: template.Spec}
+
+	// Native sidecars keep running next to the containers and are sized with them
+	podContainers := append([]corev1.Container{}, template.Spec.Containers...)
+	for _, container := range template.Spec.InitContainers {
+		if cluster.IsNativeSidecar(container) {
+			podContainers = append(podContainers, container)
+		}
+	}
+
+	for _, container := range podContainers {
+		cpu, memory, storage, gpu, tpu := getContainerRequests(container)
+
+		if service.IsSidecar(container.Name) {
+			pod.sidecarCpu += cpu
+			pod.sidecarMemory += memory
+			pod.sidecarStorage += storage
+			if service.SidecarMode == SIDECARS_EXCLUDE {
+				continue
+			}
+		}
+
+		pod.cpu += cpu
+		pod.memory += memory
+		pod.storage += storage
+		pod.gpu += gpu
+		pod.tpu += tpu
+		pod.burstable = pod.burstable || cluster.IsBurstable(container)
+		pod.containers++
+	}
+
+	return service.priceWorkload(pod)
+}
+
+// priceWorkload maps a pod onto its compute class and prices it as Autopilot bills it, for both running pods
+// and manifests. The warnings of the pod are added to those the caller already raised.
+func (service *PricingService) priceWorkload(pod podResources) cluster.Workload {
+	node := pod.node
+	cpu, memory, storage := pod.cpu, pod.memory, pod.storage
+
+	// Init containers run before the containers, the Pod is sized for the larger of both
+	initCpu, initMemory, initStorage := cluster.GetInitContainerPeak(pod.spec)
+	if initCpu > cpu {
+		cpu = initCpu
+	}
+	if initMemory > memory {
+		memory = initMemory
+	}
+	if initStorage > storage {
+		storage = initStorage
+	}
+
+	requestedCpu, requestedMemory := cpu, memory
+
+	if node.Windows || cluster.IsWindows(pod.spec.NodeSelector) {
+		service.warn(cluster.WarningMigrationBlocker, "Workload (%s) runs on Windows Server nodes, which Autopilot doesn't support. It's priced as a Linux workload.", pod.name)
+	}
+
+	sandboxMcpuOverhead, sandboxMemoryOverhead, sandboxed := service.GetSandboxOverhead(pod.name, pod.spec)
+	cpu += sandboxMcpuOverhead
+	memory += sandboxMemoryOverhead
+
+	// Check and modify the limits of summed workloads from the Pod
+	cpu, memory, storage = ApplyMinimumResources(cpu, memory, storage)
+
+	gpuModel := pod.spec.NodeSelector["cloud.google.com/gke-accelerator"]
+	gpuSharing, gpuShare := cluster.GetGPUShare(pod.spec.NodeSelector)
+	if gpuSharing == "" && node.GPUSharing != "" {
+		gpuSharing, gpuShare = node.GPUSharing, node.GPUShare
+	}
+	tpuType, tpuTopology := cluster.GetTPUType(pod.spec.NodeSelector)
+	arm64 := service.IsArm64MachineType(node.InstanceType) || pod.spec.NodeSelector["kubernetes.io/arch"] == "arm64"
+
+	computeClass, priority, pinned := service.GetSelectedComputeClass(pod.name, cluster.GetComputeClassName(pod.spec))
+	// Pods of a GPU or TPU compute class get the accelerator of its priority, they don't need to select it
+	gpu := pod.gpu
+	if gpuModel == "" && priority.GPUType != "" {
+		gpuModel = priority.GPUType
+		if gpu == 0 {
+			gpu = priority.GPUCount
+		}
+	}
+	if tpuType == "" {
+		tpuType = priority.TPUType
+	}
+	if !pinned {
+		computeClass, pinned = service.GetMachineFamilyComputeClass(pod.name, cluster.GetMachineFamily(pod.spec))
+	}
+	// TPUs are only available in the Accelerator compute class
+	if pod.tpu > 0 {
+		computeClass, pinned = cluster.ComputeClassAccelerator, true
+	}
+	if !pinned {
+		computeClass = service.DecideComputeClass(pod.name, node.InstanceType, cpu, memory, gpu, gpuModel, arm64)
+	}
+
+	// Autopilot bills the requests after adjusting them to the rules of the compute class
+	cpu, memory = service.AdjustResources(computeClass, gpuModel, cpu, memory)
+	storage, extendedDisks := service.GetExtendedStorage(pod.name, computeClass, storage)
+
+	// Without a node, the machine family stands in for the machine type, eg. for the node of a Performance workload
+	instanceType := node.InstanceType
+	if instanceType == "" {
+		instanceType = cluster.GetMachineFamily(pod.spec)
+	}
+
+	// Flex-start capacity is never Spot, it has its own discounted rates
+	flexStart := node.FlexStart || cluster.IsFlexStart(pod.spec.NodeSelector) || pod.annotations["cluster-autoscaler.kubernetes.io/consume-provisioning-request"] != ""
+	spot := (node.Spot || pod.spec.NodeSelector["cloud.google.com/gke-spot"] == "true" || priority.Spot) && !flexStart
+	price := service.CalculatePriceBreakdown(PriceRequest{Cpu: cpu, Memory: memory, Storage: storage, GPU: gpu, GPUModel: gpuModel, TPU: pod.tpu, TPUType: tpuType, Class: computeClass, InstanceType: instanceType, Spot: spot})
+	price = shareGPUPrice(price, gpuShare)
+	if flexStart {
+		price = service.GetFlexStartPrice(pod.name, computeClass, price)
+	}
+
+	if node.Confidential || cluster.IsConfidential(pod.spec.NodeSelector) {
+		price = service.GetConfidentialPrice(pod.name, computeClass, cpu, memory, spot, price)
+	}
+
+	disks := append(pod.disks, extendedDisks...)
+	price.Disk = service.GetDisksPrice(pod.name, disks)
+	price.Network = service.GetEgressPrice(pod.name, pod.annotations)
+
+	cost := cluster.NewMoney(price.Total())
+
+	var sidecarCost cluster.Money
+	if service.SidecarMode == SIDECARS_SEPARATE {
+		sidecarCost = service.GetSidecarCost(pod.sidecarCpu, pod.sidecarMemory, pod.sidecarStorage, computeClass, instanceType, spot, cost)
+	}
+
+	// Pods of Jobs only run, and are only billed, part of the month
+	var dutyCycle float64
+	if share := service.GetDutyCycle(pod.name, pod.namespace, pod.owner); share < 1 {
+		dutyCycle = share
+		price = prorateJobPrice(price, dutyCycle)
+		cost, sidecarCost = cluster.NewMoney(price.Total()), sidecarCost.Mul(dutyCycle)
+	}
+
+	workload := cluster.Workload{
+		Name:              pod.name,
+		Namespace:         pod.namespace,
+		Owner:             pod.owner,
+		Labels:            pod.labels,
+		Containers:        pod.containers,
+		Node_name:         pod.spec.NodeName,
+		Cpu:               cpu,
+		Memory:            memory,
+		Storage:           storage,
+		RequestedCpu:      requestedCpu,
+		RequestedMemory:   requestedMemory,
+		AcceleratorType:   gpuModel,
+		AcceleratorAmount: gpu,
+		GPUSharing:        gpuSharing,
+		GPUShare:          gpuShare,
+		TPUType:           tpuType,
+		TPUTopology:       tpuTopology,
+		TPUCount:          pod.tpu,
+		Cost:              cost,
+		EffectiveCost:     cost,
+		CostBreakdown:     price.CostBreakdown(),
+		SidecarCost:       sidecarCost,
+		ComputeClass:      computeClass,
+		Sandboxed:         sandboxed,
+		Spot:              spot,
+		FlexStart:         flexStart,
+		Burstable:         pod.burstable && CanBurst(computeClass),
+		GracePeriod:       cluster.GetTerminationGracePeriod(pod.spec),
+		DutyCycle:         dutyCycle,
+		Disks:             disks,
+		Warnings:          service.warnings,
+	}
+
+	for i := range workload.Warnings {
+		workload.Warnings[i].Workload = pod.name
+	}
+
+	return workload
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/location"
+)
+
+// FREE_TIER_CREDIT is the monthly GKE free tier credit per billing account, which covers
+// the fee of one zonal Standard or Autopilot cluster.
+// https://cloud.google.com/kubernetes-engine/pricing#cluster_management_fee_and_free_tier
+const FREE_TIER_CREDIT = 74.4
+
+// GetClusterFees returns the hourly fee of the current Standard cluster and of the Autopilot cluster
+// it would be migrated to. With the free tier, the credit is taken off the fee of a zonal Standard
+// cluster and of an Autopilot cluster, regional Standard clusters don't qualify.
+func GetClusterFees(clusterFee float64, clusterLocation string, freeTier bool) (cluster.Money, cluster.Money) {
+	if !freeTier {
+		return cluster.NewMoney(clusterFee), cluster.NewMoney(clusterFee)
+	}
+
+	discountedFee := math.Max(clusterFee-FREE_TIER_CREDIT/HOURS_PER_MONTH, 0)
+
+	standardFee := clusterFee
+	if location.IsZone(clusterLocation) {
+		standardFee = discountedFee
+	}
+
+	return cluster.NewMoney(standardFee), cluster.NewMoney(discountedFee)
+}
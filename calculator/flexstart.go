@@ -0,0 +1,50 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// getFlexStartDiscount returns the price multiplier of a billed resource (cpu, memory, storage,
+// accelerator or machine) from the [flex_start] section, falling back to the flat discount key.
+func (service *PricingService) getFlexStartDiscount(resource string) float64 {
+	for _, key := range []string{resource, "discount"} {
+		if discount, err := service.Config.Section("flex_start").Key(key).Float64(); err == nil {
+			return discount
+		}
+	}
+
+	return 1
+}
+
+// GetFlexStartPrice applies the Dynamic Workload Scheduler (flex-start and queued provisioning)
+// rates to the on-demand price of a GPU workload. Other compute classes are returned unchanged.
+func (service *PricingService) GetFlexStartPrice(name string, class cluster.ComputeClass, price PriceBreakdown) PriceBreakdown {
+	if class != cluster.ComputeClassGPUPod && class != cluster.ComputeClassAccelerator {
+		service.warn(cluster.WarningFlexStart, "Workload (%s) requests flex-start capacity, which is only available for GPUs. Using on-demand pricing.", name)
+		return price
+	}
+
+	service.warn(cluster.WarningFlexStart, "Workload (%s) uses flex-start capacity, it may wait for GPUs to be available and runs for at most 7 days.", name)
+
+	price.Cpu *= service.getFlexStartDiscount("cpu")
+	price.Memory *= service.getFlexStartDiscount("memory")
+	price.Storage *= service.getFlexStartDiscount("storage")
+	price.Accelerator *= service.getFlexStartDiscount("accelerator")
+	price.Machine *= service.getFlexStartDiscount("machine")
+
+	return price
+}
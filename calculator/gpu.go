@@ -0,0 +1,151 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"reflect"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// gpuModel is a GPU Autopilot can run, by the value of the cloud.google.com/gke-accelerator node selector.
+// GPUs are billed per GPU Pod SKU in the GPU Pod compute class, eg. "Autopilot NVIDIA L4 Pod GPU Requests (us-central1)",
+// and per premium SKU in the Accelerator compute class, eg. "Autopilot L4 Premium (us-central1)". Models without
+// a GPU Pod SKU are only available in the Accelerator compute class.
+type gpuModel struct {
+	Selector string
+
+	PodSku           string
+	PodField         string
+	SpotPodField     string
+	PremiumSku       string
+	PremiumField     string
+	SpotPremiumField string
+	AcceleratorOnly  bool
+}
+
+var gpuModels = []gpuModel{
+	{
+		Selector: "nvidia-tesla-t4",
+		PodSku:   "NVIDIA T4", PodField: "NVIDIAT4PodGPUPrice", SpotPodField: "SpotNVIDIAT4PodGPUPrice",
+		PremiumSku: "T4", PremiumField: "AcceleratorT4GPUPricePremium", SpotPremiumField: "SpotAcceleratorT4GPUPricePremium",
+	},
+	{
+		Selector: "nvidia-l4",
+		PodSku:   "NVIDIA L4", PodField: "NVIDIAL4PodGPUPrice", SpotPodField: "SpotNVIDIAL4PodGPUPrice",
+		PremiumSku: "L4", PremiumField: "AcceleratorL4GPUPricePremium", SpotPremiumField: "SpotAcceleratorL4GPUPricePremium",
+	},
+	{
+		Selector: "nvidia-tesla-a100",
+		PodSku:   "NVIDIA A100", PodField: "NVIDIAA10040GPodGPUPrice", SpotPodField: "SpotNVIDIAA10040GPodGPUPrice",
+		PremiumSku: "A100 40GB", PremiumField: "AcceleratorA10040GGPUPricePremium", SpotPremiumField: "SpotAcceleratorA10040GGPUPricePremium",
+	},
+	{
+		Selector: "nvidia-a100-80gb",
+		PodSku:   "NVIDIA A100 80GB", PodField: "NVIDIAA10080GPodGPUPrice", SpotPodField: "SpotNVIDIAA10080GPodGPUPrice",
+		PremiumSku: "A100 80GB", PremiumField: "AcceleratorA10080GGPUPricePremium", SpotPremiumField: "SpotAcceleratorA10080GGPUPricePremium",
+	},
+	{
+		Selector:   "nvidia-h100-80gb",
+		PremiumSku: "H100 80GB", PremiumField: "AcceleratorH100GPUPricePremium", SpotPremiumField: "SpotAcceleratorH100GPUPricePremium",
+		AcceleratorOnly: true,
+	},
+	{
+		Selector:   "nvidia-h200-141gb",
+		PremiumSku: "H200 141GB", PremiumField: "AcceleratorH200GPUPricePremium", SpotPremiumField: "SpotAcceleratorH200GPUPricePremium",
+		AcceleratorOnly: true,
+	},
+	{
+		Selector:   "nvidia-b200",
+		PremiumSku: "B200", PremiumField: "AcceleratorB200GPUPricePremium", SpotPremiumField: "SpotAcceleratorB200GPUPricePremium",
+		AcceleratorOnly: true,
+	},
+	{
+		Selector:   "nvidia-gb200",
+		PremiumSku: "GB200", PremiumField: "AcceleratorGB200GPUPricePremium", SpotPremiumField: "SpotAcceleratorGB200GPUPricePremium",
+		AcceleratorOnly: true,
+	},
+}
+
+func getGPUModel(selector string) (gpuModel, bool) {
+	for _, model := range gpuModels {
+		if model.Selector == selector {
+			return model, true
+		}
+	}
+
+	return gpuModel{}, false
+}
+
+// IsAcceleratorOnlyGPU checks if the GPU has no GPU Pod pricing and can only run in the Accelerator compute class.
+func IsAcceleratorOnlyGPU(selector string) bool {
+	model, ok := getGPUModel(selector)
+	return ok && model.AcceleratorOnly
+}
+
+// acceleratorSkuMapping maps the descriptions of the GPU and TPU SKUs in the region to their price list fields,
+// so they are matched like a user supplied SKU mapping.
+func acceleratorSkuMapping(region string) map[string]string {
+	mapping := make(map[string]string)
+	for _, model := range gpuModels {
+		if model.PodSku != "" {
+			mapping["Autopilot "+model.PodSku+" Pod GPU Requests ("+region+")"] = model.PodField
+			mapping["Autopilot "+model.PodSku+" Spot Pod GPU Requests ("+region+")"] = model.SpotPodField
+		}
+		mapping["Autopilot "+model.PremiumSku+" Premium ("+region+")"] = model.PremiumField
+		mapping["Autopilot "+model.PremiumSku+" Spot Premium ("+region+")"] = model.SpotPremiumField
+	}
+	for _, model := range tpuModels {
+		mapping["Autopilot "+model.Sku+" Pod Chip Requests ("+region+")"] = model.PriceField
+		mapping["Autopilot "+model.Sku+" Spot Pod Chip Requests ("+region+")"] = model.SpotPriceField
+	}
+
+	return mapping
+}
+
+// getGPUPrice returns the hourly price of a single GPU in the GPU Pod or Accelerator compute class.
+// It reports false if the model is not known or not available in the compute class.
+func (service *PricingService) getGPUPrice(selector string, class cluster.ComputeClass, spot bool) (float64, bool) {
+	model, ok := getGPUModel(selector)
+	if !ok {
+		return 0, false
+	}
+
+	var field string
+	switch {
+	case class == cluster.ComputeClassGPUPod && spot:
+		field = model.SpotPodField
+	case class == cluster.ComputeClassGPUPod:
+		field = model.PodField
+	case spot:
+		field = model.SpotPremiumField
+	default:
+		field = model.PremiumField
+	}
+	if field == "" {
+		return 0, false
+	}
+
+	return reflect.ValueOf(service.AutopilotPricing).FieldByName(field).Float(), true
+}
+
+// shareGPUPrice bills the share of the physical GPUs a pod gets with time-sharing, MPS or MIG instead of whole GPUs.
+func shareGPUPrice(price PriceBreakdown, share float64) PriceBreakdown {
+	if share > 0 && share < 1 {
+		price.Accelerator *= share
+	}
+
+	return price
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+)
+
+// GetDutyCycle returns the share of the month the pod of a Job runs, from the JobRuns of the service.
+// Pods of other controllers, and of Jobs without known runs, run all month.
+func (service *PricingService) GetDutyCycle(workloadName string, namespace string, owner cluster.Owner) float64 {
+	if owner.Kind != "Job" {
+		return 1
+	}
+
+	runs, ok := service.JobRuns[namespace+"/"+owner.Name]
+	if !ok {
+		return 1
+	}
+
+	dutyCycle := math.Min(runs.RunsPerMonth*runs.RunHours/HOURS_PER_MONTH, 1)
+	logging.Debug("Workload (%s) runs %.1f times a month for %.2f hours, it is priced for %.1f%% of the month", workloadName, runs.RunsPerMonth, runs.RunHours, dutyCycle*100)
+
+	return dutyCycle
+}
+
+// prorateJobPrice bills a pod only for the share of the month it runs. Persistent disks are billed
+// while they exist, not only while the pod runs, so their price is kept.
+func prorateJobPrice(price PriceBreakdown, dutyCycle float64) PriceBreakdown {
+	price.Cpu *= dutyCycle
+	price.Memory *= dutyCycle
+	price.Storage *= dutyCycle
+	price.Accelerator *= dutyCycle
+	price.Machine *= dutyCycle
+	price.Network *= dutyCycle
+
+	return price
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// gpuLimitKeys name the GPU models in the [limits] keys, eg. gpupod_t4_mcpu_max.
+var gpuLimitKeys = map[string]string{
+	"nvidia-tesla-t4":   "t4",
+	"nvidia-l4":         "l4",
+	"nvidia-tesla-a100": "a100_40",
+	"nvidia-a100-80gb":  "a100_80",
+	"nvidia-h100-80gb":  "h100_80",
+}
+
+// defaultClassOrder is the order compute classes are tried for workloads without GPUs or pinned hardware
+// when the [classes] section has no order.
+var defaultClassOrder = []cluster.ComputeClass{
+	cluster.ComputeClassGeneralPurpose,
+	cluster.ComputeClassScaleout,
+	cluster.ComputeClassBalanced,
+}
+
+// classLimits are the smallest and largest requests and memory to vCPU ratio a compute class allows, and
+// the step mCPU is rounded up to once the class is decided.
+type classLimits struct {
+	mCPUMin   int64
+	mCPUMax   int64
+	mCPUStep  int64
+	memoryMin int64
+	memoryMax int64
+	ratioMin  float64
+	ratioMax  float64
+	// storageMax is the largest ephemeral storage request, larger Pods need a generic ephemeral volume
+	storageMax int64
+}
+
+// allows checks if the requests and their memory to vCPU ratio are within the limits. Requests below the
+// minimums are allowed, Autopilot raises them.
+func (limits classLimits) allows(mCPU int64, memory int64) bool {
+	ratio := math.Ceil(float64(memory) / float64(mCPU))
+
+	return ratio >= limits.ratioMin && ratio <= limits.ratioMax &&
+		mCPU <= limits.mCPUMax && memory <= limits.memoryMax
+}
+
+// exceeds checks if the requests are above the largest of the limits, which can't be corrected by raising them.
+func (limits classLimits) exceeds(mCPU int64, memory int64) bool {
+	return mCPU > limits.mCPUMax || memory > limits.memoryMax
+}
+
+// getClassLimits reads the limits of the compute class from the [limits] and [ratios] sections, eg. balanced_mcpu_max
+// and balanced_max. With a GPU model the [limits] keys of the model are read first, eg. gpupod_t4_mcpu_max, then those
+// of the class. Keys that are not set don't limit the requests. Scale-Out on Arm has the ratio of Scale-Out.
+func (service *PricingService) getClassLimits(class cluster.ComputeClass, gpuModel string) classLimits {
+	key := cluster.ComputeClassKeys[class]
+	ratioKey := key
+	if class == cluster.ComputeClassScaleoutArm {
+		ratioKey = cluster.ComputeClassKeys[cluster.ComputeClassScaleout]
+	}
+
+	limitKeys := []string{key}
+	if gpuKey, ok := gpuLimitKeys[gpuModel]; ok {
+		limitKeys = []string{key + "_" + gpuKey, key}
+	}
+	limit := func(name string, fallback int64) int64 {
+		for _, limitKey := range limitKeys {
+			if value, err := service.Config.Section("limits").Key(limitKey + "_" + name).Int64(); err == nil {
+				return value
+			}
+		}
+		return fallback
+	}
+
+	return classLimits{
+		mCPUMin:    limit("mcpu_min", 0),
+		mCPUMax:    limit("mcpu_max", math.MaxInt64),
+		mCPUStep:   limit("mcpu_step", 1),
+		memoryMin:  limit("memory_min", 0),
+		memoryMax:  limit("memory_max", math.MaxInt64),
+		ratioMin:   service.Config.Section("ratios").Key(ratioKey + "_min").MustFloat64(math.Inf(-1)),
+		ratioMax:   service.Config.Section("ratios").Key(ratioKey + "_max").MustFloat64(math.Inf(1)),
+		storageMax: limit("storage_max", math.MaxInt64),
+	}
+}
+
+// getClassOrder returns the compute classes of the order key of the [classes] section, the classes that workloads
+// without GPUs or pinned hardware are tried in. Unknown class names are skipped.
+func (service *PricingService) getClassOrder() []cluster.ComputeClass {
+	keys := service.Config.Section("classes").Key("order").Strings(",")
+	if len(keys) == 0 {
+		return defaultClassOrder
+	}
+
+	order := []cluster.ComputeClass{}
+	for _, key := range keys {
+		if class, ok := getComputeClassByKey(key); ok {
+			order = append(order, class)
+		}
+	}
+
+	return order
+}
+
+// getComputeClassByKey looks a compute class up by its config name, eg. scaleout.
+func getComputeClassByKey(key string) (cluster.ComputeClass, bool) {
+	for class, classKey := range cluster.ComputeClassKeys {
+		if key == classKey {
+			return cluster.ComputeClass(class), true
+		}
+	}
+
+	return cluster.ComputeClassGeneralPurpose, false
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// The minimum forwarding rule charge covers this many rules per project and region
+const FORWARDING_RULES_INCLUDED = 5
+
+// PriceLoadBalancers prices the forwarding rules of the load balancers and the data they process,
+// each load balancer gets an equal share of the minimum charge. Load balancers cost the same in
+// Standard and Autopilot, so they are reported apart from the workloads.
+func (service *PricingService) PriceLoadBalancers(loadBalancers []cluster.LoadBalancer) ([]cluster.LoadBalancer, cluster.Money) {
+	if len(loadBalancers) == 0 {
+		return loadBalancers, 0
+	}
+
+	// Keep the workload warnings clear of the load balancer pricing
+	warnings := service.warnings
+	defer func() { service.warnings = warnings }()
+
+	if service.GCEPricing.ForwardingRuleMinimumPrice == 0 {
+		service.warn(cluster.WarningPricingUnavailable, "Forwarding rule pricing is not available in %s region.", service.GCEPricing.Region)
+	}
+
+	forwardingRulesPrice := service.GCEPricing.ForwardingRuleMinimumPrice
+	if len(loadBalancers) > FORWARDING_RULES_INCLUDED {
+		forwardingRulesPrice += service.GCEPricing.ForwardingRuleAdditionalPrice * float64(len(loadBalancers)-FORWARDING_RULES_INCLUDED)
+	}
+
+	dataProcessedGb := service.Config.Section("load_balancing").Key("data_processed_gb_month").MustFloat64(0)
+	dataProcessingPrice := service.GCEPricing.LoadBalancerDataProcessingPrice * dataProcessedGb / HOURS_PER_MONTH
+
+	price := forwardingRulesPrice/float64(len(loadBalancers)) + dataProcessingPrice
+
+	priced := make([]cluster.LoadBalancer, 0, len(loadBalancers))
+	for _, loadBalancer := range loadBalancers {
+		loadBalancer.Cost = cluster.NewMoney(price)
+		priced = append(priced, loadBalancer)
+	}
+
+	// The total is priced at once, so the shares don't add up rounding errors
+	return priced, cluster.NewMoney(price * float64(len(loadBalancers)))
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// getPerformanceFamily returns the machine family of the node a Performance workload runs on: the family of
+// instanceType, a machine type or just a family, if it is configured as a Performance family, else the
+// default_family of the [performance] section.
+func (service *PricingService) getPerformanceFamily(instanceType string) string {
+	family, _, _ := strings.Cut(instanceType, "-")
+	if class, ok := service.machineFamilyClass(family); ok && class == cluster.ComputeClassPerformance {
+		return family
+	}
+
+	return service.Config.Section("performance").Key("default_family").String()
+}
+
+// getPerformanceMachinePrice prices the node of a Performance workload, which is billed on top of the
+// Performance premiums. Every Performance workload gets a node of its own, sized to its requests by the
+// vCPU and memory prices of the machine family, with whole vCPUs.
+func (service *PricingService) getPerformanceMachinePrice(instanceType string, mCPU int64, memory int64, spot bool) float64 {
+	family := service.getPerformanceFamily(instanceType)
+
+	cpuPrice, memoryPrice, ok := service.getMachineFamilyPrice(family, spot)
+	if !ok {
+		service.warn(cluster.WarningUnsupportedMachineType, "Performance machine family %s is not implemented for price querying.", family)
+		return 0
+	}
+	if cpuPrice == 0 || memoryPrice == 0 {
+		service.warn(cluster.WarningPricingUnavailable, "Performance machine family %s pricing is not available in %s region.", family, service.GCEPricing.Region)
+	}
+
+	return cpuPrice*math.Ceil(float64(mCPU)/1000) + memoryPrice*float64(memory)/1000
+}
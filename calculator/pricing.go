@@ -15,13 +15,12 @@
 package calculator
 
 import (
-	"context"
-	"fmt"
 	"strings"
 
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/location"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
 	"golang.org/x/exp/slices"
 	"google.golang.org/api/cloudbilling/v1"
-	"google.golang.org/api/option"
 )
 
 type GCEPriceList struct {
@@ -54,6 +53,156 @@ type GCEPriceList struct {
 	SpotA2MemoryPrice  float64
 	SpotA3CpuPrice     float64
 	SpotA3MemoryPrice  float64
+
+	// General purpose and compute optimized families
+	E2CpuPrice     float64
+	E2MemoryPrice  float64
+	N1CpuPrice     float64
+	N1MemoryPrice  float64
+	N2CpuPrice     float64
+	N2MemoryPrice  float64
+	N2DCpuPrice    float64
+	N2DMemoryPrice float64
+	N4CpuPrice     float64
+	N4MemoryPrice  float64
+	C3CpuPrice     float64
+	C3MemoryPrice  float64
+	C3DCpuPrice    float64
+	C3DMemoryPrice float64
+	C4CpuPrice     float64
+	C4MemoryPrice  float64
+	T2DCpuPrice    float64
+	T2DMemoryPrice float64
+	T2ACpuPrice    float64
+	T2AMemoryPrice float64
+	C4ACpuPrice    float64
+	C4AMemoryPrice float64
+
+	SpotE2CpuPrice     float64
+	SpotE2MemoryPrice  float64
+	SpotN1CpuPrice     float64
+	SpotN1MemoryPrice  float64
+	SpotN2CpuPrice     float64
+	SpotN2MemoryPrice  float64
+	SpotN2DCpuPrice    float64
+	SpotN2DMemoryPrice float64
+	SpotN4CpuPrice     float64
+	SpotN4MemoryPrice  float64
+	SpotC3CpuPrice     float64
+	SpotC3MemoryPrice  float64
+	SpotC3DCpuPrice    float64
+	SpotC3DMemoryPrice float64
+	SpotC4CpuPrice     float64
+	SpotC4MemoryPrice  float64
+	SpotT2DCpuPrice    float64
+	SpotT2DMemoryPrice float64
+	SpotT2ACpuPrice    float64
+	SpotT2AMemoryPrice float64
+	SpotC4ACpuPrice    float64
+	SpotC4AMemoryPrice float64
+
+	// Memory optimized families, M1 and M2 share their SKUs
+	M1CpuPrice    float64
+	M1MemoryPrice float64
+	M3CpuPrice    float64
+	M3MemoryPrice float64
+
+	// Custom machine types, extended memory is billed above the memory limit per vCPU
+	N1CustomCpuPrice             float64
+	N1CustomMemoryPrice          float64
+	N1CustomExtendedMemoryPrice  float64
+	N2CustomCpuPrice             float64
+	N2CustomMemoryPrice          float64
+	N2CustomExtendedMemoryPrice  float64
+	N2DCustomCpuPrice            float64
+	N2DCustomMemoryPrice         float64
+	N2DCustomExtendedMemoryPrice float64
+
+	SpotN1CustomCpuPrice             float64
+	SpotN1CustomMemoryPrice          float64
+	SpotN1CustomExtendedMemoryPrice  float64
+	SpotN2CustomCpuPrice             float64
+	SpotN2CustomMemoryPrice          float64
+	SpotN2CustomExtendedMemoryPrice  float64
+	SpotN2DCustomCpuPrice            float64
+	SpotN2DCustomMemoryPrice         float64
+	SpotN2DCustomExtendedMemoryPrice float64
+
+	// Confidential VM premium on top of the machine price
+	ConfidentialCpuPrice        float64
+	ConfidentialMemoryPrice     float64
+	SpotConfidentialCpuPrice    float64
+	SpotConfidentialMemoryPrice float64
+
+	// Persistent disk pricing, per GiB or IOPS per month
+	PdStandardCapacityPrice         float64
+	PdBalancedCapacityPrice         float64
+	PdSsdCapacityPrice              float64
+	PdExtremeCapacityPrice          float64
+	PdExtremeIopsPrice              float64
+	RegionalPdStandardCapacityPrice float64
+	RegionalPdBalancedCapacityPrice float64
+	RegionalPdSsdCapacityPrice      float64
+
+	// Network egress between zones of the region, per GiB
+	InterZoneEgressPrice float64
+
+	// Load balancing, the forwarding rule charges are hourly, data processing is per GiB
+	ForwardingRuleMinimumPrice      float64
+	ForwardingRuleAdditionalPrice   float64
+	LoadBalancerDataProcessingPrice float64
+
+	// Hyperdisk pricing, per GiB, IOPS or MiB/s per month
+	HyperdiskBalancedCapacityPrice   float64
+	HyperdiskBalancedIopsPrice       float64
+	HyperdiskBalancedThroughputPrice float64
+	HyperdiskExtremeCapacityPrice    float64
+	HyperdiskExtremeIopsPrice        float64
+
+	// Resource-based committed use discounts, per vCPU or GB per hour. The prices without a family are those of N1.
+	CommitmentOneYearCpuPrice         float64
+	CommitmentOneYearMemoryPrice      float64
+	CommitmentThreeYearCpuPrice       float64
+	CommitmentThreeYearMemoryPrice    float64
+	CommitmentOneYearN2CpuPrice       float64
+	CommitmentOneYearN2MemoryPrice    float64
+	CommitmentThreeYearN2CpuPrice     float64
+	CommitmentThreeYearN2MemoryPrice  float64
+	CommitmentOneYearN2DCpuPrice      float64
+	CommitmentOneYearN2DMemoryPrice   float64
+	CommitmentThreeYearN2DCpuPrice    float64
+	CommitmentThreeYearN2DMemoryPrice float64
+	CommitmentOneYearE2CpuPrice       float64
+	CommitmentOneYearE2MemoryPrice    float64
+	CommitmentThreeYearE2CpuPrice     float64
+	CommitmentThreeYearE2MemoryPrice  float64
+	CommitmentOneYearC2CpuPrice       float64
+	CommitmentOneYearC2MemoryPrice    float64
+	CommitmentThreeYearC2CpuPrice     float64
+	CommitmentThreeYearC2MemoryPrice  float64
+	CommitmentOneYearC2DCpuPrice      float64
+	CommitmentOneYearC2DMemoryPrice   float64
+	CommitmentThreeYearC2DCpuPrice    float64
+	CommitmentThreeYearC2DMemoryPrice float64
+	CommitmentOneYearC3CpuPrice       float64
+	CommitmentOneYearC3MemoryPrice    float64
+	CommitmentThreeYearC3CpuPrice     float64
+	CommitmentThreeYearC3MemoryPrice  float64
+	CommitmentOneYearC3DCpuPrice      float64
+	CommitmentOneYearC3DMemoryPrice   float64
+	CommitmentThreeYearC3DCpuPrice    float64
+	CommitmentThreeYearC3DMemoryPrice float64
+	CommitmentOneYearT2DCpuPrice      float64
+	CommitmentOneYearT2DMemoryPrice   float64
+	CommitmentThreeYearT2DCpuPrice    float64
+	CommitmentThreeYearT2DMemoryPrice float64
+	CommitmentOneYearN4CpuPrice       float64
+	CommitmentOneYearN4MemoryPrice    float64
+	CommitmentThreeYearN4CpuPrice     float64
+	CommitmentThreeYearN4MemoryPrice  float64
+
+	// Windows Server license, per vCPU per hour on top of the machine
+	WindowsServerCpuPrice float64
 }
 
 type AutopilotPriceList struct {
@@ -82,7 +231,7 @@ type AutopilotPriceList struct {
 	SpotArmCpuScaleoutPrice    float64
 	SpotArmMemoryScaleoutPrice float64
 
-	// gpu pricing
+	// gpu pricing, see gpuModels
 	GPUPodvCPUPrice              float64
 	GPUPodMemoryPrice            float64
 	GPUPodLocalSSDPrice          float64
@@ -119,6 +268,9 @@ type AutopilotPriceList struct {
 	AcceleratorA10040GGPUPricePremium     float64
 	AcceleratorA10080GGPUPricePremium     float64
 	AcceleratorH100GPUPricePremium        float64
+	AcceleratorH200GPUPricePremium        float64
+	AcceleratorB200GPUPricePremium        float64
+	AcceleratorGB200GPUPricePremium       float64
 	SpotAcceleratorCpuPricePremium        float64
 	SpotAcceleratorMemoryGPUPricePremium  float64
 	SpotAcceleratorPDPricePremium         float64
@@ -128,9 +280,98 @@ type AutopilotPriceList struct {
 	SpotAcceleratorA10040GGPUPricePremium float64
 	SpotAcceleratorA10080GGPUPricePremium float64
 	SpotAcceleratorH100GPUPricePremium    float64
+	SpotAcceleratorH200GPUPricePremium    float64
+	SpotAcceleratorB200GPUPricePremium    float64
+	SpotAcceleratorGB200GPUPricePremium   float64
+
+	// tpu chips, see tpuModels
+	TPUV4PodChipPrice      float64
+	TPUV5ePodChipPrice     float64
+	TPUV5pPodChipPrice     float64
+	TPUV6ePodChipPrice     float64
+	SpotTPUV4PodChipPrice  float64
+	SpotTPUV5ePodChipPrice float64
+	SpotTPUV5pPodChipPrice float64
+	SpotTPUV6ePodChipPrice float64
+
+	// committed use pricing, per vCPU and GB like the on-demand requests
+	CommitmentOneYearCpuPrice              float64
+	CommitmentOneYearMemoryPrice           float64
+	CommitmentThreeYearCpuPrice            float64
+	CommitmentThreeYearMemoryPrice         float64
+	CommitmentOneYearCpuBalancedPrice      float64
+	CommitmentOneYearMemoryBalancedPrice   float64
+	CommitmentThreeYearCpuBalancedPrice    float64
+	CommitmentThreeYearMemoryBalancedPrice float64
+	CommitmentOneYearCpuScaleoutPrice      float64
+	CommitmentOneYearMemoryScaleoutPrice   float64
+	CommitmentThreeYearCpuScaleoutPrice    float64
+	CommitmentThreeYearMemoryScaleoutPrice float64
+	CommitmentOneYearGPUPodvCPUPrice       float64
+	CommitmentOneYearGPUPodMemoryPrice     float64
+	CommitmentThreeYearGPUPodvCPUPrice     float64
+	CommitmentThreeYearGPUPodMemoryPrice   float64
 }
 
-func GetGCEPricing(sku string, region string) (GCEPriceList, error) {
+// applyAutopilotCommitment sets the price of a committed use SKU, eg. "Commitment v1: Autopilot Balanced Pod mCPU
+// Requests in us-central1 for 1 Year". Commitments are billed per resource of a compute class like on-demand requests,
+// at a discount that differs between the classes.
+func applyAutopilotCommitment(pricing *AutopilotPriceList, description string, oneYear bool, price float64) {
+	setPrice := func(oneYearPrice *float64, threeYearPrice *float64) {
+		if oneYear {
+			*oneYearPrice = price
+		} else {
+			*threeYearPrice = price
+		}
+	}
+
+	switch {
+	case strings.Contains(description, "Autopilot Pod mCPU Requests"):
+		setPrice(&pricing.CommitmentOneYearCpuPrice, &pricing.CommitmentThreeYearCpuPrice)
+	case strings.Contains(description, "Autopilot Pod Memory Requests"):
+		setPrice(&pricing.CommitmentOneYearMemoryPrice, &pricing.CommitmentThreeYearMemoryPrice)
+	case strings.Contains(description, "Autopilot Balanced Pod mCPU Requests"):
+		setPrice(&pricing.CommitmentOneYearCpuBalancedPrice, &pricing.CommitmentThreeYearCpuBalancedPrice)
+	case strings.Contains(description, "Autopilot Balanced Pod Memory Requests"):
+		setPrice(&pricing.CommitmentOneYearMemoryBalancedPrice, &pricing.CommitmentThreeYearMemoryBalancedPrice)
+	case strings.Contains(description, "Autopilot Scale-Out x86 Pod mCPU Requests"):
+		setPrice(&pricing.CommitmentOneYearCpuScaleoutPrice, &pricing.CommitmentThreeYearCpuScaleoutPrice)
+	case strings.Contains(description, "Autopilot Scale-Out x86 Pod Memory Requests"):
+		setPrice(&pricing.CommitmentOneYearMemoryScaleoutPrice, &pricing.CommitmentThreeYearMemoryScaleoutPrice)
+	case strings.Contains(description, "Autopilot NVIDIA") && strings.Contains(description, "Pod mCPU Requests"):
+		setPrice(&pricing.CommitmentOneYearGPUPodvCPUPrice, &pricing.CommitmentThreeYearGPUPodvCPUPrice)
+	case strings.Contains(description, "Autopilot NVIDIA") && strings.Contains(description, "Pod Memory Requests"):
+		setPrice(&pricing.CommitmentOneYearGPUPodMemoryPrice, &pricing.CommitmentThreeYearGPUPodMemoryPrice)
+	}
+}
+
+// GetSkuPrice returns the unit price of the SKU. Tiered SKUs often start with a free tier, eg. the first GiB
+// of egress, so the price is the rate of the first paid tier: the usage the estimate covers is well past the
+// free allowance. It reports false if the SKU has no rates.
+func GetSkuPrice(sku *cloudbilling.Sku) (float64, bool) {
+	if len(sku.PricingInfo) == 0 || sku.PricingInfo[0].PricingExpression == nil || len(sku.PricingInfo[0].PricingExpression.TieredRates) == 0 {
+		return 0, false
+	}
+	expression := sku.PricingInfo[0].PricingExpression
+
+	rate := expression.TieredRates[0]
+	for _, tier := range expression.TieredRates {
+		if tier.UnitPrice != nil && (tier.UnitPrice.Units > 0 || tier.UnitPrice.Nanos > 0) {
+			rate = tier
+			break
+		}
+	}
+	if rate.UnitPrice == nil {
+		return 0, true
+	}
+
+	decimal := rate.UnitPrice.Units * 1000000000
+	mantissa := rate.UnitPrice.Nanos * int64(expression.DisplayQuantity)
+
+	return float64(decimal+mantissa) / 1000000000, true
+}
+
+func GetGCEPricing(skus []*cloudbilling.Sku, region string, mapping map[string]string) GCEPriceList {
 	pricing := GCEPriceList{
 		Region:         region,
 		H3CpuPrice:     0,
@@ -158,107 +399,71 @@ func GetGCEPricing(sku string, region string) (GCEPriceList, error) {
 		SpotA2MemoryPrice:  0,
 		SpotA3CpuPrice:     0,
 		SpotA3MemoryPrice:  0,
-	}
 
-	// If the "region" is actual "zone", we need to remove the zone to get the pricing for the whole region.
-	if len(strings.Split(region, "-")) > 2 {
-		region = strings.Join(
-			strings.Split(region, "-")[:len(
-				strings.Split(
-					region,
-					"-",
-				),
-			)-1],
-			"-",
-		)
-	}
+		ConfidentialCpuPrice:        0,
+		ConfidentialMemoryPrice:     0,
+		SpotConfidentialCpuPrice:    0,
+		SpotConfidentialMemoryPrice: 0,
+
+		HyperdiskBalancedCapacityPrice:   0,
+		HyperdiskBalancedIopsPrice:       0,
+		HyperdiskBalancedThroughputPrice: 0,
+		HyperdiskExtremeCapacityPrice:    0,
+		HyperdiskExtremeIopsPrice:        0,
 
-	ctx := context.Background()
+		CommitmentOneYearCpuPrice:      0,
+		CommitmentOneYearMemoryPrice:   0,
+		CommitmentThreeYearCpuPrice:    0,
+		CommitmentThreeYearMemoryPrice: 0,
 
-	cloudbillingService, err := cloudbilling.NewService(ctx, option.WithScopes(cloudbilling.CloudPlatformScope))
-	if err != nil {
-		err = fmt.Errorf("unable to initialize cloud billing service: %v", err)
-		return GCEPriceList{}, err
+		WindowsServerCpuPrice: 0,
 	}
 
-	err = cloudbillingService.Services.Skus.List("services/"+sku).CurrencyCode("USD").Pages(ctx, func(pricingInfo *cloudbilling.ListSkusResponse) error {
-		for _, sku := range pricingInfo.Skus {
-			if !slices.Contains(sku.ServiceRegions, region) {
-				continue
-			}
-
-			decimal := sku.PricingInfo[0].PricingExpression.TieredRates[0].UnitPrice.Units * 1000000000
-			mantissa := sku.PricingInfo[0].PricingExpression.TieredRates[0].UnitPrice.Nanos * int64(sku.PricingInfo[0].PricingExpression.DisplayQuantity)
-
-			price := float64(decimal+mantissa) / 1000000000
-
-			switch {
-			case strings.HasPrefix(sku.Description, "H3 Instance Core"):
-				pricing.H3CpuPrice = price
-			case strings.HasPrefix(sku.Description, "H3 Instance Ram"):
-				pricing.H3MemoryPrice = price
-
-			case strings.HasPrefix(sku.Description, "Compute optimized Instance Core"):
-				pricing.C2CpuPrice = price
-			case strings.HasPrefix(sku.Description, "Compute optimized Instance Ram"):
-				pricing.C2MemoryPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible Compute optimized Instance Core"):
-				pricing.SpotC2CpuPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible Compute optimized Instance Ram"):
-
-				pricing.SpotC2MemoryPrice = price
-			case strings.HasPrefix(sku.Description, "C2D AMD Instance Core"):
-				pricing.C2DCpuPrice = price
-			case strings.HasPrefix(sku.Description, "C2D AMD Instance Ram"):
-				pricing.C2DMemoryPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible C2D AMD Instance Core"):
-				pricing.SpotC2DCpuPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible C2D AMD Instance Ram"):
-				pricing.SpotC2DMemoryPrice = price
-
-			case strings.HasPrefix(sku.Description, "G2 Instance Core"):
-				pricing.G2CpuPrice = price
-			case strings.HasPrefix(sku.Description, "G2 Instance Ram"):
-				pricing.G2MemoryPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible G2 Instance Core"):
-				pricing.SpotG2DCpuPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible G2 Instance Ram"):
-				pricing.SpotG2DMemoryPrice = price
-
-			case strings.HasPrefix(sku.Description, "A2 Instance Core"):
-				pricing.A2CpuPrice = price
-			case strings.HasPrefix(sku.Description, "A2 Instance Ram"):
-				pricing.A2MemoryPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible A2 Instance Core"):
-				pricing.SpotA2CpuPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible A2 Instance Ram"):
-				pricing.SpotA2MemoryPrice = price
-
-			case strings.HasPrefix(sku.Description, "A3 Instance Core"):
-				pricing.A3CpuPrice = price
-			case strings.HasPrefix(sku.Description, "A3 Instance Ram"):
-				pricing.A3MemoryPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible A3 Instance Core"):
-				pricing.SpotA3CpuPrice = price
-			case strings.HasPrefix(sku.Description, "Spot Preemptible A3 Instance Ram"):
-				pricing.SpotA3MemoryPrice = price
-
-			}
+	// If the "region" is actual "zone", we need to remove the zone to get the pricing for the whole region.
+	region = location.Region(region)
 
+	for _, sku := range skus {
+		// Windows Server licenses are global, they cost the same in every region
+		windowsLicense := strings.HasPrefix(sku.Description, "Licensing Fee for Windows Server") && strings.HasSuffix(sku.Description, "(CPU cost)")
+		if !slices.Contains(sku.ServiceRegions, region) && !windowsLicense {
+			continue
 		}
 
-		return nil
-	})
+		price, ok := GetSkuPrice(sku)
+		if !ok {
+			logging.Debug("SKU %s %q has no price", sku.SkuId, sku.Description)
+			continue
+		}
+		logging.Debug("SKU %s %q in %s: %v", sku.SkuId, sku.Description, region, price)
 
-	if err != nil {
-		err = fmt.Errorf("unable to fetch gce cloud billing information: %v", err)
-		return GCEPriceList{}, err
+		// A user supplied mapping overrides the built-in matching
+		if applySkuMapping(&pricing, mapping, sku, price) {
+			continue
+		}
+
+		switch {
+		case windowsLicense:
+			pricing.WindowsServerCpuPrice = price
+
+		// Confidential Computing SKUs are named after the machine family, so they have to be matched before it
+		case strings.Contains(sku.Description, "Confidential") && strings.HasPrefix(sku.Description, "Spot Preemptible") && strings.Contains(sku.Description, "Core"):
+			pricing.SpotConfidentialCpuPrice = price
+		case strings.Contains(sku.Description, "Confidential") && strings.HasPrefix(sku.Description, "Spot Preemptible") && strings.Contains(sku.Description, "Ram"):
+			pricing.SpotConfidentialMemoryPrice = price
+		case strings.Contains(sku.Description, "Confidential") && strings.Contains(sku.Description, "Core"):
+			pricing.ConfidentialCpuPrice = price
+		case strings.Contains(sku.Description, "Confidential") && strings.Contains(sku.Description, "Ram"):
+			pricing.ConfidentialMemoryPrice = price
+
+		default:
+			pricing.ApplySku(sku, price)
+		}
 	}
 
-	return pricing, nil
+	return pricing
 }
 
-func GetAutopilotPricing(sku string, region string) (AutopilotPriceList, error) {
+func GetAutopilotPricing(skus []*cloudbilling.Sku, region string, mapping map[string]string) AutopilotPriceList {
 	// Init all to zeroes
 	pricing := AutopilotPriceList{
 		Region:                     region,
@@ -325,197 +530,39 @@ func GetAutopilotPricing(sku string, region string) (AutopilotPriceList, error)
 	}
 
 	// If the "region" is actual "zone", we need to remove the zone to get the pricing for the whole region.
-	if len(strings.Split(region, "-")) > 2 {
-		region = strings.Join(
-			strings.Split(region, "-")[:len(
-				strings.Split(
-					region,
-					"-",
-				),
-			)-1],
-			"-",
-		)
-	}
+	region = location.Region(region)
 
-	ctx := context.Background()
+	acceleratorMapping := acceleratorSkuMapping(region)
 
-	cloudbillingService, err := cloudbilling.NewService(ctx, option.WithScopes(cloudbilling.CloudPlatformScope))
-	if err != nil {
-		err = fmt.Errorf("unable to initialize cloud billing service: %v", err)
-		return AutopilotPriceList{}, err
-	}
+	for _, sku := range skus {
+		if !slices.Contains(sku.ServiceRegions, region) {
+			continue
+		}
+
+		price, ok := GetSkuPrice(sku)
+		if !ok {
+			logging.Debug("SKU %s %q has no price", sku.SkuId, sku.Description)
+			continue
+		}
+		logging.Debug("SKU %s %q in %s: %v", sku.SkuId, sku.Description, region, price)
+
+		// A user supplied mapping overrides the built-in matching
+		if applySkuMapping(&pricing, mapping, sku, price) {
+			continue
+		}
+
+		// GPUs and TPUs are matched by their data tables
+		if applySkuMapping(&pricing, acceleratorMapping, sku, price) {
+			continue
+		}
 
-	err = cloudbillingService.Services.Skus.List("services/"+sku).CurrencyCode("USD").Pages(ctx, func(pricingInfo *cloudbilling.ListSkusResponse) error {
-		for _, sku := range pricingInfo.Skus {
-			if !slices.Contains(sku.ServiceRegions, region) {
-				continue
-			}
-
-			decimal := sku.PricingInfo[0].PricingExpression.TieredRates[0].UnitPrice.Units * 1000000000
-			mantissa := sku.PricingInfo[0].PricingExpression.TieredRates[0].UnitPrice.Nanos * int64(sku.PricingInfo[0].PricingExpression.DisplayQuantity)
-
-			price := float64(decimal+mantissa) / 1000000000
-
-			switch sku.Description {
-			case "Autopilot Pod Ephemeral Storage Requests (" + region + ")":
-				pricing.StoragePrice = price
-
-			case "Autopilot Pod Memory Requests (" + region + ")":
-				pricing.MemoryPrice = price
-
-			case "Autopilot Pod mCPU Requests (" + region + ")":
-				pricing.CpuPrice = price
-
-			case "Autopilot Balanced Pod Memory Requests (" + region + ")":
-				pricing.MemoryBalancedPrice = price
-
-			case "Autopilot Balanced Pod mCPU Requests (" + region + ")":
-				pricing.CpuBalancedPrice = price
-
-			case "Autopilot Scale-Out x86 Pod Memory Requests (" + region + ")":
-				pricing.MemoryScaleoutPrice = price
-
-			case "Autopilot Scale-Out x86 Pod mCPU Requests (" + region + ")":
-				pricing.CpuScaleoutPrice = price
-
-			case "Autopilot Scale-Out Arm Spot Pod Memory Requests (" + region + ")":
-				pricing.MemoryArmScaleoutPrice = price
-
-			case "Autopilot Scale-Out Arm Spot Pod mCPU Requests (" + region + ")":
-				pricing.CpuArmScaleoutPrice = price
-
-			case "Autopilot Spot Pod Memory Requests (" + region + ")":
-				pricing.SpotMemoryPrice = price
-
-			case "Autopilot Spot Pod mCPU Requests (" + region + ")":
-				pricing.SpotCpuPrice = price
-
-			case "Autopilot Balanced Spot Pod Memory Requests (" + region + ")":
-				pricing.SpotMemoryBalancedPrice = price
-
-			case "Autopilot Balanced Spot Pod mCPU Requests (" + region + ")":
-				pricing.SpotCpuBalancedPrice = price
-
-			case "Autopilot Scale-Out x86 Spot Pod Memory Requests (" + region + ")":
-				pricing.SpotMemoryScaleoutPrice = price
-
-			case "Autopilot Scale-Out x86 Spot Pod mCPU Requests (" + region + ")":
-				pricing.SpotCpuScaleoutPrice = price
-
-			case "Autopilot Scale-Out Arm Spot Pod Memory Requests (" + region + ")":
-				pricing.SpotArmMemoryScaleoutPrice = price
-
-			case "Autopilot Scale-Out Arm Spot Pod mCPU Requests (" + region + ")":
-				pricing.SpotArmCpuScaleoutPrice = price
-
-			case "Autopilot NVIDIA T4 Pod mCPU Requests (" + region + ")":
-			case "Autopilot NVIDIA L4 Pod mCPU Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 Pod mCPU Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 80GB Pod mCPU Requests (" + region + ")":
-				pricing.GPUPodvCPUPrice = price
-			case "Autopilot NVIDIA T4 Pod Memory Requests (" + region + ")":
-			case "Autopilot NVIDIA L4 Pod Memory Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 Pod Memory Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 80GB Pod Memory Requests (" + region + ")":
-				pricing.GPUPodMemoryPrice = price
-			case "Autopilot NVIDIA T4 Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAT4PodGPUPrice = price
-			case "Autopilot NVIDIA L4 Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAL4PodGPUPrice = price
-			case "Autopilot NVIDIA A100 Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAA10040GPodGPUPrice = price
-			case "Autopilot NVIDIA A100 80GB Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAA10080GPodGPUPrice = price
-			case "Autopilot GPU Pod Local SSD (" + region + ")":
-				pricing.SpotGPUPodLocalSSDPrice = price
-
-			case "Autopilot NVIDIA T4 Spot Pod mCPU Requests (" + region + ")":
-			case "Autopilot NVIDIA L4 Spot Pod mCPU Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 Spot Pod mCPU Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 80GB Spot Pod mCPU Requests (" + region + ")":
-				pricing.GPUPodvCPUPrice = price
-			case "Autopilot NVIDIA T4 Spot Pod Memory Requests (" + region + ")":
-			case "Autopilot NVIDIA L4 Spot Pod Memory Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 Spot Pod Memory Requests (" + region + ")":
-			case "Autopilot NVIDIA A100 80GB Spot Pod Memory Requests (" + region + ")":
-				pricing.GPUPodMemoryPrice = price
-			case "Autopilot NVIDIA T4 Spot Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAT4PodGPUPrice = price
-			case "Autopilot NVIDIA L4 Spot Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAL4PodGPUPrice = price
-			case "Autopilot NVIDIA A100 Spot Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAA10040GPodGPUPrice = price
-			case "Autopilot NVIDIA A100 80GB Spot Pod GPU Requests (" + region + ")":
-				pricing.NVIDIAA10080GPodGPUPrice = price
-			case "Autopilot GPU Spot Pod Local SSD (" + region + ")":
-				pricing.SpotGPUPodLocalSSDPrice = price
-
-			case "Autopilot PD Balanced Premium (" + region + ")":
-				pricing.PerformancePDPricePremium = price
-				pricing.SpotPerformancePDPricePremium = price
-				pricing.AcceleratorPDPricePremium = price
-				pricing.SpotAcceleratorPDPricePremium = price
-
-			case "Autopilot Performance CPU Premium (" + region + ")":
-				pricing.PerformanceCpuPricePremium = price
-			case "Autopilot Performance Memory Premium (" + region + ")":
-				pricing.PerformanceMemoryPricePremium = price
-			case "Autopilot Local SSD Premium (" + region + ")":
-				pricing.PerformanceLocalSSDPricePremium = price
-				pricing.AcceleratorLocalSSDPricePremium = price
-
-			case "Autopilot Spot PD Balanced Premium (" + region + ")":
-				pricing.PerformancePDPricePremium = price
-				pricing.SpotPerformancePDPricePremium = price
-				pricing.AcceleratorPDPricePremium = price
-				pricing.SpotAcceleratorPDPricePremium = price
-
-			case "Autopilot Performance Spot CPU Premium (" + region + ")":
-				pricing.SpotPerformanceCpuPricePremium = price
-			case "Autopilot Performance Spot Memory Premium (" + region + ")":
-				pricing.SpotPerformanceMemoryPricePremium = price
-			case "Autopilot Local SSD Spot Premium (" + region + ")":
-				pricing.SpotPerformanceLocalSSDPricePremium = price
-				pricing.SpotAcceleratorLocalSSDPricePremium = price
-
-			case "Autopilot Accelerator CPU Premium (" + region + ")":
-				pricing.AcceleratorCpuPricePremium = price
-			case "Autopilot Accelerator Memory Premium (" + region + ")":
-				pricing.AcceleratorMemoryGPUPricePremium = price
-			case "Autopilot T4 Premium (" + region + ")":
-				pricing.AcceleratorT4GPUPricePremium = price
-			case "Autopilot L4 Premium (" + region + ")":
-				pricing.AcceleratorL4GPUPricePremium = price
-			case "Autopilot A100 40GB Premium (" + region + ")":
-				pricing.AcceleratorA10040GGPUPricePremium = price
-			case "Autopilot A100 80GB Premium (" + region + ")":
-				pricing.AcceleratorA10080GGPUPricePremium = price
-			case "Autopilot H100 80GB Premium (" + region + ")":
-				pricing.AcceleratorH100GPUPricePremium = price
-
-			case "Autopilot Accelerator Spot CPU Premium (" + region + ")":
-				pricing.SpotAcceleratorCpuPricePremium = price
-			case "Autopilot Accelerator Spot Memory Premium (" + region + ")":
-				pricing.SpotAcceleratorMemoryGPUPricePremium = price
-			case "Autopilot T4 Spot Premium (" + region + ")":
-				pricing.SpotAcceleratorT4GPUPricePremium = price
-			case "Autopilot L4 Spot Premium (" + region + ")":
-				pricing.SpotAcceleratorL4GPUPricePremium = price
-			case "Autopilot A100 40GB Spot Premium (" + region + ")":
-				pricing.SpotAcceleratorA10040GGPUPricePremium = price
-			case "Autopilot A100 80GB Spot Premium (" + region + ")":
-				pricing.SpotAcceleratorA10080GGPUPricePremium = price
-			case "Autopilot H100 80GB Spot Premium (" + region + ")":
-				pricing.SpotAcceleratorH100GPUPricePremium = price
-			}
+		if sku.Category != nil && (sku.Category.UsageType == USAGE_ONE_YEAR || sku.Category.UsageType == USAGE_THREE_YEAR) {
+			applyAutopilotCommitment(&pricing, sku.Description, sku.Category.UsageType == USAGE_ONE_YEAR, price)
+			continue
 		}
-		return nil
-	})
 
-	if err != nil {
-		err = fmt.Errorf("unable to fetch autopilot cloud billing information: %v", err)
-		return AutopilotPriceList{}, err
+		pricing.ApplySku(sku, price)
 	}
 
-	return pricing, nil
+	return pricing
 }
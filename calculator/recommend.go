@@ -0,0 +1,103 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"sort"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"golang.org/x/exp/slices"
+)
+
+// recommendedClasses are the compute classes any x86 workload without GPUs can be moved to by a
+// compute class selector alone.
+var recommendedClasses = []cluster.ComputeClass{
+	cluster.ComputeClassGeneralPurpose,
+	cluster.ComputeClassBalanced,
+	cluster.ComputeClassScaleout,
+}
+
+// ClassRecommendation is a compute class that is cheaper for the workload than the class it was mapped to.
+type ClassRecommendation struct {
+	Name         string
+	Namespace    string
+	Current      cluster.ComputeClass
+	CurrentCost  cluster.Money
+	Cheapest     cluster.ComputeClass
+	CheapestCost cluster.Money
+	Savings      cluster.Money
+}
+
+// IsEligibleForClass reports if the CPU to memory ratio and the size of the workload are within the
+// [ratios] and [limits] of the compute class.
+func (service *PricingService) IsEligibleForClass(class cluster.ComputeClass, mCPU int64, memory int64) bool {
+	return service.getClassLimits(class, "").allows(mCPU, memory)
+}
+
+// GetClassRecommendations prices every workload in each compute class it is eligible for and returns the
+// workloads that would be cheaper in another class, by savings. Only the compute resources are compared,
+// disks and egress cost the same in every class. Workloads with GPUs, on Arm, on flex-start capacity or in
+// the Performance and Accelerator classes are pinned to their hardware and not evaluated.
+func (service *PricingService) GetClassRecommendations(nodes map[string]cluster.Node) []ClassRecommendation {
+	// Keep the warnings of the workloads intact
+	warnings := service.warnings
+	defer func() { service.warnings = warnings }()
+
+	recommendations := []ClassRecommendation{}
+	for _, node := range cluster.SortedNodes(nodes) {
+		for _, workload := range node.Workloads {
+			if workload.AcceleratorAmount > 0 || workload.FlexStart || !slices.Contains(recommendedClasses, workload.ComputeClass) {
+				continue
+			}
+
+			// The requests are adjusted to the minimums, mCPU step and ratio of every class they are priced in
+			price := func(class cluster.ComputeClass) cluster.Money {
+				cpu, memory := service.AdjustResources(class, "", workload.Cpu, workload.Memory)
+				return cluster.NewMoney(service.CalculatePricing(PriceRequest{Cpu: cpu, Memory: memory, Storage: workload.Storage, Class: class, InstanceType: node.InstanceType, Spot: node.Spot || workload.Spot}))
+			}
+
+			recommendation := ClassRecommendation{
+				Name:        workload.Name,
+				Namespace:   workload.Namespace,
+				Current:     workload.ComputeClass,
+				CurrentCost: price(workload.ComputeClass),
+			}
+			recommendation.Cheapest, recommendation.CheapestCost = recommendation.Current, recommendation.CurrentCost
+
+			for _, class := range recommendedClasses {
+				if class == workload.ComputeClass || !service.IsEligibleForClass(class, workload.Cpu, workload.Memory) {
+					continue
+				}
+
+				// No price in the region
+				cost := price(class)
+				if cost > 0 && cost < recommendation.CheapestCost {
+					recommendation.Cheapest, recommendation.CheapestCost = class, cost
+				}
+			}
+
+			if recommendation.Cheapest != recommendation.Current {
+				recommendation.Savings = recommendation.CurrentCost - recommendation.CheapestCost
+				recommendations = append(recommendations, recommendation)
+			}
+		}
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].Savings > recommendations[j].Savings
+	})
+
+	return recommendations
+}
@@ -0,0 +1,145 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/retry"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"google.golang.org/api/cloudbilling/v1"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// billingServices are the display names of the Cloud Billing services the prices are fetched from, by their
+// key in the service IDs of NewService.
+var billingServices = map[string]string{
+	"autopilot": "Kubernetes Engine",
+	"gce":       "Compute Engine",
+}
+
+var discoveredServices struct {
+	sync.Mutex
+	ids map[string]string
+}
+
+// FindServiceIds picks the IDs of the billing services from the services of the catalog, eg. 6F81-5844-456A
+// for Compute Engine.
+func FindServiceIds(services []*cloudbilling.Service) map[string]string {
+	ids := make(map[string]string)
+	for _, service := range services {
+		for key, name := range billingServices {
+			if service.DisplayName == name {
+				ids[key] = strings.TrimPrefix(service.Name, "services/")
+			}
+		}
+	}
+	return ids
+}
+
+// newBillingService returns a Cloud Billing client that retries the transient failures of the catalog.
+func newBillingService(ctx context.Context) (*cloudbilling.APIService, error) {
+	client, err := retry.HTTPClient(ctx, usage.Billing, cloudbilling.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize cloud billing service: %v", err)
+	}
+
+	cloudbillingService, err := cloudbilling.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize cloud billing service: %v", err)
+	}
+	return cloudbillingService, nil
+}
+
+// newComputeService returns a Compute Engine client that retries the transient failures of the API.
+func newComputeService(ctx context.Context) (*compute.Service, error) {
+	client, err := retry.HTTPClient(ctx, usage.Compute, compute.ComputeReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize compute engine service: %v", err)
+	}
+
+	computeService, err := compute.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize compute engine service: %v", err)
+	}
+	return computeService, nil
+}
+
+// DiscoverServiceIds lists the public services of the Cloud Billing Catalog to find the IDs of the billing
+// services. They don't change, so the catalog is only listed once per process.
+func DiscoverServiceIds(ctx context.Context) (map[string]string, error) {
+	discoveredServices.Lock()
+	defer discoveredServices.Unlock()
+
+	if discoveredServices.ids != nil {
+		return discoveredServices.ids, nil
+	}
+
+	cloudbillingService, err := newBillingService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*cloudbilling.Service
+	err = cloudbillingService.Services.List().Pages(ctx, func(response *cloudbilling.ListServicesResponse) error {
+		usage.Count(usage.Billing)
+		services = append(services, response.Services...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing billing services: %v", err)
+	}
+
+	ids := FindServiceIds(services)
+	for key, name := range billingServices {
+		if ids[key] == "" {
+			return nil, fmt.Errorf("billing service %q not found in the catalog, set the %s_sku service ID in config.ini", name, key)
+		}
+		logging.Debug("Billing service %q has ID %s.", name, ids[key])
+	}
+
+	discoveredServices.ids = ids
+	return ids, nil
+}
+
+// resolveServiceIds keeps the configured service IDs and discovers the others.
+func resolveServiceIds(ctx context.Context, configured map[string]string) (map[string]string, error) {
+	ids := make(map[string]string)
+	missing := false
+	for key := range billingServices {
+		ids[key] = configured[key]
+		missing = missing || ids[key] == ""
+	}
+	if !missing {
+		return ids, nil
+	}
+
+	discovered, err := DiscoverServiceIds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for key, id := range discovered {
+		if ids[key] == "" {
+			ids[key] = id
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// Sidecar modes decide how containers injected next to the application, eg. by a service mesh, are priced:
+// as part of the workload, not at all, or as part of the workload with their cost shown separately.
+const (
+	SIDECARS_INCLUDE  = "include"
+	SIDECARS_EXCLUDE  = "exclude"
+	SIDECARS_SEPARATE = "separate"
+)
+
+// IsSidecar checks if the container is one of the injected sidecars listed in the names key of the [sidecars] section.
+func (service *PricingService) IsSidecar(containerName string) bool {
+	for _, name := range service.Config.Section("sidecars").Key("names").Strings(",") {
+		if strings.TrimSpace(name) == containerName {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSidecarCost prices the resources of the sidecars of a workload in its compute class, the part of the workload
+// cost that is sidecar overhead. It is capped at the cost of the workload, eg. for Pods billed by the node.
+func (service *PricingService) GetSidecarCost(cpu int64, memory int64, storage int64, class cluster.ComputeClass, instanceType string, spot bool, cost cluster.Money) cluster.Money {
+	if cpu == 0 && memory == 0 && storage == 0 {
+		return 0
+	}
+
+	// Keep the warnings of the workload being priced intact
+	warnings := service.warnings
+	defer func() { service.warnings = warnings }()
+
+	sidecarCost := cluster.NewMoney(service.CalculatePricing(PriceRequest{Cpu: cpu, Memory: memory, Storage: storage, Class: class, InstanceType: instanceType, Spot: spot}))
+	if sidecarCost > cost {
+		return cost
+	}
+	return sidecarCost
+}
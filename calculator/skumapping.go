@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"google.golang.org/api/cloudbilling/v1"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+)
+
+// SkuMapping maps SKU IDs or SKU description prefixes to the name of a price list field,
+// for SKUs the built-in matching doesn't know, eg.:
+//
+//	{"GCE": {"C2D AMD Instance Core running in Sydney": "C2DCpuPrice"}, "Autopilot": {"ABCD-1234-EF56": "CpuPrice"}}
+type SkuMapping struct {
+	Autopilot map[string]string
+	GCE       map[string]string
+}
+
+// LoadSkuMapping reads a SKU mapping file and checks that all mapped fields exist in the price lists.
+func LoadSkuMapping(path string) (SkuMapping, error) {
+	var mapping SkuMapping
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("error reading sku mapping file: %v", err)
+		return SkuMapping{}, err
+	}
+
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		err = fmt.Errorf("error parsing sku mapping file: %v", err)
+		return SkuMapping{}, err
+	}
+
+	if err := validateSkuMapping(reflect.TypeOf(AutopilotPriceList{}), mapping.Autopilot); err != nil {
+		return SkuMapping{}, err
+	}
+	if err := validateSkuMapping(reflect.TypeOf(GCEPriceList{}), mapping.GCE); err != nil {
+		return SkuMapping{}, err
+	}
+
+	return mapping, nil
+}
+
+func validateSkuMapping(priceList reflect.Type, mapping map[string]string) error {
+	for sku, field := range mapping {
+		structField, ok := priceList.FieldByName(field)
+		if !ok || structField.Type.Kind() != reflect.Float64 {
+			return fmt.Errorf("sku %q is mapped to %s, which is not a price of %s", sku, field, priceList.Name())
+		}
+	}
+
+	return nil
+}
+
+// applySkuMapping sets the price of the SKU on the mapped field of the price list. An exact SKU ID
+// wins over description prefixes and the longest matching prefix wins over shorter ones.
+// It reports false if the SKU is not mapped, so the built-in matching can be used.
+func applySkuMapping(priceList interface{}, mapping map[string]string, sku *cloudbilling.Sku, price float64) bool {
+	field, ok := mapping[sku.SkuId]
+	if !ok {
+		matched := ""
+		for prefix, prefixField := range mapping {
+			if strings.HasPrefix(sku.Description, prefix) && len(prefix) > len(matched) {
+				matched, field = prefix, prefixField
+			}
+		}
+		if matched == "" {
+			return false
+		}
+	}
+
+	logging.Debug("SKU %s %q matched %s by the SKU mapping: %v", sku.SkuId, sku.Description, field, price)
+	reflect.ValueOf(priceList).Elem().FieldByName(field).SetFloat(price)
+
+	return true
+}
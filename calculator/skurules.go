@@ -0,0 +1,294 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"google.golang.org/api/cloudbilling/v1"
+)
+
+// Usage types of the billing SKU categories.
+const (
+	USAGE_ON_DEMAND  = "OnDemand"
+	USAGE_SPOT       = "Preemptible"
+	USAGE_ONE_YEAR   = "Commit1Yr"
+	USAGE_THREE_YEAR = "Commit3Yr"
+)
+
+// skuRule matches billing SKUs to the price list fields of their usage types. A SKU whose ID is in SkuIds
+// matches the rule whatever its description. Other SKUs are matched by their category first: its usage
+// type tells on-demand, Spot and committed use prices apart and its resource family and group keep eg.
+// disks and machines apart. Within those the SKU is matched by the start of its description without the
+// Spot wording, eg. "N2 Instance Core", so the rules don't depend on how Spot or commitments are spelled.
+// An empty ResourceFamily or ResourceGroup matches any category, as does a SKU without one. SKUs the rules
+// miss can be pinned by their ID with the sku mapping.
+type skuRule struct {
+	ResourceFamily string
+	ResourceGroup  string
+	Description    string
+	OnDemand       string
+	Spot           string
+	OneYear        string
+	ThreeYear      string
+	// SkuIds are the IDs of SKUs of the rule, which keep matching when the SKU is renamed. The IDs differ
+	// per region, so the description stays the fallback for the SKUs that are not listed.
+	SkuIds []string
+}
+
+// hasSkuId checks if the SKU ID is one of the IDs of the rule.
+func (rule skuRule) hasSkuId(skuId string) bool {
+	for _, id := range rule.SkuIds {
+		if id == skuId {
+			return true
+		}
+	}
+	return false
+}
+
+// field is the price list field of the usage type, empty if the rule has none.
+func (rule skuRule) field(usageType string) string {
+	switch usageType {
+	case USAGE_ON_DEMAND:
+		return rule.OnDemand
+	case USAGE_SPOT:
+		return rule.Spot
+	case USAGE_ONE_YEAR:
+		return rule.OneYear
+	case USAGE_THREE_YEAR:
+		return rule.ThreeYear
+	}
+	return ""
+}
+
+// getSkuUsage returns the usage type of the SKU and its description without the Spot wording, eg.
+// "Spot Preemptible N2 Instance Core running in Americas" is Preemptible "N2 Instance Core running in Americas".
+// SKUs without a category fall back to the wording of their description.
+func getSkuUsage(sku *cloudbilling.Sku) (string, string) {
+	description := sku.Description
+	spot := strings.HasPrefix(description, "Spot Preemptible ") || strings.Contains(description, " Spot ")
+	description = strings.TrimPrefix(description, "Spot Preemptible ")
+	description = strings.Replace(description, " Spot ", " ", 1)
+
+	if sku.Category != nil && sku.Category.UsageType != "" {
+		if sku.Category.UsageType == USAGE_ON_DEMAND && spot {
+			return USAGE_SPOT, description
+		}
+		return sku.Category.UsageType, description
+	}
+
+	switch {
+	case spot:
+		return USAGE_SPOT, description
+	case strings.HasSuffix(description, "1 Year"):
+		return USAGE_ONE_YEAR, description
+	case strings.HasSuffix(description, "3 Year"):
+		return USAGE_THREE_YEAR, description
+	}
+	return USAGE_ON_DEMAND, description
+}
+
+// matchSkuRules returns the fields the SKU is priced on, of the rules that list its ID or else of the rules
+// with the longest matching description.
+func matchSkuRules(rules []skuRule, sku *cloudbilling.Sku) []string {
+	usageType, description := getSkuUsage(sku)
+
+	var idFields []string
+	for _, rule := range rules {
+		if field := rule.field(usageType); field != "" && sku.SkuId != "" && rule.hasSkuId(sku.SkuId) {
+			idFields = append(idFields, field)
+		}
+	}
+	if len(idFields) > 0 {
+		return idFields
+	}
+
+	category := cloudbilling.Category{}
+	if sku.Category != nil {
+		category = *sku.Category
+	}
+
+	var fields []string
+	matched := ""
+	for _, rule := range rules {
+		field := rule.field(usageType)
+		if field == "" || !strings.HasPrefix(description, rule.Description) || len(rule.Description) < len(matched) {
+			continue
+		}
+		if !matchesCategory(rule.ResourceFamily, category.ResourceFamily) || !matchesCategory(rule.ResourceGroup, category.ResourceGroup) {
+			continue
+		}
+
+		if len(rule.Description) > len(matched) {
+			matched, fields = rule.Description, nil
+		}
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+func matchesCategory(expected string, actual string) bool {
+	return expected == "" || actual == "" || expected == actual
+}
+
+// applySkuRules sets the price of the SKU on the fields of the price list it matches. It reports false if
+// no rule matches.
+func applySkuRules(priceList interface{}, rules []skuRule, sku *cloudbilling.Sku, price float64) bool {
+	fields := matchSkuRules(rules, sku)
+	for _, field := range fields {
+		logging.Debug("SKU %s %q matched %s: %v", sku.SkuId, sku.Description, field, price)
+		reflect.ValueOf(priceList).Elem().FieldByName(field).SetFloat(price)
+	}
+
+	return len(fields) > 0
+}
+
+// ApplySku sets the price of a Compute Engine SKU on the price list. It reports false if no rule matches.
+func (pricing *GCEPriceList) ApplySku(sku *cloudbilling.Sku, price float64) bool {
+	return applySkuRules(pricing, gceSkuRules, sku, price)
+}
+
+// ApplySku sets the price of an Autopilot SKU on the price list. It reports false if no rule matches.
+func (pricing *AutopilotPriceList) ApplySku(sku *cloudbilling.Sku, price float64) bool {
+	return applySkuRules(pricing, autopilotSkuRules, sku, price)
+}
+
+var gceSkuRules = []skuRule{
+	{ResourceFamily: "Compute", Description: "H3 Instance Core", OnDemand: "H3CpuPrice"},
+	{ResourceFamily: "Compute", Description: "H3 Instance Ram", OnDemand: "H3MemoryPrice"},
+
+	{ResourceFamily: "Compute", Description: "Compute optimized Instance Core", OnDemand: "C2CpuPrice", Spot: "SpotC2CpuPrice"},
+	{ResourceFamily: "Compute", Description: "Compute optimized Instance Ram", OnDemand: "C2MemoryPrice", Spot: "SpotC2MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "C2D AMD Instance Core", OnDemand: "C2DCpuPrice", Spot: "SpotC2DCpuPrice"},
+	{ResourceFamily: "Compute", Description: "C2D AMD Instance Ram", OnDemand: "C2DMemoryPrice", Spot: "SpotC2DMemoryPrice"},
+
+	{ResourceFamily: "Compute", Description: "G2 Instance Core", OnDemand: "G2CpuPrice", Spot: "SpotG2DCpuPrice"},
+	{ResourceFamily: "Compute", Description: "G2 Instance Ram", OnDemand: "G2MemoryPrice", Spot: "SpotG2DMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "A2 Instance Core", OnDemand: "A2CpuPrice", Spot: "SpotA2CpuPrice"},
+	{ResourceFamily: "Compute", Description: "A2 Instance Ram", OnDemand: "A2MemoryPrice", Spot: "SpotA2MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "A3 Instance Core", OnDemand: "A3CpuPrice", Spot: "SpotA3CpuPrice"},
+	{ResourceFamily: "Compute", Description: "A3 Instance Ram", OnDemand: "A3MemoryPrice", Spot: "SpotA3MemoryPrice"},
+
+	{ResourceFamily: "Compute", Description: "E2 Instance Core", OnDemand: "E2CpuPrice", Spot: "SpotE2CpuPrice"},
+	{ResourceFamily: "Compute", Description: "E2 Instance Ram", OnDemand: "E2MemoryPrice", Spot: "SpotE2MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N1 Predefined Instance Core", OnDemand: "N1CpuPrice", Spot: "SpotN1CpuPrice"},
+	{ResourceFamily: "Compute", Description: "N1 Predefined Instance Ram", OnDemand: "N1MemoryPrice", Spot: "SpotN1MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N2 Instance Core", OnDemand: "N2CpuPrice", Spot: "SpotN2CpuPrice"},
+	{ResourceFamily: "Compute", Description: "N2 Instance Ram", OnDemand: "N2MemoryPrice", Spot: "SpotN2MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N2D AMD Instance Core", OnDemand: "N2DCpuPrice", Spot: "SpotN2DCpuPrice"},
+	{ResourceFamily: "Compute", Description: "N2D AMD Instance Ram", OnDemand: "N2DMemoryPrice", Spot: "SpotN2DMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N4 Instance Core", OnDemand: "N4CpuPrice", Spot: "SpotN4CpuPrice"},
+	{ResourceFamily: "Compute", Description: "N4 Instance Ram", OnDemand: "N4MemoryPrice", Spot: "SpotN4MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "C3 Instance Core", OnDemand: "C3CpuPrice", Spot: "SpotC3CpuPrice"},
+	{ResourceFamily: "Compute", Description: "C3 Instance Ram", OnDemand: "C3MemoryPrice", Spot: "SpotC3MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "C3D Instance Core", OnDemand: "C3DCpuPrice", Spot: "SpotC3DCpuPrice"},
+	{ResourceFamily: "Compute", Description: "C3D Instance Ram", OnDemand: "C3DMemoryPrice", Spot: "SpotC3DMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "C4 Instance Core", OnDemand: "C4CpuPrice", Spot: "SpotC4CpuPrice"},
+	{ResourceFamily: "Compute", Description: "C4 Instance Ram", OnDemand: "C4MemoryPrice", Spot: "SpotC4MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "T2D AMD Instance Core", OnDemand: "T2DCpuPrice", Spot: "SpotT2DCpuPrice"},
+	{ResourceFamily: "Compute", Description: "T2D AMD Instance Ram", OnDemand: "T2DMemoryPrice", Spot: "SpotT2DMemoryPrice"},
+
+	// Arm families, Tau T2A and Axion C4A
+	{ResourceFamily: "Compute", Description: "T2A Arm Instance Core", OnDemand: "T2ACpuPrice", Spot: "SpotT2ACpuPrice"},
+	{ResourceFamily: "Compute", Description: "T2A Arm Instance Ram", OnDemand: "T2AMemoryPrice", Spot: "SpotT2AMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "C4A Arm Instance Core", OnDemand: "C4ACpuPrice", Spot: "SpotC4ACpuPrice"},
+	{ResourceFamily: "Compute", Description: "C4A Arm Instance Ram", OnDemand: "C4AMemoryPrice", Spot: "SpotC4AMemoryPrice"},
+
+	{ResourceFamily: "Compute", Description: "Memory-optimized Instance Core", OnDemand: "M1CpuPrice"},
+	{ResourceFamily: "Compute", Description: "Memory-optimized Instance Ram", OnDemand: "M1MemoryPrice"},
+	{ResourceFamily: "Compute", Description: "M3 Memory-optimized Instance Core", OnDemand: "M3CpuPrice"},
+	{ResourceFamily: "Compute", Description: "M3 Memory-optimized Instance Ram", OnDemand: "M3MemoryPrice"},
+
+	{ResourceFamily: "Compute", Description: "Custom Instance Core", OnDemand: "N1CustomCpuPrice", Spot: "SpotN1CustomCpuPrice"},
+	{ResourceFamily: "Compute", Description: "Custom Instance Ram", OnDemand: "N1CustomMemoryPrice", Spot: "SpotN1CustomMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "Custom Extended Instance Ram", OnDemand: "N1CustomExtendedMemoryPrice", Spot: "SpotN1CustomExtendedMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N2 Custom Instance Core", OnDemand: "N2CustomCpuPrice", Spot: "SpotN2CustomCpuPrice"},
+	{ResourceFamily: "Compute", Description: "N2 Custom Instance Ram", OnDemand: "N2CustomMemoryPrice", Spot: "SpotN2CustomMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N2 Custom Extended Instance Ram", OnDemand: "N2CustomExtendedMemoryPrice", Spot: "SpotN2CustomExtendedMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N2D AMD Custom Instance Core", OnDemand: "N2DCustomCpuPrice", Spot: "SpotN2DCustomCpuPrice"},
+	{ResourceFamily: "Compute", Description: "N2D AMD Custom Instance Ram", OnDemand: "N2DCustomMemoryPrice", Spot: "SpotN2DCustomMemoryPrice"},
+	{ResourceFamily: "Compute", Description: "N2D AMD Custom Extended Instance Ram", OnDemand: "N2DCustomExtendedMemoryPrice", Spot: "SpotN2DCustomExtendedMemoryPrice"},
+
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: Cpu in", OneYear: "CommitmentOneYearCpuPrice", ThreeYear: "CommitmentThreeYearCpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: Ram in", OneYear: "CommitmentOneYearMemoryPrice", ThreeYear: "CommitmentThreeYearMemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: N2 Cpu in", OneYear: "CommitmentOneYearN2CpuPrice", ThreeYear: "CommitmentThreeYearN2CpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: N2 Ram in", OneYear: "CommitmentOneYearN2MemoryPrice", ThreeYear: "CommitmentThreeYearN2MemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: N2D AMD Cpu in", OneYear: "CommitmentOneYearN2DCpuPrice", ThreeYear: "CommitmentThreeYearN2DCpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: N2D AMD Ram in", OneYear: "CommitmentOneYearN2DMemoryPrice", ThreeYear: "CommitmentThreeYearN2DMemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: E2 Cpu in", OneYear: "CommitmentOneYearE2CpuPrice", ThreeYear: "CommitmentThreeYearE2CpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: E2 Ram in", OneYear: "CommitmentOneYearE2MemoryPrice", ThreeYear: "CommitmentThreeYearE2MemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: Compute optimized Cpu in", OneYear: "CommitmentOneYearC2CpuPrice", ThreeYear: "CommitmentThreeYearC2CpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: Compute optimized Ram in", OneYear: "CommitmentOneYearC2MemoryPrice", ThreeYear: "CommitmentThreeYearC2MemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: C2D AMD Cpu in", OneYear: "CommitmentOneYearC2DCpuPrice", ThreeYear: "CommitmentThreeYearC2DCpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: C2D AMD Ram in", OneYear: "CommitmentOneYearC2DMemoryPrice", ThreeYear: "CommitmentThreeYearC2DMemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: C3 Cpu in", OneYear: "CommitmentOneYearC3CpuPrice", ThreeYear: "CommitmentThreeYearC3CpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: C3 Ram in", OneYear: "CommitmentOneYearC3MemoryPrice", ThreeYear: "CommitmentThreeYearC3MemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: C3D Cpu in", OneYear: "CommitmentOneYearC3DCpuPrice", ThreeYear: "CommitmentThreeYearC3DCpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: C3D Ram in", OneYear: "CommitmentOneYearC3DMemoryPrice", ThreeYear: "CommitmentThreeYearC3DMemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: T2D AMD Cpu in", OneYear: "CommitmentOneYearT2DCpuPrice", ThreeYear: "CommitmentThreeYearT2DCpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: T2D AMD Ram in", OneYear: "CommitmentOneYearT2DMemoryPrice", ThreeYear: "CommitmentThreeYearT2DMemoryPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "CPU", Description: "Commitment v1: N4 Cpu in", OneYear: "CommitmentOneYearN4CpuPrice", ThreeYear: "CommitmentThreeYearN4CpuPrice"},
+	{ResourceFamily: "Compute", ResourceGroup: "RAM", Description: "Commitment v1: N4 Ram in", OneYear: "CommitmentOneYearN4MemoryPrice", ThreeYear: "CommitmentThreeYearN4MemoryPrice"},
+
+	{ResourceFamily: "Storage", Description: "Storage PD Capacity", OnDemand: "PdStandardCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Balanced PD Capacity", OnDemand: "PdBalancedCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "SSD backed PD Capacity", OnDemand: "PdSsdCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Extreme PD Capacity", OnDemand: "PdExtremeCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Extreme PD IOPS", OnDemand: "PdExtremeIopsPrice"},
+	{ResourceFamily: "Storage", Description: "Regional Storage PD Capacity", OnDemand: "RegionalPdStandardCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Regional Balanced PD Capacity", OnDemand: "RegionalPdBalancedCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Regional SSD backed PD Capacity", OnDemand: "RegionalPdSsdCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Hyperdisk Balanced Capacity", OnDemand: "HyperdiskBalancedCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Hyperdisk Balanced IOPS", OnDemand: "HyperdiskBalancedIopsPrice"},
+	{ResourceFamily: "Storage", Description: "Hyperdisk Balanced Throughput", OnDemand: "HyperdiskBalancedThroughputPrice"},
+	{ResourceFamily: "Storage", Description: "Hyperdisk Extreme Capacity", OnDemand: "HyperdiskExtremeCapacityPrice"},
+	{ResourceFamily: "Storage", Description: "Hyperdisk Extreme IOPS", OnDemand: "HyperdiskExtremeIopsPrice"},
+
+	{ResourceFamily: "Network", Description: "Network Inter Zone Egress", OnDemand: "InterZoneEgressPrice"},
+	{ResourceFamily: "Network", Description: "Network Load Balancing: Forwarding Rule Minimum Service Charge", OnDemand: "ForwardingRuleMinimumPrice"},
+	{ResourceFamily: "Network", Description: "Network Load Balancing: Forwarding Rule Additional Service Charge", OnDemand: "ForwardingRuleAdditionalPrice"},
+	{ResourceFamily: "Network", Description: "Network Load Balancing: Data Processing Charge", OnDemand: "LoadBalancerDataProcessingPrice"},
+}
+
+// autopilotSkuRules match the Autopilot SKUs, eg. "Autopilot Balanced Pod mCPU Requests (us-central1)". The committed
+// use SKUs are matched by applyAutopilotCommitment, GPUs and TPUs by their data tables.
+var autopilotSkuRules = []skuRule{
+	{Description: "Autopilot Pod Ephemeral Storage Requests", OnDemand: "StoragePrice"},
+	{Description: "Autopilot Pod mCPU Requests", OnDemand: "CpuPrice", Spot: "SpotCpuPrice"},
+	{Description: "Autopilot Pod Memory Requests", OnDemand: "MemoryPrice", Spot: "SpotMemoryPrice"},
+	{Description: "Autopilot Balanced Pod mCPU Requests", OnDemand: "CpuBalancedPrice", Spot: "SpotCpuBalancedPrice"},
+	{Description: "Autopilot Balanced Pod Memory Requests", OnDemand: "MemoryBalancedPrice", Spot: "SpotMemoryBalancedPrice"},
+	{Description: "Autopilot Scale-Out x86 Pod mCPU Requests", OnDemand: "CpuScaleoutPrice", Spot: "SpotCpuScaleoutPrice"},
+	{Description: "Autopilot Scale-Out x86 Pod Memory Requests", OnDemand: "MemoryScaleoutPrice", Spot: "SpotMemoryScaleoutPrice"},
+	{Description: "Autopilot Scale-Out Arm Pod mCPU Requests", OnDemand: "CpuArmScaleoutPrice", Spot: "SpotArmCpuScaleoutPrice"},
+	{Description: "Autopilot Scale-Out Arm Pod Memory Requests", OnDemand: "MemoryArmScaleoutPrice", Spot: "SpotArmMemoryScaleoutPrice"},
+
+	// The GPU Pod class bills the same vCPU and memory rates for every GPU model
+	{Description: "Autopilot NVIDIA A100 80GB Pod mCPU Requests", OnDemand: "GPUPodvCPUPrice", Spot: "SpotGPUPodvCPUPrice"},
+	{Description: "Autopilot NVIDIA A100 80GB Pod Memory Requests", OnDemand: "GPUPodMemoryPrice", Spot: "SpotGPUPodMemoryPrice"},
+	{Description: "Autopilot GPU Pod Local SSD", OnDemand: "GPUPodLocalSSDPrice", Spot: "SpotGPUPodLocalSSDPrice"},
+
+	{Description: "Autopilot PD Balanced Premium", OnDemand: "PerformancePDPricePremium", Spot: "SpotPerformancePDPricePremium"},
+	{Description: "Autopilot PD Balanced Premium", OnDemand: "AcceleratorPDPricePremium", Spot: "SpotAcceleratorPDPricePremium"},
+	{Description: "Autopilot Local SSD Premium", OnDemand: "PerformanceLocalSSDPricePremium", Spot: "SpotPerformanceLocalSSDPricePremium"},
+	{Description: "Autopilot Local SSD Premium", OnDemand: "AcceleratorLocalSSDPricePremium", Spot: "SpotAcceleratorLocalSSDPricePremium"},
+	{Description: "Autopilot Performance CPU Premium", OnDemand: "PerformanceCpuPricePremium", Spot: "SpotPerformanceCpuPricePremium"},
+	{Description: "Autopilot Performance Memory Premium", OnDemand: "PerformanceMemoryPricePremium", Spot: "SpotPerformanceMemoryPricePremium"},
+	{Description: "Autopilot Accelerator CPU Premium", OnDemand: "AcceleratorCpuPricePremium", Spot: "SpotAcceleratorCpuPricePremium"},
+	{Description: "Autopilot Accelerator Memory Premium", OnDemand: "AcceleratorMemoryGPUPricePremium", Spot: "SpotAcceleratorMemoryGPUPricePremium"},
+}
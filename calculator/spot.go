@@ -0,0 +1,178 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// SpotScenario is the cluster cost if every eligible workload ran as an Autopilot Spot Pod.
+type SpotScenario struct {
+	Cost    cluster.Money
+	Savings cluster.Money
+	// Ineligible lists workloads that can't run on Spot, they are kept at their regular price
+	Ineligible []string
+
+	// PreemptionOverhead is the share of Spot Pod time lost to preemptions and rescheduling, eg. 0.1
+	PreemptionOverhead float64
+	// EffectiveCost and EffectiveSavings include the preemption overhead of the Spot Pods
+	EffectiveCost    cluster.Money
+	EffectiveSavings cluster.Money
+
+	// Workloads lists every workload that moves to Spot, by savings
+	Workloads []SpotWorkload
+	// SafeSavings is the part of the savings of the workloads that look spot-safe, the suggested move
+	SafeSavings cluster.Money
+}
+
+// SpotWorkload is the cost of a workload that doesn't run on Spot yet at its regular and at Spot rates.
+type SpotWorkload struct {
+	Name      string
+	Namespace string
+	Cost      cluster.Money
+	SpotCost  cluster.Money
+	Savings   cluster.Money
+	// SpotSafe is set if nothing suggests the workload can't handle preemptions, see Blockers
+	SpotSafe bool
+	Blockers []string `json:",omitempty"`
+}
+
+// GetSpotScenario re-prices all workloads at Spot rates where the compute class supports it.
+// The effective cost adds the preemption overhead to every workload that runs on Spot.
+func (service *PricingService) GetSpotScenario(nodes map[string]cluster.Node, preemptionOverhead float64) SpotScenario {
+	scenario := SpotScenario{Ineligible: []string{}, PreemptionOverhead: preemptionOverhead, Workloads: []SpotWorkload{}}
+
+	var onDemandCost, spotCost, movedCost cluster.Money
+	for _, node := range cluster.SortedNodes(nodes) {
+		for _, workload := range node.Workloads {
+			// Workloads of Spot compute classes are already priced at Spot rates
+			if node.Spot || workload.Spot {
+				spotCost += workload.Cost
+				continue
+			}
+
+			workloadSpotCost, eligible := service.getSpotCost(workload, node.InstanceType)
+			if !eligible {
+				scenario.Ineligible = append(scenario.Ineligible, workload.Name)
+				onDemandCost += workload.Cost
+				continue
+			}
+
+			spotCost += workloadSpotCost
+			movedCost += workloadSpotCost
+			scenario.Savings += workload.Cost - workloadSpotCost
+			scenario.Workloads = append(scenario.Workloads, SpotWorkload{
+				Name:      workload.Name,
+				Namespace: workload.Namespace,
+				Cost:      workload.Cost,
+				SpotCost:  workloadSpotCost,
+				Savings:   workload.Cost - workloadSpotCost,
+			})
+		}
+	}
+
+	sort.SliceStable(scenario.Workloads, func(i, j int) bool {
+		return scenario.Workloads[i].Savings > scenario.Workloads[j].Savings
+	})
+
+	scenario.Cost = onDemandCost + spotCost
+	scenario.EffectiveCost = onDemandCost + spotCost.Mul(1+preemptionOverhead)
+	// Workloads that already run on Spot have the overhead today, so only moved workloads lower the savings
+	scenario.EffectiveSavings = scenario.Savings - movedCost.Mul(preemptionOverhead)
+
+	return scenario
+}
+
+// GetPreemptionOverhead returns the expected share of Spot Pod time lost to preemptions, from the
+// preemption_overhead key of the [spot] section. Without it the overhead is derived from the churn
+// of the Spot nodes in the cluster: the time to reschedule a Pod divided by the average node age.
+func (service *PricingService) GetPreemptionOverhead(nodes map[string]cluster.Node, now time.Time) float64 {
+	if overhead, err := service.Config.Section("spot").Key("preemption_overhead").Float64(); err == nil {
+		return overhead
+	}
+
+	rescheduleMinutes, err := service.Config.Section("spot").Key("reschedule_minutes").Float64()
+	if err != nil {
+		return 0
+	}
+
+	var spotNodes int
+	var totalAge time.Duration
+	for _, node := range nodes {
+		if !node.Spot || node.Created.IsZero() {
+			continue
+		}
+		spotNodes++
+		totalAge += now.Sub(node.Created)
+	}
+
+	if spotNodes == 0 || totalAge <= 0 {
+		return 0
+	}
+
+	averageAgeMinutes := totalAge.Minutes() / float64(spotNodes)
+
+	return math.Min(rescheduleMinutes/averageAgeMinutes, 1)
+}
+
+// getSpotCost prices the workload as a Spot Pod and reports if the workload is eligible for Spot at all.
+// Only the difference between the regular and the Spot rates of the resources is taken off the cost
+// of the workload, so disks, egress, premiums and sidecars stay priced as they are.
+func (service *PricingService) getSpotCost(workload cluster.Workload, instanceType string) (cluster.Money, bool) {
+	// H3 machines are not available as Spot VMs, and flex-start capacity is never Spot
+	if workload.FlexStart || workload.ComputeClass == cluster.ComputeClassPerformance && strings.HasPrefix(instanceType, "h3-") {
+		return 0, false
+	}
+
+	// Keep the warnings of the workload being priced intact
+	warnings := service.warnings
+	defer func() { service.warnings = warnings }()
+
+	spotPrice := service.CalculatePriceBreakdown(PriceRequest{Cpu: workload.Cpu, Memory: workload.Memory, Storage: workload.Storage, GPU: workload.AcceleratorAmount, GPUModel: workload.AcceleratorType, TPU: workload.TPUCount, TPUType: workload.TPUType, Class: workload.ComputeClass, InstanceType: instanceType, Spot: true})
+	spotCost := cluster.NewMoney(shareGPUPrice(spotPrice, workload.GPUShare).Total())
+
+	// No Spot price in the region
+	if spotCost == 0 {
+		return 0, false
+	}
+
+	regularPrice := service.CalculatePriceBreakdown(PriceRequest{Cpu: workload.Cpu, Memory: workload.Memory, Storage: workload.Storage, GPU: workload.AcceleratorAmount, GPUModel: workload.AcceleratorType, TPU: workload.TPUCount, TPUType: workload.TPUType, Class: workload.ComputeClass, InstanceType: instanceType})
+	delta := cluster.NewMoney(shareGPUPrice(regularPrice, workload.GPUShare).Total()) - spotCost
+
+	// Pods of Jobs are billed for the part of the month they run at Spot rates too
+	if workload.DutyCycle > 0 {
+		delta = delta.Mul(workload.DutyCycle)
+	}
+
+	return workload.Cost - delta, true
+}
+
+// SetSpotBlockers flags the workloads of the scenario that have no reason against running on Spot as spot-safe,
+// and sums their savings. The blockers are keyed by namespace/name.
+func (scenario *SpotScenario) SetSpotBlockers(blockers map[string][]string) {
+	scenario.SafeSavings = 0
+	for i, workload := range scenario.Workloads {
+		scenario.Workloads[i].Blockers = blockers[workload.Namespace+"/"+workload.Name]
+		scenario.Workloads[i].SpotSafe = len(scenario.Workloads[i].Blockers) == 0
+		if scenario.Workloads[i].SpotSafe {
+			scenario.SafeSavings += workload.Savings
+		}
+	}
+}
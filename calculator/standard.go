@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"math"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+)
+
+// StandardComparison puts the current cost of the Standard cluster next to the Autopilot estimate.
+// Both include the cluster fee.
+type StandardComparison struct {
+	// StandardCost is what the nodes cost, including their unused capacity, plus persistent disks and unused reservations
+	StandardCost cluster.Money
+	// AutopilotCost is the estimate plus the commitments that keep being billed after the migration
+	AutopilotCost  cluster.Money
+	Savings        cluster.Money
+	SavingsPercent float64
+	// Unpriced lists nodes without a machine price, they are missing from the Standard cost
+	Unpriced []string
+
+	// UnusedCommitments is the committed capacity by machine family that no on-demand node of the cluster uses.
+	// It is billed all the same, so the commitments are larger than the cluster.
+	UnusedCommitments map[string]CommittedCapacity `json:",omitempty"`
+}
+
+// GetStandardNodeCost prices a node at its GCE machine rate, with the Confidential Computing premium
+// for confidential nodes and the license for Windows Server nodes. It reports false if there is no
+// price for the machine type.
+func (service *PricingService) GetStandardNodeCost(node cluster.Node) (cluster.Money, bool) {
+	// Keep the workload warnings clear of the node pricing
+	warnings := service.warnings
+	defer func() { service.warnings = warnings }()
+
+	price, _ := service.GetGCEMachinePrice(node.InstanceType, node.Spot)
+	if price == 0 {
+		return 0, false
+	}
+
+	if node.Confidential {
+		machine, _ := parseMachineType(node.InstanceType)
+		price += service.GetConfidentialPremium(int64(machine.Cpus*1000), int64(machine.RamGb*1000), node.Spot).Total()
+	}
+
+	if node.Windows {
+		machine, _ := parseMachineType(node.InstanceType)
+		price += service.GCEPricing.WindowsServerCpuPrice * machine.Cpus
+	}
+
+	return cluster.NewMoney(price), true
+}
+
+// getCommittedNodeCost returns the part of the price of an on-demand machine the remaining commitments of its family
+// cover, its committed vCPUs at the vCPU price and its committed GB at the memory price. The commitments are used up.
+func (service *PricingService) getCommittedNodeCost(machine machineType, committed map[string]CommittedCapacity) cluster.Money {
+	capacity, ok := committed[machine.Family]
+	if !ok {
+		return 0
+	}
+
+	var cpuPrice, memoryPrice float64
+	if machine.Custom {
+		cpuPrice, memoryPrice, _, ok = service.getCustomMachinePrice(machine.Family, false)
+	} else {
+		cpuPrice, memoryPrice, ok = service.getMachineFamilyPrice(machine.Family, false)
+	}
+	if !ok {
+		return 0
+	}
+
+	// Commitments don't cover extended memory
+	coveredCpus := math.Min(capacity.Cpus, machine.Cpus)
+	coveredMemory := math.Min(capacity.MemoryGb, machine.RamGb-machine.ExtendedRamGb)
+	capacity.Cpus -= coveredCpus
+	capacity.MemoryGb -= coveredMemory
+	committed[machine.Family] = capacity
+
+	return cluster.NewMoney(cpuPrice*coveredCpus + memoryPrice*coveredMemory)
+}
+
+// CompareWithStandard prices every node of the cluster and compares it with the Autopilot cost of
+// its workloads. The node prices are stored on the nodes. Existing commitments are billed in both
+// modes, unused reservations only in the current one, so they are accounted for when known.
+func (service *PricingService) CompareWithStandard(nodes map[string]cluster.Node, standardFee cluster.Money, autopilotFee cluster.Money, existing *ExistingCapacity) StandardComparison {
+	comparison := StandardComparison{
+		StandardCost:  standardFee,
+		AutopilotCost: autopilotFee,
+		Unpriced:      []string{},
+	}
+
+	// Committed vCPUs and memory cover on-demand nodes of their machine family, those are billed through the commitment instead
+	committed := make(map[string]CommittedCapacity)
+	if existing != nil {
+		for family, capacity := range existing.Committed {
+			committed[family] = capacity
+		}
+	}
+
+	for _, node := range cluster.SortedNodes(nodes) {
+		nodeCost, ok := service.GetStandardNodeCost(node)
+		if !ok {
+			comparison.Unpriced = append(comparison.Unpriced, node.Name)
+		}
+		node.StandardCost = nodeCost
+		nodes[node.Name] = node
+
+		if machine, ok := parseMachineType(node.InstanceType); ok && !node.Spot {
+			nodeCost -= service.getCommittedNodeCost(machine, committed)
+		}
+
+		comparison.StandardCost += nodeCost
+		for _, workload := range node.Workloads {
+			// Persistent disks and egress cost the same in both modes
+			comparison.StandardCost += workload.CostBreakdown.Disk + workload.CostBreakdown.Network
+			comparison.AutopilotCost += workload.Cost
+		}
+	}
+
+	if existing != nil {
+		comparison.StandardCost += existing.CommitmentCost + existing.ReservationCost
+		comparison.AutopilotCost += existing.CommitmentCost
+
+		for family, capacity := range committed {
+			if capacity.Cpus <= 0 && capacity.MemoryGb <= 0 {
+				continue
+			}
+			if comparison.UnusedCommitments == nil {
+				comparison.UnusedCommitments = make(map[string]CommittedCapacity)
+			}
+			comparison.UnusedCommitments[family] = capacity
+			logging.Warn("Commitments for %s cover %.1f vCPU and %.1f GB memory more than the on-demand nodes of the cluster use, they are billed all the same.", family, capacity.Cpus, capacity.MemoryGb)
+		}
+	}
+
+	comparison.Savings = comparison.StandardCost - comparison.AutopilotCost
+	if comparison.StandardCost > 0 {
+		comparison.SavingsPercent = comparison.Savings.Float64() / comparison.StandardCost.Float64() * 100
+	}
+
+	return comparison
+}
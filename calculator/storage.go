@@ -0,0 +1,99 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// Disk prices are monthly, the rest of the calculator works per hour
+const HOURS_PER_MONTH = 730
+
+// EXTENDED_STORAGE_DISK_TYPE backs generic ephemeral volumes of the default standard-rwo storage class of Autopilot
+const EXTENDED_STORAGE_DISK_TYPE = "pd-balanced"
+
+// Performance included in the Hyperdisk Balanced capacity price
+const (
+	HYPERDISK_BALANCED_BASELINE_IOPS       = 3000
+	HYPERDISK_BALANCED_BASELINE_THROUGHPUT = 140
+)
+
+// GetDiskPrice returns the hourly price of a persistent disk, or false if the disk type can't be priced.
+func (service *PricingService) GetDiskPrice(disk cluster.Disk) (float64, bool) {
+	size := float64(disk.Size) / 1024 // GiB
+
+	switch disk.Type {
+	case "pd-standard":
+		if disk.Regional {
+			return service.GCEPricing.RegionalPdStandardCapacityPrice * size / HOURS_PER_MONTH, true
+		}
+		return service.GCEPricing.PdStandardCapacityPrice * size / HOURS_PER_MONTH, true
+	case "pd-balanced":
+		if disk.Regional {
+			return service.GCEPricing.RegionalPdBalancedCapacityPrice * size / HOURS_PER_MONTH, true
+		}
+		return service.GCEPricing.PdBalancedCapacityPrice * size / HOURS_PER_MONTH, true
+	case "pd-ssd":
+		if disk.Regional {
+			return service.GCEPricing.RegionalPdSsdCapacityPrice * size / HOURS_PER_MONTH, true
+		}
+		return service.GCEPricing.PdSsdCapacityPrice * size / HOURS_PER_MONTH, true
+	case "pd-extreme":
+		monthly := service.GCEPricing.PdExtremeCapacityPrice*size + service.GCEPricing.PdExtremeIopsPrice*float64(disk.Iops)
+		return monthly / HOURS_PER_MONTH, true
+	case "hyperdisk-balanced":
+		monthly := service.GCEPricing.HyperdiskBalancedCapacityPrice * size
+		if disk.Iops > HYPERDISK_BALANCED_BASELINE_IOPS {
+			monthly += service.GCEPricing.HyperdiskBalancedIopsPrice * float64(disk.Iops-HYPERDISK_BALANCED_BASELINE_IOPS)
+		}
+		if disk.Throughput > HYPERDISK_BALANCED_BASELINE_THROUGHPUT {
+			monthly += service.GCEPricing.HyperdiskBalancedThroughputPrice * float64(disk.Throughput-HYPERDISK_BALANCED_BASELINE_THROUGHPUT)
+		}
+		return monthly / HOURS_PER_MONTH, true
+	case "hyperdisk-extreme":
+		monthly := service.GCEPricing.HyperdiskExtremeCapacityPrice*size + service.GCEPricing.HyperdiskExtremeIopsPrice*float64(disk.Iops)
+		return monthly / HOURS_PER_MONTH, true
+	}
+
+	return 0, false
+}
+
+// GetExtendedStorage caps the ephemeral storage of a Pod at the <class>_storage_max of [limits]. Storage above it
+// needs a generic ephemeral volume in Autopilot, which is returned as a disk to be priced at its persistent disk rate.
+func (service *PricingService) GetExtendedStorage(workloadName string, class cluster.ComputeClass, storage int64) (int64, []cluster.Disk) {
+	storageMax := service.getClassLimits(class, "").storageMax
+	if storage <= storageMax {
+		return storage, nil
+	}
+
+	service.warn(cluster.WarningOutOfRange, "Workload (%s) requests %d MiB of ephemeral storage, above the maximum of %d MiB of the %s compute class. The rest is priced as a %s generic ephemeral volume.", workloadName, storage, storageMax, cluster.ComputeClasses[class], EXTENDED_STORAGE_DISK_TYPE)
+
+	return storageMax, []cluster.Disk{{Claim: "ephemeral-storage", Type: EXTENDED_STORAGE_DISK_TYPE, Size: storage - storageMax, Ephemeral: true}}
+}
+
+// GetDisksPrice sums the hourly price of the disks, warning about the ones that can't be priced.
+func (service *PricingService) GetDisksPrice(workloadName string, disks []cluster.Disk) float64 {
+	price := 0.0
+	for _, disk := range disks {
+		diskPrice, ok := service.GetDiskPrice(disk)
+		if !ok {
+			service.warn(cluster.WarningPricingUnavailable, "Persistent disk type (%s) of claim %s used by workload (%s) is not supported for price querying.", disk.Type, disk.Claim, workloadName)
+			continue
+		}
+		price += diskPrice
+	}
+
+	return price
+}
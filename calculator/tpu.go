@@ -0,0 +1,86 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calculator
+
+import (
+	"reflect"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// tpuModel is a TPU version Autopilot can run, by the value of the cloud.google.com/gke-tpu-accelerator
+// node selector. Its chips are priced from the Autopilot SKUs, eg. "Autopilot TPU v5e Pod Chip Requests (us-west4)".
+type tpuModel struct {
+	Selector       string
+	Sku            string
+	PriceField     string
+	SpotPriceField string
+}
+
+var tpuModels = []tpuModel{
+	{Selector: "tpu-v4-podslice", Sku: "TPU v4", PriceField: "TPUV4PodChipPrice", SpotPriceField: "SpotTPUV4PodChipPrice"},
+	{Selector: "tpu-v5-lite-podslice", Sku: "TPU v5e", PriceField: "TPUV5ePodChipPrice", SpotPriceField: "SpotTPUV5ePodChipPrice"},
+	{Selector: "tpu-v5p-slice", Sku: "TPU v5p", PriceField: "TPUV5pPodChipPrice", SpotPriceField: "SpotTPUV5pPodChipPrice"},
+	{Selector: "tpu-v6e-slice", Sku: "TPU v6e", PriceField: "TPUV6ePodChipPrice", SpotPriceField: "SpotTPUV6ePodChipPrice"},
+}
+
+// getTPUChipPrice returns the hourly price of a single chip of the TPU version.
+// It reports false if the version is not known.
+func (service *PricingService) getTPUChipPrice(tpuType string, spot bool) (float64, bool) {
+	for _, model := range tpuModels {
+		if model.Selector != tpuType {
+			continue
+		}
+
+		field := model.PriceField
+		if spot {
+			field = model.SpotPriceField
+		}
+
+		return reflect.ValueOf(service.AutopilotPricing).FieldByName(field).Float(), true
+	}
+
+	return 0, false
+}
+
+// getTPUPriceBreakdown prices a TPU Pod. TPUs run in the Accelerator compute class, the chips
+// are billed on top of the Accelerator premiums of the requests.
+func (service *PricingService) getTPUPriceBreakdown(cpu int64, memory int64, storage int64, tpu int64, tpuType string, spot bool) PriceBreakdown {
+	tpuPrice := PriceBreakdown{
+		Cpu:     service.AutopilotPricing.AcceleratorCpuPricePremium * float64(cpu) / 1000,
+		Memory:  service.AutopilotPricing.AcceleratorMemoryGPUPricePremium * float64(memory) / 1000,
+		Storage: service.AutopilotPricing.AcceleratorLocalSSDPricePremium * float64(storage) / 1000,
+	}
+	if spot {
+		tpuPrice = PriceBreakdown{
+			Cpu:     service.AutopilotPricing.SpotAcceleratorCpuPricePremium * float64(cpu) / 1000,
+			Memory:  service.AutopilotPricing.SpotAcceleratorMemoryGPUPricePremium * float64(memory) / 1000,
+			Storage: service.AutopilotPricing.SpotAcceleratorLocalSSDPricePremium * float64(storage) / 1000,
+		}
+	}
+
+	chipPrice, ok := service.getTPUChipPrice(tpuType, spot)
+	if !ok {
+		service.warn(cluster.WarningPricingUnavailable, "Requested TPU (%s) is not supported, its chips are not priced.", tpuType)
+		return tpuPrice
+	}
+	if chipPrice == 0 {
+		service.warn(cluster.WarningPricingUnavailable, "Requested TPU (%s) pricing is not available in %s region.", tpuType, service.AutopilotPricing.Region)
+	}
+
+	tpuPrice.Accelerator = chipPrice * float64(tpu)
+
+	return tpuPrice
+}
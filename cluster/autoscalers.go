@@ -0,0 +1,97 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AutoscalerCost is the cost of the target of a HorizontalPodAutoscaler now and if it scaled out to maxReplicas.
+type AutoscalerCost struct {
+	Namespace   string
+	Name        string
+	Target      Owner
+	Replicas    int
+	MinReplicas int32
+	MaxReplicas int32
+	Cost        Money
+	MaxCost     Money
+}
+
+// GetAutoscalerCosts prices the targets of the HorizontalPodAutoscalers at their maxReplicas, a replica costs
+// the average of the running pods of the target. The owners are the ones of GetOwnerCosts, targets without
+// running pods have nothing to price a replica by and are left out. They are ordered by namespace and name.
+func GetAutoscalerCosts(ctx context.Context, client kubernetes.Interface, owners []OwnerCost) ([]AutoscalerCost, error) {
+	autoscalers, err := client.AutoscalingV2().HorizontalPodAutoscalers("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("error listing horizontal pod autoscalers: %v", err)
+		return nil, err
+	}
+
+	targets := make(map[string]OwnerCost)
+	for _, owner := range owners {
+		targets[fmt.Sprintf("%s/%s/%s", owner.Namespace, owner.Owner.Kind, owner.Owner.Name)] = owner
+	}
+
+	costs := []AutoscalerCost{}
+	for _, autoscaler := range autoscalers.Items {
+		target, ok := targets[fmt.Sprintf("%s/%s/%s", autoscaler.Namespace, autoscaler.Spec.ScaleTargetRef.Kind, autoscaler.Spec.ScaleTargetRef.Name)]
+		if !ok || target.Replicas == 0 {
+			continue
+		}
+
+		cost := AutoscalerCost{
+			Namespace:   autoscaler.Namespace,
+			Name:        autoscaler.Name,
+			Target:      target.Owner,
+			Replicas:    target.Replicas,
+			MinReplicas: 1,
+			MaxReplicas: autoscaler.Spec.MaxReplicas,
+			Cost:        target.Cost,
+			MaxCost:     target.Cost.Mul(float64(autoscaler.Spec.MaxReplicas) / float64(target.Replicas)),
+		}
+		if autoscaler.Spec.MinReplicas != nil {
+			cost.MinReplicas = *autoscaler.Spec.MinReplicas
+		}
+		// Surge pods of a rollout can take the target above maxReplicas for a while
+		if cost.MaxCost < cost.Cost {
+			cost.MaxCost = cost.Cost
+		}
+		costs = append(costs, cost)
+	}
+
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].Namespace != costs[j].Namespace {
+			return costs[i].Namespace < costs[j].Namespace
+		}
+		return costs[i].Name < costs[j].Name
+	})
+
+	return costs, nil
+}
+
+// GetMaxScaleIncrease sums what the targets of the autoscalers add to the cost at their maxReplicas.
+func GetMaxScaleIncrease(autoscalers []AutoscalerCost) Money {
+	var increase Money
+	for _, autoscaler := range autoscalers {
+		increase += autoscaler.MaxCost - autoscaler.Cost
+	}
+	return increase
+}
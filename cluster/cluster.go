@@ -18,9 +18,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/location"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -42,17 +45,130 @@ const (
 
 var ComputeClasses [7]string = [7]string{"General-purpose", "Balanced", "Scale-out", "Scale-out arm64", "Performance", "Accelerator", "GPU Pod"}
 
+// ComputeClassKeys name the compute classes in config.ini keys.
+var ComputeClassKeys [7]string = [7]string{"generalpurpose", "balanced", "scaleout", "scaleout_arm", "performance", "accelerator", "gpupod"}
+
+type WarningType string
+
+const (
+	WarningPricingUnavailable     WarningType = "pricing-unavailable"
+	WarningUnsupportedMachineType WarningType = "unsupported-machine-type"
+	WarningOutOfRange             WarningType = "out-of-range"
+	WarningNoComputeClass         WarningType = "no-compute-class"
+	WarningConfidentialNodes      WarningType = "confidential-nodes"
+	WarningSandbox                WarningType = "sandbox"
+	WarningCompletedPod           WarningType = "completed-pod"
+	WarningFlexStart              WarningType = "flex-start"
+	WarningNoUsageHistory         WarningType = "no-usage-history"
+	// WarningMigrationBlocker is raised for workloads that can't run in Autopilot as they are
+	WarningMigrationBlocker WarningType = "migration-blocker"
+)
+
+// Warning describes a condition that lowers the quality of an estimate, eg. a
+// missing SKU price or resources outside of the compute class limits.
+type Warning struct {
+	Type     WarningType
+	Workload string
+	Message  string
+}
+
+// CostBreakdown splits the hourly cost of a workload by the billed resource.
+type CostBreakdown struct {
+	Cpu         Money
+	Memory      Money
+	Storage     Money
+	Accelerator Money
+	Machine     Money
+	Disk        Money
+	Network     Money
+}
+
 type Workload struct {
-	Name              string
-	Node_name         string
-	Containers        int
-	Cpu               int64
-	Memory            int64
-	Storage           int64
+	Name       string
+	Namespace  string
+	Owner      Owner
+	Labels     map[string]string
+	Node_name  string
+	Containers int
+	Cpu        int64
+	Memory     int64
+	Storage    int64
+	// RequestedCpu and RequestedMemory are the sized resources before Autopilot adjusts them to the compute class
+	RequestedCpu      int64
+	RequestedMemory   int64
 	AcceleratorType   string
 	AcceleratorAmount int64
-	Cost              float64
-	ComputeClass      ComputeClass
+	// GPUSharing is the time-sharing, MPS or MIG strategy the GPUs are shared with, GPUShare is the
+	// share of a physical GPU every requested GPU is, eg. 0.25 for time-sharing with 4 clients
+	GPUSharing string
+	GPUShare   float64
+	// TPUType is the TPU version of the cloud.google.com/gke-tpu-accelerator node selector, eg. tpu-v5-lite-podslice
+	TPUType       string
+	TPUTopology   string
+	TPUCount      int64
+	Cost          Money
+	EffectiveCost Money
+	CostBreakdown CostBreakdown
+	// SidecarCost is the part of Cost for the injected sidecars of the workload, with the separate sidecar mode
+	SidecarCost  Money
+	ComputeClass ComputeClass
+	Sandboxed    bool
+	Spot         bool
+	FlexStart    bool
+	// Burstable workloads have containers with limits above their requests, which can burst into unused capacity after the migration
+	Burstable bool
+	// GracePeriod is the terminationGracePeriodSeconds of the pod, 0 if it has the default
+	GracePeriod int64
+	Disks       []Disk
+	Warnings    []Warning
+	// DutyCycle is the share of the month the pod of a Job runs and its cost is prorated by, 0 for pods that run all month
+	DutyCycle float64 `json:",omitempty"`
+}
+
+// NamespaceCost is the summed cost of all workloads in a namespace.
+type NamespaceCost struct {
+	Namespace     string
+	Workloads     int
+	Cost          Money
+	EffectiveCost Money
+}
+
+// Sample is the total cost of the workloads at one point in time, when the
+// cluster is sampled over a window.
+type Sample struct {
+	Timestamp time.Time
+	Workloads int
+	Cost      Money
+}
+
+func NewSample(timestamp time.Time, workloads []Workload) Sample {
+	sample := Sample{Timestamp: timestamp, Workloads: len(workloads)}
+	for _, workload := range workloads {
+		sample.Cost += workload.Cost
+	}
+
+	return sample
+}
+
+// SummarizeSamples returns the average, lowest and highest cost of the samples.
+func SummarizeSamples(samples []Sample) (Money, Money, Money) {
+	if len(samples) == 0 {
+		return 0, 0, 0
+	}
+
+	var total Money
+	lowest, highest := samples[0].Cost, samples[0].Cost
+	for _, sample := range samples {
+		total += sample.Cost
+		if sample.Cost < lowest {
+			lowest = sample.Cost
+		}
+		if sample.Cost > highest {
+			highest = sample.Cost
+		}
+	}
+
+	return total / Money(len(samples)), lowest, highest
 }
 
 type Node struct {
@@ -61,32 +177,113 @@ type Node struct {
 	InstanceType string
 	Region       string
 	Spot         bool
-	Cost         float64
+	Cost         Money
+	StandardCost Money
 	Accelerator  string
+	// GPUSharing and GPUShare are how the GPUs of the node are shared between pods, see GetGPUShare
+	GPUSharing   string
+	GPUShare     float64
+	Confidential bool
+	FlexStart    bool
+	Windows      bool
+	Created      time.Time
+}
+
+// KubeConfigPath and KubeContext override the kube config file and its current context,
+// like the --kubeconfig and --context flags of kubectl.
+var (
+	KubeConfigPath string
+	KubeContext    string
+)
+
+// kubeConfigLoadingRules finds the kube config files like kubectl: KubeConfigPath, else the files
+// listed in the KUBECONFIG environment variable merged, else ~/.kube/config.
+func kubeConfigLoadingRules() *clientcmd.ClientConfigLoadingRules {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = KubeConfigPath
+
+	return rules
+}
+
+// hasKubeConfig checks if any of the kube config files of the rules exists.
+func hasKubeConfig(rules *clientcmd.ClientConfigLoadingRules) bool {
+	if rules.ExplicitPath != "" {
+		return true
+	}
+
+	for _, path := range rules.Precedence {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+
+	return false
 }
 
 func GetKubeConfig() (*rest.Config, string, error) {
-	userHomeDir, err := os.UserHomeDir()
-	if err != nil {
-		err = fmt.Errorf("error getting user home dir: %v", err)
-		return nil, "", err
+	return GetKubeConfigForContext("")
+}
+
+// GetKubeConfigForContext returns the kubernetes config of a context from the kube config file,
+// an empty context name uses KubeContext or the current context.
+// Without a kube config file, running in a pod falls back to the in-cluster config and an empty path.
+func GetKubeConfigForContext(contextName string) (*rest.Config, string, error) {
+	if contextName == "" {
+		contextName = KubeContext
 	}
 
-	kubeConfigPath := filepath.Join(userHomeDir, ".kube", "config")
-	// log.Printf("Using kubeconfig: %s\n", kubeConfigPath)
+	rules := kubeConfigLoadingRules()
+
+	if !hasKubeConfig(rules) && contextName == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		kubeConfig, err := rest.InClusterConfig()
+		if err != nil {
+			err = fmt.Errorf("error getting in-cluster kubernetes config: %v", err)
+			return nil, "", err
+		}
+
+		return kubeConfig, "", nil
+	}
 
-	kubeConfig, err := clientcmd.BuildConfigFromFlags("", kubeConfigPath)
+	kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		rules,
+		&clientcmd.ConfigOverrides{CurrentContext: contextName},
+	).ClientConfig()
 	if err != nil {
 		err = fmt.Errorf("error getting kubernetes config: %v", err)
 		return nil, "", err
 	}
 
-	return kubeConfig, kubeConfigPath, nil
+	return kubeConfig, rules.GetDefaultFilename(), nil
+}
+
+// ListGKEContexts returns the names of all GKE contexts in the kube config files, ordered by name.
+func ListGKEContexts() ([]string, error) {
+	config, err := kubeConfigLoadingRules().Load()
+	if err != nil {
+		err = fmt.Errorf("error getting kubernetes contexts: %v", err)
+		return nil, err
+	}
+
+	contexts := []string{}
+	for name := range config.Contexts {
+		if len(strings.Split(name, "_")) == 4 && strings.HasPrefix(name, "gke_") {
+			contexts = append(contexts, name)
+		}
+	}
+	sort.Strings(contexts)
+
+	return contexts, nil
 }
 
-func GetCurrentContext(kubeConfigPath string) ([]string, error) {
+// GetCurrentContext returns the parts of the name of KubeContext or the current context,
+// eg. gke, project, location and cluster name for a GKE context.
+func GetCurrentContext() ([]string, error) {
+	if KubeContext != "" {
+		return strings.Split(KubeContext, "_"), nil
+	}
+
 	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeConfigPath},
+		kubeConfigLoadingRules(),
 		&clientcmd.ConfigOverrides{
 			CurrentContext: "",
 		}).RawConfig()
@@ -99,41 +296,334 @@ func GetCurrentContext(kubeConfigPath string) ([]string, error) {
 	return strings.Split(config.CurrentContext, "_"), nil
 }
 
-func GetClusterNodes(clientset *kubernetes.Clientset) (map[string]Node, error) {
+func GetClusterNodes(ctx context.Context, clientset kubernetes.Interface) (map[string]Node, error) {
 	nodes := make(map[string]Node)
 
-	clusterNodes, err := ListNodes(clientset)
+	clusterNodes, err := ListNodes(ctx, clientset)
 	if err != nil {
 		err = fmt.Errorf("error getting nodes: %v", err)
 		return nil, err
 	}
 
 	for _, clusterNode := range clusterNodes.Items {
+		gpuSharing, gpuShare := GetGPUShare(clusterNode.Labels)
+		// Older nodes only have the zone label
+		region := clusterNode.Labels["topology.kubernetes.io/region"]
+		if region == "" {
+			region = clusterNode.Labels["topology.kubernetes.io/zone"]
+		}
+		instanceType := clusterNode.Labels["beta.kubernetes.io/instance-type"]
+		if instanceType == "" {
+			instanceType = clusterNode.Labels["node.kubernetes.io/instance-type"]
+		}
+		// Nodes of other clouds or on-prem are compared with a GKE Standard node of the same size
+		if !IsGKENode(clusterNode.Labels) {
+			instanceType = EquivalentMachineType(clusterNode.Status.Capacity)
+		}
 		nodes[clusterNode.Name] = Node{
 			Name:         clusterNode.Name,
-			Region:       clusterNode.Labels["topology.kubernetes.io/region"],
-			Spot:         clusterNode.Labels["cloud.google.com/gke-spot"] == "true",
+			Region:       location.Region(region),
+			Spot:         IsSpot(clusterNode.Labels),
 			Accelerator:  clusterNode.Labels["cloud.google.com/gke-accelerator"],
-			InstanceType: clusterNode.Labels["beta.kubernetes.io/instance-type"]}
+			GPUSharing:   gpuSharing,
+			GPUShare:     gpuShare,
+			Confidential: IsConfidential(clusterNode.Labels),
+			FlexStart:    IsFlexStart(clusterNode.Labels),
+			Windows:      IsWindows(clusterNode.Labels),
+			Created:      clusterNode.CreationTimestamp.Time,
+			InstanceType: instanceType}
 	}
 
 	return nodes, nil
 }
 
-func ListPods(client kubernetes.Interface) (*v1.PodList, error) {
-	pods, err := client.CoreV1().Pods("").List(
-		context.Background(),
-		metav1.ListOptions{FieldSelector: "status.phase=Running,metadata.namespace!=kube-system,metadata.namespace!=gke-gmp-system"},
-	)
-	if err != nil {
-		err = fmt.Errorf("error getting pods: %v", err)
-		return nil, err
+// IsGKENode checks the node labels for a node of a GKE node pool.
+func IsGKENode(labels map[string]string) bool {
+	return labels["cloud.google.com/gke-nodepool"] != ""
+}
+
+// IsSpot checks the node labels for a Spot VM, of GKE or of the spot capacity of EKS, AKS and Karpenter
+// for clusters of other clouds.
+func IsSpot(labels map[string]string) bool {
+	return labels["cloud.google.com/gke-spot"] == "true" ||
+		labels["eks.amazonaws.com/capacityType"] == "SPOT" ||
+		labels["kubernetes.azure.com/scalesetpriority"] == "spot" ||
+		labels["karpenter.sh/capacity-type"] == "spot"
+}
+
+// EquivalentMachineType names the N2 custom machine type with the capacity of a node that doesn't run on GCE,
+// eg. n2-custom-4-16384, rounded up to an even number of vCPUs and to 256 MiB of memory as custom machines are.
+// It is empty if the node reports no capacity.
+func EquivalentMachineType(capacity v1.ResourceList) string {
+	cpus := capacity.Cpu().Value()
+	if cpus == 0 {
+		return ""
+	}
+	cpus += cpus % 2
+
+	memory := (capacity.Memory().Value()/1024/1024 + 255) / 256 * 256
+
+	return fmt.Sprintf("n2-custom-%d-%d", cpus, memory)
+}
+
+// IsConfidential checks node labels or a pod node selector for Confidential GKE Nodes.
+func IsConfidential(labels map[string]string) bool {
+	return labels["cloud.google.com/gke-confidential-nodes"] == "true" || labels["cloud.google.com/gke-confidential-nodes-instance-type"] != ""
+}
+
+// IsWindows checks node labels or a pod node selector for Windows Server nodes.
+func IsWindows(labels map[string]string) bool {
+	return labels["kubernetes.io/os"] == "windows"
+}
+
+// IsFlexStart checks node labels or a pod node selector for capacity from the Dynamic Workload
+// Scheduler, either flex-start or queued provisioning.
+func IsFlexStart(labels map[string]string) bool {
+	return labels["cloud.google.com/gke-flex-start"] == "true" || labels["cloud.google.com/gke-queued"] == "true"
+}
+
+// TPU_RESOURCE is the extended resource containers request TPU chips with.
+const TPU_RESOURCE = "google.com/tpu"
+
+// GetTPUType returns the TPU version and topology a pod selects with the cloud.google.com/gke-tpu-accelerator
+// and cloud.google.com/gke-tpu-topology node selectors, eg. tpu-v5-lite-podslice and 2x4.
+func GetTPUType(selector map[string]string) (string, string) {
+	return selector["cloud.google.com/gke-tpu-accelerator"], selector["cloud.google.com/gke-tpu-topology"]
+}
+
+// MIG_COMPUTE_SLICES is the number of compute slices a GPU is partitioned into with Multi-Instance GPU.
+const MIG_COMPUTE_SLICES = 7
+
+// GetGPUShare returns how a pod shares its GPUs with other pods, by the cloud.google.com/gke-gpu-sharing-strategy,
+// cloud.google.com/gke-max-shared-clients-per-gpu and cloud.google.com/gke-gpu-partition-size node labels or node
+// selectors, and the share of a physical GPU it gets. Unshared GPUs have a share of 1.
+func GetGPUShare(selector map[string]string) (string, float64) {
+	var strategies []string
+	share := 1.0
+
+	// MIG partitions are named by their compute slices and memory, eg. 1g.5gb
+	if partition := selector["cloud.google.com/gke-gpu-partition-size"]; partition != "" {
+		slices, err := strconv.Atoi(strings.SplitN(partition, "g.", 2)[0])
+		if err == nil && slices > 0 && slices < MIG_COMPUTE_SLICES {
+			strategies = append(strategies, "mig")
+			share /= float64(MIG_COMPUTE_SLICES) / float64(slices)
+		}
+	}
+
+	// Time-sharing and MPS split the GPU, or the MIG partition, between the clients
+	if strategy := selector["cloud.google.com/gke-gpu-sharing-strategy"]; strategy == "time-sharing" || strategy == "mps" {
+		clients, err := strconv.Atoi(selector["cloud.google.com/gke-max-shared-clients-per-gpu"])
+		if err == nil && clients > 1 {
+			strategies = append(strategies, strategy)
+			share /= float64(clients)
+		}
 	}
-	return pods, nil
+
+	return strings.Join(strategies, "+"), share
+}
+
+// IsBurstable checks if the CPU or memory limit of the container is above its request, so it can burst.
+func IsBurstable(container v1.Container) bool {
+	cpuLimit := container.Resources.Limits[v1.ResourceCPU]
+	memoryLimit := container.Resources.Limits[v1.ResourceMemory]
+
+	return cpuLimit.Cmp(container.Resources.Requests[v1.ResourceCPU]) > 0 || memoryLimit.Cmp(container.Resources.Requests[v1.ResourceMemory]) > 0
+}
+
+// IsNativeSidecar checks if an init container is a native sidecar, which has restartPolicy Always and keeps
+// running next to the containers.
+func IsNativeSidecar(container v1.Container) bool {
+	return container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways
+}
+
+// GetInitContainerPeak returns the largest mCPU, memory and ephemeral storage in MiB the init containers of the
+// pod request while they run one after another, each with the native sidecars started before it. Autopilot bills
+// the larger of the peak and the sum of the containers and native sidecars.
+func GetInitContainerPeak(spec v1.PodSpec) (int64, int64, int64) {
+	var cpuPeak, memoryPeak, storagePeak int64
+	var sidecarCpu, sidecarMemory, sidecarStorage int64
+
+	for _, container := range spec.InitContainers {
+		cpuRequest := container.Resources.Requests[v1.ResourceCPU]
+		memoryRequest := container.Resources.Requests[v1.ResourceMemory]
+		storageRequest := container.Resources.Requests[v1.ResourceEphemeralStorage]
+
+		cpu := cpuRequest.MilliValue()
+		memory := memoryRequest.MilliValue() / 1000000000   // Division to get MiB
+		storage := storageRequest.MilliValue() / 1000000000 // Division to get MiB
+
+		if IsNativeSidecar(container) {
+			sidecarCpu += cpu
+			sidecarMemory += memory
+			sidecarStorage += storage
+			continue
+		}
+
+		if cpu+sidecarCpu > cpuPeak {
+			cpuPeak = cpu + sidecarCpu
+		}
+		if memory+sidecarMemory > memoryPeak {
+			memoryPeak = memory + sidecarMemory
+		}
+		if storage+sidecarStorage > storagePeak {
+			storagePeak = storage + sidecarStorage
+		}
+	}
+
+	return cpuPeak, memoryPeak, storagePeak
+}
+
+// GetMachineFamily returns the machine family a pod is pinned to with the cloud.google.com/machine-family
+// node selector or a required node affinity on the same label. Affinities with more than one family are ignored.
+func GetMachineFamily(spec v1.PodSpec) string {
+	return getRequiredNodeLabel(spec, "cloud.google.com/machine-family")
 }
 
-func ListNamespaces(client kubernetes.Interface) (*v1.NamespaceList, error) {
-	namespaces, err := client.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+// getRequiredNodeLabel returns the value of a node label the pod requires with its node selector,
+// or with a required node affinity with a single value.
+func getRequiredNodeLabel(spec v1.PodSpec, key string) string {
+	if value := spec.NodeSelector[key]; value != "" {
+		return value
+	}
+
+	if spec.Affinity == nil || spec.Affinity.NodeAffinity == nil || spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+
+	for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == key && expression.Operator == v1.NodeSelectorOpIn && len(expression.Values) == 1 {
+				return expression.Values[0]
+			}
+		}
+	}
+
+	return ""
+}
+
+// SortedNodes returns the nodes ordered by name, so that every output built
+// from the nodes map is stable between runs.
+func SortedNodes(nodes map[string]Node) []Node {
+	sorted := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		sorted = append(sorted, node)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// SortWorkloads orders workloads by name, then namespace to break ties between pods with the
+// same name in different namespaces, then node name.
+func SortWorkloads(workloads []Workload) {
+	sort.SliceStable(workloads, func(i, j int) bool {
+		if workloads[i].Name != workloads[j].Name {
+			return workloads[i].Name < workloads[j].Name
+		}
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		return workloads[i].Node_name < workloads[j].Node_name
+	})
+}
+
+// CollectWarnings gathers the warnings of all workloads into a single list.
+func CollectWarnings(workloads []Workload) []Warning {
+	warnings := []Warning{}
+	for _, workload := range workloads {
+		warnings = append(warnings, workload.Warnings...)
+	}
+
+	return warnings
+}
+
+// GetMigrationBlockers returns the warnings of the workloads that can't run in Autopilot as they are.
+func GetMigrationBlockers(workloads []Workload) []Warning {
+	blockers := []Warning{}
+	for _, workload := range workloads {
+		for _, warning := range workload.Warnings {
+			if warning.Type == WarningMigrationBlocker {
+				blockers = append(blockers, warning)
+			}
+		}
+	}
+
+	return blockers
+}
+
+// GetNamespaceCosts sums workload costs per namespace, ordered by namespace.
+func GetNamespaceCosts(nodes map[string]Node) []NamespaceCost {
+	costs := make(map[string]NamespaceCost)
+	for _, node := range nodes {
+		for _, workload := range node.Workloads {
+			entry := costs[workload.Namespace]
+			entry.Namespace = workload.Namespace
+			entry.Workloads++
+			entry.Cost += workload.Cost
+			entry.EffectiveCost += workload.EffectiveCost
+			costs[workload.Namespace] = entry
+		}
+	}
+
+	namespaces := make([]NamespaceCost, 0, len(costs))
+	for _, namespace := range costs {
+		namespaces = append(namespaces, namespace)
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Namespace < namespaces[j].Namespace
+	})
+
+	return namespaces
+}
+
+// LabelCost is the summed cost of the workloads with the same value of an allocation label.
+type LabelCost struct {
+	Value         string
+	Workloads     int
+	Cost          Money
+	EffectiveCost Money
+}
+
+// UNLABELED is the value of workloads without the allocation label.
+const UNLABELED = "(unlabeled)"
+
+// GetLabelCosts sums workload costs per value of the label, eg. team, ordered by value.
+func GetLabelCosts(nodes map[string]Node, label string) []LabelCost {
+	costs := make(map[string]LabelCost)
+	for _, node := range nodes {
+		for _, workload := range node.Workloads {
+			value, ok := workload.Labels[label]
+			if !ok || value == "" {
+				value = UNLABELED
+			}
+
+			entry := costs[value]
+			entry.Value = value
+			entry.Workloads++
+			entry.Cost += workload.Cost
+			entry.EffectiveCost += workload.EffectiveCost
+			costs[value] = entry
+		}
+	}
+
+	values := make([]LabelCost, 0, len(costs))
+	for _, value := range costs {
+		values = append(values, value)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Value < values[j].Value
+	})
+
+	return values
+}
+
+func ListNamespaces(ctx context.Context, client kubernetes.Interface) (*v1.NamespaceList, error) {
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		err = fmt.Errorf("error getting namespaces: %v", err)
 		return nil, err
@@ -141,20 +631,52 @@ func ListNamespaces(client kubernetes.Interface) (*v1.NamespaceList, error) {
 	return namespaces, nil
 }
 
-func ListNodes(client kubernetes.Interface) (*v1.NodeList, error) {
-	nodes, err := client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
-	if err != nil {
-		err = fmt.Errorf("error getting namespaces: %v", err)
-		return nil, err
+func ListNodes(ctx context.Context, client kubernetes.Interface) (*v1.NodeList, error) {
+	nodes := &v1.NodeList{}
+
+	options := metav1.ListOptions{Limit: POD_PAGE_SIZE}
+	for {
+		page, err := client.CoreV1().Nodes().List(ctx, options)
+		if err != nil {
+			err = fmt.Errorf("error getting nodes: %v", err)
+			return nil, err
+		}
+		nodes.Items = append(nodes.Items, page.Items...)
+
+		if page.Continue == "" {
+			return nodes, nil
+		}
+		options.Continue = page.Continue
 	}
-	return nodes, nil
 }
 
-func DescribePod(client kubernetes.Interface, podName string, namespace string) (*v1.Pod, error) {
-	pod, err := client.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
-	if err != nil {
-		err = fmt.Errorf("error getting pods: %v", err)
-		return nil, err
+// POD_PAGE_SIZE is the number of pods, pod metrics, nodes or jobs listed per call, so big clusters are listed in pages.
+const POD_PAGE_SIZE = 500
+
+// PodKey identifies a pod across the pod and metrics lists.
+func PodKey(namespace string, pod string) string {
+	return namespace + "/" + pod
+}
+
+// EachPod lists the pods of the namespace, all if empty, page by page and calls fn with every pod, so
+// only a page of pods is held in memory and the pods of the metrics are not fetched with a call per pod.
+func EachPod(ctx context.Context, client kubernetes.Interface, namespace string, options metav1.ListOptions, fn func(pod *v1.Pod) error) error {
+	options.Limit = POD_PAGE_SIZE
+	for {
+		page, err := client.CoreV1().Pods(namespace).List(ctx, options)
+		if err != nil {
+			return fmt.Errorf("error getting pods: %v", err)
+		}
+
+		for i := range page.Items {
+			if err := fn(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+
+		if page.Continue == "" {
+			return nil
+		}
+		options.Continue = page.Continue
 	}
-	return pod, nil
 }
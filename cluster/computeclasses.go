@@ -0,0 +1,95 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// COMPUTE_CLASS_SELECTOR is the node label pods select a compute class with, an Autopilot compute class or a ComputeClass object.
+const COMPUTE_CLASS_SELECTOR = "cloud.google.com/compute-class"
+
+var computeClassResource = schema.GroupVersionResource{Group: "cloud.google.com", Version: "v1", Resource: "computeclasses"}
+
+// ComputeClassPriority is a rule of a custom compute class. GKE tries to create nodes by the rules in order.
+type ComputeClassPriority struct {
+	MachineFamily string
+	MachineType   string
+	GPUType       string
+	GPUCount      int64
+	TPUType       string
+	// PodFamily is set for the rules that run pods in an Autopilot compute class, eg. general-purpose
+	PodFamily string
+	Spot      bool
+}
+
+// CustomComputeClass is a ComputeClass object of the cluster.
+type CustomComputeClass struct {
+	Name       string
+	Priorities []ComputeClassPriority
+}
+
+// ListComputeClasses reads the ComputeClass objects of the cluster by name. Clusters without the ComputeClass CRD have none.
+func ListComputeClasses(ctx context.Context, dynamicClient dynamic.Interface) (map[string]CustomComputeClass, error) {
+	classes := make(map[string]CustomComputeClass)
+
+	objects, err := dynamicClient.Resource(computeClassResource).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return classes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting compute classes: %v", err)
+	}
+
+	for _, object := range objects.Items {
+		class := CustomComputeClass{Name: object.GetName()}
+
+		priorities, _, _ := unstructured.NestedSlice(object.Object, "spec", "priorities")
+		for _, item := range priorities {
+			rule, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			var priority ComputeClassPriority
+			priority.MachineFamily, _, _ = unstructured.NestedString(rule, "machineFamily")
+			priority.MachineType, _, _ = unstructured.NestedString(rule, "machineType")
+			priority.GPUType, _, _ = unstructured.NestedString(rule, "gpu", "type")
+			priority.GPUCount, _, _ = unstructured.NestedInt64(rule, "gpu", "count")
+			priority.TPUType, _, _ = unstructured.NestedString(rule, "tpu", "type")
+			priority.PodFamily, _, _ = unstructured.NestedString(rule, "podFamily")
+			priority.Spot, _, _ = unstructured.NestedBool(rule, "spot")
+
+			class.Priorities = append(class.Priorities, priority)
+		}
+
+		classes[class.Name] = class
+	}
+
+	return classes, nil
+}
+
+// GetComputeClassName returns the compute class a pod selects with its node selector or a required node affinity, empty if none.
+func GetComputeClassName(spec v1.PodSpec) string {
+	return getRequiredNodeLabel(spec, COMPUTE_CLASS_SELECTOR)
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SPOT_TERMINATION_NOTICE is the notice in seconds a Spot VM gets before it is preempted, pods
+// that take longer to shut down are killed before they are done.
+const SPOT_TERMINATION_NOTICE = 30
+
+// GetTerminationGracePeriod returns the terminationGracePeriodSeconds of the pod spec, 0 if it is not set.
+func GetTerminationGracePeriod(spec v1.PodSpec) int64 {
+	if spec.TerminationGracePeriodSeconds == nil {
+		return 0
+	}
+	return *spec.TerminationGracePeriodSeconds
+}
+
+// GetSpotBlockers returns the reasons why workloads may not be safe to run as Spot Pods, keyed by
+// namespace/name. Spot preemption doesn't respect PodDisruptionBudgets, so stateful workloads, single
+// replicas and pods of a budget that allows no disruptions are flagged. Pods without a controller
+// are not recreated after a preemption, and pods with a termination grace period above the Spot
+// notice don't get to shut down cleanly. Jobs restart their pods, so single Job pods are fine.
+// Workloads without a reason look spot-safe.
+func GetSpotBlockers(ctx context.Context, client kubernetes.Interface, workloads []Workload) (map[string][]string, error) {
+	budgets, err := client.PolicyV1().PodDisruptionBudgets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("error listing pod disruption budgets: %v", err)
+		return nil, err
+	}
+
+	replicas := make(map[string]int)
+	for _, workload := range workloads {
+		if workload.Owner.Kind != "" {
+			replicas[fmt.Sprintf("%s/%s/%s", workload.Namespace, workload.Owner.Kind, workload.Owner.Name)]++
+		}
+	}
+
+	blockers := make(map[string][]string)
+	for _, workload := range workloads {
+		var reasons []string
+
+		if workload.Owner.Kind == "StatefulSet" || HasPersistentDisk(workload.Disks) {
+			reasons = append(reasons, "stateful")
+		}
+
+		switch {
+		case workload.Owner.Kind == "":
+			reasons = append(reasons, "no controller")
+		case workload.Owner.Kind == "Job":
+		case replicas[fmt.Sprintf("%s/%s/%s", workload.Namespace, workload.Owner.Kind, workload.Owner.Name)] < 2:
+			reasons = append(reasons, "single replica")
+		}
+
+		if workload.GracePeriod > SPOT_TERMINATION_NOTICE {
+			reasons = append(reasons, fmt.Sprintf("termination grace period of %ds", workload.GracePeriod))
+		}
+
+		for _, budget := range budgets.Items {
+			if budget.Namespace != workload.Namespace || budget.Status.DisruptionsAllowed > 0 {
+				continue
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+			if err != nil || selector.Empty() || !selector.Matches(labels.Set(workload.Labels)) {
+				continue
+			}
+			reasons = append(reasons, fmt.Sprintf("disruption budget %s allows no disruptions", budget.Name))
+		}
+
+		if len(reasons) > 0 {
+			blockers[workload.Namespace+"/"+workload.Name] = reasons
+		}
+	}
+
+	return blockers, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/compute/metadata"
+)
+
+// GetInClusterContext discovers the cluster the calculator runs in from the metadata server
+// of the node. It returns the same parts as GetCurrentContext: gke, project, location and name.
+func GetInClusterContext() ([]string, error) {
+	if !metadata.OnGCE() {
+		return nil, fmt.Errorf("metadata server is not available, unable to discover the cluster")
+	}
+
+	project, err := metadata.ProjectID()
+	if err != nil {
+		err = fmt.Errorf("error getting project from metadata server: %v", err)
+		return nil, err
+	}
+
+	// GKE sets both attributes on every node of the cluster
+	location, err := metadata.InstanceAttributeValue("cluster-location")
+	if err != nil {
+		err = fmt.Errorf("error getting cluster location from metadata server: %v", err)
+		return nil, err
+	}
+
+	name, err := metadata.InstanceAttributeValue("cluster-name")
+	if err != nil {
+		err = fmt.Errorf("error getting cluster name from metadata server: %v", err)
+		return nil, err
+	}
+
+	return []string{"gke", project, location, name}, nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// JobRuns is how often the pods of a Job run and for how long, so their cost can be prorated to the time they run.
+type JobRuns struct {
+	// Schedule is the schedule of the CronJob of the Job
+	Schedule     string `json:",omitempty"`
+	RunsPerMonth float64
+	RunHours     float64
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parseCronField returns the values of a field of a cron schedule between min and max, eg. 0,15,30,45 for */15.
+// Names are the values of min and up, eg. jan for the month 1.
+func parseCronField(field string, min int, max int, names []string) (map[int]bool, error) {
+	values := make(map[int]bool)
+
+	parseValue := func(value string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(value, name) {
+				return min + i, nil
+			}
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < min || number > max {
+			return 0, fmt.Errorf("value %q is not between %d and %d", value, min, max)
+		}
+		return number, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepPart)
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("step %q is not a positive number", stepPart)
+			}
+		}
+
+		first, last := min, max
+		if rangePart != "*" && rangePart != "?" {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			first, err = parseValue(startPart)
+			if err != nil {
+				return nil, err
+			}
+			last = first
+			if isRange {
+				last, err = parseValue(endPart)
+				if err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				last = max
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+// GetScheduleRunsPerMonth counts the runs of a CronJob schedule, eg. "0 */6 * * *" or "@daily", in an average month.
+// Like cron, a day matches either the day of the month or the day of the week if both are restricted.
+func GetScheduleRunsPerMonth(schedule string) (float64, error) {
+	fields := strings.Fields(schedule)
+	// The time zone doesn't change how often the schedule runs
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "TZ=") || strings.HasPrefix(fields[0], "CRON_TZ=")) {
+		fields = fields[1:]
+	}
+	if len(fields) == 1 {
+		if macro, ok := cronMacros[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(macro)
+		}
+	}
+	if len(fields) != 5 {
+		return 0, fmt.Errorf("schedule %q doesn't have 5 fields", schedule)
+	}
+
+	minutes, err := parseCronField(fields[0], 0, 59, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing minutes of schedule %q: %v", schedule, err)
+	}
+	hours, err := parseCronField(fields[1], 0, 23, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing hours of schedule %q: %v", schedule, err)
+	}
+	daysOfMonth, err := parseCronField(fields[2], 1, 31, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing days of month of schedule %q: %v", schedule, err)
+	}
+	months, err := parseCronField(fields[3], 1, 12, cronMonths)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing months of schedule %q: %v", schedule, err)
+	}
+	// Sunday is both 0 and 7
+	daysOfWeek, err := parseCronField(fields[4], 0, 7, cronWeekdays)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing days of week of schedule %q: %v", schedule, err)
+	}
+	daysOfWeek[0] = daysOfWeek[0] || daysOfWeek[7]
+
+	anyDayOfMonth := strings.HasPrefix(fields[2], "*") || fields[2] == "?"
+	anyDayOfWeek := strings.HasPrefix(fields[4], "*") || fields[4] == "?"
+
+	// The days are counted over a year without a leap day
+	days := 0
+	for day := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC); day.Year() == 2023; day = day.AddDate(0, 0, 1) {
+		if !months[int(day.Month())] {
+			continue
+		}
+
+		dayOfMonth, dayOfWeek := daysOfMonth[day.Day()], daysOfWeek[int(day.Weekday())]
+		matches := dayOfMonth && dayOfWeek
+		if !anyDayOfMonth && !anyDayOfWeek {
+			matches = dayOfMonth || dayOfWeek
+		}
+		if matches {
+			days++
+		}
+	}
+
+	return float64(days*len(hours)*len(minutes)) / 12, nil
+}
+
+// GetJobRuns returns how often the Jobs of the cluster run, keyed by namespace/name. Jobs of a CronJob run
+// as often as its schedule, for as long as the completed Jobs of the CronJob took on average, or as long as
+// the Job has run so far if none completed yet. Jobs that run once don't recur every month, so they are left
+// out and priced like the snapshot.
+func GetJobRuns(ctx context.Context, client kubernetes.Interface, now time.Time) (map[string]JobRuns, error) {
+	cronJobs, err := listCronJobs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := listJobs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := make(map[string]string)
+	for _, cronJob := range cronJobs {
+		schedules[cronJob.Namespace+"/"+cronJob.Name] = cronJob.Spec.Schedule
+	}
+
+	// Average run time of the completed Jobs of every CronJob
+	completedHours := make(map[string]float64)
+	completed := make(map[string]int)
+	for _, job := range jobs {
+		controller := metav1.GetControllerOf(&job)
+		if controller == nil || controller.Kind != "CronJob" || job.Status.StartTime == nil || job.Status.CompletionTime == nil {
+			continue
+		}
+		key := job.Namespace + "/" + controller.Name
+		completedHours[key] += job.Status.CompletionTime.Sub(job.Status.StartTime.Time).Hours()
+		completed[key]++
+	}
+
+	runs := make(map[string]JobRuns)
+	for _, job := range jobs {
+		controller := metav1.GetControllerOf(&job)
+		if controller == nil || controller.Kind != "CronJob" {
+			continue
+		}
+
+		key := job.Namespace + "/" + controller.Name
+		schedule, ok := schedules[key]
+		if !ok {
+			continue
+		}
+		jobRuns := JobRuns{Schedule: schedule}
+		jobRuns.RunsPerMonth, err = GetScheduleRunsPerMonth(schedule)
+		if err != nil {
+			continue
+		}
+
+		if completed[key] > 0 {
+			jobRuns.RunHours = completedHours[key] / float64(completed[key])
+		} else if job.Status.StartTime != nil {
+			jobRuns.RunHours = now.Sub(job.Status.StartTime.Time).Hours()
+		}
+
+		if jobRuns.RunsPerMonth > 0 && jobRuns.RunHours > 0 {
+			runs[job.Namespace+"/"+job.Name] = jobRuns
+		}
+	}
+
+	return runs, nil
+}
+
+// listCronJobs lists the CronJobs of the cluster page by page.
+func listCronJobs(ctx context.Context, client kubernetes.Interface) ([]batchv1.CronJob, error) {
+	var cronJobs []batchv1.CronJob
+
+	options := metav1.ListOptions{Limit: POD_PAGE_SIZE}
+	for {
+		page, err := client.BatchV1().CronJobs("").List(ctx, options)
+		if err != nil {
+			return nil, fmt.Errorf("error listing cronjobs: %v", err)
+		}
+		cronJobs = append(cronJobs, page.Items...)
+
+		if page.Continue == "" {
+			return cronJobs, nil
+		}
+		options.Continue = page.Continue
+	}
+}
+
+// listJobs lists the Jobs of the cluster page by page.
+func listJobs(ctx context.Context, client kubernetes.Interface) ([]batchv1.Job, error) {
+	var jobs []batchv1.Job
+
+	options := metav1.ListOptions{Limit: POD_PAGE_SIZE}
+	for {
+		page, err := client.BatchV1().Jobs("").List(ctx, options)
+		if err != nil {
+			return nil, fmt.Errorf("error listing jobs: %v", err)
+		}
+		jobs = append(jobs, page.Items...)
+
+		if page.Continue == "" {
+			return jobs, nil
+		}
+		options.Continue = page.Continue
+	}
+}
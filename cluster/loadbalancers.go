@@ -0,0 +1,94 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var gatewayResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "gateways"}
+
+// LoadBalancer is a Service, Ingress or Gateway that provisions a Cloud Load Balancer with its own forwarding rule.
+type LoadBalancer struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Hourly cost of the forwarding rule and the processed data
+	Cost Money
+}
+
+// GetLoadBalancers lists the Services of type LoadBalancer, the GKE Ingresses and the GKE Gateways of the cluster.
+// Gateways are skipped if the Gateway API isn't enabled.
+func GetLoadBalancers(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface) ([]LoadBalancer, error) {
+	loadBalancers := []LoadBalancer{}
+
+	services, err := client.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("error getting services: %v", err)
+		return nil, err
+	}
+	for _, service := range services.Items {
+		if service.Spec.Type == v1.ServiceTypeLoadBalancer {
+			loadBalancers = append(loadBalancers, LoadBalancer{Kind: "Service", Namespace: service.Namespace, Name: service.Name})
+		}
+	}
+
+	ingresses, err := client.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("error getting ingresses: %v", err)
+		return nil, err
+	}
+	for _, ingress := range ingresses.Items {
+		// Without a class, GKE provisions an external Application Load Balancer
+		class := ingress.Annotations["kubernetes.io/ingress.class"]
+		if ingress.Spec.IngressClassName != nil {
+			class = *ingress.Spec.IngressClassName
+		}
+		if class == "" || class == "gce" || class == "gce-internal" {
+			loadBalancers = append(loadBalancers, LoadBalancer{Kind: "Ingress", Namespace: ingress.Namespace, Name: ingress.Name})
+		}
+	}
+
+	if dynamicClient == nil {
+		return loadBalancers, nil
+	}
+
+	gateways, err := dynamicClient.Resource(gatewayResource).Namespace("").List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return loadBalancers, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("error getting gateways: %v", err)
+		return nil, err
+	}
+	for _, gateway := range gateways.Items {
+		class, _, _ := unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+		if strings.HasPrefix(class, "gke-") {
+			loadBalancers = append(loadBalancers, LoadBalancer{Kind: "Gateway", Namespace: gateway.GetNamespace(), Name: gateway.GetName()})
+		}
+	}
+
+	return loadBalancers, nil
+}
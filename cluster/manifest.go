@@ -0,0 +1,155 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ErrUnsupportedManifest is returned for manifests of objects without a pod template, eg. Services or custom resources.
+var ErrUnsupportedManifest = errors.New("unsupported manifest kind")
+
+// PodTemplate is the pod spec of a manifest together with the object it was read from.
+type PodTemplate struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Replicas  int32
+	Spec      v1.PodSpec
+}
+
+// DecodePodTemplate reads a single YAML or JSON manifest of a Pod or a controller and returns its pod spec.
+func DecodePodTemplate(data []byte) (*PodTemplate, error) {
+	object, gvk, err := scheme.Codecs.UniversalDeserializer().Decode(data, nil, nil)
+	if runtime.IsNotRegisteredError(err) || runtime.IsMissingKind(err) {
+		err = fmt.Errorf("%w: %v", ErrUnsupportedManifest, err)
+		return nil, err
+	}
+	if err != nil {
+		err = fmt.Errorf("error decoding manifest: %v", err)
+		return nil, err
+	}
+
+	template := &PodTemplate{Kind: gvk.Kind, Replicas: 1}
+
+	switch object := object.(type) {
+	case *v1.Pod:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec
+	case *appsv1.Deployment:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec.Template.Spec
+		if object.Spec.Replicas != nil {
+			template.Replicas = *object.Spec.Replicas
+		}
+	case *appsv1.StatefulSet:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec.Template.Spec
+		if object.Spec.Replicas != nil {
+			template.Replicas = *object.Spec.Replicas
+		}
+	case *appsv1.ReplicaSet:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec.Template.Spec
+		if object.Spec.Replicas != nil {
+			template.Replicas = *object.Spec.Replicas
+		}
+	case *appsv1.DaemonSet:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec.Template.Spec
+	case *batchv1.Job:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec.Template.Spec
+		if object.Spec.Parallelism != nil {
+			template.Replicas = *object.Spec.Parallelism
+		}
+	case *batchv1.CronJob:
+		template.Name, template.Namespace, template.Spec = object.Name, object.Namespace, object.Spec.JobTemplate.Spec.Template.Spec
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedManifest, gvk.Kind)
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// ReadPodTemplates reads every manifest of a YAML or JSON file, or of all such files in a directory
+// and its subdirectories. Objects without a pod template are skipped.
+func ReadPodTemplates(path string) ([]*PodTemplate, error) {
+	var templates []*PodTemplate
+
+	err := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		extension := strings.ToLower(filepath.Ext(file))
+		if extension != ".yaml" && extension != ".yml" && extension != ".json" {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		// A single file can hold several documents separated by ---
+		reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+		for {
+			document, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", file, err)
+			}
+			if len(bytes.TrimSpace(document)) == 0 {
+				continue
+			}
+
+			template, err := DecodePodTemplate(document)
+			if errors.Is(err, ErrUnsupportedManifest) {
+				logging.Warn("Skipping %s: %v", file, err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error reading %s: %v", file, err)
+			}
+
+			templates = append(templates, template)
+		}
+
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("error reading manifests: %v", err)
+		return nil, err
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const microsPerUnit = 1000000
+
+// microsPrecision is the number of decimal places of a micro.
+const microsPrecision = 6
+
+// DisplayPrecision is the number of decimal places used when money is printed
+// in tables. JSON keeps every micro, see MarshalJSON.
+var DisplayPrecision = 4
+
+// DisplayCurrency is the ISO 4217 code of the currency prices are fetched and printed in.
+var DisplayCurrency = "USD"
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"INR": "₹",
+}
+
+// CurrencySymbol returns the symbol of the display currency, or its code if it has no common symbol.
+func CurrencySymbol() string {
+	if symbol, ok := currencySymbols[DisplayCurrency]; ok {
+		return symbol
+	}
+	return DisplayCurrency
+}
+
+// Money is a monetary amount stored as integer micros, so summing thousands of
+// workload costs doesn't accumulate floating point drift.
+type Money int64
+
+func NewMoney(amount float64) Money {
+	return Money(math.Round(amount * microsPerUnit))
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / microsPerUnit
+}
+
+// Mul scales the amount by a factor, eg. a commitment discount multiplier.
+func (m Money) Mul(factor float64) Money {
+	return Money(math.Round(float64(m) * factor))
+}
+
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Float64(), 'f', DisplayPrecision, 64)
+}
+
+// MarshalJSON writes the amount with every micro, regardless of DisplayPrecision, so machine-readable
+// reports can be summed and compared without rounding errors.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(m.Float64(), 'f', microsPrecision, 64)), nil
+}
+
+// UnmarshalJSON reads an amount written by MarshalJSON, eg. to compare saved reports.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	amount, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("error parsing amount %s: %v", data, err)
+	}
+
+	*m = NewMoney(amount)
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// ContainerUsage is the CPU (mCPU) and memory (MiB) usage of a container.
+type ContainerUsage struct {
+	Cpu    int64
+	Memory int64
+}
+
+// UsageHistory is the usage of containers over a window, sized at a percentile, keyed by UsageKey.
+// It replaces the point in time usage from metrics-server.
+type UsageHistory map[string]ContainerUsage
+
+// UsageKey identifies a container of a pod in the usage history.
+func UsageKey(namespace string, pod string, container string) string {
+	return namespace + "/" + pod + "/" + container
+}
+
+// Percentile returns the value below which the percentile (0-100) of the values fall, using the nearest rank.
+func Percentile(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(percentile / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
+
+// MONITORING_ALIGNMENT is the period usage samples of Cloud Monitoring are aligned to before the percentile is taken.
+const MONITORING_ALIGNMENT = 5 * time.Minute
+
+// GetMonitoringUsage reads the CPU and memory usage of every container of the cluster over the window from
+// Cloud Monitoring and sizes it at the percentile, eg. 95. Memory is the non-evictable working set.
+func GetMonitoringUsage(ctx context.Context, project string, location string, clusterName string, window time.Duration, percentile float64) (UsageHistory, error) {
+	monitoringService, err := monitoring.NewService(ctx)
+	if err != nil {
+		err = fmt.Errorf("unable to initialize cloud monitoring service: %v", err)
+		return nil, err
+	}
+
+	end := time.Now().UTC()
+	start := end.Add(-window)
+	resourceFilter := fmt.Sprintf(`resource.type="k8s_container" AND resource.labels.cluster_name=%q AND resource.labels.location=%q`, clusterName, location)
+
+	listUsage := func(filter string, aligner string) (map[string][]float64, error) {
+		values := make(map[string][]float64)
+
+		err := monitoringService.Projects.TimeSeries.List("projects/"+project).
+			Filter(filter+" AND "+resourceFilter).
+			IntervalStartTime(start.Format(time.RFC3339)).
+			IntervalEndTime(end.Format(time.RFC3339)).
+			AggregationAlignmentPeriod(fmt.Sprintf("%ds", int(MONITORING_ALIGNMENT.Seconds()))).
+			AggregationPerSeriesAligner(aligner).
+			Pages(ctx, func(response *monitoring.ListTimeSeriesResponse) error {
+				usage.Count(usage.Monitoring)
+
+				for _, series := range response.TimeSeries {
+					labels := series.Resource.Labels
+					key := UsageKey(labels["namespace_name"], labels["pod_name"], labels["container_name"])
+
+					for _, point := range series.Points {
+						if point.Value == nil {
+							continue
+						}
+						if point.Value.DoubleValue != nil {
+							values[key] = append(values[key], *point.Value.DoubleValue)
+						} else if point.Value.Int64Value != nil {
+							values[key] = append(values[key], float64(*point.Value.Int64Value))
+						}
+					}
+				}
+				return nil
+			})
+
+		return values, err
+	}
+
+	cpuValues, err := listUsage(`metric.type="kubernetes.io/container/cpu/core_usage_time"`, "ALIGN_RATE")
+	if err != nil {
+		err = fmt.Errorf("unable to fetch cpu usage from cloud monitoring: %v", err)
+		return nil, err
+	}
+
+	memoryValues, err := listUsage(`metric.type="kubernetes.io/container/memory/used_bytes" AND metric.labels.memory_type="non-evictable"`, "ALIGN_MAX")
+	if err != nil {
+		err = fmt.Errorf("unable to fetch memory usage from cloud monitoring: %v", err)
+		return nil, err
+	}
+
+	return newUsageHistory(cpuValues, memoryValues, percentile), nil
+}
+
+// newUsageHistory sizes CPU usage in cores and memory usage in bytes at the percentile.
+func newUsageHistory(cpuValues map[string][]float64, memoryValues map[string][]float64, percentile float64) UsageHistory {
+	history := make(UsageHistory)
+	for key, values := range cpuValues {
+		history.setCpu(key, Percentile(values, percentile))
+	}
+	for key, values := range memoryValues {
+		history.setMemory(key, Percentile(values, percentile))
+	}
+
+	return history
+}
+
+// setCpu sets the CPU usage of the container from cores.
+func (history UsageHistory) setCpu(key string, cores float64) {
+	containerUsage := history[key]
+	containerUsage.Cpu = int64(math.Ceil(cores * 1000))
+	history[key] = containerUsage
+}
+
+// setMemory sets the memory usage of the container from bytes, in the same units as the usage from metrics-server.
+func (history UsageHistory) setMemory(key string, bytes float64) {
+	containerUsage := history[key]
+	containerUsage.Memory = int64(math.Ceil(bytes / 1000000))
+	history[key] = containerUsage
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SystemNamespaces run the GKE system components, which are not billed on Autopilot.
+var SystemNamespaces = []string{"kube-system", "gke-gmp-system", "gmp-system"}
+
+// NamespaceFilter selects the namespaces whose workloads are estimated. Patterns are globs, eg. team-*.
+// With no Include patterns all namespaces are selected except the excluded and system ones. System
+// namespaces are only matched by name, eg. -namespace kube-system, globs like kube-* leave them out.
+type NamespaceFilter struct {
+	Include []string
+	Exclude []string
+	// IncludeSystem selects the system namespaces as well, unless they are excluded
+	IncludeSystem bool
+}
+
+// IsSystemNamespace checks if the namespace runs GKE system components.
+func IsSystemNamespace(namespace string) bool {
+	return matchesAny(SystemNamespaces, namespace)
+}
+
+// ValidateNamespacePattern checks the glob syntax of a namespace pattern.
+func ValidateNamespacePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid namespace pattern %q: %v", pattern, err)
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, namespace string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, namespace); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches checks if the workloads of the namespace are estimated.
+func (filter NamespaceFilter) Matches(namespace string) bool {
+	if matchesAny(filter.Exclude, namespace) {
+		return false
+	}
+
+	if IsSystemNamespace(namespace) {
+		// The system namespaces are included with IncludeSystem, even if they don't match Include
+		return filter.IncludeSystem || filter.names(namespace)
+	}
+
+	return len(filter.Include) == 0 || matchesAny(filter.Include, namespace)
+}
+
+// names checks if the namespace is included by its name rather than by a glob.
+func (filter NamespaceFilter) names(namespace string) bool {
+	for _, pattern := range filter.Include {
+		if pattern == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectsSystem checks if the workloads of any system namespace are estimated, with IncludeSystem
+// or because a system namespace is included by name.
+func (filter NamespaceFilter) SelectsSystem() bool {
+	for _, namespace := range SystemNamespaces {
+		if filter.Matches(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListNamespace is the namespace to list pods in, so a single namespace can be estimated with
+// namespaced permissions. It is empty, for all namespaces, unless a single namespace without
+// wildcards is included.
+func (filter NamespaceFilter) ListNamespace() string {
+	if len(filter.Include) != 1 || filter.IncludeSystem || strings.ContainsAny(filter.Include[0], `*?[\`) {
+		return ""
+	}
+	return filter.Include[0]
+}
+
+// FieldSelector skips the system namespaces when listing pods, unless they are included.
+func (filter NamespaceFilter) FieldSelector() string {
+	if filter.IncludeSystem {
+		return ""
+	}
+
+	var selectors []string
+	for _, namespace := range SystemNamespaces {
+		if !filter.names(namespace) {
+			selectors = append(selectors, "metadata.namespace!="+namespace)
+		}
+	}
+	return strings.Join(selectors, ",")
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Owner is the controller that manages a pod, eg. a ReplicaSet or a Job.
+// Bare pods have no owner.
+type Owner struct {
+	Kind string
+	Name string
+}
+
+// GetPodOwner returns the direct controller of the pod.
+func GetPodOwner(pod *v1.Pod) Owner {
+	controller := metav1.GetControllerOf(pod)
+	if controller == nil {
+		return Owner{}
+	}
+
+	return Owner{Kind: controller.Kind, Name: controller.Name}
+}
+
+// ResolveOwner follows ReplicaSets up to their Deployment and Jobs up to their
+// CronJob, so that the owner is the object users actually manage.
+func ResolveOwner(ctx context.Context, client kubernetes.Interface, namespace string, owner Owner) (Owner, error) {
+	var controller *metav1.OwnerReference
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		replicaSet, err := client.AppsV1().ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("error getting replicaset: %v", err)
+			return Owner{}, err
+		}
+		controller = metav1.GetControllerOf(replicaSet)
+	case "Job":
+		job, err := client.BatchV1().Jobs(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			err = fmt.Errorf("error getting job: %v", err)
+			return Owner{}, err
+		}
+		controller = metav1.GetControllerOf(job)
+	}
+
+	if controller == nil {
+		return owner, nil
+	}
+
+	return Owner{Kind: controller.Kind, Name: controller.Name}, nil
+}
+
+// OwnerCost is the summed usage and cost of the pods of a top-level controller.
+// Pods without a controller are their own owner of kind Pod.
+type OwnerCost struct {
+	Namespace string
+	Owner     Owner
+	Replicas  int
+	Cpu       int64
+	Memory    int64
+	Storage   int64
+	Cost      Money
+}
+
+// GetOwnerCosts aggregates the workloads by the controller users manage, eg. the pods of
+// a Deployment instead of its ReplicaSets. They are ordered by namespace, kind and name.
+func GetOwnerCosts(ctx context.Context, client kubernetes.Interface, workloads []Workload) ([]OwnerCost, error) {
+	owners := make(map[string]*OwnerCost)
+	// ReplicaSets and Jobs are looked up only once
+	resolved := make(map[string]Owner)
+
+	for _, workload := range workloads {
+		owner := workload.Owner
+		if owner.Kind == "" {
+			owner = Owner{Kind: "Pod", Name: workload.Name}
+		} else {
+			ownerKey := fmt.Sprintf("%s/%s/%s", workload.Namespace, owner.Kind, owner.Name)
+			resolvedOwner, ok := resolved[ownerKey]
+			if !ok {
+				var err error
+				resolvedOwner, err = ResolveOwner(ctx, client, workload.Namespace, owner)
+				if err != nil {
+					return nil, err
+				}
+				resolved[ownerKey] = resolvedOwner
+			}
+			owner = resolvedOwner
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", workload.Namespace, owner.Kind, owner.Name)
+		if _, ok := owners[key]; !ok {
+			owners[key] = &OwnerCost{Namespace: workload.Namespace, Owner: owner}
+		}
+		owners[key].Replicas++
+		owners[key].Cpu += workload.Cpu
+		owners[key].Memory += workload.Memory
+		owners[key].Storage += workload.Storage
+		owners[key].Cost += workload.Cost
+	}
+
+	keys := make([]string, 0, len(owners))
+	for key := range owners {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ownerCosts := make([]OwnerCost, 0, len(keys))
+	for _, key := range keys {
+		ownerCosts = append(ownerCosts, *owners[key])
+	}
+
+	return ownerCosts, nil
+}
+
+// AnnotateOwner merges the annotations into the metadata of the controller.
+func AnnotateOwner(ctx context.Context, client kubernetes.Interface, namespace string, owner Owner, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch owner.Kind {
+	case "Deployment":
+		_, err = client.AppsV1().Deployments(namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = client.AppsV1().StatefulSets(namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = client.AppsV1().DaemonSets(namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "ReplicaSet":
+		_, err = client.AppsV1().ReplicaSets(namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "Job":
+		_, err = client.BatchV1().Jobs(namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	case "CronJob":
+		_, err = client.BatchV1().CronJobs(namespace).Patch(ctx, owner.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	default:
+		err = fmt.Errorf("unsupported controller kind: %s", owner.Kind)
+	}
+	if err != nil {
+		err = fmt.Errorf("error annotating %s %s/%s: %v", owner.Kind, namespace, owner.Name, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"golang.org/x/oauth2/google"
+)
+
+// prometheusResponse is the result of an instant query of the Prometheus HTTP API.
+type prometheusResponse struct {
+	Status string
+	Error  string
+	Data   struct {
+		Result []struct {
+			Metric map[string]string
+			// Value is the timestamp and the value as a string
+			Value [2]interface{}
+		}
+	}
+}
+
+// isManagedPrometheus reports if the url is the Prometheus API of Google Cloud Managed Service for Prometheus,
+// which needs Google credentials and holds the metrics of all clusters of the project.
+func isManagedPrometheus(prometheusUrl string) bool {
+	return strings.Contains(prometheusUrl, "monitoring.googleapis.com")
+}
+
+// GetPrometheusUsage queries the CPU and memory working set usage of every container over the window from
+// Prometheus or Managed Service for Prometheus and sizes it at the percentile, eg. 95.
+func GetPrometheusUsage(ctx context.Context, prometheusUrl string, clusterName string, window time.Duration, percentile float64) (UsageHistory, error) {
+	client := http.DefaultClient
+	selector := `container!="",container!="POD"`
+	if isManagedPrometheus(prometheusUrl) {
+		var err error
+		client, err = google.DefaultClient(ctx, "https://www.googleapis.com/auth/monitoring.read")
+		if err != nil {
+			err = fmt.Errorf("unable to get google credentials for managed prometheus: %v", err)
+			return nil, err
+		}
+		selector += fmt.Sprintf(",cluster=%q", clusterName)
+	}
+
+	quantile := strconv.FormatFloat(percentile/100, 'f', -1, 64)
+	duration := fmt.Sprintf("%ds", int(window.Seconds()))
+	step := fmt.Sprintf("%ds", int(MONITORING_ALIGNMENT.Seconds()))
+
+	history := make(UsageHistory)
+
+	cpuQuery := fmt.Sprintf("quantile_over_time(%s, rate(container_cpu_usage_seconds_total{%s}[%s])[%s:%s])", quantile, selector, step, duration, step)
+	err := queryPrometheus(ctx, client, prometheusUrl, cpuQuery, history.setCpu)
+	if err != nil {
+		err = fmt.Errorf("unable to fetch cpu usage from prometheus: %v", err)
+		return nil, err
+	}
+
+	memoryQuery := fmt.Sprintf("quantile_over_time(%s, container_memory_working_set_bytes{%s}[%s])", quantile, selector, duration)
+	err = queryPrometheus(ctx, client, prometheusUrl, memoryQuery, history.setMemory)
+	if err != nil {
+		err = fmt.Errorf("unable to fetch memory usage from prometheus: %v", err)
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// queryPrometheus runs an instant query and passes the value of every container in the result to set.
+func queryPrometheus(ctx context.Context, client *http.Client, prometheusUrl string, query string, set func(key string, value float64)) error {
+	usage.Count(usage.Prometheus)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(prometheusUrl, "/")+"/api/v1/query", strings.NewReader(url.Values{"query": {query}}.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var result prometheusResponse
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("error decoding response (%s): %v", response.Status, err)
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("query failed (%s): %s", response.Status, result.Error)
+	}
+
+	for _, sample := range result.Data.Result {
+		value, ok := sample.Value[1].(string)
+		if !ok {
+			continue
+		}
+
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		set(UsageKey(sample.Metric["namespace"], sample.Metric["pod"], sample.Metric["container"]), number)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Disk is a persistent disk backing a PersistentVolumeClaim.
+type Disk struct {
+	Claim string
+	Type  string
+	// Size in MiB
+	Size int64
+	// Provisioned IOPS and throughput (MiB/s), only set for Hyperdisk and Extreme PD
+	Iops       int64
+	Throughput int64
+	// Regional disks are replicated to a second zone
+	Regional bool
+	// Ephemeral disks back generic ephemeral volumes, they are deleted with the Pod
+	Ephemeral bool `json:",omitempty"`
+}
+
+// HasPersistentDisk checks if any of the disks outlives the Pod.
+func HasPersistentDisk(disks []Disk) bool {
+	for _, disk := range disks {
+		if !disk.Ephemeral {
+			return true
+		}
+	}
+	return false
+}
+
+// Volumes maps PersistentVolumeClaims to the disks backing them.
+type Volumes struct {
+	claims         map[string]v1.PersistentVolumeClaim
+	storageClasses map[string]storagev1.StorageClass
+	defaultClass   string
+	// claims that were already assigned to a workload, so shared volumes are only priced once
+	assigned map[string]bool
+}
+
+// GetVolumes lists all PersistentVolumeClaims and StorageClasses of the cluster.
+func GetVolumes(ctx context.Context, client kubernetes.Interface) (*Volumes, error) {
+	claims, err := client.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("error getting persistent volume claims: %v", err)
+		return nil, err
+	}
+
+	storageClasses, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		err = fmt.Errorf("error getting storage classes: %v", err)
+		return nil, err
+	}
+
+	volumes := &Volumes{
+		claims:         make(map[string]v1.PersistentVolumeClaim),
+		storageClasses: make(map[string]storagev1.StorageClass),
+		assigned:       make(map[string]bool),
+	}
+
+	for _, claim := range claims.Items {
+		volumes.claims[claim.Namespace+"/"+claim.Name] = claim
+	}
+
+	for _, storageClass := range storageClasses.Items {
+		volumes.storageClasses[storageClass.Name] = storageClass
+		if storageClass.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+			volumes.defaultClass = storageClass.Name
+		}
+	}
+
+	return volumes, nil
+}
+
+// PodDisks returns the disks of the claims mounted by the pod which were not assigned to another pod yet.
+func (volumes *Volumes) PodDisks(pod *v1.Pod) []Disk {
+	var disks []Disk
+
+	for _, volume := range pod.Spec.Volumes {
+		var claimName string
+		ephemeral := volume.Ephemeral != nil
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claimName = volume.PersistentVolumeClaim.ClaimName
+		case ephemeral:
+			// The claim of a generic ephemeral volume is named after the Pod and the volume
+			claimName = pod.Name + "-" + volume.Name
+		default:
+			continue
+		}
+
+		key := pod.Namespace + "/" + claimName
+		claim, ok := volumes.claims[key]
+		if !ok || volumes.assigned[key] {
+			continue
+		}
+		volumes.assigned[key] = true
+
+		storageClassName := volumes.defaultClass
+		if claim.Spec.StorageClassName != nil {
+			storageClassName = *claim.Spec.StorageClassName
+		}
+		storageClass := volumes.storageClasses[storageClassName]
+
+		size, ok := claim.Status.Capacity[v1.ResourceStorage]
+		if !ok {
+			size = claim.Spec.Resources.Requests[v1.ResourceStorage]
+		}
+
+		// Both the CSI driver and the legacy in-tree provisioner use the "type" parameter
+		diskType := storageClass.Parameters["type"]
+		if diskType == "" && (storageClass.Provisioner == "pd.csi.storage.gke.io" || storageClass.Provisioner == "kubernetes.io/gce-pd") {
+			diskType = "pd-standard"
+		}
+		iops, _ := strconv.ParseInt(storageClass.Parameters["provisioned-iops-on-create"], 10, 64)
+		throughput := parseThroughput(storageClass.Parameters["provisioned-throughput-on-create"])
+		regional := storageClass.Parameters["replication-type"] == "regional-pd"
+
+		disks = append(disks, Disk{
+			Claim:      claim.Name,
+			Type:       diskType,
+			Size:       size.Value() / 1024 / 1024,
+			Iops:       iops,
+			Throughput: throughput,
+			Regional:   regional,
+			Ephemeral:  ephemeral,
+		})
+	}
+
+	return disks
+}
+
+// parseThroughput reads a throughput parameter like "250Mi" or a plain number of MiB/s.
+func parseThroughput(value string) int64 {
+	if throughput, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return throughput
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0
+	}
+
+	return quantity.Value() / 1024 / 1024
+}
@@ -0,0 +1,534 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_ENV_PREFIX starts the environment variables that override config values, eg. AUTOPILOT_CALC_FEES_CLUSTER_FEE.
+const CONFIG_ENV_PREFIX = "AUTOPILOT_CALC_"
+
+// defaultConfig is the config.ini of the repository, built into the binary so it runs without one.
+//
+//go:embed config.ini
+var defaultConfig []byte
+
+// configValues are the keys of a config file by section, the default section is "".
+type configValues map[string]map[string]string
+
+// configLoader reads a config file format into its sections and keys.
+type configLoader func(data []byte) (configValues, error)
+
+// configFiles are the config file names that are looked for, in order, with their loaders.
+var configFiles = []struct {
+	Name   string
+	Loader configLoader
+}{
+	{"config.ini", loadIniConfig},
+	{"config.yaml", loadYamlConfig},
+	{"config.yml", loadYamlConfig},
+	{"config.toml", loadTomlConfig},
+}
+
+// findConfigFile looks for a config file in the working directory or, when the binary runs as a kubectl
+// plugin from anywhere, in the directory of the binary. It returns an empty path if there is none.
+func findConfigFile() (string, configLoader) {
+	dirs := []string{"."}
+	if executable, err := os.Executable(); err == nil {
+		if executable, err = filepath.EvalSymlinks(executable); err == nil {
+			dirs = append(dirs, filepath.Dir(executable))
+		}
+	}
+
+	for _, dir := range dirs {
+		for _, file := range configFiles {
+			path := filepath.Join(dir, file.Name)
+			if _, err := os.Stat(path); err == nil {
+				return path, file.Loader
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// loadConfig reads the built-in configuration and overrides it with config.ini, config.yaml or config.toml
+// from the working directory or the directory of the binary. The file is optional, keys it doesn't set keep
+// their built-in values. AUTOPILOT_CALC_* environment variables override both.
+func loadConfig() (*ini.File, error) {
+	cfg, err := ini.Load(defaultConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	path, loader := findConfigFile()
+	if path == "" {
+		logging.Debug("No config file found, using the built-in configuration.")
+	} else {
+		logging.Debug("Reading the configuration from %s.", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		values, err := loader(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", path, err)
+		}
+		for section, keys := range values {
+			for key, value := range keys {
+				setConfigValue(cfg, section, key, value)
+			}
+		}
+	}
+
+	applyEnvOverrides(cfg, os.Environ())
+
+	return cfg, nil
+}
+
+func loadIniConfig(data []byte) (configValues, error) {
+	file, err := ini.Load(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := configValues{}
+	for _, section := range file.Sections() {
+		name := section.Name()
+		if name == ini.DefaultSection {
+			name = ""
+		}
+		values[name] = section.KeysHash()
+	}
+
+	return values, nil
+}
+
+// loadYamlConfig reads a YAML config, sections are maps and the keys of the default section are at the top, eg.
+//
+//	currency: EUR
+//	fees:
+//	  cluster_fee: 0.1
+func loadYamlConfig(data []byte) (configValues, error) {
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	return getConfigValues(document)
+}
+
+// loadTomlConfig reads a TOML config, which has the sections and keys of config.ini.
+func loadTomlConfig(data []byte) (configValues, error) {
+	var document map[string]interface{}
+	if err := toml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	return getConfigValues(document)
+}
+
+// getConfigValues reads the sections of a YAML or TOML document. Lists are joined by commas, like the
+// prefix lists of config.ini.
+func getConfigValues(document map[string]interface{}) (configValues, error) {
+	values := configValues{"": {}}
+
+	for name, value := range document {
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			formatted, err := formatConfigValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			values[""][name] = formatted
+			continue
+		}
+
+		values[name] = map[string]string{}
+		for key, value := range section {
+			formatted, err := formatConfigValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", name, key, err)
+			}
+			values[name][key] = formatted
+		}
+	}
+
+	return values, nil
+}
+
+func formatConfigValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			formatted, err := formatConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatted)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v, sections can't be nested", value)
+	}
+}
+
+// applyEnvOverrides sets the config values of AUTOPILOT_CALC_<SECTION>_<KEY>=value environment variables,
+// eg. AUTOPILOT_CALC_RATIOS_BALANCED_MAX=8. Keys of the default section have no section,
+// eg. AUTOPILOT_CALC_CURRENCY=EUR. The section is the longest one of the config the name starts with.
+func applyEnvOverrides(cfg *ini.File, environ []string) {
+	sections := cfg.SectionStrings()
+	sort.Slice(sections, func(i, j int) bool {
+		return len(sections[i]) > len(sections[j])
+	})
+
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, CONFIG_ENV_PREFIX) {
+			continue
+		}
+		name = strings.ToLower(strings.TrimPrefix(name, CONFIG_ENV_PREFIX))
+
+		section, key := ini.DefaultSection, name
+		for _, candidate := range sections {
+			if candidate != ini.DefaultSection && strings.HasPrefix(name, candidate+"_") {
+				section, key = candidate, strings.TrimPrefix(name, candidate+"_")
+				break
+			}
+		}
+
+		logging.Debug("Config value %s is set by the environment.", configKeyName(section, key))
+		setConfigValue(cfg, section, key, value)
+	}
+}
+
+// configKeyName names a config key like the -set flag, eg. fees.cluster_fee or currency.
+func configKeyName(section string, key string) string {
+	if section == "" || section == ini.DefaultSection {
+		return key
+	}
+	return section + "." + key
+}
+
+func setConfigValue(cfg *ini.File, section string, key string, value string) {
+	if section == ini.DefaultSection {
+		section = ""
+	}
+	cfg.Section(section).Key(key).SetValue(value)
+
+	// The display currency is read from the config before the flags are parsed
+	if section == "" && key == "currency" && value != "" {
+		cluster.DisplayCurrency = value
+	}
+}
+
+// configOverrides is the repeatable -set flag, eg. -set fees.cluster_fee=0.2 -set currency=EUR. Values
+// are written to the config as the flag is parsed.
+type configOverrides struct {
+	cfg *ini.File
+}
+
+func (overrides configOverrides) String() string {
+	return ""
+}
+
+func (overrides configOverrides) Set(value string) error {
+	name, value, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("config override %q is not section.key=value", name)
+	}
+
+	section, key, ok := strings.Cut(name, ".")
+	if !ok {
+		section, key = "", name
+	}
+	setConfigValue(overrides.cfg, section, key, value)
+
+	return nil
+}
+
+// addConfigFlags registers -set on the flag set, which overrides config values after config.ini and the environment.
+func addConfigFlags(flags *flag.FlagSet, cfg *ini.File) {
+	flags.Var(configOverrides{cfg}, "set", "Override a config value, eg. fees.cluster_fee=0.2 or currency=EUR, can be repeated")
+}
+
+// configRule checks the values of the keys of a section matching Key, a glob like *_max.
+type configRule struct {
+	Section string
+	Key     string
+	Check   func(value string) error
+}
+
+func anyValue(value string) error {
+	return nil
+}
+
+func isBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("%q is not true or false", value)
+	}
+	return nil
+}
+
+func isInt(min int64, max int64) func(string) error {
+	return func(value string) error {
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		if number < min || number > max {
+			return fmt.Errorf("%d is out of range, it has to be between %d and %d", number, min, max)
+		}
+		return nil
+	}
+}
+
+func isFloat(min float64, max float64) func(string) error {
+	return func(value string) error {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		if number < min || number > max {
+			return fmt.Errorf("%v is out of range, it has to be between %v and %v", number, min, max)
+		}
+		return nil
+	}
+}
+
+func isCurrency(value string) error {
+	if len(value) != 3 || strings.ToUpper(value) != value {
+		return fmt.Errorf("%q is not a currency code like USD", value)
+	}
+	return nil
+}
+
+func isComputeClass(value string) error {
+	for _, classKey := range cluster.ComputeClassKeys {
+		if value == classKey {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a compute class, use one of %s", value, strings.Join(cluster.ComputeClassKeys[:], ", "))
+}
+
+func isComputeClassList(value string) error {
+	for _, class := range strings.Split(value, ",") {
+		if err := isComputeClass(strings.TrimSpace(class)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var configRules = []configRule{
+	{"", "autopilot_sku", anyValue},
+	{"", "gce_sku", anyValue},
+	{"", "currency", isCurrency},
+	{"", "sku_mapping_file", anyValue},
+	{"", "gce_arm64_prefix", anyValue},
+	{"", "gce_compute_optimized_prefixed", anyValue},
+	{"", "gce_accelerator_optimized_prefixed", anyValue},
+	{"", "nvidia_h100_identifier", anyValue},
+	{"fees", "cluster_fee", isFloat(0, math.MaxFloat64)},
+	{"fees", "free_tier", isBool},
+	{"display", "precision", isInt(0, 10)},
+	{"limits", "*", isInt(0, math.MaxInt64)},
+	{"classes", "order", isComputeClassList},
+	{"sandbox", "*_overhead", isInt(0, math.MaxInt64)},
+	{"sidecars", "names", anyValue},
+	{"flex_start", "discount", isFloat(0, 1)},
+	{"flex_start", "cpu", isFloat(0, 1)},
+	{"flex_start", "memory", isFloat(0, 1)},
+	{"flex_start", "storage", isFloat(0, 1)},
+	{"flex_start", "accelerator", isFloat(0, 1)},
+	{"flex_start", "machine", isFloat(0, 1)},
+	{"spot", "preemption_overhead", isFloat(0, 0.99)},
+	{"spot", "reschedule_minutes", isFloat(0, math.MaxFloat64)},
+	{"machine_families", "*", isComputeClass},
+	{"performance", "default_family", anyValue},
+	{"egress", "*_gb_month", isFloat(0, math.MaxFloat64)},
+	{"egress", "*_price", isFloat(0, math.MaxFloat64)},
+	{"load_balancing", "data_processed_gb_month", isFloat(0, math.MaxFloat64)},
+	{"bursting", "enabled", isBool},
+	{"notifications", "webhook_url", anyValue},
+	{"ratios", "*_min", isFloat(-math.MaxFloat64, math.MaxFloat64)},
+	{"ratios", "*_max", isFloat(-math.MaxFloat64, math.MaxFloat64)},
+	{"discounts", "oneyear_*commit*", isFloat(0, 1)},
+	{"discounts", "threeyear_*commit*", isFloat(0, 1)},
+}
+
+// usdPriceKeys are the prices of the built-in configuration, which are in USD like the CLUSTER_FEE fallback.
+var usdPriceKeys = []struct{ Section, Key string }{
+	{"fees", "cluster_fee"},
+	{"egress", "internet_price"},
+}
+
+// positiveRatioClasses are the compute classes whose memory to CPU ratio has to be above 0 for
+// DecideComputeClass, the others have no ratio enforced.
+var positiveRatioClasses = []string{"generalpurpose", "balanced", "scaleout"}
+
+// configProblems are the results of validateConfig. Errors make the estimate wrong, eg. a limit that is not
+// a number, warnings are likely typos, eg. an unknown section.
+type configProblems struct {
+	Errors   []string
+	Warnings []string
+}
+
+// validateConfig checks the types and ranges of the config values, that the min and max of the limits and
+// ratios are in order, and that the sections and keys are known. Reads of the config fall back to zero,
+// which would silently break the compute class decision.
+func validateConfig(cfg *ini.File) configProblems {
+	var problems configProblems
+
+	for _, section := range cfg.Sections() {
+		name := section.Name()
+		if name == ini.DefaultSection {
+			name = ""
+		}
+
+		known := false
+		for _, rule := range configRules {
+			known = known || rule.Section == name
+		}
+		if !known {
+			problems.Warnings = append(problems.Warnings, fmt.Sprintf("unknown section [%s] is ignored", name))
+			continue
+		}
+
+		for _, key := range section.Keys() {
+			var rule *configRule
+			for i := range configRules {
+				if matched, _ := path.Match(configRules[i].Key, key.Name()); matched && configRules[i].Section == name {
+					rule = &configRules[i]
+					break
+				}
+			}
+
+			keyName := configKeyName(name, key.Name())
+			if rule == nil {
+				problems.Warnings = append(problems.Warnings, fmt.Sprintf("unknown key %s is ignored", keyName))
+				continue
+			}
+			// Empty values of the built-in keys are reported as missing below, other keys are optional
+			if key.String() == "" {
+				continue
+			}
+			if err := rule.Check(key.String()); err != nil {
+				problems.Errors = append(problems.Errors, fmt.Sprintf("%s: %v", keyName, err))
+			}
+		}
+	}
+
+	// Every key of the built-in configuration is read, an empty value would be read as zero
+	defaults, _ := ini.Load(defaultConfig)
+	for _, section := range defaults.Sections() {
+		for _, key := range section.Keys() {
+			if key.String() != "" && cfg.Section(section.Name()).Key(key.Name()).String() == "" {
+				problems.Errors = append(problems.Errors, fmt.Sprintf("%s: is missing a value", configKeyName(section.Name(), key.Name())))
+			}
+		}
+	}
+
+	for _, sectionName := range []string{"limits", "ratios"} {
+		section := cfg.Section(sectionName)
+		for _, key := range section.Keys() {
+			prefix, ok := strings.CutSuffix(key.Name(), "_min")
+			if !ok || !section.HasKey(prefix+"_max") {
+				continue
+			}
+
+			min, minErr := key.Float64()
+			max, maxErr := section.Key(prefix + "_max").Float64()
+			if minErr == nil && maxErr == nil && min > max {
+				problems.Errors = append(problems.Errors, fmt.Sprintf("%s.%s_min: %v is above %s_max of %v", sectionName, prefix, min, prefix, max))
+			}
+		}
+	}
+
+	for _, class := range positiveRatioClasses {
+		if ratio, err := cfg.Section("ratios").Key(class + "_min").Float64(); err == nil && ratio <= 0 {
+			problems.Errors = append(problems.Errors, fmt.Sprintf("ratios.%s_min: %v is out of range, the memory to CPU ratio has to be above 0", class, ratio))
+		}
+	}
+
+	problems.Warnings = append(problems.Warnings, currencyWarnings(cfg)...)
+
+	sort.Strings(problems.Errors)
+	sort.Strings(problems.Warnings)
+
+	return problems
+}
+
+// currencyWarnings lists the prices that are left at their USD value of the built-in configuration when
+// another currency is configured, they would be added to prices of the catalog in that currency.
+func currencyWarnings(cfg *ini.File) []string {
+	currency := cfg.Section("").Key("currency").MustString("USD")
+	if currency == "USD" {
+		return nil
+	}
+
+	var warnings []string
+	defaults, _ := ini.Load(defaultConfig)
+	for _, price := range usdPriceKeys {
+		value := cfg.Section(price.Section).Key(price.Key).String()
+		if value == "" || value == defaults.Section(price.Section).Key(price.Key).String() {
+			warnings = append(warnings, fmt.Sprintf("%s is the built-in price in USD, set it in %s too", configKeyName(price.Section, price.Key), currency))
+		}
+	}
+
+	return warnings
+}
+
+// checkConfig logs the warnings of validateConfig and returns its errors as a single error.
+func checkConfig(cfg *ini.File) error {
+	problems := validateConfig(cfg)
+	for _, warning := range problems.Warnings {
+		logging.Warn("Config %s.", warning)
+	}
+	if len(problems.Errors) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems.Errors, "\n  "))
+	}
+	return nil
+}
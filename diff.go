@@ -0,0 +1,241 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// savedReport reads both the report of a single cluster and the fleet report written by -json.
+type savedReport struct {
+	Context    string
+	Currency   string
+	Nodes      map[string]cluster.Node
+	Comparison calculator.StandardComparison
+	Clusters   []savedReport
+	Total      *calculator.StandardComparison
+}
+
+// workloadDiff is the change of the cost of a workload between two reports.
+type workloadDiff struct {
+	Workload    string
+	Status      string
+	OldReplicas int
+	NewReplicas int
+	OldCost     cluster.Money
+	NewCost     cluster.Money
+	Delta       cluster.Money
+}
+
+// reportDiff is the document written by `diff -json`.
+type reportDiff struct {
+	Currency       string
+	Workloads      []workloadDiff
+	OldTotal       calculator.StandardComparison
+	NewTotal       calculator.StandardComparison
+	AutopilotDelta cluster.Money
+	StandardDelta  cluster.Money
+}
+
+// workloadTotal is the summed cost of the pods of a workload in a report.
+type workloadTotal struct {
+	Replicas int
+	Cost     cluster.Money
+}
+
+// RunDiff compares two reports saved with -json, eg. `diff old.json new.json`.
+func RunDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	jsonFlag := flags.Bool("json", false, "Print the differences as json")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("diff needs the old and the new report, eg. diff old.json new.json")
+	}
+
+	oldReport, err := readSavedReport(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newReport, err := readSavedReport(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if newReport.Currency != "" {
+		cluster.DisplayCurrency = newReport.Currency
+	}
+	if oldReport.Currency != "" && oldReport.Currency != newReport.Currency {
+		return fmt.Errorf("reports are in different currencies, %s and %s", oldReport.Currency, newReport.Currency)
+	}
+
+	diff := diffReports(oldReport, newReport)
+
+	if *jsonFlag {
+		contents, _ := json.MarshalIndent(diff, "", "    ")
+		fmt.Printf("%s\n", contents)
+		return nil
+	}
+
+	fmt.Println(pinkTextStyle.Render(fmt.Sprintf("Changes from %s to %s", flags.Arg(0), flags.Arg(1))))
+	DisplayDiffTable(diff)
+
+	return nil
+}
+
+func readSavedReport(path string) (savedReport, error) {
+	var report savedReport
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("error reading report: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("error parsing report %s: %v", path, err)
+	}
+
+	return report, nil
+}
+
+// total returns the Standard and Autopilot cost of the report, or the fleet total.
+func (report savedReport) total() calculator.StandardComparison {
+	if report.Total != nil {
+		return *report.Total
+	}
+	return report.Comparison
+}
+
+// workloads sums the cost of the workloads of the report by getDiffKey.
+func (report savedReport) workloads() map[string]workloadTotal {
+	totals := make(map[string]workloadTotal)
+
+	reports := report.Clusters
+	if len(reports) == 0 {
+		reports = []savedReport{report}
+	}
+	for _, clusterReport := range reports {
+		for _, node := range clusterReport.Nodes {
+			for _, workload := range node.Workloads {
+				key := getDiffKey(clusterReport.Context, workload)
+				total := totals[key]
+				total.Replicas++
+				total.Cost += workload.Cost
+				totals[key] = total
+			}
+		}
+	}
+
+	return totals
+}
+
+// getDiffKey identifies a workload across reports. Pods are matched by their controller, as their names
+// change with every rollout, and the pods of a Deployment are matched across its ReplicaSets.
+func getDiffKey(context string, workload cluster.Workload) string {
+	name := workload.Name
+
+	owner := workload.Owner
+	if hash := workload.Labels["pod-template-hash"]; owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+		owner = cluster.Owner{Kind: "Deployment", Name: strings.TrimSuffix(owner.Name, "-"+hash)}
+	}
+	if owner.Kind != "" {
+		name = owner.Kind + "/" + owner.Name
+	}
+
+	key := workload.Namespace + "/" + name
+	if context != "" {
+		key = context + " " + key
+	}
+
+	return key
+}
+
+// diffReports lists the added, removed and changed workloads, the largest changes first, and the change of the totals.
+func diffReports(oldReport savedReport, newReport savedReport) reportDiff {
+	oldWorkloads := oldReport.workloads()
+	newWorkloads := newReport.workloads()
+
+	diff := reportDiff{
+		Currency:  cluster.DisplayCurrency,
+		Workloads: []workloadDiff{},
+		OldTotal:  oldReport.total(),
+		NewTotal:  newReport.total(),
+	}
+	diff.AutopilotDelta = diff.NewTotal.AutopilotCost - diff.OldTotal.AutopilotCost
+	diff.StandardDelta = diff.NewTotal.StandardCost - diff.OldTotal.StandardCost
+
+	for key, newWorkload := range newWorkloads {
+		oldWorkload, ok := oldWorkloads[key]
+
+		status := DiffChanged
+		if !ok {
+			status = DiffAdded
+		} else if oldWorkload == newWorkload {
+			continue
+		}
+
+		diff.Workloads = append(diff.Workloads, workloadDiff{
+			Workload:    key,
+			Status:      status,
+			OldReplicas: oldWorkload.Replicas,
+			NewReplicas: newWorkload.Replicas,
+			OldCost:     oldWorkload.Cost,
+			NewCost:     newWorkload.Cost,
+			Delta:       newWorkload.Cost - oldWorkload.Cost,
+		})
+	}
+	for key, oldWorkload := range oldWorkloads {
+		if _, ok := newWorkloads[key]; ok {
+			continue
+		}
+
+		diff.Workloads = append(diff.Workloads, workloadDiff{
+			Workload:    key,
+			Status:      DiffRemoved,
+			OldReplicas: oldWorkload.Replicas,
+			OldCost:     oldWorkload.Cost,
+			Delta:       -oldWorkload.Cost,
+		})
+	}
+
+	sort.Slice(diff.Workloads, func(i, j int) bool {
+		a, b := diff.Workloads[i].Delta, diff.Workloads[j].Delta
+		if a < 0 {
+			a = -a
+		}
+		if b < 0 {
+			b = -b
+		}
+		if a != b {
+			return a > b
+		}
+		return diff.Workloads[i].Workload < diff.Workloads[j].Workload
+	})
+
+	return diff
+}
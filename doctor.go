@@ -0,0 +1,338 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/serviceusage/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Permissions the calculator needs on the project of the cluster.
+var doctorPermissions = []string{
+	"container.nodes.list",
+	"container.pods.list",
+}
+
+// APIs that have to be enabled on the project of the cluster.
+var doctorServices = []string{
+	"cloudbilling.googleapis.com",
+}
+
+// doctorOptions are the flags of a run the checks depend on, like the flags of the same name.
+type doctorOptions struct {
+	targetRegion     string
+	skipGKECheck     bool
+	existingCapacity bool
+	metricsSource    string
+}
+
+// permissions returns the IAM permissions the run needs on the project of the cluster.
+func (options doctorOptions) permissions() []string {
+	permissions := append([]string{}, doctorPermissions...)
+	if !options.skipGKECheck {
+		permissions = append(permissions, "container.clusters.get")
+	}
+	if options.existingCapacity {
+		permissions = append(permissions, "compute.commitments.list", "compute.reservations.list")
+	}
+	if options.metricsSource == "monitoring" {
+		permissions = append(permissions, "monitoring.timeSeries.list")
+	}
+
+	return permissions
+}
+
+// services returns the APIs the run needs on the project of the cluster.
+func (options doctorOptions) services() []string {
+	services := append([]string{}, doctorServices...)
+	if !options.skipGKECheck {
+		services = append(services, "container.googleapis.com")
+	}
+	if options.existingCapacity {
+		services = append(services, "compute.googleapis.com")
+	}
+	if options.metricsSource == "monitoring" {
+		services = append(services, "monitoring.googleapis.com")
+	}
+
+	return services
+}
+
+// doctorEnv are the calls of the checks to the machine, the cluster and the Google APIs, replaced in tests.
+type doctorEnv struct {
+	getKubeConfig       func() (*rest.Config, string, error)
+	getCurrentContext   func() ([]string, error)
+	getInClusterContext func() ([]string, error)
+	lookPath            func(string) (string, error)
+	findCredentials     func(ctx context.Context) error
+	grantedPermissions  func(ctx context.Context, project string, permissions []string) ([]string, error)
+	serviceState        func(ctx context.Context, project string, name string) (string, error)
+	checkMetricsAPI     func(kubeConfig *rest.Config) error
+}
+
+var defaultDoctorEnv = doctorEnv{
+	getKubeConfig:       cluster.GetKubeConfig,
+	getCurrentContext:   cluster.GetCurrentContext,
+	getInClusterContext: cluster.GetInClusterContext,
+	lookPath:            exec.LookPath,
+	findCredentials: func(ctx context.Context) error {
+		_, err := google.FindDefaultCredentials(ctx, cloudresourcemanager.CloudPlatformScope)
+		return err
+	},
+	grantedPermissions: func(ctx context.Context, project string, permissions []string) ([]string, error) {
+		svc, err := cloudresourcemanager.NewService(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := svc.Projects.TestIamPermissions(project, &cloudresourcemanager.TestIamPermissionsRequest{
+			Permissions: permissions,
+		}).Do()
+		if err != nil {
+			return nil, err
+		}
+
+		return response.Permissions, nil
+	},
+	serviceState: func(ctx context.Context, project string, name string) (string, error) {
+		svc, err := serviceusage.NewService(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		service, err := svc.Services.Get(fmt.Sprintf("projects/%s/services/%s", project, name)).Do()
+		if err != nil {
+			return "", err
+		}
+
+		return service.State, nil
+	},
+	checkMetricsAPI: func(kubeConfig *rest.Config) error {
+		clientset, err := kubernetes.NewForConfig(kubeConfig)
+		if err != nil {
+			return err
+		}
+
+		_, err = clientset.Discovery().ServerResourcesForGroupVersion("metrics.k8s.io/v1beta1")
+		return err
+	},
+}
+
+type doctorCheck struct {
+	name string
+	fix  string
+	run  func() error
+}
+
+// RunDoctor checks everything a successful run needs and prints a pass/fail
+// report with fix instructions. It returns false if any of the checks failed.
+func RunDoctor(args []string) bool {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	addKubeConfigFlags(flags)
+	targetRegionFlag := flags.String("target-region", "", "Check for a cluster that doesn't run on GKE, estimated with -target-region")
+	skipGKECheckFlag := flags.Bool("skip-gke-check", false, "Check for a run with -skip-gke-check, which doesn't call the GKE API")
+	existingCapacityFlag := flags.Bool("existing-capacity", false, "Check the permissions of -existing-capacity too")
+	metricsSourceFlag := flags.String("metrics-source", "metrics-server", "Check the usage source of the run: metrics-server, monitoring or prometheus")
+	flags.Parse(args)
+
+	options := doctorOptions{
+		targetRegion:     *targetRegionFlag,
+		skipGKECheck:     *skipGKECheckFlag,
+		existingCapacity: *existingCapacityFlag,
+		metricsSource:    *metricsSourceFlag,
+	}
+
+	passed := true
+	for _, check := range getDoctorChecks(context.Background(), options, defaultDoctorEnv) {
+		err := check.run()
+		if err != nil {
+			passed = false
+			fmt.Println(redTextStyle.Render(fmt.Sprintf("FAIL %s", check.name)))
+			fmt.Printf("     %v\n", err)
+			fmt.Printf("     Fix: %s\n", check.fix)
+			continue
+		}
+
+		fmt.Println(greenTextStyle.Render(fmt.Sprintf("PASS %s", check.name)))
+	}
+
+	return passed
+}
+
+// getDoctorChecks returns the checks of a run with the options, in order. Later checks use what
+// earlier ones found, eg. the project of the cluster.
+func getDoctorChecks(ctx context.Context, options doctorOptions, env doctorEnv) []doctorCheck {
+	var kubeConfig *rest.Config
+	var kubeConfigPath string
+	var clusterProject string
+
+	checks := []doctorCheck{
+		{
+			name: "Kubernetes config",
+			fix:  "Run `gcloud container clusters get-credentials CLUSTER_NAME --zone ZONE --project PROJECT_NAME`, or pass -kubeconfig and -context.",
+			run: func() error {
+				var err error
+				kubeConfig, kubeConfigPath, err = env.getKubeConfig()
+				return err
+			},
+		},
+	}
+
+	// Clusters that don't run on GKE have any context, and the project of the cluster isn't used
+	if options.targetRegion == "" {
+		checks = append(checks, doctorCheck{
+			name: "GKE context",
+			fix:  "Switch to a GKE context with `kubectl config use-context gke_PROJECT_LOCATION_CLUSTER` or pass -context.",
+			run: func() error {
+				if kubeConfig == nil {
+					return fmt.Errorf("kubernetes config is not available")
+				}
+
+				// Running in a pod, the cluster comes from the metadata server
+				getContext := env.getCurrentContext
+				if kubeConfigPath == "" {
+					getContext = env.getInClusterContext
+				}
+
+				currentContext, err := getContext()
+				if err != nil {
+					return err
+				}
+
+				if len(currentContext) != 4 || currentContext[0] != "gke" {
+					return fmt.Errorf("context %q is not a GKE context", strings.Join(currentContext, "_"))
+				}
+				clusterProject = currentContext[1]
+
+				return nil
+			},
+		})
+	}
+
+	checks = append(checks,
+		doctorCheck{
+			name: "Kubernetes credential plugin",
+			fix:  "Run `gcloud components install gke-gcloud-auth-plugin`, or install the credential plugin of the context.",
+			run: func() error {
+				// In-cluster and token credentials need no plugin
+				if kubeConfig == nil || kubeConfig.ExecProvider == nil {
+					return nil
+				}
+
+				_, err := env.lookPath(kubeConfig.ExecProvider.Command)
+				return err
+			},
+		},
+		doctorCheck{
+			name: "Application Default Credentials",
+			fix:  "Run `gcloud auth application-default login`.",
+			run: func() error {
+				return env.findCredentials(ctx)
+			},
+		},
+	)
+
+	if options.targetRegion == "" {
+		checks = append(checks,
+			doctorCheck{
+				name: "IAM permissions",
+				fix:  "Grant your account the Kubernetes Engine Viewer role (roles/container.viewer) on the project, and the Compute Viewer and Monitoring Viewer roles for -existing-capacity and -metrics-source monitoring.",
+				run: func() error {
+					if clusterProject == "" {
+						return fmt.Errorf("project of the cluster is not known")
+					}
+
+					permissions := options.permissions()
+					granted, err := env.grantedPermissions(ctx, clusterProject, permissions)
+					if err != nil {
+						return err
+					}
+
+					var missing []string
+					for _, permission := range permissions {
+						isGranted := false
+						for _, grantedPermission := range granted {
+							if permission == grantedPermission {
+								isGranted = true
+							}
+						}
+						if !isGranted {
+							missing = append(missing, permission)
+						}
+					}
+
+					if len(missing) > 0 {
+						return fmt.Errorf("missing permissions: %s", strings.Join(missing, ", "))
+					}
+
+					return nil
+				},
+			},
+			doctorCheck{
+				name: "Google Cloud APIs",
+				fix:  "Run `gcloud services enable " + strings.Join(options.services(), " ") + "`.",
+				run: func() error {
+					if clusterProject == "" {
+						return fmt.Errorf("project of the cluster is not known")
+					}
+
+					var disabled []string
+					for _, name := range options.services() {
+						state, err := env.serviceState(ctx, clusterProject, name)
+						if err != nil {
+							return err
+						}
+						if state != "ENABLED" {
+							disabled = append(disabled, name)
+						}
+					}
+
+					if len(disabled) > 0 {
+						return fmt.Errorf("disabled APIs: %s", strings.Join(disabled, ", "))
+					}
+
+					return nil
+				},
+			},
+		)
+	}
+
+	// The usage comes from metrics-server unless another source is used
+	if options.metricsSource == "metrics-server" {
+		checks = append(checks, doctorCheck{
+			name: "metrics-server",
+			fix:  "Make sure metrics-server is deployed and the metrics.k8s.io API is available in the cluster.",
+			run: func() error {
+				if kubeConfig == nil {
+					return fmt.Errorf("kubernetes config is not available")
+				}
+
+				return env.checkMetricsAPI(kubeConfig)
+			},
+		})
+	}
+
+	return checks
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/pkg/estimator"
+	"gopkg.in/ini.v1"
+)
+
+// podEstimate is the document written by `estimate pod -json`.
+type podEstimate struct {
+	Kind        string
+	Replicas    int32
+	Workload    cluster.Workload
+	HourlyCost  cluster.Money
+	MonthlyCost cluster.Money
+	Currency    string
+}
+
+func newPodEstimate(template *cluster.PodTemplate, workload cluster.Workload) podEstimate {
+	return podEstimate{
+		Kind:        template.Kind,
+		Replicas:    template.Replicas,
+		Workload:    workload,
+		HourlyCost:  workload.Cost.Mul(float64(template.Replicas)),
+		MonthlyCost: workload.Cost.Mul(float64(template.Replicas) * calculator.HOURS_PER_MONTH),
+		Currency:    cluster.DisplayCurrency,
+	}
+}
+
+// RunEstimatePod prices a single Pod or controller manifest, eg. `estimate pod -f - -region us-central1`.
+func RunEstimatePod(cfg *ini.File, args []string) error {
+	flags := flag.NewFlagSet("estimate pod", flag.ExitOnError)
+	fileFlag := flags.String("f", "-", "Manifest file of a Pod or a controller, - reads from stdin")
+	regionFlag := flags.String("region", "", "Region used for the pricing, eg. us-central1")
+	jsonFlag := flags.Bool("json", false, "Print the estimate as json")
+	addConfigFlags(flags, cfg)
+	flags.Parse(args)
+
+	if err := checkConfig(cfg); err != nil {
+		return err
+	}
+
+	if *regionFlag == "" {
+		return fmt.Errorf("-region is required to estimate a pod")
+	}
+
+	var data []byte
+	var err error
+	if *fileFlag == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*fileFlag)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading manifest: %v", err)
+	}
+
+	template, err := cluster.DecodePodTemplate(data)
+	if err != nil {
+		return err
+	}
+
+	pricingService, err := calculator.NewService(context.Background(), estimator.GetPricingSKUs(cfg), *regionFlag, nil, nil, cfg)
+	if err != nil {
+		return fmt.Errorf("error initializing pricing service: %v", err)
+	}
+
+	workload := pricingService.EstimatePodTemplate(template)
+	estimate := newPodEstimate(template, workload)
+
+	if *jsonFlag {
+		contents, _ := json.MarshalIndent(estimate, "", "    ")
+		fmt.Printf("%s\n", contents)
+		return nil
+	}
+
+	fmt.Println(pinkTextStyle.Render(fmt.Sprintf("%s %q in %s", template.Kind, template.Name, *regionFlag)))
+	fmt.Printf("Compute class: %s\n", cluster.ComputeClasses[workload.ComputeClass])
+	if workload.RequestedCpu != workload.Cpu || workload.RequestedMemory != workload.Memory {
+		fmt.Printf("Requested per pod: %d mCPU, %d MiB memory, adjusted by Autopilot\n", workload.RequestedCpu, workload.RequestedMemory)
+	}
+	fmt.Printf("Billed per pod: %d mCPU, %d MiB memory, %d MiB ephemeral storage", workload.Cpu, workload.Memory, workload.Storage)
+	if workload.AcceleratorAmount > 0 {
+		fmt.Printf(", %d x %s", workload.AcceleratorAmount, workload.AcceleratorType)
+		if workload.GPUSharing != "" {
+			fmt.Printf(" (%s, %.3g of a GPU each)", workload.GPUSharing, workload.GPUShare)
+		}
+	}
+	if workload.TPUCount > 0 {
+		fmt.Printf(", %d x %s TPU chips (%s topology)", workload.TPUCount, workload.TPUType, workload.TPUTopology)
+	}
+	fmt.Println()
+	fmt.Printf("Price per pod: %s\n", perHour(workload.Cost))
+	fmt.Println(greenTextStyle.Render(fmt.Sprintf("%d replica(s): %s, %s", template.Replicas, perHour(estimate.HourlyCost), perMonth(estimate.MonthlyCost))))
+
+	return nil
+}
+
+// RunEstimateManifests prices all workloads in local manifests without a cluster, eg. `-manifests ./k8s/ -region us-central1`.
+// It returns the hourly cost of all replicas of the workloads.
+func RunEstimateManifests(cfg *ini.File, path string, region string, jsonOutput bool) (cluster.Money, error) {
+	if region == "" {
+		return 0, fmt.Errorf("-region is required to estimate manifests")
+	}
+
+	templates, err := cluster.ReadPodTemplates(path)
+	if err != nil {
+		return 0, err
+	}
+
+	pricingService, err := calculator.NewService(context.Background(), estimator.GetPricingSKUs(cfg), region, nil, nil, cfg)
+	if err != nil {
+		return 0, fmt.Errorf("error initializing pricing service: %v", err)
+	}
+
+	estimates := []podEstimate{}
+	var hourlyCost cluster.Money
+	for _, template := range templates {
+		estimate := newPodEstimate(template, pricingService.EstimatePodTemplate(template))
+		estimates = append(estimates, estimate)
+		hourlyCost += estimate.HourlyCost
+	}
+
+	if jsonOutput {
+		contents, _ := json.MarshalIndent(estimates, "", "    ")
+		fmt.Printf("%s\n", contents)
+		return hourlyCost, nil
+	}
+
+	fmt.Println(greenTextStyle.Render(fmt.Sprintf("%d workloads from %s mapped to GKE Autopilot mode in %s.", len(estimates), path, region)))
+	DisplayEstimateTable(estimates)
+
+	return hourlyCost, nil
+}
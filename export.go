@@ -0,0 +1,364 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// exportSchema are the typed columns of the workload export, in the order of exportRow.values.
+// Rows are appended to the tables of earlier runs, so the names must not change and new columns go last.
+var exportSchema = []*bigquery.TableFieldSchema{
+	{Name: "run_time", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "project", Type: "STRING"},
+	{Name: "cluster", Type: "STRING"},
+	{Name: "region", Type: "STRING"},
+	{Name: "namespace", Type: "STRING"},
+	{Name: "workload", Type: "STRING"},
+	{Name: "owner_kind", Type: "STRING"},
+	{Name: "owner_name", Type: "STRING"},
+	{Name: "node", Type: "STRING"},
+	{Name: "spot", Type: "BOOLEAN"},
+	{Name: "compute_class", Type: "STRING"},
+	{Name: "mcpu", Type: "INTEGER"},
+	{Name: "memory_mib", Type: "INTEGER"},
+	{Name: "storage_mib", Type: "INTEGER"},
+	{Name: "accelerator_type", Type: "STRING"},
+	{Name: "accelerator_count", Type: "INTEGER"},
+	{Name: "hourly_cost", Type: "FLOAT"},
+	{Name: "effective_hourly_cost", Type: "FLOAT"},
+	{Name: "monthly_cost", Type: "FLOAT"},
+	{Name: "labels", Type: "STRING"},
+	{Name: "warnings", Type: "STRING"},
+}
+
+// exportRow is a single workload of a run, flattened for Looker Studio.
+type exportRow struct {
+	RunTime  time.Time
+	Project  string
+	Cluster  string
+	Region   string
+	Node     cluster.Node
+	Workload cluster.Workload
+}
+
+func (row exportRow) values() []interface{} {
+	return []interface{}{
+		row.RunTime.UTC().Format(time.RFC3339),
+		row.Project,
+		row.Cluster,
+		row.Region,
+		row.Workload.Namespace,
+		row.Workload.Name,
+		row.Workload.Owner.Kind,
+		row.Workload.Owner.Name,
+		row.Node.Name,
+		row.Node.Spot || row.Workload.Spot,
+		cluster.ComputeClasses[row.Workload.ComputeClass],
+		row.Workload.Cpu,
+		row.Workload.Memory,
+		row.Workload.Storage,
+		row.Workload.AcceleratorType,
+		row.Workload.AcceleratorAmount,
+		row.Workload.Cost.Float64(),
+		row.Workload.EffectiveCost.Float64(),
+		row.Workload.Cost.Mul(calculator.HOURS_PER_MONTH).Float64(),
+		formatLabels(row.Workload.Labels),
+		formatWarnings(row.Workload.Warnings),
+	}
+}
+
+// insertId identifies the row of a workload in a run, so BigQuery drops the duplicates of a retried insert.
+func (row exportRow) insertId() string {
+	hash := sha1.Sum([]byte(strings.Join([]string{row.RunTime.UTC().Format(time.RFC3339Nano), row.Project, row.Cluster, row.Region, row.Node.Name, row.Workload.Namespace, row.Workload.Name}, "/")))
+	return hex.EncodeToString(hash[:])
+}
+
+// formatLabels joins the labels as sorted key=value pairs, so they can be filtered on as text.
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// formatWarnings joins the codes of the warnings with ";", each code once, eg. sandbox;out-of-range.
+func formatWarnings(warnings []cluster.Warning) string {
+	codes := []string{}
+	for _, warning := range warnings {
+		if code := string(warning.Type); !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+
+	return strings.Join(codes, ";")
+}
+
+func getExportRows(runTime time.Time, project string, clusterName string, region string, nodes map[string]cluster.Node) []exportRow {
+	var rows []exportRow
+	for _, node := range cluster.SortedNodes(nodes) {
+		for _, workload := range node.Workloads {
+			rows = append(rows, exportRow{RunTime: runTime, Project: project, Cluster: clusterName, Region: region, Node: node, Workload: workload})
+		}
+	}
+
+	return rows
+}
+
+// writeExportCsv writes the rows with a header of the column names.
+func writeExportCsv(path string, rows []exportRow) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return renderExportCsv(file, rows)
+}
+
+func renderExportCsv(w io.Writer, rows []exportRow) error {
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(exportSchema))
+	for i, field := range exportSchema {
+		header[i] = field.Name
+	}
+	writer.Write(header)
+
+	for _, row := range rows {
+		values := row.values()
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = fmt.Sprint(value)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// writeExportBigQuery appends the rows to a BigQuery table given as project.dataset.table,
+// the table is created with a daily partitioning on run_time if it doesn't exist and the
+// columns added since it was created are added to it.
+func writeExportBigQuery(tablePath string, rows []exportRow) error {
+	parts := strings.Split(tablePath, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("bigquery table %q is not in the project.dataset.table format", tablePath)
+	}
+	project, dataset, table := parts[0], parts[1], parts[2]
+
+	ctx := context.Background()
+
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to initialize bigquery service: %v", err)
+	}
+
+	existing, err := svc.Tables.Get(project, dataset, table).Do()
+	if err == nil && existing.Schema != nil && len(existing.Schema.Fields) < len(exportSchema) {
+		_, err = svc.Tables.Patch(project, dataset, table, &bigquery.Table{
+			Schema: &bigquery.TableSchema{Fields: exportSchema},
+		}).Do()
+	}
+	if apiErr, ok := err.(*googleapi.Error); ok && apiErr.Code == http.StatusNotFound {
+		_, err = svc.Tables.Insert(project, dataset, &bigquery.Table{
+			TableReference:   &bigquery.TableReference{ProjectId: project, DatasetId: dataset, TableId: table},
+			Schema:           &bigquery.TableSchema{Fields: exportSchema},
+			TimePartitioning: &bigquery.TimePartitioning{Type: "DAY", Field: "run_time"},
+		}).Do()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to get or create bigquery table: %v", err)
+	}
+
+	// Streaming inserts are limited in size, so rows are sent in batches
+	const batchSize = 500
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		request := &bigquery.TableDataInsertAllRequest{}
+		for _, row := range rows[start:end] {
+			values := row.values()
+			json := make(map[string]bigquery.JsonValue, len(values))
+			for i, value := range values {
+				json[exportSchema[i].Name] = value
+			}
+			request.Rows = append(request.Rows, &bigquery.TableDataInsertAllRequestRows{InsertId: row.insertId(), Json: json})
+		}
+
+		response, err := svc.Tabledata.InsertAll(project, dataset, table, request).Do()
+		if err != nil {
+			return fmt.Errorf("unable to insert rows into bigquery: %v", err)
+		}
+		if len(response.InsertErrors) > 0 {
+			return fmt.Errorf("bigquery rejected %d rows", len(response.InsertErrors))
+		}
+	}
+
+	return nil
+}
+
+//go:embed templates/looker-studio.sql
+var lookerStudioQueryTemplate string
+
+// lookerStudioQuery returns the custom query of the Looker Studio data source over a table of the export.
+func lookerStudioQuery(project string, dataset string, table string) (string, error) {
+	tmpl, err := template.New("looker-studio").Parse(lookerStudioQueryTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var query strings.Builder
+	if err := tmpl.Execute(&query, struct{ Table string }{project + "." + dataset + "." + table}); err != nil {
+		return "", err
+	}
+
+	return query.String(), nil
+}
+
+// getLookerStudioUrl returns the Looker Studio link that creates a report with the custom query
+// of templates/looker-studio.sql as data source, run in the project of the table.
+func getLookerStudioUrl(tablePath string) (string, error) {
+	parts := strings.Split(tablePath, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("bigquery table %q is not in the project.dataset.table format", tablePath)
+	}
+
+	query, err := lookerStudioQuery(parts[0], parts[1], parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("c.mode", "edit")
+	params.Set("r.reportName", "GKE Autopilot cost estimate")
+	params.Set("ds.ds0.datasourceName", "Autopilot cost export "+parts[2])
+	params.Set("ds.ds0.connector", "bigQuery")
+	params.Set("ds.ds0.type", "CUSTOM_QUERY")
+	params.Set("ds.ds0.projectId", parts[0])
+	params.Set("ds.ds0.sql", query)
+
+	return "https://lookerstudio.google.com/reporting/create?" + params.Encode(), nil
+}
+
+// totalsHeader are the columns of the cluster totals appended to a Google Sheet.
+var totalsHeader = []interface{}{"run_time", "project", "cluster", "region", "workloads", "standard_hourly_cost", "autopilot_hourly_cost", "savings_hourly", "savings_percent", "autopilot_monthly_cost"}
+
+// getTotalRows returns a row with the Standard and Autopilot cost of every cluster of the run.
+func getTotalRows(reports []*clusterReport) [][]interface{} {
+	var rows [][]interface{}
+	for _, report := range reports {
+		comparison := report.comparison
+		rows = append(rows, []interface{}{
+			report.samples[len(report.samples)-1].Timestamp.UTC().Format(time.RFC3339),
+			report.Project,
+			report.Name,
+			report.Region,
+			len(report.workloads),
+			comparison.StandardCost.Float64(),
+			comparison.AutopilotCost.Float64(),
+			comparison.Savings.Float64(),
+			comparison.SavingsPercent,
+			comparison.AutopilotCost.Mul(calculator.HOURS_PER_MONTH).Float64(),
+		})
+	}
+
+	return rows
+}
+
+// writeExportSheet appends the workload rows to the Workloads tab and the cluster totals to the Totals tab
+// of a Google Sheet, the tabs are created with a header row if they don't exist.
+func writeExportSheet(spreadsheetId string, rows []exportRow, totals [][]interface{}) error {
+	ctx := context.Background()
+
+	svc, err := sheets.NewService(ctx, option.WithScopes(sheets.SpreadsheetsScope))
+	if err != nil {
+		return fmt.Errorf("unable to initialize sheets service: %v", err)
+	}
+
+	spreadsheet, err := svc.Spreadsheets.Get(spreadsheetId).Fields("sheets.properties.title").Do()
+	if err != nil {
+		return fmt.Errorf("unable to get spreadsheet: %v", err)
+	}
+	tabs := make(map[string]bool)
+	for _, sheet := range spreadsheet.Sheets {
+		tabs[sheet.Properties.Title] = true
+	}
+
+	header := make([]interface{}, len(exportSchema))
+	for i, field := range exportSchema {
+		header[i] = field.Name
+	}
+	workloads := [][]interface{}{}
+	for _, row := range rows {
+		workloads = append(workloads, row.values())
+	}
+
+	for _, tab := range []struct {
+		Title  string
+		Header []interface{}
+		Rows   [][]interface{}
+	}{
+		{"Workloads", header, workloads},
+		{"Totals", totalsHeader, totals},
+	} {
+		values := tab.Rows
+		if !tabs[tab.Title] {
+			_, err := svc.Spreadsheets.BatchUpdate(spreadsheetId, &sheets.BatchUpdateSpreadsheetRequest{
+				Requests: []*sheets.Request{{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: tab.Title}}}},
+			}).Do()
+			if err != nil {
+				return fmt.Errorf("unable to add %s sheet: %v", tab.Title, err)
+			}
+			values = append([][]interface{}{tab.Header}, values...)
+		}
+
+		_, err := svc.Spreadsheets.Values.Append(spreadsheetId, tab.Title+"!A1", &sheets.ValueRange{Values: values}).
+			ValueInputOption("RAW").InsertDataOption("INSERT_ROWS").Do()
+		if err != nil {
+			return fmt.Errorf("unable to append rows to %s sheet: %v", tab.Title, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// namespacePatterns is a repeatable flag of namespace globs, eg. -namespace shop -namespace team-*.
+type namespacePatterns []string
+
+func (patterns *namespacePatterns) String() string {
+	if patterns == nil {
+		return ""
+	}
+	return strings.Join(*patterns, ",")
+}
+
+func (patterns *namespacePatterns) Set(value string) error {
+	if err := cluster.ValidateNamespacePattern(value); err != nil {
+		return err
+	}
+	*patterns = append(*patterns, value)
+	return nil
+}
+
+// labelSelector is a flag of a label selector, eg. app=shop,tier!=batch, checked when it is parsed.
+type labelSelector string
+
+func (selector *labelSelector) String() string {
+	if selector == nil {
+		return ""
+	}
+	return string(*selector)
+}
+
+func (selector *labelSelector) Set(value string) error {
+	if _, err := labels.Parse(value); err != nil {
+		return fmt.Errorf("invalid label selector %q: %v", value, err)
+	}
+	*selector = labelSelector(value)
+	return nil
+}
+
+// addKubectlFlags adds the --kubeconfig, --context, --namespace (-n) and --selector (-l) flags of kubectl,
+// so the binary behaves the same when it's installed as the kubectl-autopilot_cost plugin, and
+// --exclude-namespace. It returns the namespaces and the label selector the workloads are limited to.
+func addKubectlFlags(flags *flag.FlagSet) (*cluster.NamespaceFilter, *string) {
+	addKubeConfigFlags(flags)
+
+	namespaces := &cluster.NamespaceFilter{}
+	flags.Var((*namespacePatterns)(&namespaces.Include), "namespace", "Only estimate the workloads of this namespace, can be a glob like team-* and repeated")
+	flags.Var((*namespacePatterns)(&namespaces.Include), "n", "Shorthand for -namespace")
+	flags.Var((*namespacePatterns)(&namespaces.Exclude), "exclude-namespace", "Don't estimate the workloads of this namespace, can be a glob like *-test and repeated")
+
+	selector := new(string)
+	flags.Var((*labelSelector)(selector), "selector", "Only estimate the pods matching this label selector, eg. app=shop,tier!=batch")
+	flags.Var((*labelSelector)(selector), "l", "Shorthand for -selector")
+
+	return namespaces, selector
+}
+
+// addKubeConfigFlags adds the --kubeconfig and --context flags of kubectl.
+func addKubeConfigFlags(flags *flag.FlagSet) {
+	flags.StringVar(&cluster.KubeConfigPath, "kubeconfig", "", "Path to the kube config file, defaults to KUBECONFIG or ~/.kube/config like kubectl")
+	flags.StringVar(&cluster.KubeContext, "context", "", "Kube config context to use instead of the current context")
+}
@@ -0,0 +1,53 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package location normalizes the locations of clusters and nodes, eg. the region of a zone,
+// so pricing and cluster metadata agree on where a cluster runs.
+package location
+
+import (
+	"regexp"
+	"strings"
+)
+
+// locationPattern matches regions like us-central1 and zones like us-central1-a or us-central1-ai1a,
+// the region is the first group and the zone suffix the second.
+var locationPattern = regexp.MustCompile(`^([a-z]+-[a-z]+[0-9]+)(?:-([a-z][a-z0-9]*))?$`)
+
+// Normalize trims and lowercases a location, and picks the first of a list of locations, eg. the
+// node locations "us-central1-a,us-central1-b" of a multi-zone cluster, as they share a region.
+func Normalize(location string) string {
+	location, _, _ = strings.Cut(location, ",")
+	return strings.ToLower(strings.TrimSpace(location))
+}
+
+// Region returns the region of a zone or region, eg. us-central1 for us-central1-a. Locations that
+// are neither, eg. global or the multi-region us, are returned normalized but otherwise as they are.
+func Region(location string) string {
+	location = Normalize(location)
+
+	parts := locationPattern.FindStringSubmatch(location)
+	if parts == nil {
+		return location
+	}
+
+	return parts[1]
+}
+
+// IsZone checks if the location is a zone, eg. us-central1-a, rather than a region.
+func IsZone(location string) bool {
+	parts := locationPattern.FindStringSubmatch(Normalize(location))
+
+	return parts != nil && parts[2] != ""
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package location
+
+import "testing"
+
+func TestLocationRegion(t *testing.T) {
+	tests := []struct {
+		location string
+		region   string
+		zone     bool
+	}{
+		{"us-central1", "us-central1", false},
+		{"us-central1-a", "us-central1", true},
+		{"northamerica-northeast1-b", "northamerica-northeast1", true},
+		{"us-central1-ai1a", "us-central1", true},
+		{" Europe-West4-C ", "europe-west4", true},
+		{"us-central1-a,us-central1-b", "us-central1", true},
+		{"global", "global", false},
+		{"us", "us", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		if got := Region(test.location); got != test.region {
+			t.Errorf("Region(%q) = %q, expected %q", test.location, got, test.region)
+		}
+		if got := IsZone(test.location); got != test.zone {
+			t.Errorf("IsZone(%q) = %v, expected %v", test.location, got, test.zone)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"us-central1", "us-central1"},
+		{" US-Central1-A\n", "us-central1-a"},
+		// The node locations of a multi-zone cluster share a region
+		{"europe-west4-b, europe-west4-c", "europe-west4-b"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := Normalize(test.location); got != test.want {
+			t.Errorf("Normalize(%q) = %q, expected %q", test.location, got, test.want)
+		}
+	}
+}
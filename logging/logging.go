@@ -0,0 +1,107 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging writes leveled log messages to stderr, so normal runs only show
+// what the user acts on and -verbose reveals details like the SKU matching.
+package logging
+
+import (
+	"flag"
+	"log"
+	"strconv"
+	"sync/atomic"
+)
+
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+var level atomic.Int32
+
+func init() {
+	level.Store(int32(LevelInfo))
+}
+
+// SetLevel sets the lowest level that is logged.
+func SetLevel(l Level) {
+	level.Store(int32(l))
+}
+
+// Enabled checks if messages of the level are logged, eg. to skip building an expensive debug message.
+func Enabled(l Level) bool {
+	return l >= Level(level.Load())
+}
+
+func logf(l Level, format string, args ...interface{}) {
+	if !Enabled(l) {
+		return
+	}
+
+	log.Printf(levelNames[l]+" "+format, args...)
+}
+
+// Debug logs details that are only useful to troubleshoot the estimate, shown with -verbose.
+func Debug(format string, args ...interface{}) {
+	logf(LevelDebug, format, args...)
+}
+
+// Info logs the progress of the run, eg. where a report was saved.
+func Info(format string, args ...interface{}) {
+	logf(LevelInfo, format, args...)
+}
+
+// Warn logs conditions that lower the accuracy of the estimate.
+func Warn(format string, args ...interface{}) {
+	logf(LevelWarn, format, args...)
+}
+
+// Error logs failures the run continues after, they are shown with -quiet as well.
+func Error(format string, args ...interface{}) {
+	logf(LevelError, format, args...)
+}
+
+// levelFlag is a boolean flag that sets the log level when it is enabled.
+type levelFlag Level
+
+func (f levelFlag) String() string {
+	return "false"
+}
+
+func (f levelFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		SetLevel(Level(f))
+	}
+	return nil
+}
+
+func (f levelFlag) IsBoolFlag() bool {
+	return true
+}
+
+// AddFlags registers -verbose and -quiet on the flag set.
+func AddFlags(flags *flag.FlagSet) {
+	flags.Var(levelFlag(LevelDebug), "verbose", "Log debug details, eg. how billing SKUs are matched to prices")
+	flags.Var(levelFlag(LevelError), "quiet", "Only log errors")
+}
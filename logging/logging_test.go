@@ -0,0 +1,70 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoggingFlags(t *testing.T) {
+	defer SetLevel(LevelInfo)
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	AddFlags(flags)
+
+	if Enabled(LevelDebug) || !Enabled(LevelInfo) {
+		t.Fatalf("debug messages are logged by default")
+	}
+
+	if err := flags.Parse([]string{"-verbose"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !Enabled(LevelDebug) {
+		t.Errorf("-verbose doesn't log debug messages")
+	}
+
+	if err := flags.Parse([]string{"-quiet"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if Enabled(LevelWarn) || !Enabled(LevelError) {
+		t.Errorf("-quiet logs more than errors")
+	}
+}
+
+func TestLevels(t *testing.T) {
+	var buffer bytes.Buffer
+	log.SetOutput(&buffer)
+	defer log.SetOutput(os.Stderr)
+	defer SetLevel(LevelInfo)
+	flags := log.Flags()
+	log.SetFlags(0)
+	defer log.SetFlags(flags)
+
+	SetLevel(LevelWarn)
+	Debug("sku %s", "A")
+	Info("saved %s", "report.html")
+	Warn("no price in %s", "us-central1")
+	Error("failed %d times", 2)
+
+	// Messages below the level are dropped, the others are prefixed with their level
+	if got := strings.Split(strings.TrimSpace(buffer.String()), "\n"); len(got) != 2 || got[0] != "WARN no price in us-central1" || got[1] != "ERROR failed 2 times" {
+		t.Fatalf("logged %q, expected the warning and the error", buffer.String())
+	}
+}
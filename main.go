@@ -16,93 +16,568 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
 	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
-	container "google.golang.org/api/container/v1"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/location"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/pkg/estimator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
 	"gopkg.in/ini.v1"
-	"k8s.io/client-go/kubernetes"
-	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
+// JSON_SCHEMA_VERSION is the version of the -json documents. It is raised when a field is removed or
+// changes its meaning, new fields are added without a new version.
+const JSON_SCHEMA_VERSION = 1
+
+// jsonReport is the document written by the -json flag.
+type jsonReport struct {
+	SchemaVersion    int
+	Context          string       `json:",omitempty"`
+	Cluster          *clusterInfo `json:",omitempty"`
+	Currency         string
+	TimeBasis        string           `json:",omitempty"`
+	Pricing          *pricingSnapshot `json:",omitempty"`
+	Nodes            map[string]cluster.Node
+	Workloads        []cluster.Workload
+	ClassTotals      []classTotal
+	Discounts        []discountScenario
+	Namespaces       []cluster.NamespaceCost
+	Owners           []cluster.OwnerCost              `json:",omitempty"`
+	Autoscalers      []cluster.AutoscalerCost         `json:",omitempty"`
+	MaxScaleCost     cluster.Money                    `json:",omitempty"`
+	Labels           []cluster.LabelCost              `json:",omitempty"`
+	LoadBalancers    []cluster.LoadBalancer           `json:",omitempty"`
+	LoadBalancerCost cluster.Money                    `json:",omitempty"`
+	SpotScenario     *calculator.SpotScenario         `json:",omitempty"`
+	Coverage         []calculator.CoverageScenario    `json:",omitempty"`
+	Recommendations  []calculator.ClassRecommendation `json:",omitempty"`
+	Existing         *calculator.ExistingCapacity     `json:",omitempty"`
+	Comparison       calculator.StandardComparison
+	Projections      []costProjection `json:",omitempty"`
+	TimeSeries       []cluster.Sample `json:",omitempty"`
+	Usage            *usage.Report    `json:",omitempty"`
+	// MigrationBlockers are the warnings of workloads that can't run in Autopilot as they are
+	MigrationBlockers []cluster.Warning `json:",omitempty"`
+	Warnings          []cluster.Warning
+	// SystemWorkloads are the workloads of the system namespaces with -include-system, they are not billed
+	SystemWorkloads []cluster.Workload `json:",omitempty"`
+	// Partial is set when the estimate was interrupted, the later samples and eg. the owners are missing
+	Partial bool `json:",omitempty"`
+}
+
+// clusterInfo identifies the estimated cluster.
+type clusterInfo struct {
+	Name    string
+	Project string
+	Region  string
+	Status  string
+	Version string
+	// PricingRegion is set when the cluster was priced as if it ran in another region with -region
+	PricingRegion string `json:",omitempty"`
+}
+
+// pricingSnapshot are the prices the estimate was made with, so it can be reproduced after a price change.
+type pricingSnapshot struct {
+	Region     string
+	ClusterFee cluster.Money
+	Autopilot  calculator.AutopilotPriceList
+	Standard   calculator.GCEPriceList
+}
+
+// classTotal is the summed cost of the workloads of a compute class.
+type classTotal struct {
+	ComputeClass string
+	Workloads    int
+	Cost         cluster.Money
+}
+
+// discountScenario is the Autopilot cost of the workloads with the commitment discounts of a term.
+type discountScenario struct {
+	Term string
+	Cost cluster.Money
+}
+
+// fleetReport is the document written by the -json flag when more than one context is estimated.
+type fleetReport struct {
+	SchemaVersion int
+	Currency      string
+	TimeBasis     string `json:",omitempty"`
+	Clusters      []jsonReport
+	Total         calculator.StandardComparison
+	Projections   []costProjection `json:",omitempty"`
+	Usage         *usage.Report    `json:",omitempty"`
+}
+
+// costProjection is the Standard and Autopilot cost over a period selected with -projection.
+type costProjection struct {
+	Period        string
+	Hours         float64
+	StandardCost  cluster.Money
+	AutopilotCost cluster.Money
+	Savings       cluster.Money
+}
+
+// getTimeBasis names the period selected with -time-basis for the JSON documents, empty for the hourly default.
+func getTimeBasis() string {
+	if timeBasis.Hours == 1 {
+		return ""
+	}
+	return timeBasis.Period
+}
+
+func getCostProjections(comparison calculator.StandardComparison) []costProjection {
+	costProjections := []costProjection{}
+	for _, projection := range projections {
+		costProjections = append(costProjections, costProjection{
+			Period:        projection.Period,
+			Hours:         projection.Hours,
+			StandardCost:  comparison.StandardCost.Mul(projection.Hours),
+			AutopilotCost: comparison.AutopilotCost.Mul(projection.Hours),
+			Savings:       comparison.Savings.Mul(projection.Hours),
+		})
+	}
+	return costProjections
+}
+
+// runOptions are the flags that change how a cluster is estimated and reported.
+type runOptions struct {
+	amortizeFee       bool
+	allSpot           bool
+	spotOverhead      float64
+	includeCompleted  bool
+	existingCapacity  bool
+	samples           int
+	sampleInterval    time.Duration
+	timeSeries        bool
+	groupByOwner      bool
+	hpaMax            bool
+	prorateJobs       bool
+	groupByLabel      string
+	loadBalancers     bool
+	freeTier          bool
+	cudCoverage       float64
+	metricsSource     string
+	promUrl           string
+	sizingMode        string
+	sidecarMode       string
+	metricsWindow     time.Duration
+	metricsPercentile float64
+	recommendClasses  bool
+	showAdjustments   bool
+	namespaces        cluster.NamespaceFilter
+	selector          string
+	region            string
+	targetRegion      string
+	skipGKECheck      bool
+	sortBy            workloadOrder
+	top               int
+}
+
+// clusterReport is the estimate of a single cluster.
+type clusterReport struct {
+	Context string
+	Name    string
+	Project string
+	Region  string
+	Status  string
+	Version string
+	// pricingRegion is set when -region prices the cluster as if it ran in another region
+	pricingRegion string
+
+	pricingService *calculator.PricingService
+	// Fee of the Autopilot cluster, standardFee is the fee of the current cluster
+	clusterFee  cluster.Money
+	standardFee cluster.Money
+
+	nodes            map[string]cluster.Node
+	workloads        []cluster.Workload
+	systemWorkloads  []cluster.Workload
+	owners           []cluster.OwnerCost
+	autoscalers      []cluster.AutoscalerCost
+	loadBalancers    []cluster.LoadBalancer
+	loadBalancerCost cluster.Money
+	samples          []cluster.Sample
+	spotScenario     *calculator.SpotScenario
+	coverage         []calculator.CoverageScenario
+	recommendations  []calculator.ClassRecommendation
+	existing         *calculator.ExistingCapacity
+	comparison       calculator.StandardComparison
+	// partial is set when the estimate was interrupted by Ctrl-C or -timeout after its first sample
+	partial bool
+}
+
 func main() {
-	cfg, err := ini.Load("config.ini")
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		if !RunDoctor(os.Args[2:]) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := loadConfig()
 	if err != nil {
 		fmt.Printf("Fail to read file: %v", err)
 		os.Exit(1)
 	}
 
+	if currency := cfg.Section("").Key("currency").String(); currency != "" {
+		cluster.DisplayCurrency = currency
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "estimate" && os.Args[2] == "pod" {
+		if err := RunEstimatePod(cfg, os.Args[3:]); err != nil {
+			log.Fatalf("Error estimating pod: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "annotate" {
+		if err := RunAnnotate(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Error annotating controllers: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := RunDiff(os.Args[2:]); err != nil {
+			log.Fatalf("Error comparing reports: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := RunServe(cfg, os.Args[2:]); err != nil {
+			log.Fatalf("Error serving the estimate API: %v", err)
+		}
+		return
+	}
+
 	jsonFlag := flag.Bool("json", false, "Generate json file with the results")
+	projectionFlag := flag.String("projection", "", "Comma separated periods hourly prices are projected to next to the hourly price: day, month or year")
+	timeBasisFlag := flag.String("time-basis", "hour", "Period of the prices of the tables and the report: hour, day or month (730 hours)")
+	outputFlag := flag.String("output", "table", "Output format: table, markdown, plain or json")
+	sortByFlag := flag.String("sort-by", "node", "Order of the workload table: node, namespace, name, cost, mcpu or memory, add :asc or :desc to change the direction, eg. cost:asc")
+	topFlag := flag.Int("top", 0, "Only list the N most expensive workloads in the workload table and sum up the others in a single row, 0 lists all workloads")
+	includeSystemFlag := flag.Bool("include-system", false, "Also price the workloads of the system namespaces, eg. kube-system, and show them in a separate section as they are not billed in Autopilot")
+	interactiveFlag := flag.Bool("interactive", false, "Browse the workloads in an interactive table that can be scrolled, sorted and filtered")
+	plainFlag := flag.Bool("plain", false, "Print ASCII tables and text without colors, same as -output plain or the NO_COLOR environment variable")
 	jsonFileFlag := flag.String("json-file", "", "json file location")
+	amortizeFeeFlag := flag.Bool("amortize-fee", false, "Spread the cluster fee across workloads proportionally to their cost")
+	allSpotFlag := flag.Bool("all-spot", false, "Show the cost if every eligible workload ran as a Spot Pod")
+	spotOverheadFlag := flag.Float64("spot-overhead", -1, "Share of Spot Pod time lost to preemptions, eg. 0.1, overrides the config value and the value derived from node churn")
+	includeCompletedFlag := flag.Bool("include-completed", false, "Include Succeeded and Failed (eg. Evicted) pods in the estimate for audit")
+	groupByOwnerFlag := flag.Bool("group-by-owner", false, "Aggregate the pods of every Deployment, StatefulSet, DaemonSet, Job or CronJob into one row")
+	hpaMaxFlag := flag.Bool("hpa-max", false, "Show the cost if every HorizontalPodAutoscaler scaled its target to maxReplicas, the upper bound of the bill")
+	prorateJobsFlag := flag.Bool("prorate-jobs", false, "Price the pods of Jobs and CronJobs for the share of the month they run, from the CronJob schedule and the run time of completed Jobs")
+	groupByLabelFlag := flag.String("group-by-label", "", "Sum the cost of the workloads per value of this label, eg. team")
+	loadBalancersFlag := flag.Bool("load-balancers", false, "Price the load balancers of Services, Ingresses and Gateways as separate line items")
+	showAdjustmentsFlag := flag.Bool("show-adjustments", false, "List the workloads whose requests Autopilot adjusts to the minimums, mCPU steps and ratios of their compute class")
+	recommendClassesFlag := flag.Bool("recommend-classes", false, "Price every compute class a workload is eligible for and show where another class is cheaper")
+	cudCoverageFlag := flag.String("cud-coverage", "", "Share of the eligible spend covered by flexible committed use discounts, eg. 60%")
+	existingCapacityFlag := flag.Bool("existing-capacity", false, "Look up Compute Engine commitments and reservations in the project that are already paid for")
+	metricsSourceFlag := flag.String("metrics-source", "metrics-server", "Source of the container usage: metrics-server for the current usage, monitoring or prometheus for a percentile over -metrics-window")
+	sizingModeFlag := flag.String("sizing-mode", calculator.SIZING_MAX, "What containers are priced by: requests (how Autopilot bills), usage (to see the rightsizing potential) or max of both")
+	sidecarsFlag := flag.String("sidecars", calculator.SIDECARS_INCLUDE, "How the injected sidecars of the names key of the [sidecars] config section, eg. istio-proxy, are priced: include, exclude or separate to show their cost on its own")
+	promUrlFlag := flag.String("prom-url", "", "URL of the Prometheus API for -metrics-source prometheus, eg. http://localhost:9090")
+	metricsWindowFlag := flag.String("metrics-window", "7d", "Window of the usage history of -metrics-source monitoring or prometheus, eg. 7d or 30d")
+	metricsPercentileFlag := flag.Float64("metrics-percentile", 95, "Percentile of the usage history workloads are sized at, eg. 95")
+	samplesFlag := flag.Int("samples", 1, "Number of times the cluster is sampled, the report uses the last sample")
+	sampleIntervalFlag := flag.Duration("sample-interval", time.Minute, "Time between two samples")
+	timeSeriesFlag := flag.Bool("time-series", false, "Add the timestamped total of every sample to the json output")
+	timeSeriesCsvFlag := flag.String("time-series-csv", "", "Write the timestamped total of every sample to this csv file")
+	exportCsvFlag := flag.String("export-csv", "", "Write one row per workload for Looker Studio to this csv file")
+	exportBigQueryFlag := flag.String("export-bigquery", "", "Append one row per workload for Looker Studio to this BigQuery table (project.dataset.table)")
+	exportSheetFlag := flag.String("export-sheet", "", "Append the workloads and cluster totals to the Workloads and Totals tabs of this Google Sheet (spreadsheet ID)")
+	htmlFileFlag := flag.String("html-file", "", "Write a self-contained HTML report to this file")
+	uploadFlag := flag.String("upload", "", "Upload the JSON, CSV and HTML reports with a timestamped name to this Cloud Storage location, eg. gs://bucket/path/")
+	manifestsFlag := flag.String("manifests", "", "Estimate the workloads of local manifest files or a directory instead of a live cluster")
+	regionFlag := flag.String("region", "", "Region used for the pricing of -manifests, or to price the clusters as if they ran in it, eg. europe-west4")
+	skipGKECheckFlag := flag.Bool("skip-gke-check", false, "Don't ask the GKE API for the cluster, eg. without the container.clusters.get permission or to estimate a cluster that already runs in Autopilot")
+	targetRegionFlag := flag.String("target-region", "", "Estimate clusters that don't run on GKE, eg. EKS, AKS or on-prem, as if they were migrated to Autopilot in this region")
+	contextsFlag := flag.String("contexts", "", "Comma separated kubeconfig contexts to estimate instead of the current one")
+	allContextsFlag := flag.Bool("all-contexts", false, "Estimate every GKE context of the kubeconfig")
+	usageReportFlag := flag.Bool("usage-report", false, "Report the API calls made by the run and how long each phase took")
+	currencyFlag := flag.String("currency", "", "Currency code prices are fetched and shown in, eg. EUR, overrides the config value")
+	precisionFlag := flag.Int("precision", -1, "Number of decimal places used for prices, overrides the config value")
+	webhookUrlFlag := flag.String("webhook-url", "", "Post a summary of the run to this Slack compatible webhook URL, overrides the config value")
+	maxHourlyCostFlag := flag.Float64("max-hourly-cost", 0, "Exit with code 3 if the estimated Autopilot hourly cost is above this amount, for CI pipelines")
+	maxMonthlyCostFlag := flag.Float64("max-monthly-cost", 0, "Exit with code 3 if the estimated Autopilot monthly cost is above this amount, for CI pipelines")
+	watchFlag := flag.Bool("watch", false, "Keep running and recalculate the estimate every -interval")
+	intervalFlag := flag.Duration("interval", 10*time.Minute, "Time between two estimates of -watch")
+	timeoutFlag := flag.Duration("timeout", 0, "Stop the API calls after this time and report what was estimated so far, eg. 5m, 0 for no limit")
+	metricsAddrFlag := flag.String("metrics-addr", "", "Address the Prometheus metrics of -watch are served on, eg. :9090")
+	namespaceFlag, selectorFlag := addKubectlFlags(flag.CommandLine)
+	addConfigFlags(flag.CommandLine, cfg)
+	logging.AddFlags(flag.CommandLine)
 	flag.Parse()
 
-	// Setting up kube configurations
-	kubeConfig, kubeConfigPath, err := cluster.GetKubeConfig()
+	if err := checkConfig(cfg); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if precision, err := cfg.Section("display").Key("precision").Int(); err == nil {
+		cluster.DisplayPrecision = precision
+	}
+	if *precisionFlag >= 0 {
+		cluster.DisplayPrecision = *precisionFlag
+	}
+
+	switch *outputFlag {
+	case "table":
+	case "markdown":
+		markdownOutput = true
+	case "plain":
+		plainOutput = true
+	case "json":
+		*jsonFlag = true
+	default:
+		log.Fatalf("Unsupported output format %q, use table, markdown, plain or json", *outputFlag)
+	}
+	if *plainFlag {
+		plainOutput = true
+	}
+
+	projections, err = parseProjections(*projectionFlag)
 	if err != nil {
-		log.Fatalf("Error getting kubernetes config: %v\n", err)
+		log.Fatalf("Error parsing -projection: %v", err)
 	}
 
-	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	timeBasis, err = parseTimeBasis(*timeBasisFlag)
 	if err != nil {
-		log.Fatalf("Error setting kubernetes config: %v\n", err)
+		log.Fatalf("Error parsing -time-basis: %v", err)
+	}
+	// The amounts of -json stay hourly, its projections add the costs in the time basis
+	projected := timeBasis.Hours == 1
+	for _, projection := range projections {
+		projected = projected || projection.Period == timeBasis.Period
+	}
+	if !projected {
+		projections = append(projections, timeBasis)
 	}
 
-	metricsClientset, err := metricsv.NewForConfig(kubeConfig)
+	switch *metricsSourceFlag {
+	case "metrics-server", "monitoring":
+	case "prometheus":
+		if *promUrlFlag == "" {
+			log.Fatalf("-metrics-source prometheus needs -prom-url")
+		}
+	default:
+		log.Fatalf("Unsupported metrics source %q, use metrics-server, monitoring or prometheus", *metricsSourceFlag)
+	}
+
+	switch *sizingModeFlag {
+	case calculator.SIZING_REQUESTS, calculator.SIZING_USAGE, calculator.SIZING_MAX:
+	default:
+		log.Fatalf("Unsupported sizing mode %q, use requests, usage or max", *sizingModeFlag)
+	}
+
+	switch *sidecarsFlag {
+	case calculator.SIDECARS_INCLUDE, calculator.SIDECARS_EXCLUDE, calculator.SIDECARS_SEPARATE:
+	default:
+		log.Fatalf("Unsupported sidecar mode %q, use include, exclude or separate", *sidecarsFlag)
+	}
+
+	metricsWindow, err := parseWindow(*metricsWindowFlag)
 	if err != nil {
-		log.Fatalf("Error setting kubernetes metrics config: %v\n", err)
+		log.Fatalf("Error parsing -metrics-window: %v", err)
+	}
+	if *metricsPercentileFlag <= 0 || *metricsPercentileFlag > 100 {
+		log.Fatalf("-metrics-percentile has to be between 0 and 100")
 	}
 
-	svc, err := container.NewService(context.Background())
+	cudCoverage, err := parseCoverage(*cudCoverageFlag)
 	if err != nil {
-		log.Fatalf("Error initializing GKE client: %v", err)
+		log.Fatalf("Error parsing -cud-coverage: %v", err)
 	}
 
-	// Extract the information out of kube config file
-	currentContext, err := cluster.GetCurrentContext(kubeConfigPath)
+	sortBy, err := parseSortBy(*sortByFlag)
 	if err != nil {
-		log.Fatalf("Error getting GKE context: %v", err)
+		log.Fatalf("Error parsing -sort-by: %v", err)
 	}
 
-	clusterName := currentContext[3]
-	clusterRegion := currentContext[2]
-	clusterProject := currentContext[1]
-	clusterLocation := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", clusterProject, clusterRegion, clusterName)
+	namespaceFlag.IncludeSystem = *includeSystemFlag
 
-	clusterObject, err := svc.Projects.Locations.Clusters.Get(clusterLocation).Do()
-	if err != nil {
-		log.Fatalf("Error getting GKE cluster information: %s, %v", clusterName, err)
+	if *topFlag < 0 {
+		log.Fatalf("-top has to be 0 or more, got %d", *topFlag)
 	}
 
-	if clusterObject.Autopilot != nil && clusterObject.Autopilot.Enabled {
-		log.Fatalf("This is already an Autopilot cluster, `aborting`")
+	if *currencyFlag != "" {
+		cluster.DisplayCurrency = *currencyFlag
+		cfg.Section("").Key("currency").SetValue(*currencyFlag)
+		for _, warning := range currencyWarnings(cfg) {
+			logging.Warn("Config %s.", warning)
+		}
 	}
 
-	nodes, err := cluster.GetClusterNodes(clientset)
-	if err != nil {
-		log.Fatalf("Error getting cluster nodes: %v", err)
+	if *manifestsFlag != "" {
+		hourlyCost, err := RunEstimateManifests(cfg, *manifestsFlag, *regionFlag, *jsonFlag)
+		if err != nil {
+			log.Fatalf("Error estimating manifests: %v", err)
+		}
+		enforceBudget(hourlyCost, false, *maxHourlyCostFlag, *maxMonthlyCostFlag)
+		return
 	}
 
-	pricingSKUs := map[string]string{
-		"autopilot": cfg.Section("").Key("autopilot_sku").String(),
-		"gce":       cfg.Section("").Key("gce_sku").String(),
+	options := runOptions{
+		amortizeFee:       *amortizeFeeFlag,
+		allSpot:           *allSpotFlag,
+		spotOverhead:      *spotOverheadFlag,
+		includeCompleted:  *includeCompletedFlag,
+		existingCapacity:  *existingCapacityFlag,
+		samples:           *samplesFlag,
+		sampleInterval:    *sampleIntervalFlag,
+		timeSeries:        *timeSeriesFlag,
+		groupByOwner:      *groupByOwnerFlag,
+		hpaMax:            *hpaMaxFlag,
+		prorateJobs:       *prorateJobsFlag,
+		groupByLabel:      *groupByLabelFlag,
+		loadBalancers:     *loadBalancersFlag,
+		freeTier:          cfg.Section("fees").Key("free_tier").MustBool(false),
+		cudCoverage:       cudCoverage,
+		metricsSource:     *metricsSourceFlag,
+		promUrl:           *promUrlFlag,
+		sizingMode:        *sizingModeFlag,
+		sidecarMode:       *sidecarsFlag,
+		recommendClasses:  *recommendClassesFlag,
+		showAdjustments:   *showAdjustmentsFlag,
+		metricsWindow:     metricsWindow,
+		metricsPercentile: *metricsPercentileFlag,
+		namespaces:        *namespaceFlag,
+		selector:          *selectorFlag,
+		region:            *regionFlag,
+		targetRegion:      *targetRegionFlag,
+		skipGKECheck:      *skipGKECheckFlag,
+		sortBy:            sortBy,
+		top:               *topFlag,
 	}
-	pricingService, err := calculator.NewService(pricingSKUs, clusterRegion, clientset, metricsClientset, cfg)
-	if err != nil {
-		log.Fatalf("Error initializing pricing service: %v", err)
+
+	// An empty context name stands for the current context
+	contexts := []string{""}
+	if *contextsFlag != "" {
+		contexts = strings.Split(*contextsFlag, ",")
+	}
+	if *allContextsFlag {
+		contexts, err = cluster.ListGKEContexts()
+		if err != nil {
+			log.Fatalf("Error listing GKE contexts: %v", err)
+		}
 	}
 
-	workloads, err := pricingService.PopulateWorkloads(nodes)
-	if err != nil {
-		log.Fatalf(err.Error())
+	if *watchFlag {
+		if *intervalFlag <= 0 {
+			log.Fatalf("-interval has to be positive")
+		}
+		ctx, stop := newRunContext(0)
+		defer stop()
+		watchClusters(ctx, cfg, contexts, options, *intervalFlag, *timeoutFlag, *jsonFlag, *metricsAddrFlag)
+		return
+	}
+
+	ctx, stop := newRunContext(*timeoutFlag)
+	defer stop()
+
+	progress.Start()
+
+	var reports []*clusterReport
+	for _, contextName := range contexts {
+		if ctx.Err() != nil {
+			logging.Warn("Estimate interrupted, reporting the %d of %d clusters estimated so far", len(reports), len(contexts))
+			break
+		}
+
+		report, err := estimateCluster(ctx, cfg, contextName, options)
+		if err != nil {
+			// A single broken cluster shouldn't stop the report of a fleet
+			if len(contexts) > 1 {
+				logging.Warn("Skipping context %s: %v", contextName, err)
+				continue
+			}
+			progress.Stop()
+			log.Fatal(err)
+		}
+		reports = append(reports, report)
+
+		// The free tier credit is per billing account, so it only covers the first cluster
+		options.freeTier = false
+	}
+	progress.Stop()
+
+	if ctx.Err() != nil && len(reports) == 0 {
+		log.Fatalf("Estimate interrupted before any cluster was estimated: %v", ctx.Err())
+	}
+
+	if *timeSeriesCsvFlag != "" {
+		if err := writeTimeSeriesCsv(*timeSeriesCsvFlag, reports); err != nil {
+			log.Fatalf("Error writing time series: %v", err)
+		}
+		logging.Info("Time series saved to %s.", *timeSeriesCsvFlag)
+	}
+
+	var rows []exportRow
+	if *exportCsvFlag != "" || *exportBigQueryFlag != "" || *exportSheetFlag != "" || *uploadFlag != "" {
+		for _, report := range reports {
+			rows = append(rows, getExportRows(report.samples[len(report.samples)-1].Timestamp, report.Project, report.Name, report.Region, report.nodes)...)
+		}
+	}
+
+	if *exportCsvFlag != "" {
+		if err := writeExportCsv(*exportCsvFlag, rows); err != nil {
+			log.Fatalf("Error writing export: %v", err)
+		}
+		logging.Info("Export saved to %s.", *exportCsvFlag)
+	}
+
+	if *exportBigQueryFlag != "" {
+		if err := writeExportBigQuery(*exportBigQueryFlag, rows); err != nil {
+			log.Fatalf("Error writing export: %v", err)
+		}
+		logging.Info("Export appended to %s.", *exportBigQueryFlag)
+		if link, err := getLookerStudioUrl(*exportBigQueryFlag); err == nil {
+			logging.Info("Chart it in Looker Studio: %s", link)
+		}
+	}
+
+	if *exportSheetFlag != "" {
+		if err := writeExportSheet(*exportSheetFlag, rows, getTotalRows(reports)); err != nil {
+			log.Fatalf("Error writing export: %v", err)
+		}
+		logging.Info("Export appended to spreadsheet %s.", *exportSheetFlag)
+	}
+
+	if *htmlFileFlag != "" {
+		if err := writeHtmlReport(*htmlFileFlag, reports); err != nil {
+			log.Fatalf("Error writing HTML report: %v", err)
+		}
+		logging.Info("HTML report saved to %s.", *htmlFileFlag)
+	}
+
+	if *uploadFlag != "" {
+		objects, err := renderUploadObjects(reports, options, rows)
+		if err != nil {
+			log.Fatalf("Error rendering reports for upload: %v", err)
+		}
+
+		urls, err := uploadReports(*uploadFlag, time.Now(), objects)
+		if err != nil {
+			log.Fatalf("Error uploading reports: %v", err)
+		}
+		logging.Info("Reports uploaded to %s.", strings.Join(urls, ", "))
 	}
 
 	if *jsonFlag {
-		contents, _ := json.MarshalIndent(nodes, "", "    ")
+		contents, _ := json.MarshalIndent(getJSONDocument(reports, options, *usageReportFlag), "", "    ")
 
 		if *jsonFileFlag != "" {
 			jsonOutput, err := os.Create(*jsonFileFlag)
@@ -112,39 +587,587 @@ func main() {
 
 			_, err = jsonOutput.Write(contents)
 			if err != nil {
-				log.Printf("Error writing json to file: %s", err.Error())
+				logging.Error("Error writing json to file: %s", err.Error())
 			}
-			log.Printf("JSON output saved to %s.", *jsonFileFlag)
+			logging.Info("JSON output saved to %s.", *jsonFileFlag)
 		} else {
 			fmt.Printf("%s", contents)
 		}
 
+	} else if *interactiveFlag {
+		if err := browseWorkloads(reports); err != nil {
+			log.Fatalf("Error browsing workloads: %v", err)
+		}
 	} else {
-		fmt.Println(pinkTextStyle.Render(fmt.Sprintf("Cluster %q (%s) on version: v%s", clusterObject.Name, clusterObject.Status, clusterObject.CurrentMasterVersion)))
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Println()
+			}
+			printClusterReport(report, options)
+		}
+
+		if len(contexts) > 1 {
+			fmt.Println()
+			fmt.Println(pinkTextStyle.Render(fmt.Sprintf("Fleet of %d clusters", len(reports))))
+			DisplayFleetTable(reports, getFleetTotal(reports))
+		}
+
+		if *usageReportFlag {
+			fmt.Println()
+			fmt.Println(blueTextStyle.Render("API calls and time spent by this run"))
+			DisplayUsageReport(usage.GetReport())
+		}
+	}
+
+	webhookUrl := cfg.Section("notifications").Key("webhook_url").String()
+	if *webhookUrlFlag != "" {
+		webhookUrl = *webhookUrlFlag
+	}
+	if webhookUrl != "" {
+		if err := sendNotification(webhookUrl, reports); err != nil {
+			logging.Error("Error sending notification: %v", err)
+		} else {
+			logging.Info("Summary posted to the webhook.")
+		}
+	}
+
+	enforceBudget(getFleetTotal(reports).AutopilotCost, isIncomplete(reports, len(contexts)), *maxHourlyCostFlag, *maxMonthlyCostFlag)
+}
+
+// newRunContext is cancelled by Ctrl-C, so the API calls in flight stop and the clusters estimated so far
+// are reported, and after timeout if it is positive. A second Ctrl-C exits right away.
+func newRunContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if timeout <= 0 {
+		return ctx, stop
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// estimateCluster maps all workloads of the cluster of a kubeconfig context to Autopilot.
+func estimateCluster(ctx context.Context, cfg *ini.File, contextName string, options runOptions) (*clusterReport, error) {
+	e := estimator.New()
+	err := e.Configure(cfg, estimator.Options{
+		Context:           contextName,
+		PricingRegion:     options.region,
+		TargetRegion:      options.targetRegion,
+		SkipGKECheck:      options.skipGKECheck,
+		AmortizeFee:       options.amortizeFee,
+		AllSpot:           options.allSpot,
+		SpotOverhead:      options.spotOverhead,
+		IncludeCompleted:  options.includeCompleted,
+		ExistingCapacity:  options.existingCapacity,
+		Samples:           options.samples,
+		SampleInterval:    options.sampleInterval,
+		GroupByOwner:      options.groupByOwner,
+		HPAMax:            options.hpaMax,
+		ProrateJobs:       options.prorateJobs,
+		LoadBalancers:     options.loadBalancers,
+		FreeTier:          options.freeTier,
+		CudCoverage:       options.cudCoverage,
+		MetricsSource:     options.metricsSource,
+		PrometheusUrl:     options.promUrl,
+		SizingMode:        options.sizingMode,
+		SidecarMode:       options.sidecarMode,
+		MetricsWindow:     options.metricsWindow,
+		MetricsPercentile: options.metricsPercentile,
+		RecommendClasses:  options.recommendClasses,
+		Namespaces:        options.namespaces,
+		Selector:          options.selector,
+		Progress:          progress.Step,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := e.Estimate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newClusterReport(report), nil
+}
+
+// newClusterReport wraps the estimate of the library for the reports of the command line.
+func newClusterReport(report *estimator.Report) *clusterReport {
+	clusterReport := &clusterReport{
+		Context:          report.Context,
+		Name:             report.Name,
+		Project:          report.Project,
+		Region:           report.Region,
+		Status:           report.Status,
+		Version:          report.Version,
+		pricingService:   report.PricingService,
+		clusterFee:       report.ClusterFee,
+		standardFee:      report.StandardFee,
+		nodes:            report.Nodes,
+		workloads:        report.Workloads,
+		systemWorkloads:  report.SystemWorkloads,
+		owners:           report.Owners,
+		autoscalers:      report.Autoscalers,
+		loadBalancers:    report.LoadBalancers,
+		loadBalancerCost: report.LoadBalancerCost,
+		samples:          report.Samples,
+		spotScenario:     report.SpotScenario,
+		coverage:         report.Coverage,
+		recommendations:  report.Recommendations,
+		existing:         report.Existing,
+		comparison:       report.Comparison,
+		partial:          report.Partial,
+	}
+	if report.PricingRegion != location.Region(report.Region) {
+		clusterReport.pricingRegion = report.PricingRegion
+	}
+
+	return clusterReport
+}
+
+func (report *clusterReport) jsonReport(options runOptions) jsonReport {
+	workloads := getSortedWorkloads(report.nodes)
+	document := jsonReport{
+		SchemaVersion:     JSON_SCHEMA_VERSION,
+		Context:           report.Context,
+		Currency:          cluster.DisplayCurrency,
+		TimeBasis:         getTimeBasis(),
+		Nodes:             report.nodes,
+		Workloads:         workloads,
+		ClassTotals:       getClassTotals(workloads),
+		Discounts:         []discountScenario{},
+		Namespaces:        cluster.GetNamespaceCosts(report.nodes),
+		Owners:            report.owners,
+		Autoscalers:       report.autoscalers,
+		LoadBalancers:     report.loadBalancers,
+		LoadBalancerCost:  report.loadBalancerCost,
+		SpotScenario:      report.spotScenario,
+		Coverage:          report.coverage,
+		Recommendations:   report.recommendations,
+		Existing:          report.existing,
+		Comparison:        report.comparison,
+		Partial:           report.partial,
+		Projections:       getCostProjections(report.comparison),
+		MigrationBlockers: cluster.GetMigrationBlockers(report.workloads),
+		Warnings:          cluster.CollectWarnings(report.workloads),
+		SystemWorkloads:   report.systemWorkloads,
+	}
+	if report.Name != "" {
+		document.Cluster = &clusterInfo{Name: report.Name, Project: report.Project, Region: report.Region, Status: report.Status, Version: report.Version, PricingRegion: report.pricingRegion}
+	}
+	if report.pricingService != nil {
+		region := report.Region
+		if report.pricingRegion != "" {
+			region = report.pricingRegion
+		}
+		document.Pricing = &pricingSnapshot{
+			Region:     region,
+			ClusterFee: report.clusterFee,
+			Autopilot:  report.pricingService.AutopilotPricing,
+			Standard:   report.pricingService.GCEPricing,
+		}
+		for _, term := range []string{calculator.CommitOneYear, calculator.CommitThreeYear} {
+			document.Discounts = append(document.Discounts, discountScenario{Term: term, Cost: report.pricingService.GetCommittedCost(report.nodes, term)})
+		}
+	}
+	if options.hpaMax {
+		document.MaxScaleCost = report.comparison.AutopilotCost + cluster.GetMaxScaleIncrease(report.autoscalers)
+	}
+	if options.groupByLabel != "" {
+		document.Labels = cluster.GetLabelCosts(report.nodes, options.groupByLabel)
+	}
+	if options.timeSeries {
+		document.TimeSeries = report.samples
+	}
+
+	return document
+}
+
+// getSortedWorkloads lists the workloads of all nodes by namespace and name.
+func getSortedWorkloads(nodes map[string]cluster.Node) []cluster.Workload {
+	workloads := []cluster.Workload{}
+	for _, node := range nodes {
+		workloads = append(workloads, node.Workloads...)
+	}
+	sort.Slice(workloads, func(i, j int) bool {
+		if workloads[i].Namespace != workloads[j].Namespace {
+			return workloads[i].Namespace < workloads[j].Namespace
+		}
+		return workloads[i].Name < workloads[j].Name
+	})
+
+	return workloads
+}
+
+// getClassTotals sums the cost of the workloads per compute class, in the order of cluster.ComputeClasses.
+func getClassTotals(workloads []cluster.Workload) []classTotal {
+	totals := make([]classTotal, len(cluster.ComputeClasses))
+	for class, name := range cluster.ComputeClasses {
+		totals[class].ComputeClass = name
+	}
+	for _, workload := range workloads {
+		totals[workload.ComputeClass].Workloads++
+		totals[workload.ComputeClass].Cost += workload.Cost
+	}
+
+	used := []classTotal{}
+	for _, total := range totals {
+		if total.Workloads > 0 {
+			used = append(used, total)
+		}
+	}
+
+	return used
+}
+
+// getJSONDocument returns the report of a single cluster, or the fleet report of several clusters.
+func getJSONDocument(reports []*clusterReport, options runOptions, usageReport bool) interface{} {
+	var usageDocument *usage.Report
+	if usageReport {
+		report := usage.GetReport()
+		usageDocument = &report
+	}
+
+	if len(reports) == 1 {
+		report := reports[0].jsonReport(options)
+		report.Usage = usageDocument
+		return report
+	}
+
+	total := getFleetTotal(reports)
+	fleet := fleetReport{SchemaVersion: JSON_SCHEMA_VERSION, Currency: cluster.DisplayCurrency, TimeBasis: getTimeBasis(), Clusters: []jsonReport{}, Total: total, Projections: getCostProjections(total), Usage: usageDocument}
+	for _, report := range reports {
+		fleet.Clusters = append(fleet.Clusters, report.jsonReport(options))
+	}
+
+	return fleet
+}
+
+// getFleetTotal sums the Standard and Autopilot costs of all clusters.
+func getFleetTotal(reports []*clusterReport) calculator.StandardComparison {
+	total := calculator.StandardComparison{Unpriced: []string{}}
+	for _, report := range reports {
+		total.StandardCost += report.comparison.StandardCost
+		total.AutopilotCost += report.comparison.AutopilotCost
+		total.Unpriced = append(total.Unpriced, report.comparison.Unpriced...)
+	}
+
+	total.Savings = total.StandardCost - total.AutopilotCost
+	if total.StandardCost > 0 {
+		total.SavingsPercent = total.Savings.Float64() / total.StandardCost.Float64() * 100
+	}
+
+	return total
+}
+
+func printClusterReport(report *clusterReport, options runOptions) {
+	nodes := report.nodes
+	pricingService := report.pricingService
+	spotScenario := report.spotScenario
+	existingCapacity := report.existing
+	comparison := report.comparison
+
+	fmt.Println(pinkTextStyle.Render(fmt.Sprintf("Cluster %q (%s) on version: v%s", report.Name, report.Status, report.Version)))
+	if report.pricingRegion != "" {
+		fmt.Println(redTextStyle.Render(fmt.Sprintf("Priced as if the cluster ran in %s instead of %s.", report.pricingRegion, report.Region)))
+	}
+	if report.partial {
+		fmt.Println(redTextStyle.Render("The estimate was interrupted, it only covers the samples taken until then."))
+	}
+	fmt.Println()
+
+	fmt.Println(blueTextStyle.Render(fmt.Sprintf("Nodes that you currently have at your cluster in %s: %d", report.Region, len(nodes))))
+	DisplayNodeTable(nodes)
+	fmt.Println()
+
+	oneYearCost := pricingService.GetCommittedCost(nodes, calculator.CommitOneYear)
+	threeYearCost := pricingService.GetCommittedCost(nodes, calculator.CommitThreeYear)
+
+	fmt.Println(greenTextStyle.Render(fmt.Sprintf("%d workloads from your cluster (%s) mapped to GKE Autopilot mode.", len(report.workloads), report.Name)))
+	fmt.Println()
+	switch options.sizingMode {
+	case calculator.SIZING_REQUESTS:
+		fmt.Println(redTextStyle.Render("Displayed values for mCPU, Memory and Storage are the requests of the containers, which is what Autopilot bills"))
+	case calculator.SIZING_USAGE:
+		fmt.Println(redTextStyle.Render("Displayed values for mCPU, Memory and Storage are the used values, regardless of requests, to show the cost after rightsizing"))
+	default:
+		fmt.Println(redTextStyle.Render("Displayed values for mCPU, Memory and Storage are a snapshot of this point in time. Those are not requets/limits but currently used values"))
+	}
+
+	DisplayWorkloadTable(nodes, options.sortBy, options.top, oneYearCost, threeYearCost, report.clusterFee, options.amortizeFee)
+
+	switch options.sidecarMode {
+	case calculator.SIDECARS_EXCLUDE:
+		fmt.Println(redTextStyle.Render("Injected sidecars are left out of the workloads, the cost of the service mesh or proxies is not included"))
+	case calculator.SIDECARS_SEPARATE:
+		var sidecarCost, totalCost cluster.Money
+		for _, workload := range report.workloads {
+			sidecarCost += workload.SidecarCost
+			totalCost += workload.Cost
+		}
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("Injected sidecars cost %s of the %s of the workloads", perPeriod(sidecarCost), perPeriod(totalCost))))
+	}
+
+	if options.prorateJobs {
+		var prorated []string
+		for _, workload := range report.workloads {
+			if workload.DutyCycle > 0 {
+				prorated = append(prorated, fmt.Sprintf("%s (%.1f%%)", workload.Name, workload.DutyCycle*100))
+			}
+		}
+		if len(prorated) > 0 {
+			fmt.Println(blueTextStyle.Render(fmt.Sprintf("Pods of Jobs priced for the share of the month they run: %s", strings.Join(prorated, ", "))))
+		}
+	}
+
+	if options.namespaces.SelectsSystem() {
+		var systemCost cluster.Money
+		for _, workload := range report.systemWorkloads {
+			systemCost += workload.Cost
+		}
+
 		fmt.Println()
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("%d workloads of the system namespaces would cost %s, they are run by GKE and not billed in Autopilot", len(report.systemWorkloads), perPeriod(systemCost))))
+		if len(report.systemWorkloads) > 0 {
+			DisplaySystemTable(report.systemWorkloads)
+		}
+	}
 
-		fmt.Println(blueTextStyle.Render(fmt.Sprintf("Nodes that you currently have at your cluster in %s: %d", clusterRegion, len(nodes))))
-		DisplayNodeTable(nodes)
+	if blockers := cluster.GetMigrationBlockers(report.workloads); len(blockers) > 0 {
 		fmt.Println()
+		fmt.Println(redTextStyle.Render(fmt.Sprintf("%d workloads can't run in Autopilot as they are, they need changes before migrating", len(blockers))))
+		for _, blocker := range blockers {
+			fmt.Printf("  %s\n", blocker.Message)
+		}
+	}
 
-		oneYearDiscount, err := cfg.Section("discounts").Key("oneyear_commit").Float64()
-		if err != nil {
-			oneYearDiscount = 1
+	if options.showAdjustments {
+		var adjusted []cluster.Workload
+		for _, workload := range report.workloads {
+			if workload.RequestedCpu != workload.Cpu || workload.RequestedMemory != workload.Memory {
+				adjusted = append(adjusted, workload)
+			}
 		}
-		threeYearDiscount, err := cfg.Section("discounts").Key("threeyear_commit").Float64()
-		if err != nil {
-			threeYearDiscount = 1
+
+		fmt.Println()
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("%d workloads are billed for more than requested after Autopilot adjusts them to their compute class", len(adjusted))))
+		if len(adjusted) > 0 {
+			DisplayAdjustmentTable(adjusted)
 		}
+	}
 
-		fmt.Println(greenTextStyle.Render(fmt.Sprintf("%d workloads from your cluster (%s) mapped to GKE Autopilot mode.", len(workloads), clusterName)))
+	if options.groupByOwner {
 		fmt.Println()
-		fmt.Println(redTextStyle.Render("Displayed values for mCPU, Memory and Storage are a snapshot of this point in time. Those are not requets/limits but currently used values"))
+		fmt.Println(blueTextStyle.Render("Cost per controller, with the replicas of every controller summed"))
+		DisplayOwnerTable(report.owners)
+	}
 
-		cluster_fee, err := cfg.Section("fees").Key("cluster_fee").Float64()
-		if err != nil {
-			cluster_fee = calculator.CLUSTER_FEE
+	if options.hpaMax {
+		increase := cluster.GetMaxScaleIncrease(report.autoscalers)
+
+		fmt.Println()
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("If all %d autoscalers scaled to their maxReplicas: %s per cluster instead of %s, %s more", len(report.autoscalers), perPeriod(comparison.AutopilotCost+increase), perPeriod(comparison.AutopilotCost), perPeriod(increase))))
+		if len(report.autoscalers) > 0 {
+			DisplayAutoscalerTable(report.autoscalers)
 		}
+	}
+
+	if options.groupByLabel != "" {
+		fmt.Println()
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("Cost per value of the %q label", options.groupByLabel)))
+		DisplayLabelTable(options.groupByLabel, cluster.GetLabelCosts(report.nodes, options.groupByLabel), options.amortizeFee)
+	}
+
+	if len(report.samples) > 1 {
+		average, lowest, highest := cluster.SummarizeSamples(report.samples)
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("Over %d samples the workloads cost %s on average (lowest %s, highest %s)", len(report.samples), perPeriod(average), perPeriod(lowest), perPeriod(highest))))
+	}
+
+	if options.amortizeFee {
+		fmt.Println()
+		fmt.Println(blueTextStyle.Render("Cost per namespace with the cluster fee amortized across workloads"))
+		DisplayNamespaceTable(cluster.GetNamespaceCosts(nodes))
+	}
 
-		DisplayWorkloadTable(nodes, oneYearDiscount, threeYearDiscount, cluster_fee)
+	fmt.Println()
+	fmt.Println(blueTextStyle.Render("Current GKE Standard cost compared with GKE Autopilot, including the cluster fee"))
+	DisplayComparisonTable(comparison)
+	if len(comparison.Unpriced) > 0 {
+		fmt.Println(redTextStyle.Render(fmt.Sprintf("Nodes without a machine price are missing from the Standard cost: %s", strings.Join(comparison.Unpriced, ", "))))
 	}
+
+	if options.loadBalancers {
+		fmt.Println()
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("%d load balancers cost %s in both modes, on top of the workloads", len(report.loadBalancers), perPeriod(report.loadBalancerCost))))
+		DisplayLoadBalancerTable(report.loadBalancers)
+	}
+
+	if spotScenario != nil {
+		fmt.Println()
+		fmt.Println(greenTextStyle.Render(fmt.Sprintf("Everything on Spot: %s per cluster, saving up to %s", perPeriod(spotScenario.Cost+report.clusterFee), perPeriod(spotScenario.Savings))))
+		if spotScenario.PreemptionOverhead > 0 {
+			fmt.Println(greenTextStyle.Render(fmt.Sprintf("With %.1f%% of Spot time lost to preemptions: %s per cluster, saving %s", spotScenario.PreemptionOverhead*100, perPeriod(spotScenario.EffectiveCost+report.clusterFee), perPeriod(spotScenario.EffectiveSavings))))
+		}
+		if len(spotScenario.Ineligible) > 0 {
+			fmt.Println(redTextStyle.Render(fmt.Sprintf("Workloads kept at regular price (not eligible for Spot): %s", strings.Join(spotScenario.Ineligible, ", "))))
+		}
+		if len(spotScenario.Workloads) > 0 {
+			fmt.Println(blueTextStyle.Render("Savings per workload moved to Spot, workloads that are stateful, single replicas, without a controller, slow to shut down or protected by a disruption budget are flagged"))
+			DisplaySpotTable(spotScenario.Workloads)
+			if spotScenario.SafeSavings > 0 {
+				fmt.Println(greenTextStyle.Render(fmt.Sprintf("Moving only the spot-safe workloads to Spot saves %s", perPeriod(spotScenario.SafeSavings))))
+			}
+		}
+	}
+
+	if options.recommendClasses {
+		var savings cluster.Money
+		for _, recommendation := range report.recommendations {
+			savings += recommendation.Savings
+		}
+
+		fmt.Println()
+		fmt.Println(greenTextStyle.Render(fmt.Sprintf("%d workloads are cheaper in another eligible compute class, saving %s", len(report.recommendations), perPeriod(savings))))
+		if len(report.recommendations) > 0 {
+			DisplayRecommendationTable(report.recommendations)
+		}
+	}
+
+	if len(report.coverage) > 0 {
+		fmt.Println()
+		fmt.Println(greenTextStyle.Render(fmt.Sprintf("Flexible committed use discounts covering %.0f%% of the eligible spend, including the cluster fee", options.cudCoverage*100)))
+		DisplayCoverageTable(report.coverage, report.clusterFee)
+	}
+
+	if existingCapacity != nil {
+		fmt.Println()
+		fmt.Println(blueTextStyle.Render(fmt.Sprintf("Compute Engine commitments in %s: %d vCPU and %.1f GB memory, %s billed until they end, also after migrating", report.Region, existingCapacity.CommittedCpus, existingCapacity.CommittedMemoryGb, perPeriod(existingCapacity.CommitmentCost))))
+		for _, commitment := range existingCapacity.Commitments {
+			fmt.Printf("  %s\n", commitment)
+		}
+		if len(comparison.UnusedCommitments) > 0 {
+			families := make([]string, 0, len(comparison.UnusedCommitments))
+			for family := range comparison.UnusedCommitments {
+				families = append(families, family)
+			}
+			sort.Strings(families)
+			fmt.Println(redTextStyle.Render("The commitments are larger than the on-demand nodes of the cluster, the rest is billed without being used:"))
+			for _, family := range families {
+				unused := comparison.UnusedCommitments[family]
+				fmt.Printf("  %s: %.1f vCPU and %.1f GB memory\n", family, unused.Cpus, unused.MemoryGb)
+			}
+		}
+		if len(existingCapacity.UnusedReservations) > 0 {
+			fmt.Println(blueTextStyle.Render(fmt.Sprintf("Unused reserved VMs add %s to the current bill", perPeriod(existingCapacity.ReservationCost))))
+			machineTypes := make([]string, 0, len(existingCapacity.UnusedReservations))
+			for machineType := range existingCapacity.UnusedReservations {
+				machineTypes = append(machineTypes, machineType)
+			}
+			sort.Strings(machineTypes)
+			for _, machineType := range machineTypes {
+				fmt.Printf("  %d x %s\n", existingCapacity.UnusedReservations[machineType], machineType)
+			}
+		}
+	}
+}
+
+// writeTimeSeriesCsv writes one row with the timestamp, cluster, number of workloads and total cost per sample.
+func writeTimeSeriesCsv(path string, reports []*clusterReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"timestamp", "cluster", "workloads", "cost"})
+	for _, report := range reports {
+		for _, sample := range report.samples {
+			writer.Write([]string{sample.Timestamp.Format(time.RFC3339), report.Name, strconv.Itoa(sample.Workloads), sample.Cost.String()})
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// parseWindow parses a duration that can also be given in days, eg. "30d" or "12h".
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		count, err := strconv.Atoi(days)
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("invalid window %q, use days like 7d or a duration like 12h", value)
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q, use days like 7d or a duration like 12h", value)
+	}
+
+	return window, nil
+}
+
+// parseCoverage parses a share of spend given in percent, eg. "60%" or "60", into a fraction.
+func parseCoverage(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coverage %q, use a percentage like 60%%", value)
+	}
+	if percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("coverage %q is not between 0%% and 100%%", value)
+	}
+
+	return percent / 100, nil
+}
+
+// workloadOrder is the order of the workload table selected with -sort-by.
+type workloadOrder struct {
+	Field      string
+	Descending bool
+}
+
+// sortFields are the -sort-by fields, with whether they are descending unless :asc is given.
+var sortFields = map[string]bool{
+	"node":      false,
+	"namespace": false,
+	"name":      false,
+	"cost":      true,
+	"mcpu":      true,
+	"memory":    true,
+}
+
+// parseSortBy reads a -sort-by value like cost or name:desc. Costs and resources are
+// sorted descending and names ascending, unless :asc or :desc is given.
+func parseSortBy(value string) (workloadOrder, error) {
+	field, direction, _ := strings.Cut(strings.ToLower(strings.TrimSpace(value)), ":")
+
+	descending, ok := sortFields[field]
+	if !ok {
+		return workloadOrder{}, fmt.Errorf("unsupported sort field %q, use node, namespace, name, cost, mcpu or memory", field)
+	}
+
+	switch direction {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return workloadOrder{}, fmt.Errorf("unsupported sort direction %q, use asc or desc", direction)
+	}
+
+	return workloadOrder{Field: field, Descending: descending}, nil
 }
@@ -15,13 +15,53 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
 	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/pkg/estimator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/cloudbilling/v1"
+	compute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
 	"gopkg.in/ini.v1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
 )
 
 const (
@@ -153,37 +193,96 @@ func TestMain(m *testing.M) {
 	m.Run()
 }
 
-func TestValidateAndRoundResources(t *testing.T) {
+func TestApplyMinimumResources(t *testing.T) {
 	// Test Case #1
 	var cpuWant int64 = 1000
 	var memoryWant int64 = 1000
 	var storageWant int64 = 1000
 
-	cpu, memory, storage := calculator.ValidateAndRoundResources(1000, 1000, 1000)
+	cpu, memory, storage := calculator.ApplyMinimumResources(1000, 1000, 1000)
 	if cpu != cpuWant || memory != memoryWant || storage != storageWant {
-		t.Fatalf(`ValidateAndRoundResources(1000,1000,1000) = %d, %d, %d doesn't match expected %d %d %d`, cpu, memory, storage, cpuWant, memoryWant, storageWant)
+		t.Fatalf(`ApplyMinimumResources(1000,1000,1000) = %d, %d, %d doesn't match expected %d %d %d`, cpu, memory, storage, cpuWant, memoryWant, storageWant)
 	}
 
-	// Test Case #2
-	cpuWant = 250
+	// Test Case #2, mCPU is rounded to the step of the class later
+	cpuWant = 249
 	memoryWant = 52
 	storageWant = 10
 
-	cpu, memory, storage = calculator.ValidateAndRoundResources(249, 49, 9)
+	cpu, memory, storage = calculator.ApplyMinimumResources(249, 49, 9)
 	if cpu != cpuWant || memory != memoryWant || storage != storageWant {
-		t.Fatalf(`ValidateAndRoundResources(249,52,5) = %d, %d, %d doesn't match expected %d %d %d`, cpu, memory, storage, cpuWant, memoryWant, storageWant)
+		t.Fatalf(`ApplyMinimumResources(249,49,9) = %d, %d, %d doesn't match expected %d %d %d`, cpu, memory, storage, cpuWant, memoryWant, storageWant)
 	}
 
 	// Test Case #3
-	cpuWant = 1650
+	cpuWant = 1618
 	memoryWant = 1700
 	storageWant = 900
 
-	cpu, memory, storage = calculator.ValidateAndRoundResources(1618, 1700, 900)
+	cpu, memory, storage = calculator.ApplyMinimumResources(1618, 1700, 900)
 	if cpu != cpuWant || memory != memoryWant || storage != storageWant {
-		t.Fatalf(`ValidateAndRoundResources(1650, 1700, 900) = %d, %d, %d doesn't match expected %d %d %d`, cpu, memory, storage, cpuWant, memoryWant, storageWant)
+		t.Fatalf(`ApplyMinimumResources(1618, 1700, 900) = %d, %d, %d doesn't match expected %d %d %d`, cpu, memory, storage, cpuWant, memoryWant, storageWant)
+	}
+
+}
+
+func TestMinimumResourcesBilled(t *testing.T) {
+	tests := []struct {
+		name                          string
+		cpu, memory, storage          string
+		wantCpu, wantMemory, wantDisk int64
+		// Cost of the billed mCPU and memory
+		cpuCost, memoryCost float64
+	}{
+		// 249 mCPU is billed as 250, the 52 MiB minimum is then raised to the 1:1 ratio of General-purpose
+		{"small", "249m", "49M", "9M", 250, 250, 10, 0.014325, 0.001586},
+		// 1618 mCPU is rounded up to the 50 mCPU step
+		{"medium", "1618m", "1700M", "900M", 1650, 1700, 900, 0.094545, 0.010782},
+	}
+
+	var pods []runtime.Object
+	var usage []metricsv1beta1.PodMetrics
+	for _, test := range tests {
+		pods = append(pods, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: test.name, Namespace: "default"},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse(test.cpu),
+				corev1.ResourceMemory:           resource.MustParse(test.memory),
+				corev1.ResourceEphemeralStorage: resource.MustParse(test.storage),
+			}}}}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		})
+		usage = append(usage, metricsv1beta1.PodMetrics{ObjectMeta: metav1.ObjectMeta{Name: test.name, Namespace: "default"}, Containers: []metricsv1beta1.ContainerMetrics{{Name: "app"}}})
+	}
+
+	client := fake.NewSimpleClientset(pods...)
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.PodMetricsList{Items: usage}, nil
+	})
+	minimumService := service
+	minimumService.SetClientsets(client, metricsClient)
+	workloads, err := minimumService.PopulateWorkloads(context.Background(), map[string]cluster.Node{})
+	if err != nil || len(workloads) != len(tests) {
+		t.Fatalf(`PopulateWorkloads(...) = %+v, %v, expected the small and medium pods`, workloads, err)
 	}
+	cluster.SortWorkloads(workloads)
+
+	for i, test := range []int{1, 0} {
+		workload, want := workloads[i], tests[test]
+		if workload.ComputeClass != cluster.ComputeClassGeneralPurpose || workload.Cpu != want.wantCpu || workload.Memory != want.wantMemory || workload.Storage != want.wantDisk {
+			t.Fatalf(`%s is billed %d mCPU, %d MiB and %d MiB of storage in %s, expected %d, %d and %d in General-purpose`, workload.Name, workload.Cpu, workload.Memory, workload.Storage, cluster.ComputeClasses[workload.ComputeClass], want.wantCpu, want.wantMemory, want.wantDisk)
+		}
+		if workload.CostBreakdown.Cpu != cluster.NewMoney(want.cpuCost) || workload.CostBreakdown.Memory != cluster.NewMoney(want.memoryCost) {
+			t.Fatalf(`%s costs %s of mCPU and %s of memory, expected %v and %v`, workload.Name, workload.CostBreakdown.Cpu, workload.CostBreakdown.Memory, want.cpuCost, want.memoryCost)
+		}
 
+		// The same requests through the minimums and the adjustment of the class
+		cpu, memory, _ := calculator.ApplyMinimumResources(workload.RequestedCpu, workload.RequestedMemory, 0)
+		if cpu, memory = service.AdjustResources(cluster.ComputeClassGeneralPurpose, "", cpu, memory); cpu != want.wantCpu || memory != want.wantMemory {
+			t.Fatalf(`AdjustResources(ApplyMinimumResources(%d, %d)) = %d, %d doesn't match expected %d, %d`, workload.RequestedCpu, workload.RequestedMemory, cpu, memory, want.wantCpu, want.wantMemory)
+		}
+	}
 }
 
 func TestDecideComputeClass(t *testing.T) {
@@ -213,13 +312,46 @@ func TestDecideComputeClass(t *testing.T) {
 
 }
 
+func TestDecideComputeClassLimits(t *testing.T) {
+	// Above the mCPU of General-purpose and the ratio of Scale-out, within the memory of Balanced
+	if computeClass := service.DecideComputeClass("test-pod", "e2-standard-4", 40000, 300000, 0, "", false); computeClass != cluster.ComputeClassBalanced {
+		t.Fatalf(`DecideComputeClass(40000, 300000) = %s doesn't match expected Balanced`, cluster.ComputeClasses[computeClass])
+	}
+
+	// 1:10 is above the ratio of every class, General-purpose adds vCPU to it
+	if computeClass := service.DecideComputeClass("test-pod", "e2-standard-4", 1000, 10000, 0, "", false); computeClass != cluster.ComputeClassGeneralPurpose {
+		t.Fatalf(`DecideComputeClass(1000, 10000) = %s doesn't match expected General-purpose`, cluster.ComputeClasses[computeClass])
+	}
+	// Too large for General-purpose once vCPU is added, Balanced fits
+	if computeClass := service.DecideComputeClass("test-pod", "e2-standard-4", 20000, 300000, 0, "", false); computeClass != cluster.ComputeClassBalanced {
+		t.Fatalf(`DecideComputeClass(20000, 300000) = %s doesn't match expected Balanced`, cluster.ComputeClasses[computeClass])
+	}
+
+	cfg, err := ini.Load(defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Section("classes").Key("order").SetValue("balanced,generalpurpose")
+	limitsService := service
+	limitsService.Config = cfg
+
+	if computeClass := limitsService.DecideComputeClass("test-pod", "e2-standard-4", 1000, 1000, 0, "", false); computeClass != cluster.ComputeClassBalanced {
+		t.Fatalf(`DecideComputeClass(1000, 1000) with balanced first = %s doesn't match expected Balanced`, cluster.ComputeClasses[computeClass])
+	}
+
+	// The class recommendations check the same limits
+	if limitsService.IsEligibleForClass(cluster.ComputeClassBalanced, 1000, 9000) {
+		t.Fatalf(`IsEligibleForClass(Balanced, 1000, 9000) should be false above the 1:8 ratio`)
+	}
+}
+
 func TestCalculatePricing(t *testing.T) {
 
 	// Test Case #1
 
 	computeClass := service.DecideComputeClass("test-pod", "e2-standard-4", 4000, 16000, 0, "", false)
 	priceWant := 0.3313796 // 0.000706 (cpu price * 4) + 0.1014736 (memory price * 16) +0.2292 (storage price * 10)
-	price := service.CalculatePricing(4000, 16000, 10000, 0, "", computeClass, "e2-standard-4", false)
+	price := service.CalculatePricing(calculator.PriceRequest{Cpu: 4000, Memory: 16000, Storage: 10000, Class: computeClass, InstanceType: "e2-standard-4"})
 
 	if !almostEqual(price, priceWant) {
 		t.Fatalf(`CalculatePricing(4000, 16000, 10000, {test-region-pricing}, %s, false) = %.7f doesn't match expected %.7f`, cluster.ComputeClasses[computeClass], price, priceWant)
@@ -228,7 +360,7 @@ func TestCalculatePricing(t *testing.T) {
 	// Test Case #2
 	computeClass = service.DecideComputeClass("test-pod", "e2-standard-4", 40000, 80000, 0, "", false)
 	priceWant = 4.0601700 // 3.324 (cpu price * 40) + 0.735464 (memory price * 80) + 0.2292 (storage price * 10)
-	price = service.CalculatePricing(40000, 80000, 10000, 0, "", computeClass, "e2-standard-4", false)
+	price = service.CalculatePricing(calculator.PriceRequest{Cpu: 40000, Memory: 80000, Storage: 10000, Class: computeClass, InstanceType: "e2-standard-4"})
 
 	if !almostEqual(price, priceWant) {
 		t.Fatalf(`CalculatePricing(4000, 16000, 10000, {test-region-pricing}, %s, false) = %.7f doesn't match expected %.7f`, cluster.ComputeClasses[computeClass], price, priceWant)
@@ -237,7 +369,7 @@ func TestCalculatePricing(t *testing.T) {
 	// Test Case #3
 	computeClass = service.DecideComputeClass("test-pod", "e2-standard-4", 25000, 100000, 0, "", false)
 	priceWant = 0.6209660 // 0.43 (cpu spot price * 25) + 0.19026 (spot memory price * 100) + 0.000706 (spot storage price * 10)
-	price = service.CalculatePricing(25000, 100000, 10000, 0, "", computeClass, "e2-standard-4", true)
+	price = service.CalculatePricing(calculator.PriceRequest{Cpu: 25000, Memory: 100000, Storage: 10000, Class: computeClass, InstanceType: "e2-standard-4", Spot: true})
 
 	if !almostEqual(price, priceWant) {
 		t.Fatalf(`CalculatePricing(4000, 16000, 10000, {test-region-pricing}, %s, false) = %.7f doesn't match expected %.7f`, cluster.ComputeClasses[computeClass], price, priceWant)
@@ -245,6 +377,3359 @@ func TestCalculatePricing(t *testing.T) {
 
 }
 
+func TestCalculateGPUPricing(t *testing.T) {
+	gpuService := service
+	gpuService.AutopilotPricing.NVIDIAL4PodGPUPrice = 0.7
+	gpuService.AutopilotPricing.SpotNVIDIAL4PodGPUPrice = 0.28
+	gpuService.AutopilotPricing.AcceleratorB200GPUPricePremium = 1.5
+
+	computeClass := gpuService.DecideComputeClass("test-pod", "", 4000, 16000, 2, "nvidia-b200", false)
+	if computeClass != cluster.ComputeClassAccelerator {
+		t.Fatalf(`DecideComputeClass(2 x nvidia-b200) = %s doesn't match expected %s`, cluster.ComputeClasses[computeClass], cluster.ComputeClasses[cluster.ComputeClassAccelerator])
+	}
+
+	price := gpuService.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 4000, Memory: 16000, GPU: 2, GPUModel: "nvidia-b200", Class: computeClass})
+	if !almostEqual(price.Accelerator, 3) {
+		t.Fatalf(`CalculatePriceBreakdown(2 x nvidia-b200) = %.7f GPUs doesn't match expected 3.0000000`, price.Accelerator)
+	}
+
+	price = gpuService.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 4000, Memory: 16000, GPU: 1, GPUModel: "nvidia-l4", Class: cluster.ComputeClassGPUPod, Spot: true})
+	if !almostEqual(price.Accelerator, 0.28) {
+		t.Fatalf(`CalculatePriceBreakdown(1 x spot nvidia-l4) = %.7f GPUs doesn't match expected 0.2800000`, price.Accelerator)
+	}
+
+	price = gpuService.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 4000, Memory: 16000, GPU: 1, GPUModel: "nvidia-b200", Class: cluster.ComputeClassGPUPod})
+	if price.Total() != 0 {
+		t.Fatalf(`CalculatePriceBreakdown(1 x nvidia-b200, GPU Pod) = %.7f, GPUs without GPU Pod pricing should not be priced`, price.Total())
+	}
+}
+
+func TestFlexStartPricing(t *testing.T) {
+	price := calculator.PriceBreakdown{Cpu: 1, Memory: 1, Storage: 1, Accelerator: 1}
+
+	// The discount of the [flex_start] section applies to every resource of a GPU workload
+	discounted := service.GetFlexStartPrice("trainer", cluster.ComputeClassGPUPod, price)
+	if !almostEqual(discounted.Cpu, 0.47) || !almostEqual(discounted.Accelerator, 0.47) {
+		t.Fatalf(`GetFlexStartPrice(GPU Pod) = %+v doesn't match expected 0.47 per resource`, discounted)
+	}
+
+	// A resource can have a rate of its own
+	cfg, _ := ini.Load(defaultConfig)
+	cfg.Section("flex_start").Key("accelerator").SetValue("0.4")
+	flexService := service
+	flexService.Config = cfg
+	discounted = flexService.GetFlexStartPrice("trainer", cluster.ComputeClassAccelerator, price)
+	if !almostEqual(discounted.Cpu, 0.47) || !almostEqual(discounted.Accelerator, 0.4) {
+		t.Fatalf(`GetFlexStartPrice(Accelerator) = %+v doesn't match expected 0.47 per resource and 0.4 per GPU`, discounted)
+	}
+
+	// Only GPUs run on flex-start capacity
+	if unchanged := service.GetFlexStartPrice("web", cluster.ComputeClassGeneralPurpose, price); unchanged != price {
+		t.Fatalf(`GetFlexStartPrice(General-purpose) = %+v doesn't match expected %+v`, unchanged, price)
+	}
+
+	if !cluster.IsFlexStart(map[string]string{"cloud.google.com/gke-flex-start": "true"}) || !cluster.IsFlexStart(map[string]string{"cloud.google.com/gke-queued": "true"}) || cluster.IsFlexStart(map[string]string{"cloud.google.com/gke-spot": "true"}) {
+		t.Fatalf(`IsFlexStart(...) doesn't detect the flex-start and queued provisioning selectors`)
+	}
+}
+
+func TestGetGPUShare(t *testing.T) {
+	tests := []struct {
+		selector    map[string]string
+		sharingWant string
+		shareWant   float64
+	}{
+		{map[string]string{"cloud.google.com/gke-accelerator": "nvidia-l4"}, "", 1},
+		{map[string]string{"cloud.google.com/gke-gpu-sharing-strategy": "time-sharing", "cloud.google.com/gke-max-shared-clients-per-gpu": "4"}, "time-sharing", 0.25},
+		{map[string]string{"cloud.google.com/gke-gpu-partition-size": "2g.10gb"}, "mig", 2.0 / 7},
+		{map[string]string{"cloud.google.com/gke-gpu-partition-size": "1g.5gb", "cloud.google.com/gke-gpu-sharing-strategy": "time-sharing", "cloud.google.com/gke-max-shared-clients-per-gpu": "2"}, "mig+time-sharing", 1.0 / 14},
+	}
+
+	for _, test := range tests {
+		sharing, share := cluster.GetGPUShare(test.selector)
+		if sharing != test.sharingWant || !almostEqual(share, test.shareWant) {
+			t.Fatalf(`GetGPUShare(%v) = %s, %.7f doesn't match expected %s, %.7f`, test.selector, sharing, share, test.sharingWant, test.shareWant)
+		}
+	}
+}
+
+func TestCalculateTPUPricing(t *testing.T) {
+	tpuService := service
+	tpuService.AutopilotPricing.AcceleratorCpuPricePremium = 0.01
+	tpuService.AutopilotPricing.AcceleratorMemoryGPUPricePremium = 0.001
+	tpuService.AutopilotPricing.TPUV5ePodChipPrice = 1.2
+	tpuService.AutopilotPricing.SpotTPUV5ePodChipPrice = 0.48
+
+	price := tpuService.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 4000, Memory: 16000, TPU: 4, TPUType: "tpu-v5-lite-podslice", Class: cluster.ComputeClassAccelerator, InstanceType: "ct5lp-hightpu-4t"})
+	if !almostEqual(price.Accelerator, 4.8) || !almostEqual(price.Total(), 4.856) {
+		t.Fatalf(`CalculatePriceBreakdown(4000, 16000, 0, 4 x tpu-v5-lite-podslice) = %.7f (%.7f chips) doesn't match expected 4.8560000 (4.8000000 chips)`, price.Total(), price.Accelerator)
+	}
+
+	price = tpuService.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 4000, Memory: 16000, TPU: 4, TPUType: "tpu-v5-lite-podslice", Class: cluster.ComputeClassAccelerator, InstanceType: "ct5lp-hightpu-4t", Spot: true})
+	if !almostEqual(price.Accelerator, 1.92) {
+		t.Fatalf(`CalculatePriceBreakdown(4 x spot tpu-v5-lite-podslice) = %.7f chips doesn't match expected 1.9200000`, price.Accelerator)
+	}
+
+	price = tpuService.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 4000, Memory: 16000, TPU: 4, TPUType: "tpu-v1", Class: cluster.ComputeClassAccelerator})
+	if price.Accelerator != 0 {
+		t.Fatalf(`CalculatePriceBreakdown(4 x tpu-v1) = %.7f chips, unknown TPUs should not be priced`, price.Accelerator)
+	}
+}
+
 func almostEqual(a, b float64) bool {
 	return math.Abs(a-b) <= float64EqualityThreshold
 }
+
+func TestSortedNodes(t *testing.T) {
+	nodes := map[string]cluster.Node{
+		"node-c": {Name: "node-c"},
+		"node-a": {Name: "node-a"},
+		"node-b": {Name: "node-b"},
+	}
+
+	sorted := cluster.SortedNodes(nodes)
+	for i, want := range []string{"node-a", "node-b", "node-c"} {
+		if sorted[i].Name != want {
+			t.Fatalf(`SortedNodes(...)[%d] = %s doesn't match expected %s`, i, sorted[i].Name, want)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	// Summing many small amounts must not drift
+	var total cluster.Money
+	for i := 0; i < 1000; i++ {
+		total += cluster.NewMoney(0.0001)
+	}
+
+	if total != cluster.NewMoney(0.1) {
+		t.Fatalf(`1000 * NewMoney(0.0001) = %s doesn't match expected 0.1`, total)
+	}
+
+	cluster.DisplayPrecision = 2
+	defer func() { cluster.DisplayPrecision = 4 }()
+
+	if got := cluster.NewMoney(1.23456).String(); got != "1.23" {
+		t.Fatalf(`NewMoney(1.23456).String() = %s doesn't match expected 1.23`, got)
+	}
+
+	// JSON keeps every micro, even without decimal places in the tables
+	cluster.DisplayPrecision = 0
+	contents, err := json.Marshal(cluster.NewMoney(1.234567))
+	if err != nil || string(contents) != "1.234567" {
+		t.Fatalf(`json.Marshal(NewMoney(1.234567)) = %s, %v doesn't match expected 1.234567`, contents, err)
+	}
+	var amount cluster.Money
+	if err := json.Unmarshal(contents, &amount); err != nil || amount != cluster.NewMoney(1.234567) {
+		t.Fatalf(`json.Unmarshal(%s) = %s, %v doesn't match expected 1.234567`, contents, amount, err)
+	}
+}
+
+func TestAmortizeClusterFee(t *testing.T) {
+	nodes := map[string]cluster.Node{
+		"node-a": {Name: "node-a", Workloads: []cluster.Workload{
+			{Name: "a", Namespace: "team-a", Cost: cluster.NewMoney(0.3)},
+			{Name: "b", Namespace: "team-b", Cost: cluster.NewMoney(0.6)},
+		}},
+		"node-b": {Name: "node-b", Workloads: []cluster.Workload{
+			{Name: "c", Namespace: "team-b", Cost: cluster.NewMoney(0.1)},
+		}},
+	}
+
+	calculator.AmortizeClusterFee(nodes, cluster.NewMoney(0.1))
+
+	var total cluster.Money
+	for _, namespace := range cluster.GetNamespaceCosts(nodes) {
+		total += namespace.EffectiveCost
+	}
+	if total != cluster.NewMoney(1.1) {
+		t.Fatalf(`AmortizeClusterFee(...) effective total = %s doesn't match expected 1.1`, total)
+	}
+
+	if got := nodes["node-a"].Workloads[1].EffectiveCost; got != cluster.NewMoney(0.66) {
+		t.Fatalf(`AmortizeClusterFee(...) effective cost of b = %s doesn't match expected 0.66`, got)
+	}
+}
+
+func TestGetCommitDiscount(t *testing.T) {
+	cfg := ini.Empty()
+	cfg.Section("discounts").Key("oneyear_commit").SetValue("0.8")
+	cfg.Section("discounts").Key("oneyear_commit_scaleout").SetValue("0.7")
+	cfg.Section("discounts").Key("oneyear_commit_scaleout_memory").SetValue("0.6")
+	discountService := calculator.PricingService{Config: cfg}
+
+	tests := []struct {
+		class    cluster.ComputeClass
+		resource string
+		want     float64
+	}{
+		{cluster.ComputeClassGeneralPurpose, "cpu", 0.8},
+		{cluster.ComputeClassScaleout, "cpu", 0.7},
+		{cluster.ComputeClassScaleout, "memory", 0.6},
+	}
+
+	for _, test := range tests {
+		discount := discountService.GetCommitDiscount(calculator.CommitOneYear, test.class, test.resource)
+		if !almostEqual(discount, test.want) {
+			t.Fatalf(`GetCommitDiscount(oneyear, %s, %s) = %f doesn't match expected %f`, cluster.ComputeClasses[test.class], test.resource, discount, test.want)
+		}
+	}
+
+	if discount := discountService.GetCommitDiscount(calculator.CommitThreeYear, cluster.ComputeClassBalanced, "cpu"); discount != 1 {
+		t.Fatalf(`GetCommitDiscount(threeyear, Balanced, cpu) = %f doesn't match expected 1`, discount)
+	}
+
+	// Committed use SKUs set the discount of the class unless the class has its own key
+	discountService.AutopilotPricing = calculator.AutopilotPriceList{
+		CpuBalancedPrice:                  0.05,
+		CommitmentOneYearCpuBalancedPrice: 0.036,
+		CpuScaleoutPrice:                  0.04,
+		CommitmentOneYearCpuScaleoutPrice: 0.02,
+	}
+	if discount := discountService.GetCommitDiscount(calculator.CommitOneYear, cluster.ComputeClassBalanced, "cpu"); !almostEqual(discount, 0.72) {
+		t.Fatalf(`GetCommitDiscount(oneyear, Balanced, cpu) = %f doesn't match expected 0.72`, discount)
+	}
+	if discount := discountService.GetCommitDiscount(calculator.CommitOneYear, cluster.ComputeClassBalanced, "memory"); !almostEqual(discount, 0.8) {
+		t.Fatalf(`GetCommitDiscount(oneyear, Balanced, memory) = %f doesn't match expected 0.8`, discount)
+	}
+	if discount := discountService.GetCommitDiscount(calculator.CommitOneYear, cluster.ComputeClassScaleout, "cpu"); !almostEqual(discount, 0.7) {
+		t.Fatalf(`GetCommitDiscount(oneyear, Scale-out, cpu) = %f doesn't match expected 0.7`, discount)
+	}
+}
+
+func TestGetDiskPrice(t *testing.T) {
+	diskService := calculator.PricingService{
+		GCEPricing: calculator.GCEPriceList{
+			HyperdiskBalancedCapacityPrice:   0.08,
+			HyperdiskBalancedIopsPrice:       0.005,
+			HyperdiskBalancedThroughputPrice: 0.04,
+			RegionalPdBalancedCapacityPrice:  0.2,
+		},
+	}
+
+	// 100 GiB with 1000 IOPS and 10 MiB/s above the included baseline
+	disk := cluster.Disk{Type: "hyperdisk-balanced", Size: 100 * 1024, Iops: 4000, Throughput: 150}
+	priceWant := (8 + 5 + 0.4) / calculator.HOURS_PER_MONTH
+	price, ok := diskService.GetDiskPrice(disk)
+
+	if !ok || !almostEqual(price, priceWant) {
+		t.Fatalf(`GetDiskPrice(%+v) = %.7f doesn't match expected %.7f`, disk, price, priceWant)
+	}
+
+	// 50 GiB replicated to a second zone
+	disk = cluster.Disk{Type: "pd-balanced", Size: 50 * 1024, Regional: true}
+	priceWant = 10.0 / calculator.HOURS_PER_MONTH
+	price, ok = diskService.GetDiskPrice(disk)
+
+	if !ok || !almostEqual(price, priceWant) {
+		t.Fatalf(`GetDiskPrice(%+v) = %.7f doesn't match expected %.7f`, disk, price, priceWant)
+	}
+
+	if _, ok := diskService.GetDiskPrice(cluster.Disk{Type: "unknown"}); ok {
+		t.Fatalf(`GetDiskPrice({Type: unknown}) is expected to fail`)
+	}
+}
+
+func TestPodDisks(t *testing.T) {
+	ssd := "premium-rwo"
+	claim := func(name string, storageClass *string, size string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: storageClass},
+			Status:     corev1.PersistentVolumeClaimStatus{Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)}},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: "standard-rwo", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+			Provisioner: "pd.csi.storage.gke.io",
+			Parameters:  map[string]string{"type": "pd-balanced"},
+		},
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: ssd},
+			Provisioner: "pd.csi.storage.gke.io",
+			Parameters:  map[string]string{"type": "pd-ssd"},
+		},
+		claim("data", nil, "100Gi"),
+		claim("cache", &ssd, "10Gi"),
+	)
+
+	volumes, err := cluster.GetVolumes(context.Background(), client)
+	if err != nil {
+		t.Fatalf(`GetVolumes(...) failed: %v`, err)
+	}
+
+	claimVolume := func(name string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name}}}
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "default"}, Spec: corev1.PodSpec{Volumes: []corev1.Volume{claimVolume("data"), claimVolume("cache")}}}
+
+	// Claims without a storage class use the default one
+	disks := volumes.PodDisks(pod)
+	if len(disks) != 2 || disks[0].Type != "pd-balanced" || disks[0].Size != 100*1024 || disks[1].Type != "pd-ssd" || disks[1].Size != 10*1024 {
+		t.Fatalf(`PodDisks(db-0) = %+v doesn't match expected 100 GiB pd-balanced and 10 GiB pd-ssd`, disks)
+	}
+
+	// A disk shared by several pods is billed once
+	shared := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-1", Namespace: "default"}, Spec: pod.Spec}
+	if disks := volumes.PodDisks(shared); len(disks) != 0 {
+		t.Fatalf(`PodDisks(db-1) = %+v, expected the claims of db-0 not to be priced again`, disks)
+	}
+
+	diskService := service
+	diskService.GCEPricing.PdBalancedCapacityPrice = 0.1
+	diskService.GCEPricing.PdSsdCapacityPrice = 0.17
+	// 100 GiB * 0.1 + 10 GiB * 0.17 per month
+	if price := diskService.GetDisksPrice("db-0", disks); !almostEqual(price, 11.7/calculator.HOURS_PER_MONTH) {
+		t.Fatalf(`GetDisksPrice(db-0) = %.7f doesn't match expected %.7f`, price, 11.7/calculator.HOURS_PER_MONTH)
+	}
+}
+
+func TestGetInitContainerPeak(t *testing.T) {
+	requests := func(cpu string, memory string) corev1.ResourceRequirements {
+		return corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	always := corev1.ContainerRestartPolicyAlways
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{
+			{Name: "istio-proxy", Resources: requests("500m", "500M"), RestartPolicy: &always},
+			{Name: "migrate", Resources: requests("2", "1000M")},
+		},
+		Containers: []corev1.Container{{Name: "app", Resources: requests("1", "2000M")}},
+	}
+
+	// The native sidecar keeps running while the migration runs
+	cpu, memory, _ := cluster.GetInitContainerPeak(spec)
+	if cpu != 2500 || memory != 1500 {
+		t.Fatalf(`GetInitContainerPeak(...) with a sidecar = %d, %d doesn't match expected 2500, 1500`, cpu, memory)
+	}
+
+	// Without native sidecars the init containers run one at a time
+	spec.InitContainers[0].RestartPolicy = nil
+	cpu, memory, _ = cluster.GetInitContainerPeak(spec)
+	if cpu != 2000 || memory != 1000 {
+		t.Fatalf(`GetInitContainerPeak(...) = %d, %d doesn't match expected 2000, 1000`, cpu, memory)
+	}
+
+	// Manifests size native sidecars with the containers too
+	template, err := cluster.DecodePodTemplate([]byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  initContainers:
+  - name: proxy
+    restartPolicy: Always
+    resources:
+      requests:
+        cpu: "1"
+        memory: 1G
+  containers:
+  - name: app
+    resources:
+      requests:
+        cpu: "1"
+        memory: 1G
+`))
+	if err != nil {
+		t.Fatalf(`DecodePodTemplate(...) failed: %v`, err)
+	}
+	if workload := service.EstimatePodTemplate(template); workload.Cpu != 2000 || workload.Containers != 2 {
+		t.Fatalf(`EstimatePodTemplate(...) with a native sidecar = %d mCPU for %d containers, expected 2000 for 2`, workload.Cpu, workload.Containers)
+	}
+}
+
+func TestGetExtendedStorage(t *testing.T) {
+	storage, disks := service.GetExtendedStorage("test-pod", cluster.ComputeClassGeneralPurpose, 5000)
+	if storage != 5000 || len(disks) != 0 {
+		t.Fatalf(`GetExtendedStorage(General-purpose, 5000) = %d, %v doesn't match expected 5000 without disks`, storage, disks)
+	}
+
+	// Above the 10 GiB of General-purpose the rest is a generic ephemeral volume
+	storage, disks = service.GetExtendedStorage("test-pod", cluster.ComputeClassGeneralPurpose, 30720)
+	if storage != 10240 || len(disks) != 1 || disks[0].Type != "pd-balanced" || disks[0].Size != 20480 || !disks[0].Ephemeral {
+		t.Fatalf(`GetExtendedStorage(General-purpose, 30720) = %d, %+v doesn't match expected 10240 and a 20480 MiB pd-balanced volume`, storage, disks)
+	}
+
+	// Generic ephemeral volumes don't make a workload stateful
+	if cluster.HasPersistentDisk(disks) {
+		t.Fatalf(`HasPersistentDisk(%+v) should be false for ephemeral disks`, disks)
+	}
+
+	// Performance Pods have no maximum
+	if storage, disks = service.GetExtendedStorage("test-pod", cluster.ComputeClassPerformance, 30720); storage != 30720 || len(disks) != 0 {
+		t.Fatalf(`GetExtendedStorage(Performance, 30720) = %d, %v doesn't match expected 30720 without disks`, storage, disks)
+	}
+}
+
+func TestEstimatePodTemplate(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-deployment
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        resources:
+          requests:
+            cpu: "4"
+            memory: 16G
+          limits:
+            cpu: "8"
+            memory: 16G
+`
+	template, err := cluster.DecodePodTemplate([]byte(manifest))
+	if err != nil {
+		t.Fatalf(`DecodePodTemplate(...) failed: %v`, err)
+	}
+
+	if template.Kind != "Deployment" || template.Name != "test-deployment" || template.Replicas != 3 {
+		t.Fatalf(`DecodePodTemplate(...) = %s %s %d doesn't match expected Deployment test-deployment 3`, template.Kind, template.Name, template.Replicas)
+	}
+
+	workload := service.EstimatePodTemplate(template)
+	priceWant := cluster.NewMoney(0.330674306) // 0.2292 (cpu price * 4) + 0.1014736 (memory price * 16) + 0.000000706 (storage price * 0.01)
+
+	if workload.ComputeClass != cluster.ComputeClassGeneralPurpose || workload.Cost != priceWant {
+		t.Fatalf(`EstimatePodTemplate(...) = %s, %s doesn't match expected %s, %s`, cluster.ComputeClasses[workload.ComputeClass], workload.Cost, cluster.ComputeClasses[cluster.ComputeClassGeneralPurpose], priceWant)
+	}
+
+	// The CPU limit is above the request, the Pod is billed by its requests and can burst
+	if !workload.Burstable {
+		t.Fatalf(`EstimatePodTemplate(...) should be burstable with a CPU limit above the request`)
+	}
+}
+
+func TestWorkloadWarnings(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: trainer
+spec:
+  nodeSelector:
+    cloud.google.com/gke-accelerator: nvidia-unknown
+  containers:
+  - name: app
+    resources:
+      requests:
+        cpu: "4"
+        memory: 16G
+        nvidia.com/gpu: "1"
+`
+	template, err := cluster.DecodePodTemplate([]byte(manifest))
+	if err != nil {
+		t.Fatalf(`DecodePodTemplate(...) failed: %v`, err)
+	}
+
+	// The GPU has no price, the warning is attached to the workload instead of only being logged
+	workload := service.EstimatePodTemplate(template)
+	if len(workload.Warnings) != 1 || workload.Warnings[0].Type != cluster.WarningPricingUnavailable || workload.Warnings[0].Workload != "trainer" {
+		t.Fatalf(`EstimatePodTemplate(...) warnings = %+v, expected a pricing-unavailable warning of trainer`, workload.Warnings)
+	}
+
+	// The warnings of the next workload don't carry over
+	clean := service.EstimatePodTemplate(&cluster.PodTemplate{Name: "web", Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}})
+	if len(clean.Warnings) != 0 {
+		t.Fatalf(`EstimatePodTemplate(web) warnings = %+v, expected none`, clean.Warnings)
+	}
+
+	warnings := cluster.CollectWarnings([]cluster.Workload{workload, clean})
+	if len(warnings) != 1 {
+		t.Fatalf(`CollectWarnings(...) = %+v, expected the warning of trainer`, warnings)
+	}
+	output, err := json.Marshal(warnings)
+	if err != nil || !strings.Contains(string(output), `"Type":"pricing-unavailable","Workload":"trainer"`) {
+		t.Fatalf(`json.Marshal(warnings) = %s, %v doesn't contain the typed warning`, output, err)
+	}
+}
+
+func TestCompletedPods(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, reason string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			Status:     corev1.PodStatus{Phase: phase, Reason: reason},
+		}
+	}
+	podMetrics := &metricsv1beta1.PodMetricsList{}
+	for _, name := range []string{"web", "report", "evicted"} {
+		podMetrics.Items = append(podMetrics.Items, metricsv1beta1.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Containers: []metricsv1beta1.ContainerMetrics{{Name: "app", Usage: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("1G"),
+			}}},
+		})
+	}
+	// The fake metrics clientset doesn't list the PodMetrics it is created with
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, podMetrics, nil
+	})
+	client := fake.NewSimpleClientset(pod("web", corev1.PodRunning, ""), pod("report", corev1.PodSucceeded, ""), pod("evicted", corev1.PodFailed, "Evicted"))
+
+	podService := service
+	podService.SetClientsets(client, metricsClient)
+	workloads, err := podService.PopulateWorkloads(context.Background(), map[string]cluster.Node{})
+	if err != nil {
+		t.Fatalf(`PopulateWorkloads(...) failed: %v`, err)
+	}
+	if len(workloads) != 1 || workloads[0].Name != "web" {
+		t.Fatalf(`PopulateWorkloads(...) = %+v, expected only the running web pod`, workloads)
+	}
+
+	// For audits the completed pods are priced too, and flagged
+	podService.IncludeCompletedPods = true
+	workloads, err = podService.PopulateWorkloads(context.Background(), map[string]cluster.Node{})
+	if err != nil {
+		t.Fatalf(`PopulateWorkloads(...) with completed pods failed: %v`, err)
+	}
+	completed := 0
+	for _, workload := range workloads {
+		for _, warning := range workload.Warnings {
+			if warning.Type == cluster.WarningCompletedPod {
+				completed++
+			}
+		}
+	}
+	if len(workloads) != 3 || completed != 2 {
+		t.Fatalf(`PopulateWorkloads(...) with completed pods = %d workloads, %d flagged, expected 3 and 2`, len(workloads), completed)
+	}
+}
+
+func TestBurstableWorkloads(t *testing.T) {
+	resources := func(request string, limit string) corev1.ResourceRequirements {
+		requirements := corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(request), corev1.ResourceMemory: resource.MustParse("1G")}}
+		if limit != "" {
+			requirements.Limits = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(limit), corev1.ResourceMemory: resource.MustParse("1G")}
+		}
+		return requirements
+	}
+	for _, test := range []struct {
+		limit string
+		want  bool
+	}{{"", false}, {"500m", false}, {"1", true}} {
+		if got := cluster.IsBurstable(corev1.Container{Resources: resources("500m", test.limit)}); got != test.want {
+			t.Fatalf(`IsBurstable(500m request, %q limit) = %t, expected %t`, test.limit, got, test.want)
+		}
+	}
+	if !calculator.CanBurst(cluster.ComputeClassGeneralPurpose) || calculator.CanBurst(cluster.ComputeClassPerformance) {
+		t.Fatalf(`CanBurst(...) should allow General-purpose Pods and not Performance Pods to burst`)
+	}
+
+	// The pod uses more CPU than it requests, with bursting it is billed by its requests
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: resources("500m", "1")}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Containers: []metricsv1beta1.ContainerMetrics{{Name: "app", Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("800m"), corev1.ResourceMemory: resource.MustParse("500M")}}},
+		}}}, nil
+	})
+
+	burstService := service
+	burstService.Bursting = true
+	burstService.SetClientsets(client, metricsClient)
+	workloads, err := burstService.PopulateWorkloads(context.Background(), map[string]cluster.Node{})
+	if err != nil || len(workloads) != 1 {
+		t.Fatalf(`PopulateWorkloads(...) = %d workloads, %v, expected web`, len(workloads), err)
+	}
+	if !workloads[0].Burstable || workloads[0].Cpu != 500 {
+		t.Fatalf(`PopulateWorkloads(...) = burstable %t, %d mCPU, expected a burstable web billed for 500 mCPU`, workloads[0].Burstable, workloads[0].Cpu)
+	}
+}
+
+func TestWindowsWorkloads(t *testing.T) {
+	pod := func(name string, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: node, Containers: []corev1.Container{{Name: "app"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "win", Labels: map[string]string{"cloud.google.com/gke-nodepool": "windows-pool", "kubernetes.io/os": "windows", "node.kubernetes.io/instance-type": "n2-standard-4"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "linux", Labels: map[string]string{"cloud.google.com/gke-nodepool": "default-pool", "kubernetes.io/os": "linux", "node.kubernetes.io/instance-type": "n2-standard-4"}}},
+		pod("iis", "win"),
+		pod("nginx", "linux"),
+	)
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		podMetrics := &metricsv1beta1.PodMetricsList{}
+		for _, name := range []string{"iis", "nginx"} {
+			podMetrics.Items = append(podMetrics.Items, metricsv1beta1.PodMetrics{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Containers: []metricsv1beta1.ContainerMetrics{{Name: "app"}}})
+		}
+		return true, podMetrics, nil
+	})
+
+	nodes, err := cluster.GetClusterNodes(context.Background(), client)
+	if err != nil || !nodes["win"].Windows || nodes["linux"].Windows {
+		t.Fatalf(`GetClusterNodes(...) = %+v, %v, expected only the win node to be a Windows node`, nodes, err)
+	}
+
+	windowsService := service
+	windowsService.SetClientsets(client, metricsClient)
+	workloads, err := windowsService.PopulateWorkloads(context.Background(), nodes)
+	if err != nil {
+		t.Fatalf(`PopulateWorkloads(...) failed: %v`, err)
+	}
+
+	// Autopilot doesn't run Windows Server, the pod is still priced but blocks the migration
+	blockers := cluster.GetMigrationBlockers(workloads)
+	if len(workloads) != 2 || len(blockers) != 1 || blockers[0].Workload != "iis" {
+		t.Fatalf(`GetMigrationBlockers(...) = %+v of %d workloads, expected iis only`, blockers, len(workloads))
+	}
+
+	template := &cluster.PodTemplate{Name: "iis", Spec: corev1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "windows"}, Containers: []corev1.Container{{Name: "app"}}}}
+	if blockers := cluster.GetMigrationBlockers([]cluster.Workload{service.EstimatePodTemplate(template)}); len(blockers) != 1 {
+		t.Fatalf(`EstimatePodTemplate(windows) blockers = %+v, expected a migration blocker`, blockers)
+	}
+}
+
+func TestSandboxOverhead(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: sandboxed
+spec:
+  runtimeClassName: gvisor
+  containers:
+  - name: app
+    resources:
+      requests:
+        cpu: "1"
+        memory: 4G
+`
+	template, err := cluster.DecodePodTemplate([]byte(manifest))
+	if err != nil {
+		t.Fatalf(`DecodePodTemplate(...) failed: %v`, err)
+	}
+
+	// Sandboxed Pods are billed by their requests unless an overhead is configured
+	workload := service.EstimatePodTemplate(template)
+	if !workload.Sandboxed || workload.Cpu != 1000 || workload.Memory != 4000 {
+		t.Fatalf(`EstimatePodTemplate(gvisor) = %t, %d mCPU, %d MiB doesn't match expected true, 1000, 4000`, workload.Sandboxed, workload.Cpu, workload.Memory)
+	}
+
+	cfg, _ := ini.Load(defaultConfig)
+	cfg.Section("sandbox").Key("mcpu_overhead").SetValue("250")
+	cfg.Section("sandbox").Key("memory_overhead").SetValue("500")
+	sandboxService := service
+	sandboxService.Config = cfg
+	if workload := sandboxService.EstimatePodTemplate(template); workload.Cpu != 1250 || workload.Memory != 4500 {
+		t.Fatalf(`EstimatePodTemplate(gvisor) with an overhead = %d mCPU, %d MiB doesn't match expected 1250, 4500`, workload.Cpu, workload.Memory)
+	}
+}
+
+func TestSidecarModes(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-pod
+spec:
+  containers:
+  - name: app
+    resources:
+      requests:
+        cpu: "4"
+        memory: 16G
+  - name: istio-proxy
+    resources:
+      requests:
+        cpu: "1"
+        memory: 1G
+`
+	template, err := cluster.DecodePodTemplate([]byte(manifest))
+	if err != nil {
+		t.Fatalf(`DecodePodTemplate(...) failed: %v`, err)
+	}
+
+	sidecarService := service
+	included := sidecarService.EstimatePodTemplate(template)
+
+	sidecarService.SidecarMode = calculator.SIDECARS_SEPARATE
+	separate := sidecarService.EstimatePodTemplate(template)
+	// 0.0573 (cpu price * 1) + 0.0063421 (memory price * 1)
+	if separate.Cost != included.Cost || separate.SidecarCost != cluster.NewMoney(0.0636421) {
+		t.Fatalf(`EstimatePodTemplate(...) with separate sidecars = %s, %s doesn't match expected %s, 0.0636421`, separate.Cost, separate.SidecarCost, included.Cost)
+	}
+
+	sidecarService.SidecarMode = calculator.SIDECARS_EXCLUDE
+	excluded := sidecarService.EstimatePodTemplate(template)
+	if excluded.Cost != included.Cost-separate.SidecarCost || excluded.Containers != 1 {
+		t.Fatalf(`EstimatePodTemplate(...) without sidecars = %s for %d containers, expected %s for 1`, excluded.Cost, excluded.Containers, included.Cost-separate.SidecarCost)
+	}
+}
+
+func TestSummarizeSamples(t *testing.T) {
+	samples := []cluster.Sample{
+		{Cost: cluster.NewMoney(0.2)},
+		{Cost: cluster.NewMoney(0.1)},
+		{Cost: cluster.NewMoney(0.3)},
+	}
+
+	average, lowest, highest := cluster.SummarizeSamples(samples)
+	if average != cluster.NewMoney(0.2) || lowest != cluster.NewMoney(0.1) || highest != cluster.NewMoney(0.3) {
+		t.Fatalf(`SummarizeSamples = %s, %s, %s doesn't match expected 0.2, 0.1, 0.3`, average, lowest, highest)
+	}
+}
+
+func TestGetMachineFamilyComputeClass(t *testing.T) {
+	spec := corev1.PodSpec{NodeSelector: map[string]string{"cloud.google.com/machine-family": "c3"}}
+
+	computeClass, pinned := service.GetMachineFamilyComputeClass("test-pod", cluster.GetMachineFamily(spec))
+	if !pinned || computeClass != cluster.ComputeClassPerformance {
+		t.Fatalf(`GetMachineFamilyComputeClass(c3) = %s, %t doesn't match expected %s, true`, cluster.ComputeClasses[computeClass], pinned, cluster.ComputeClasses[cluster.ComputeClassPerformance])
+	}
+
+	_, pinned = service.GetMachineFamilyComputeClass("test-pod", cluster.GetMachineFamily(corev1.PodSpec{}))
+	if pinned {
+		t.Fatalf(`GetMachineFamilyComputeClass("") = true doesn't match expected false`)
+	}
+}
+
+func TestGetSelectedComputeClass(t *testing.T) {
+	computeClass := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cloud.google.com/v1",
+		"kind":       "ComputeClass",
+		"metadata":   map[string]interface{}{"name": "cost-optimized"},
+		"spec": map[string]interface{}{"priorities": []interface{}{
+			map[string]interface{}{"machineFamily": "n4", "spot": true},
+			map[string]interface{}{"machineFamily": "n2d", "spot": true},
+			map[string]interface{}{"podFamily": "general-purpose"},
+		}},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{Group: "cloud.google.com", Version: "v1", Resource: "computeclasses"}: "ComputeClassList",
+	}, computeClass)
+
+	classes, err := cluster.ListComputeClasses(context.Background(), client)
+	if err != nil {
+		t.Fatalf("ListComputeClasses() returned error %v", err)
+	}
+
+	x := service
+	x.ComputeClasses = classes
+
+	// n4 has no compute class configured, so the Spot n2d priority is the first that maps to one
+	spec := corev1.PodSpec{NodeSelector: map[string]string{cluster.COMPUTE_CLASS_SELECTOR: "cost-optimized"}}
+	class, priority, pinned := x.GetSelectedComputeClass("test-pod", cluster.GetComputeClassName(spec))
+	if !pinned || !priority.Spot || class != cluster.ComputeClassBalanced {
+		t.Errorf(`GetSelectedComputeClass(cost-optimized) = %s, %t, %t doesn't match expected Balanced, true, true`, cluster.ComputeClasses[class], priority.Spot, pinned)
+	}
+
+	if class, _, pinned := x.GetSelectedComputeClass("test-pod", "Scale-Out"); !pinned || class != cluster.ComputeClassScaleout {
+		t.Errorf(`GetSelectedComputeClass(Scale-Out) = %s, %t doesn't match expected Scale-out, true`, cluster.ComputeClasses[class], pinned)
+	}
+	if _, _, pinned := x.GetSelectedComputeClass("test-pod", "missing"); pinned {
+		t.Errorf(`GetSelectedComputeClass(missing) = true doesn't match expected false`)
+	}
+}
+
+func TestComputeClassGPU(t *testing.T) {
+	gpuService := service
+	gpuService.AutopilotPricing.AcceleratorL4GPUPricePremium = 0.5
+	gpuService.ComputeClasses = map[string]cluster.CustomComputeClass{
+		"inference": {Name: "inference", Priorities: []cluster.ComputeClassPriority{{GPUType: "nvidia-l4", GPUCount: 1}}},
+	}
+	template := func(gpus string) *cluster.PodTemplate {
+		requests := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("4G")}
+		if gpus != "" {
+			requests["nvidia.com/gpu"] = resource.MustParse(gpus)
+		}
+		return &cluster.PodTemplate{Name: "inference", Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{cluster.COMPUTE_CLASS_SELECTOR: "inference"},
+			Containers:   []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{Requests: requests}}},
+		}}
+	}
+
+	tests := []struct {
+		gpus  string
+		count int64
+	}{
+		// The GPU type and count come from the priority of the compute class, without a GPU selector
+		{"", 1},
+		// The GPUs the Pod requests are billed
+		{"2", 2},
+	}
+	for _, test := range tests {
+		workload := gpuService.EstimatePodTemplate(template(test.gpus))
+		if workload.ComputeClass != cluster.ComputeClassAccelerator || workload.AcceleratorType != "nvidia-l4" || workload.AcceleratorAmount != test.count {
+			t.Fatalf(`EstimatePodTemplate(%s GPUs) = %s, %d %s doesn't match expected Accelerator, %d nvidia-l4`, test.gpus, cluster.ComputeClasses[workload.ComputeClass], workload.AcceleratorAmount, workload.AcceleratorType, test.count)
+		}
+		if workload.CostBreakdown.Accelerator != cluster.NewMoney(0.5*float64(test.count)) {
+			t.Fatalf(`EstimatePodTemplate(%s GPUs) costs %s of GPUs, expected %d * 0.5`, test.gpus, workload.CostBreakdown.Accelerator, test.count)
+		}
+		for _, warning := range workload.Warnings {
+			if warning.Type == cluster.WarningPricingUnavailable {
+				t.Fatalf(`EstimatePodTemplate(%s GPUs) warned %q`, test.gpus, warning.Message)
+			}
+		}
+	}
+}
+
+func TestConfidentialWorkloads(t *testing.T) {
+	cfg, _ := ini.Load("config.ini")
+	cfg.Section("sandbox").Key("mcpu_overhead").SetValue("250")
+	cfg.Section("sandbox").Key("memory_overhead").SetValue("256")
+	confidentialService := service
+	confidentialService.Config = cfg
+	confidentialService.GCEPricing.ConfidentialCpuPrice = 0.01
+	confidentialService.GCEPricing.ConfidentialMemoryPrice = 0.001
+
+	gvisor := "gvisor"
+	spec := corev1.PodSpec{
+		NodeSelector:     map[string]string{"cloud.google.com/gke-confidential-nodes": "true"},
+		RuntimeClassName: &gvisor,
+		Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:              resource.MustParse("1000m"),
+			corev1.ResourceMemory:           resource.MustParse("4000M"),
+			corev1.ResourceEphemeralStorage: resource.MustParse("2G"),
+		}}}},
+	}
+
+	// A manifest and a running pod of the same spec are priced alike
+	template := confidentialService.EstimatePodTemplate(&cluster.PodTemplate{Name: "enclave", Namespace: "default", Spec: spec})
+
+	client := fake.NewSimpleClientset(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "enclave", Namespace: "default"}, Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodRunning}})
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{{ObjectMeta: metav1.ObjectMeta{Name: "enclave", Namespace: "default"}, Containers: []metricsv1beta1.ContainerMetrics{{Name: "app"}}}}}, nil
+	})
+	confidentialService.SetClientsets(client, metricsClient)
+	workloads, err := confidentialService.PopulateWorkloads(context.Background(), map[string]cluster.Node{})
+	if err != nil || len(workloads) != 1 {
+		t.Fatalf(`PopulateWorkloads(...) = %+v, %v, expected the enclave pod`, workloads, err)
+	}
+
+	for _, workload := range []cluster.Workload{template, workloads[0]} {
+		// The requests are recorded before the sandbox overhead is added
+		if workload.RequestedCpu != 1000 || workload.RequestedMemory != 4000 || workload.Cpu != 1250 || workload.Memory != 4256 || workload.Storage != 2000 {
+			t.Fatalf(`%s requests %d mCPU and %d MiB, billed %d mCPU, %d MiB and %d MiB of storage, expected 1000, 4000, 1250, 4256 and 2000`, workload.Name, workload.RequestedCpu, workload.RequestedMemory, workload.Cpu, workload.Memory, workload.Storage)
+		}
+		// 1.25 vCPU * (0.0573 + 0.01) and 4.256 GB * (0.0063421 + 0.001)
+		if workload.CostBreakdown.Cpu != cluster.NewMoney(0.084125) || workload.CostBreakdown.Memory != cluster.NewMoney(0.031248) {
+			t.Fatalf(`%s costs %s of mCPU and %s of memory, expected 0.084125 and 0.031248 with the Confidential premium`, workload.Name, workload.CostBreakdown.Cpu, workload.CostBreakdown.Memory)
+		}
+	}
+}
+
+func TestConfidentialPremium(t *testing.T) {
+	confidentialService := service
+	confidentialService.GCEPricing.ConfidentialCpuPrice = 0.01
+	confidentialService.GCEPricing.ConfidentialMemoryPrice = 0.001
+	confidentialService.GCEPricing.SpotConfidentialCpuPrice = 0.004
+	confidentialService.GCEPricing.SpotConfidentialMemoryPrice = 0.0004
+
+	// 2 vCPU * 0.01 + 4 GB * 0.001, and at the Spot premium 2 vCPU * 0.004 + 4 GB * 0.0004
+	if premium := confidentialService.GetConfidentialPremium(2000, 4000, false).Total(); !almostEqual(premium, 0.024) {
+		t.Fatalf(`GetConfidentialPremium(2000, 4000, false) = %f doesn't match expected 0.024`, premium)
+	}
+	if premium := confidentialService.GetConfidentialPremium(2000, 4000, true).Total(); !almostEqual(premium, 0.0096) {
+		t.Fatalf(`GetConfidentialPremium(2000, 4000, true) = %f doesn't match expected 0.0096`, premium)
+	}
+
+	price := calculator.PriceBreakdown{Cpu: 0.1, Memory: 0.02}
+	if got := confidentialService.GetConfidentialPrice("web", cluster.ComputeClassBalanced, 2000, 4000, false, price); !almostEqual(got.Cpu, 0.12) || !almostEqual(got.Memory, 0.024) {
+		t.Fatalf(`GetConfidentialPrice(Balanced, ...) = %+v doesn't match expected 0.12 vCPU and 0.024 memory`, got)
+	}
+	// Confidential GKE Nodes aren't supported for Scale-Out, the price stays
+	if got := confidentialService.GetConfidentialPrice("web", cluster.ComputeClassScaleout, 2000, 4000, false, price); got != price {
+		t.Fatalf(`GetConfidentialPrice(Scale-Out, ...) = %+v doesn't match expected %+v`, got, price)
+	}
+}
+
+func TestPerformanceMachinePrice(t *testing.T) {
+	x := service
+	x.GCEPricing.C2CpuPrice, x.GCEPricing.C2MemoryPrice = 0.03, 0.004
+	x.GCEPricing.C3CpuPrice, x.GCEPricing.C3MemoryPrice = 0.05, 0.006
+
+	// The node is sized to the workload with whole vCPUs, in the family of the current node
+	price := x.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 1500, Memory: 4000, Class: cluster.ComputeClassPerformance, InstanceType: "c2-standard-60"})
+	if math.Abs(price.Machine-(2*0.03+4*0.004)) > 1e-9 {
+		t.Errorf(`Machine price on c2 = %v doesn't match expected %v`, price.Machine, 2*0.03+4*0.004)
+	}
+
+	// Nodes of other families are replaced by the default family of the class
+	price = x.CalculatePriceBreakdown(calculator.PriceRequest{Cpu: 1500, Memory: 4000, Class: cluster.ComputeClassPerformance, InstanceType: "e2-standard-4"})
+	if math.Abs(price.Machine-(2*0.05+4*0.006)) > 1e-9 {
+		t.Errorf(`Machine price on e2 = %v doesn't match expected %v`, price.Machine, 2*0.05+4*0.006)
+	}
+}
+
+func TestLoadSkuMapping(t *testing.T) {
+	path := t.TempDir() + "/sku-mapping.json"
+
+	os.WriteFile(path, []byte(`{"GCE": {"C2D AMD Instance Core running in Sydney": "C2DCpuPrice"}}`), 0644)
+	if _, err := calculator.LoadSkuMapping(path); err != nil {
+		t.Fatalf(`LoadSkuMapping(...) failed: %v`, err)
+	}
+
+	os.WriteFile(path, []byte(`{"GCE": {"C2D AMD Instance Core running in Sydney": "Region"}}`), 0644)
+	if _, err := calculator.LoadSkuMapping(path); err == nil {
+		t.Fatalf(`LoadSkuMapping(...) accepted a mapping to a field that is not a price`)
+	}
+}
+
+func TestReadPodTemplates(t *testing.T) {
+	dir := t.TempDir()
+	manifests := `
+apiVersion: v1
+kind: Service
+metadata:
+  name: test-service
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: test-statefulset
+spec:
+  replicas: 2
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: test-job
+`
+	os.WriteFile(dir+"/app.yaml", []byte(manifests), 0644)
+	os.WriteFile(dir+"/README.md", []byte("# not a manifest"), 0644)
+
+	templates, err := cluster.ReadPodTemplates(dir)
+	if err != nil {
+		t.Fatalf(`ReadPodTemplates(...) failed: %v`, err)
+	}
+
+	if len(templates) != 2 || templates[0].Name != "test-statefulset" || templates[0].Replicas != 2 || templates[1].Kind != "Job" {
+		t.Fatalf(`ReadPodTemplates(...) returned %d templates, expected the StatefulSet and the Job`, len(templates))
+	}
+}
+
+func TestEstimateManifests(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(dir+"/apps/web", 0755)
+	manifest := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"web","namespace":"shop"},"spec":{"replicas":3,"template":{"spec":{"containers":[{"name":"app","resources":{"requests":{"cpu":"1","memory":"1G"}}}]}}}}`
+	os.WriteFile(dir+"/apps/web/deployment.json", []byte(manifest), 0644)
+
+	// Manifests in subdirectories and JSON files are read too
+	templates, err := cluster.ReadPodTemplates(dir)
+	if err != nil || len(templates) != 1 {
+		t.Fatalf(`ReadPodTemplates(...) = %d templates, %v, expected the web Deployment`, len(templates), err)
+	}
+	if templates[0].Namespace != "shop" || templates[0].Replicas != 3 {
+		t.Fatalf(`ReadPodTemplates(...) = %+v doesn't match expected 3 replicas in shop`, templates[0])
+	}
+
+	// Every replica is priced, without a cluster
+	estimate := newPodEstimate(templates[0], service.EstimatePodTemplate(templates[0]))
+	if want := estimate.Workload.Cost.Mul(3); estimate.Workload.Cost == 0 || estimate.HourlyCost != want {
+		t.Fatalf(`newPodEstimate(web).HourlyCost = %s doesn't match expected %s`, estimate.HourlyCost, want)
+	}
+	if want := estimate.Workload.Cost.Mul(3 * calculator.HOURS_PER_MONTH); estimate.MonthlyCost != want {
+		t.Fatalf(`newPodEstimate(web).MonthlyCost = %s doesn't match expected %s`, estimate.MonthlyCost, want)
+	}
+
+	cfg, _ := ini.Load(defaultConfig)
+	if _, err := RunEstimateManifests(cfg, dir, "", false); err == nil {
+		t.Fatalf(`RunEstimateManifests(...) without a region didn't fail`)
+	}
+}
+
+func TestCompareWithStandard(t *testing.T) {
+	standardService := service
+	standardService.GCEPricing.C2CpuPrice = 0.03
+	standardService.GCEPricing.C2MemoryPrice = 0.004
+
+	nodes := map[string]cluster.Node{
+		"node-a": {Name: "node-a", InstanceType: "c2-standard-4", Workloads: []cluster.Workload{{Name: "pod-a", Cost: cluster.NewMoney(0.1)}}},
+	}
+
+	// 0.1 cluster fee + 4 vCPU * 0.03 + 16 GB * 0.004
+	comparison := standardService.CompareWithStandard(nodes, cluster.NewMoney(0.1), cluster.NewMoney(0.1), nil)
+	if comparison.StandardCost != cluster.NewMoney(0.284) || comparison.AutopilotCost != cluster.NewMoney(0.2) || nodes["node-a"].StandardCost != cluster.NewMoney(0.184) {
+		t.Fatalf(`CompareWithStandard(...) = %s, %s doesn't match expected 0.284, 0.2`, comparison.StandardCost, comparison.AutopilotCost)
+	}
+
+	// 2 vCPUs and 4 GB of the node are covered by a C2 commitment, which is billed in both modes:
+	// 0.1 cluster fee + 0.184 node - 2 vCPU * 0.03 - 4 GB * 0.004 + 0.05 commitment
+	existing := &calculator.ExistingCapacity{
+		CommittedCpus:     2,
+		CommittedMemoryGb: 4,
+		Committed:         map[string]calculator.CommittedCapacity{"c2": {Cpus: 2, MemoryGb: 4}},
+		CommitmentCost:    cluster.NewMoney(0.05),
+	}
+	comparison = standardService.CompareWithStandard(nodes, cluster.NewMoney(0.1), cluster.NewMoney(0.1), existing)
+	if comparison.StandardCost != cluster.NewMoney(0.258) || comparison.AutopilotCost != cluster.NewMoney(0.25) || len(comparison.UnusedCommitments) != 0 {
+		t.Fatalf(`CompareWithStandard(...) with commitments = %s, %s, %v doesn't match expected 0.258, 0.25 and no unused commitments`, comparison.StandardCost, comparison.AutopilotCost, comparison.UnusedCommitments)
+	}
+
+	// Commitments of another machine family don't cover the node
+	existing.Committed = map[string]calculator.CommittedCapacity{"n2": {Cpus: 2, MemoryGb: 4}}
+	comparison = standardService.CompareWithStandard(nodes, cluster.NewMoney(0.1), cluster.NewMoney(0.1), existing)
+	if comparison.StandardCost != cluster.NewMoney(0.334) {
+		t.Fatalf(`CompareWithStandard(...) with N2 commitments = %s doesn't match expected 0.334`, comparison.StandardCost)
+	}
+	// No node uses them, so the commitments are larger than the cluster
+	if unused := comparison.UnusedCommitments["n2"]; unused.Cpus != 2 || unused.MemoryGb != 4 {
+		t.Fatalf(`CompareWithStandard(...) with N2 commitments = %v doesn't match expected 2 unused vCPU and 4 GB`, comparison.UnusedCommitments)
+	}
+
+	// Windows Server nodes pay the license per vCPU on top of the machine
+	standardService.GCEPricing.WindowsServerCpuPrice = 0.046
+	nodeCost, ok := standardService.GetStandardNodeCost(cluster.Node{Name: "node-w", InstanceType: "c2-standard-4", Windows: true})
+	if !ok || nodeCost != cluster.NewMoney(0.368) {
+		t.Fatalf(`GetStandardNodeCost(windows c2-standard-4) = %s doesn't match expected 0.368`, nodeCost)
+	}
+}
+
+func TestStandardSavings(t *testing.T) {
+	standardService := service
+	standardService.GCEPricing.C2CpuPrice = 0.03
+	standardService.GCEPricing.C2MemoryPrice = 0.004
+	standardService.GCEPricing.SpotC2CpuPrice = 0.01
+	standardService.GCEPricing.SpotC2MemoryPrice = 0.001
+
+	// The nodes are billed whole, however little of them the workloads use
+	web := cluster.Workload{Name: "web", Cost: cluster.NewMoney(0.1), CostBreakdown: cluster.CostBreakdown{Cpu: cluster.NewMoney(0.09), Disk: cluster.NewMoney(0.01)}}
+	nodes := map[string]cluster.Node{
+		"node-a": {Name: "node-a", InstanceType: "c2-standard-4", Workloads: []cluster.Workload{web}},
+		"node-s": {Name: "node-s", InstanceType: "c2-standard-4", Spot: true},
+		"node-x": {Name: "node-x", InstanceType: "x9-unknown-4"},
+	}
+
+	// 0.1 fee + 0.184 node-a + 4 vCPU * 0.01 + 16 GB * 0.001 for node-s + 0.01 disk of web, which Autopilot bills too
+	comparison := standardService.CompareWithStandard(nodes, cluster.NewMoney(0.1), cluster.NewMoney(0.1), nil)
+	if comparison.StandardCost != cluster.NewMoney(0.35) || comparison.AutopilotCost != cluster.NewMoney(0.2) {
+		t.Fatalf(`CompareWithStandard(...) = %s, %s doesn't match expected 0.35, 0.2`, comparison.StandardCost, comparison.AutopilotCost)
+	}
+	if comparison.Savings != cluster.NewMoney(0.15) || !almostEqual(comparison.SavingsPercent, 0.15/0.35*100) {
+		t.Fatalf(`CompareWithStandard(...) savings = %s, %.2f%% doesn't match expected 0.15, 42.86%%`, comparison.Savings, comparison.SavingsPercent)
+	}
+	if len(comparison.Unpriced) != 1 || comparison.Unpriced[0] != "node-x" || nodes["node-s"].StandardCost != cluster.NewMoney(0.056) {
+		t.Fatalf(`CompareWithStandard(...) unpriced = %v, Spot node = %s, expected [node-x] and 0.056`, comparison.Unpriced, nodes["node-s"].StandardCost)
+	}
+}
+
+func TestGetExistingCapacity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/regions/us-central1/commitments"):
+			fmt.Fprint(w, `{"items":[
+				{"name":"cud-n2","status":"ACTIVE","plan":"TWELVE_MONTH","type":"GENERAL_PURPOSE_N2","endTimestamp":"2027-01-01","resources":[{"type":"VCPU","amount":"4"},{"type":"MEMORY","amount":"16384"}]},
+				{"name":"cud-n1","status":"ACTIVE","plan":"THIRTY_SIX_MONTH","type":"GENERAL_PURPOSE","endTimestamp":"2029-01-01","resources":[{"type":"VCPU","amount":"2"},{"type":"MEMORY","amount":"4096"}]},
+				{"name":"cud-old","status":"EXPIRED","plan":"TWELVE_MONTH","type":"GENERAL_PURPOSE_N2","resources":[{"type":"VCPU","amount":"8"}]}
+			]}`)
+		case strings.HasSuffix(r.URL.Path, "/aggregated/reservations"):
+			fmt.Fprint(w, `{"items":{
+				"zones/us-central1-a":{"reservations":[{"name":"res-a","status":"READY","specificReservation":{"count":"3","inUseCount":"1","instanceProperties":{"machineType":"n1-standard-1"}}}]},
+				"zones/europe-west1-b":{"reservations":[{"name":"res-b","status":"READY","specificReservation":{"count":"5","inUseCount":"0","instanceProperties":{"machineType":"n1-standard-1"}}}]}
+			}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	computeService, err := compute.NewService(context.Background(), option.WithHTTPClient(server.Client()), option.WithEndpoint(server.URL+"/compute/v1/"))
+	if err != nil {
+		t.Fatalf(`compute.NewService(...) failed: %v`, err)
+	}
+
+	capacityService := service
+	capacityService.GCEPricing.CommitmentOneYearCpuPrice = 0.05
+	capacityService.GCEPricing.CommitmentOneYearMemoryPrice = 0.005
+	capacityService.GCEPricing.CommitmentThreeYearCpuPrice = 0.01
+	capacityService.GCEPricing.CommitmentThreeYearMemoryPrice = 0.001
+	capacityService.GCEPricing.CommitmentOneYearN2CpuPrice = 0.02
+	capacityService.GCEPricing.CommitmentOneYearN2MemoryPrice = 0.002
+	capacityService.GCEPricing.N1CpuPrice = 0.03
+	capacityService.GCEPricing.N1MemoryPrice = 0.004
+
+	// The zone of the cluster is priced with the commitments of its region
+	capacity, err := capacityService.ListExistingCapacity(context.Background(), computeService, "my-project", "us-central1-a")
+	if err != nil {
+		t.Fatalf(`ListExistingCapacity(...) failed: %v`, err)
+	}
+
+	// 4 vCPU * 0.02 + 16 GB * 0.002 at the N2 one year price, 2 vCPU * 0.01 + 4 GB * 0.001 at the N1 three year price,
+	// 2 unused n1-standard-1 at 0.045 in the region
+	if len(capacity.Commitments) != 2 || capacity.CommittedCpus != 6 || capacity.Committed["n2"].MemoryGb != 16 || capacity.Committed["n1"].Cpus != 2 || capacity.CommitmentCost != cluster.NewMoney(0.136) {
+		t.Fatalf(`ListExistingCapacity(...) = %+v doesn't match expected commitments of 6 vCPU for 0.136`, capacity)
+	}
+	if capacity.UnusedReservations["n1-standard-1"] != 2 || capacity.ReservationCost != cluster.NewMoney(0.09) {
+		t.Fatalf(`ListExistingCapacity(...) = %v, %s doesn't match expected 2 unused n1-standard-1 for 0.09`, capacity.UnusedReservations, capacity.ReservationCost)
+	}
+}
+
+func TestGetGCEMachinePrice(t *testing.T) {
+	machineService := service
+	machineService.GCEPricing.N1CpuPrice = 0.03
+	machineService.GCEPricing.N1MemoryPrice = 0.004
+	machineService.GCEPricing.E2CpuPrice = 0.02
+	machineService.GCEPricing.E2MemoryPrice = 0.003
+	machineService.GCEPricing.N1CustomCpuPrice = 0.033
+	machineService.GCEPricing.N1CustomMemoryPrice = 0.0045
+	machineService.GCEPricing.N2CustomCpuPrice = 0.034
+	machineService.GCEPricing.N2CustomMemoryPrice = 0.0046
+	machineService.GCEPricing.N2CustomExtendedMemoryPrice = 0.01
+	machineService.GCEPricing.T2ACpuPrice = 0.0308
+	machineService.GCEPricing.T2AMemoryPrice = 0.0039
+	machineService.GCEPricing.C4ACpuPrice = 0.0359
+	machineService.GCEPricing.C4AMemoryPrice = 0.004
+
+	tests := []struct {
+		instanceType string
+		price        float64
+	}{
+		// 4 vCPU * 0.03 + 15 GB * 0.004
+		{"n1-standard-4", 0.18},
+		// Fractional memory isn't rounded up, 1 vCPU * 0.03 + 3.75 GB * 0.004
+		{"n1-standard-1", 0.045},
+		// 2 vCPU * 0.03 + 1.8 GB * 0.004
+		{"n1-highcpu-2", 0.0672},
+		// 8 vCPU * 0.02 + 64 GB * 0.003
+		{"e2-highmem-8", 0.352},
+		// Shared core, 1 vCPU * 0.02 + 4 GB * 0.003
+		{"e2-medium", 0.032},
+		// N1 custom, 4 vCPU * 0.033 + 16 GB * 0.0045
+		{"custom-4-16384", 0.204},
+		// 2 vCPU * 0.034 + 16 GB * 0.0046 + 4 GB extended * 0.01
+		{"n2-custom-2-20480-ext", 0.1816},
+		// Arm, 4 vCPU * 0.0308 + 16 GB * 0.0039
+		{"t2a-standard-4", 0.1856},
+		// 8 vCPU * 0.0359 + 64 GB * 0.004
+		{"c4a-highmem-8", 0.5432},
+	}
+
+	for _, test := range tests {
+		price, _ := machineService.GetGCEMachinePrice(test.instanceType, false)
+		if math.Abs(price-test.price) > 0.000001 {
+			t.Fatalf(`GetGCEMachinePrice(%s) = %f doesn't match expected %f`, test.instanceType, price, test.price)
+		}
+	}
+}
+
+func TestArmMachinePricing(t *testing.T) {
+	armService := service
+	armService.GCEPricing = calculator.GCEPriceList{}
+	skus := []struct {
+		description string
+		usageType   string
+		price       float64
+	}{
+		{"T2A Arm Instance Core running in Americas", "OnDemand", 0.0308},
+		{"T2A Arm Instance Ram running in Americas", "OnDemand", 0.0039},
+		{"Spot Preemptible T2A Arm Instance Core running in Americas", "Preemptible", 0.0092},
+		{"Spot Preemptible T2A Arm Instance Ram running in Americas", "Preemptible", 0.0012},
+		{"C4A Arm Instance Core running in Americas", "OnDemand", 0.0359},
+		{"C4A Arm Instance Ram running in Americas", "OnDemand", 0.004},
+	}
+	for _, test := range skus {
+		armService.GCEPricing.ApplySku(&cloudbilling.Sku{Description: test.description, Category: &cloudbilling.Category{ResourceFamily: "Compute", UsageType: test.usageType}}, test.price)
+	}
+
+	tests := []struct {
+		instanceType string
+		spot         bool
+		price        float64
+	}{
+		// 4 vCPU * 0.0308 + 16 GB * 0.0039
+		{"t2a-standard-4", false, 0.1856},
+		// 4 vCPU * 0.0092 + 16 GB * 0.0012
+		{"t2a-standard-4", true, 0.056},
+		// 8 vCPU * 0.0359 + 64 GB * 0.004
+		{"c4a-highmem-8", false, 0.5432},
+	}
+	for _, test := range tests {
+		price, _ := armService.GetGCEMachinePrice(test.instanceType, test.spot)
+		if !almostEqual(price, test.price) {
+			t.Fatalf(`GetGCEMachinePrice(%s, %t) = %f doesn't match expected %f`, test.instanceType, test.spot, price, test.price)
+		}
+	}
+
+	for machineType, arm := range map[string]bool{"t2a-standard-4": true, "c4a-highmem-8": true, "t2d-standard-4": false, "n2-standard-4": false} {
+		if armService.IsArm64MachineType(machineType) != arm {
+			t.Fatalf(`IsArm64MachineType(%s) doesn't match expected %t`, machineType, arm)
+		}
+	}
+}
+
+func TestWriteHtmlReport(t *testing.T) {
+	workload := cluster.Workload{Name: "pod-<a>", Namespace: "default", Cost: cluster.NewMoney(0.1)}
+	report := &clusterReport{
+		Name:           "test-cluster",
+		pricingService: &service,
+		clusterFee:     cluster.NewMoney(0.1),
+		nodes:          map[string]cluster.Node{"node-a": {Name: "node-a", InstanceType: "e2-standard-4", Workloads: []cluster.Workload{workload}}},
+		workloads:      []cluster.Workload{workload},
+	}
+
+	path := filepath.Join(t.TempDir(), "report.html")
+	if err := writeHtmlReport(path, []*clusterReport{report}); err != nil {
+		t.Fatalf(`writeHtmlReport(...) failed: %v`, err)
+	}
+
+	contents, _ := os.ReadFile(path)
+	for _, expected := range []string{"Cluster test-cluster", "pod-&lt;a&gt;", "0.2000"} {
+		if !strings.Contains(string(contents), expected) {
+			t.Fatalf(`writeHtmlReport(...) doesn't contain %q`, expected)
+		}
+	}
+}
+
+func TestParseProjections(t *testing.T) {
+	selected, err := parseProjections("hour, month,year")
+	if err != nil || len(selected) != 2 || selected[0].Hours != calculator.HOURS_PER_MONTH || selected[1].Hours != calculator.HOURS_PER_YEAR {
+		t.Fatalf(`parseProjections("hour, month,year") = %v, %v doesn't match expected month and year`, selected, err)
+	}
+
+	if _, err := parseProjections("week"); err == nil {
+		t.Fatalf(`parseProjections("week") should fail`)
+	}
+}
+
+func TestTimeBasis(t *testing.T) {
+	basis, err := parseTimeBasis("month")
+	if err != nil || basis.Hours != calculator.HOURS_PER_MONTH {
+		t.Fatalf(`parseTimeBasis("month") = %v, %v doesn't match expected 730 hours`, basis, err)
+	}
+	if _, err := parseTimeBasis("year"); err == nil {
+		t.Fatalf(`parseTimeBasis("year") should fail`)
+	}
+
+	defer func(previousBasis projection, previousProjections []projection) {
+		timeBasis, projections = previousBasis, previousProjections
+	}(timeBasis, projections)
+	timeBasis = basis
+	projections = availableProjections
+
+	if price := inBasis(cluster.NewMoney(0.1)); price != "73.0000" {
+		t.Fatalf(`inBasis(0.1) = %s, expected 73.0000`, price)
+	}
+	if price := perPeriod(cluster.NewMoney(0.1)); !strings.HasPrefix(price, "73.0000 ") || !strings.HasSuffix(price, "/month") {
+		t.Fatalf(`perPeriod(0.1) = %s, expected 73.0000 per month`, price)
+	}
+
+	// The month is the price column, so it isn't projected next to it
+	if columns := projectionColumns("Price"); len(columns) != 2 || strings.Contains(columns[0].Title+columns[1].Title, "Month") {
+		t.Fatalf(`projectionColumns("Price") = %v, expected day and year`, columns)
+	}
+}
+
+func TestGetOwnerCosts(t *testing.T) {
+	controller := true
+	client := fake.NewSimpleClientset(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web-5d4f8",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}},
+		},
+	})
+
+	workloads := []cluster.Workload{
+		{Name: "web-5d4f8-a", Namespace: "default", Owner: cluster.Owner{Kind: "ReplicaSet", Name: "web-5d4f8"}, Cpu: 250, Cost: cluster.NewMoney(0.01)},
+		{Name: "web-5d4f8-b", Namespace: "default", Owner: cluster.Owner{Kind: "ReplicaSet", Name: "web-5d4f8"}, Cpu: 250, Cost: cluster.NewMoney(0.01)},
+		{Name: "debug", Namespace: "default", Cpu: 50, Cost: cluster.NewMoney(0.002)},
+	}
+
+	owners, err := cluster.GetOwnerCosts(context.Background(), client, workloads)
+	if err != nil {
+		t.Fatalf(`GetOwnerCosts(...) failed: %v`, err)
+	}
+
+	if len(owners) != 2 || owners[0].Owner != (cluster.Owner{Kind: "Deployment", Name: "web"}) || owners[0].Replicas != 2 || owners[0].Cpu != 500 || owners[0].Cost != cluster.NewMoney(0.02) || owners[1].Owner.Kind != "Pod" {
+		t.Fatalf(`GetOwnerCosts(...) = %+v doesn't match expected web Deployment with 2 replicas and a bare pod`, owners)
+	}
+}
+
+func TestAnnotateOwner(t *testing.T) {
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{"team": "shop"}},
+	})
+
+	annotations := map[string]string{"cost.gke.io/estimate": "46.46", "cost.gke.io/estimate-updated": "2024-01-01T00:00:00Z"}
+	if err := cluster.AnnotateOwner(context.Background(), client, "default", cluster.Owner{Kind: "Deployment", Name: "web"}, annotations); err != nil {
+		t.Fatalf(`AnnotateOwner(Deployment web) failed: %v`, err)
+	}
+
+	// The estimate is merged with the annotations the Deployment already has
+	deployment, err := client.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf(`Get(web) failed: %v`, err)
+	}
+	if deployment.Annotations["cost.gke.io/estimate"] != "46.46" || deployment.Annotations["cost.gke.io/estimate-updated"] == "" || deployment.Annotations["team"] != "shop" {
+		t.Fatalf(`AnnotateOwner(Deployment web) annotations = %v, expected the estimate next to team=shop`, deployment.Annotations)
+	}
+
+	// Pods without a controller have nothing to annotate
+	if err := cluster.AnnotateOwner(context.Background(), client, "default", cluster.Owner{Kind: "Pod", Name: "debug"}, annotations); err == nil {
+		t.Fatalf(`AnnotateOwner(Pod debug) didn't fail`)
+	}
+}
+
+func TestGetAutoscalerCosts(t *testing.T) {
+	minReplicas := int32(2)
+	client := fake.NewSimpleClientset(
+		&autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "web"},
+				MinReplicas:    &minReplicas,
+				MaxReplicas:    10,
+			},
+		},
+		// Targets without running pods have no replica cost to project
+		&autoscalingv2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: "default"},
+			Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "idle"},
+				MaxReplicas:    5,
+			},
+		},
+	)
+
+	owners := []cluster.OwnerCost{
+		{Namespace: "default", Owner: cluster.Owner{Kind: "Deployment", Name: "web"}, Replicas: 2, Cost: cluster.NewMoney(0.02)},
+		{Namespace: "default", Owner: cluster.Owner{Kind: "Pod", Name: "debug"}, Replicas: 1, Cost: cluster.NewMoney(0.002)},
+	}
+
+	autoscalers, err := cluster.GetAutoscalerCosts(context.Background(), client, owners)
+	if err != nil {
+		t.Fatalf(`GetAutoscalerCosts(...) failed: %v`, err)
+	}
+
+	if len(autoscalers) != 1 || autoscalers[0].Name != "web" || autoscalers[0].MinReplicas != 2 || autoscalers[0].MaxReplicas != 10 || autoscalers[0].MaxCost != cluster.NewMoney(0.1) {
+		t.Fatalf(`GetAutoscalerCosts(...) = %+v doesn't match expected web autoscaler costing 0.1 at 10 replicas`, autoscalers)
+	}
+
+	if increase := cluster.GetMaxScaleIncrease(autoscalers); increase != cluster.NewMoney(0.08) {
+		t.Fatalf(`GetMaxScaleIncrease(...) = %s, expected 0.0800`, increase)
+	}
+}
+
+func TestGetJobRuns(t *testing.T) {
+	schedules := []struct {
+		schedule string
+		runs     float64
+	}{
+		{"@daily", 365.0 / 12},
+		{"*/15 * * * *", 2920},
+		{"TZ=Europe/Berlin 30 2 * * *", 365.0 / 12},
+		// 2023 has 260 weekdays
+		{"0 9 * * mon-fri", 260.0 / 12},
+		{"0 0 1 * *", 1},
+		{"0 0 1 jan,jul *", 2.0 / 12},
+	}
+	for _, test := range schedules {
+		runs, err := cluster.GetScheduleRunsPerMonth(test.schedule)
+		if err != nil || math.Abs(runs-test.runs) > 1e-9 {
+			t.Fatalf(`GetScheduleRunsPerMonth(%q) = %v, %v, expected %v`, test.schedule, runs, err, test.runs)
+		}
+	}
+	if _, err := cluster.GetScheduleRunsPerMonth("61 * * * *"); err == nil {
+		t.Fatalf(`GetScheduleRunsPerMonth("61 * * * *") didn't fail`)
+	}
+
+	now := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	controller := true
+	deadline := int64(3600)
+	cronJobOwner := []metav1.OwnerReference{{Kind: "CronJob", Name: "report", Controller: &controller}}
+	client := fake.NewSimpleClientset(
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+			Spec:       batchv1.CronJobSpec{Schedule: "0 * * * *"},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-1", Namespace: "default", OwnerReferences: cronJobOwner},
+			Status: batchv1.JobStatus{
+				StartTime:      &metav1.Time{Time: now.Add(-time.Hour)},
+				CompletionTime: &metav1.Time{Time: now.Add(-54 * time.Minute)},
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-2", Namespace: "default", OwnerReferences: cronJobOwner},
+			Status:     batchv1.JobStatus{StartTime: &metav1.Time{Time: now.Add(-time.Minute)}},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "default"},
+			Spec:       batchv1.JobSpec{ActiveDeadlineSeconds: &deadline},
+		},
+		// Jobs that run once don't recur every month
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "batch", Namespace: "default"},
+			Status:     batchv1.JobStatus{StartTime: &metav1.Time{Time: now.Add(-time.Hour)}},
+		},
+	)
+
+	runs, err := cluster.GetJobRuns(context.Background(), client, now)
+	if err != nil {
+		t.Fatalf(`GetJobRuns(...) failed: %v`, err)
+	}
+
+	if _, ok := runs["default/migrate"]; len(runs) != 2 || ok || runs["default/report-2"].RunsPerMonth != 730 || math.Abs(runs["default/report-2"].RunHours-0.1) > 1e-9 {
+		t.Fatalf(`GetJobRuns(...) = %+v doesn't match expected hourly report runs of 6 minutes only`, runs)
+	}
+
+	svc := service
+	svc.JobRuns = runs
+	if dutyCycle := svc.GetDutyCycle("report-2-abcde", "default", cluster.Owner{Kind: "Job", Name: "report-2"}); math.Abs(dutyCycle-0.1) > 1e-9 {
+		t.Fatalf(`GetDutyCycle(report-2) = %v, expected 0.1`, dutyCycle)
+	}
+	if dutyCycle := svc.GetDutyCycle("batch-abcde", "default", cluster.Owner{Kind: "Job", Name: "batch"}); dutyCycle != 1 {
+		t.Fatalf(`GetDutyCycle(batch) = %v, expected 1`, dutyCycle)
+	}
+}
+
+func TestGetSpotScenario(t *testing.T) {
+	// A Job pod that runs a tenth of the month, 1 vCPU and 1 GB at 0.0636421 per hour
+	job := cluster.Workload{Name: "report", Cpu: 1000, Memory: 1000, ComputeClass: cluster.ComputeClassGeneralPurpose, DutyCycle: 0.1}
+	job.Cost = cluster.NewMoney(0.0636421).Mul(job.DutyCycle)
+	job.CostBreakdown = cluster.CostBreakdown{Cpu: cluster.NewMoney(0.0573).Mul(job.DutyCycle), Memory: cluster.NewMoney(0.0063421).Mul(job.DutyCycle)}
+
+	// A web server with a disk, egress and a premium on top of its resources, which cost the same on Spot
+	web := cluster.Workload{Name: "web", Cpu: 1000, Memory: 1000, ComputeClass: cluster.ComputeClassGeneralPurpose}
+	web.CostBreakdown = cluster.CostBreakdown{Cpu: cluster.NewMoney(0.0573), Memory: cluster.NewMoney(0.0063421), Disk: cluster.NewMoney(0.01), Network: cluster.NewMoney(0.02)}
+	web.Cost = cluster.NewMoney(0.1036421)
+
+	// Flex-start capacity is never Spot, and its cost is kept with the confidential and other premiums
+	trainer := cluster.Workload{Name: "trainer", Cpu: 1000, Memory: 1000, ComputeClass: cluster.ComputeClassGeneralPurpose, FlexStart: true, Cost: cluster.NewMoney(0.05)}
+
+	nodes := map[string]cluster.Node{"node-1": {InstanceType: "e2-standard-4", Workloads: []cluster.Workload{job, web, trainer}}}
+
+	scenario := service.GetSpotScenario(nodes, 0)
+	if len(scenario.Ineligible) != 1 || scenario.Ineligible[0] != "trainer" {
+		t.Fatalf(`GetSpotScenario(...).Ineligible = %v doesn't match expected [trainer]`, scenario.Ineligible)
+	}
+	if len(scenario.Workloads) != 2 {
+		t.Fatalf(`GetSpotScenario(...).Workloads = %v doesn't match expected 2 workloads`, scenario.Workloads)
+	}
+	spotCosts := map[string]cluster.Money{}
+	for _, workload := range scenario.Workloads {
+		spotCosts[workload.Name] = workload.SpotCost
+	}
+	// The Spot rates are prorated by the duty cycle like the regular ones
+	if got, want := spotCosts["report"], job.Cost-(cluster.NewMoney(0.0636421)-cluster.NewMoney(0.0191026)).Mul(job.DutyCycle); got != want {
+		t.Fatalf(`GetSpotScenario(...) Spot cost of report = %s doesn't match expected %s`, got, want)
+	}
+	if got, want := spotCosts["web"], cluster.NewMoney(0.0591026); got != want {
+		t.Fatalf(`GetSpotScenario(...) Spot cost of web = %s doesn't match expected %s`, got, want)
+	}
+	if scenario.Savings <= 0 {
+		t.Fatalf(`GetSpotScenario(...).Savings = %s doesn't match expected positive savings`, scenario.Savings)
+	}
+}
+
+func TestAllSpotCeiling(t *testing.T) {
+	web := cluster.Workload{Name: "web", Cpu: 1000, Memory: 1000, ComputeClass: cluster.ComputeClassGeneralPurpose, Cost: cluster.NewMoney(0.0636421)}
+	web.CostBreakdown = cluster.CostBreakdown{Cpu: cluster.NewMoney(0.0573), Memory: cluster.NewMoney(0.0063421)}
+	// H3 machines have no Spot VMs
+	solver := cluster.Workload{Name: "solver", Cpu: 80000, Memory: 320000, ComputeClass: cluster.ComputeClassPerformance, Cost: cluster.NewMoney(1)}
+	// Already on Spot, it keeps its cost
+	batch := cluster.Workload{Name: "batch", Cpu: 1000, Memory: 1000, ComputeClass: cluster.ComputeClassGeneralPurpose, Cost: cluster.NewMoney(0.02)}
+
+	nodes := map[string]cluster.Node{
+		"node-1": {Name: "node-1", InstanceType: "e2-standard-4", Workloads: []cluster.Workload{web}},
+		"node-2": {Name: "node-2", InstanceType: "h3-standard-88", Workloads: []cluster.Workload{solver}},
+		"node-3": {Name: "node-3", InstanceType: "e2-standard-4", Spot: true, Workloads: []cluster.Workload{batch}},
+	}
+
+	// 0.0172 (Spot cpu price * 1) + 0.0019026 (Spot memory price * 1)
+	webSpot := cluster.NewMoney(0.0191026)
+	scenario := service.GetSpotScenario(nodes, 0.1)
+	if len(scenario.Ineligible) != 1 || scenario.Ineligible[0] != "solver" {
+		t.Fatalf(`GetSpotScenario(...).Ineligible = %v doesn't match expected [solver]`, scenario.Ineligible)
+	}
+	if want := solver.Cost + batch.Cost + webSpot; scenario.Cost != want {
+		t.Fatalf(`GetSpotScenario(...).Cost = %s doesn't match expected %s`, scenario.Cost, want)
+	}
+	if want := web.Cost - webSpot; scenario.Savings != want || len(scenario.Workloads) != 1 || scenario.Workloads[0].Savings != want {
+		t.Fatalf(`GetSpotScenario(...).Savings = %s for %v doesn't match expected %s of web`, scenario.Savings, scenario.Workloads, want)
+	}
+
+	// The preemption overhead applies to all Spot Pods, but only the moved ones lower the savings
+	if want := solver.Cost + (batch.Cost + webSpot).Mul(1.1); scenario.EffectiveCost != want {
+		t.Fatalf(`GetSpotScenario(...).EffectiveCost = %s doesn't match expected %s`, scenario.EffectiveCost, want)
+	}
+	if want := scenario.Savings - webSpot.Mul(0.1); scenario.EffectiveSavings != want {
+		t.Fatalf(`GetSpotScenario(...).EffectiveSavings = %s doesn't match expected %s`, scenario.EffectiveSavings, want)
+	}
+}
+
+func TestGetSpotBlockers(t *testing.T) {
+	client := fake.NewSimpleClientset(&policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+		Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 0},
+	})
+
+	web := cluster.Owner{Kind: "ReplicaSet", Name: "web-5d4f8"}
+	api := cluster.Owner{Kind: "ReplicaSet", Name: "api-7c9b2"}
+	workloads := []cluster.Workload{
+		{Name: "web-5d4f8-a", Namespace: "default", Owner: web},
+		{Name: "web-5d4f8-b", Namespace: "default", Owner: web},
+		{Name: "api-7c9b2-a", Namespace: "default", Owner: api, Labels: map[string]string{"app": "api"}},
+		{Name: "api-7c9b2-b", Namespace: "default", Owner: api, Labels: map[string]string{"app": "api"}},
+		{Name: "db-0", Namespace: "default", Owner: cluster.Owner{Kind: "StatefulSet", Name: "db"}},
+		{Name: "migrate-x1", Namespace: "default", Owner: cluster.Owner{Kind: "Job", Name: "migrate"}},
+		{Name: "debug", Namespace: "default", GracePeriod: 120},
+	}
+
+	blockers, err := cluster.GetSpotBlockers(context.Background(), client, workloads)
+	if err != nil {
+		t.Fatalf(`GetSpotBlockers(...) failed: %v`, err)
+	}
+
+	if len(blockers["default/web-5d4f8-a"]) != 0 {
+		t.Fatalf(`GetSpotBlockers(...) flagged web-5d4f8-a: %v`, blockers["default/web-5d4f8-a"])
+	}
+	if got := strings.Join(blockers["default/api-7c9b2-a"], ", "); got != "disruption budget api allows no disruptions" {
+		t.Fatalf(`GetSpotBlockers(...) for api-7c9b2-a = %q doesn't match expected disruption budget`, got)
+	}
+	if got := strings.Join(blockers["default/db-0"], ", "); got != "stateful, single replica" {
+		t.Fatalf(`GetSpotBlockers(...) for db-0 = %q doesn't match expected stateful, single replica`, got)
+	}
+	if len(blockers["default/migrate-x1"]) != 0 {
+		t.Fatalf(`GetSpotBlockers(...) flagged the Job pod migrate-x1: %v`, blockers["default/migrate-x1"])
+	}
+	if got := strings.Join(blockers["default/debug"], ", "); got != "no controller, termination grace period of 120s" {
+		t.Fatalf(`GetSpotBlockers(...) for debug = %q doesn't match expected no controller, termination grace period of 120s`, got)
+	}
+
+	scenario := calculator.SpotScenario{Workloads: []calculator.SpotWorkload{
+		{Name: "web-5d4f8-a", Namespace: "default", Savings: cluster.NewMoney(0.1)},
+		{Name: "db-0", Namespace: "default", Savings: cluster.NewMoney(0.2)},
+	}}
+	scenario.SetSpotBlockers(blockers)
+	if scenario.SafeSavings != cluster.NewMoney(0.1) {
+		t.Fatalf(`SetSpotBlockers(...) SafeSavings = %s, expected 0.1`, scenario.SafeSavings)
+	}
+}
+
+func TestSpotEligibility(t *testing.T) {
+	gracePeriod := int64(120)
+	pod := func(name string, owner string, gracePeriod *int64) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{TerminationGracePeriodSeconds: gracePeriod, Containers: []corev1.Container{{Name: "app"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if owner != "" {
+			controller := true
+			pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner, Controller: &controller}}
+		}
+		return pod
+	}
+	client := fake.NewSimpleClientset(pod("web-a", "web", nil), pod("web-b", "web", nil), pod("worker-a", "worker", &gracePeriod), pod("worker-b", "worker", &gracePeriod), pod("debug", "", nil))
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		podMetrics := &metricsv1beta1.PodMetricsList{}
+		for _, name := range []string{"web-a", "web-b", "worker-a", "worker-b", "debug"} {
+			podMetrics.Items = append(podMetrics.Items, metricsv1beta1.PodMetrics{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Containers: []metricsv1beta1.ContainerMetrics{{Name: "app"}}})
+		}
+		return true, podMetrics, nil
+	})
+
+	spotService := service
+	spotService.SetClientsets(client, metricsClient)
+	workloads, err := spotService.PopulateWorkloads(context.Background(), map[string]cluster.Node{})
+	if err != nil {
+		t.Fatalf(`PopulateWorkloads(...) failed: %v`, err)
+	}
+
+	// The controller and the grace period of the pods decide whether they are safe to preempt
+	blockers, err := cluster.GetSpotBlockers(context.Background(), client, workloads)
+	if err != nil {
+		t.Fatalf(`GetSpotBlockers(...) failed: %v`, err)
+	}
+	expected := map[string]string{
+		"web-a":    "",
+		"web-b":    "",
+		"worker-a": "termination grace period of 120s",
+		"worker-b": "termination grace period of 120s",
+		"debug":    "no controller",
+	}
+	for name, reasons := range expected {
+		if got := strings.Join(blockers["default/"+name], ", "); got != reasons {
+			t.Fatalf(`GetSpotBlockers(...) for %s = %q doesn't match expected %q`, name, got, reasons)
+		}
+	}
+}
+
+func TestGetLabelCosts(t *testing.T) {
+	nodes := map[string]cluster.Node{
+		"node-a": {Name: "node-a", Workloads: []cluster.Workload{
+			{Name: "pod-a", Labels: map[string]string{"team": "payments"}, Cost: cluster.NewMoney(0.1)},
+			{Name: "pod-b", Labels: map[string]string{"team": "payments"}, Cost: cluster.NewMoney(0.2)},
+			{Name: "pod-c", Cost: cluster.NewMoney(0.05)},
+		}},
+	}
+
+	values := cluster.GetLabelCosts(nodes, "team")
+	if len(values) != 2 || values[0].Value != cluster.UNLABELED || values[1].Value != "payments" || values[1].Workloads != 2 || values[1].Cost != cluster.NewMoney(0.3) {
+		t.Fatalf(`GetLabelCosts(nodes, "team") = %+v doesn't match expected unlabeled and payments`, values)
+	}
+}
+
+func TestGetEgressPrice(t *testing.T) {
+	egressConfig := ini.Empty()
+	egressConfig.Section("egress").Key("internet_gb_month").SetValue("100")
+	egressConfig.Section("egress").Key("internet_price").SetValue("0.12")
+
+	egressService := service
+	egressService.Config = egressConfig
+	egressService.GCEPricing.InterZoneEgressPrice = 0.01
+
+	// 100 GB * 0.12 from the config, the annotation adds 500 GB * 0.01 between zones
+	price := egressService.GetEgressPrice("pod-a", map[string]string{calculator.INTER_ZONE_EGRESS_ANNOTATION: "500"})
+	priceWant := (12 + 5.0) / calculator.HOURS_PER_MONTH
+	if !almostEqual(price, priceWant) {
+		t.Fatalf(`GetEgressPrice(...) = %.7f doesn't match expected %.7f`, price, priceWant)
+	}
+}
+
+func TestPriceLoadBalancers(t *testing.T) {
+	lbService := service
+	lbService.Config = ini.Empty()
+	lbService.GCEPricing.ForwardingRuleMinimumPrice = 0.025
+	lbService.GCEPricing.ForwardingRuleAdditionalPrice = 0.01
+
+	loadBalancers := make([]cluster.LoadBalancer, 6)
+
+	// The minimum charge covers 5 rules, the 6th is billed on top
+	priced, total := lbService.PriceLoadBalancers(loadBalancers)
+	if total != cluster.NewMoney(0.035) || priced[0].Cost != cluster.NewMoney(0.035/6) {
+		t.Fatalf(`PriceLoadBalancers(6 load balancers) = %s doesn't match expected 0.035`, total)
+	}
+}
+
+func TestGetClusterFees(t *testing.T) {
+	// The credit covers a zonal Standard cluster and the Autopilot cluster
+	standardFee, autopilotFee := calculator.GetClusterFees(0.1, "us-central1-a", true)
+	if standardFee != 0 || autopilotFee != 0 {
+		t.Fatalf(`GetClusterFees(0.1, "us-central1-a", true) = %s, %s doesn't match expected 0, 0`, standardFee, autopilotFee)
+	}
+
+	// Regional Standard clusters don't qualify
+	standardFee, autopilotFee = calculator.GetClusterFees(0.1, "us-central1", true)
+	if standardFee != cluster.NewMoney(0.1) || autopilotFee != 0 {
+		t.Fatalf(`GetClusterFees(0.1, "us-central1", true) = %s, %s doesn't match expected 0.1, 0`, standardFee, autopilotFee)
+	}
+}
+
+func TestGetCoverageScenario(t *testing.T) {
+	cfg := ini.Empty()
+	cfg.Section("discounts").Key("oneyear_flex_commit").SetValue("0.72")
+	coverageService := calculator.PricingService{Config: cfg}
+
+	nodes := map[string]cluster.Node{
+		"on-demand": {Workloads: []cluster.Workload{
+			{Name: "web", Cost: cluster.NewMoney(1.1), CostBreakdown: cluster.CostBreakdown{Cpu: cluster.NewMoney(0.6), Memory: cluster.NewMoney(0.4), Disk: cluster.NewMoney(0.1)}},
+		}},
+		"spot": {Spot: true, Workloads: []cluster.Workload{
+			{Name: "batch", Cost: cluster.NewMoney(0.5), CostBreakdown: cluster.CostBreakdown{Cpu: cluster.NewMoney(0.5)}},
+		}},
+	}
+
+	scenario := coverageService.GetCoverageScenario(nodes, calculator.CommitOneYear, 0.6)
+	// 60% of the eligible 1.0 is committed at 0.72, the rest of the 1.6 stays on-demand
+	if got := scenario.CommittedCost; got != cluster.NewMoney(0.432) {
+		t.Fatalf(`GetCoverageScenario(...).CommittedCost = %s doesn't match expected 0.432`, got)
+	}
+	if got := scenario.OnDemandCost; got != cluster.NewMoney(1) {
+		t.Fatalf(`GetCoverageScenario(...).OnDemandCost = %s doesn't match expected 1`, got)
+	}
+	if got := scenario.Savings; got != cluster.NewMoney(0.168) {
+		t.Fatalf(`GetCoverageScenario(...).Savings = %s doesn't match expected 0.168`, got)
+	}
+
+	// Without a discount in the config nothing is saved
+	if scenario := coverageService.GetCoverageScenario(nodes, calculator.CommitThreeYear, 0.6); scenario.Savings != 0 {
+		t.Fatalf(`GetCoverageScenario(threeyear, ...).Savings = %s doesn't match expected 0`, scenario.Savings)
+	}
+}
+
+func TestParseCoverage(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"", 0},
+		{"60%", 0.6},
+		{"35", 0.35},
+		{"100%", 1},
+	}
+
+	for _, test := range tests {
+		coverage, err := parseCoverage(test.value)
+		if err != nil || !almostEqual(coverage, test.want) {
+			t.Fatalf(`parseCoverage(%q) = %f, %v doesn't match expected %f`, test.value, coverage, err, test.want)
+		}
+	}
+
+	for _, value := range []string{"half", "120%", "-5"} {
+		if _, err := parseCoverage(value); err == nil {
+			t.Fatalf(`parseCoverage(%q) should fail`, value)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3, 10, 6, 9, 7, 8}
+
+	tests := []struct {
+		percentile float64
+		want       float64
+	}{
+		{50, 5},
+		{95, 10},
+		{90, 9},
+		{100, 10},
+		{1, 1},
+	}
+
+	for _, test := range tests {
+		if got := cluster.Percentile(values, test.percentile); got != test.want {
+			t.Fatalf(`Percentile(values, %.0f) = %f doesn't match expected %f`, test.percentile, got, test.want)
+		}
+	}
+
+	if got := cluster.Percentile(nil, 95); got != 0 {
+		t.Fatalf(`Percentile(nil, 95) = %f doesn't match expected 0`, got)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"7d", 7 * 24 * time.Hour},
+		{"30d", 30 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+	}
+
+	for _, test := range tests {
+		window, err := parseWindow(test.value)
+		if err != nil || window != test.want {
+			t.Fatalf(`parseWindow(%q) = %s, %v doesn't match expected %s`, test.value, window, err, test.want)
+		}
+	}
+
+	for _, value := range []string{"week", "0d", "-2h"} {
+		if _, err := parseWindow(value); err == nil {
+			t.Fatalf(`parseWindow(%q) should fail`, value)
+		}
+	}
+}
+
+func TestGetPrometheusUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := "0.25"
+		if strings.Contains(r.FormValue("query"), "container_memory_working_set_bytes") {
+			value = "268435456"
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"namespace":"default","pod":"web-a","container":"app"},"value":[1700000000,%q]}]}}`, value)
+	}))
+	defer server.Close()
+
+	history, err := cluster.GetPrometheusUsage(context.Background(), server.URL, "prod", 7*24*time.Hour, 95)
+	if err != nil {
+		t.Fatalf(`GetPrometheusUsage(...) failed: %v`, err)
+	}
+
+	if got := history[cluster.UsageKey("default", "web-a", "app")]; got.Cpu != 250 || got.Memory != 269 {
+		t.Fatalf(`GetPrometheusUsage(...) = %+v doesn't match expected 250 mCPU and 269 MiB`, got)
+	}
+}
+
+func TestGetPrometheusUsageCancelled(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	// Ctrl-C cancels the context before the queries are sent
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := cluster.GetPrometheusUsage(ctx, server.URL, "prod", 7*24*time.Hour, 95); err == nil {
+		t.Fatalf(`GetPrometheusUsage(...) with a cancelled context succeeded`)
+	}
+	if requests != 0 {
+		t.Fatalf(`GetPrometheusUsage(...) with a cancelled context sent %d requests`, requests)
+	}
+}
+
+func TestSizeResource(t *testing.T) {
+	tests := []struct {
+		mode string
+		want int64
+	}{
+		{calculator.SIZING_REQUESTS, 500},
+		{calculator.SIZING_USAGE, 120},
+		{calculator.SIZING_MAX, 500},
+		{"", 500},
+	}
+
+	for _, test := range tests {
+		sizingService := calculator.PricingService{SizingMode: test.mode}
+		if got := sizingService.SizeResource(120, 500); got != test.want {
+			t.Fatalf(`SizeResource(120, 500) in %q mode = %d doesn't match expected %d`, test.mode, got, test.want)
+		}
+	}
+
+	maxService := calculator.PricingService{SizingMode: calculator.SIZING_MAX}
+	if got := maxService.SizeResource(800, 500); got != 800 {
+		t.Fatalf(`SizeResource(800, 500) in max mode = %d doesn't match expected 800`, got)
+	}
+}
+
+func TestGetClassRecommendations(t *testing.T) {
+	nodes := map[string]cluster.Node{
+		"node-a": {Name: "node-a", InstanceType: "e2-standard-4", Workloads: []cluster.Workload{
+			{Name: "pinned", Namespace: "default", Cpu: 1000, Memory: 4000, Storage: 10, ComputeClass: cluster.ComputeClassBalanced},
+			{Name: "web", Namespace: "default", Cpu: 1000, Memory: 1000, Storage: 10, ComputeClass: cluster.ComputeClassGeneralPurpose},
+		}},
+	}
+
+	recommendations := service.GetClassRecommendations(nodes)
+	if len(recommendations) != 1 || recommendations[0].Name != "pinned" || recommendations[0].Cheapest != cluster.ComputeClassGeneralPurpose {
+		t.Fatalf(`GetClassRecommendations(...) = %+v doesn't match expected General-purpose for pinned`, recommendations)
+	}
+
+	// Balanced 0.0831 + 4 * 0.0091933 against General-purpose 0.0573 + 4 * 0.0063421, plus the same storage
+	if got := recommendations[0].Savings; got != cluster.NewMoney(0.0372048) {
+		t.Fatalf(`GetClassRecommendations(...) savings = %s doesn't match expected 0.0372048`, got)
+	}
+
+	if service.IsEligibleForClass(cluster.ComputeClassScaleout, 1000, 1000) {
+		t.Fatalf(`IsEligibleForClass(Scale-out, 1000, 1000) should be false for a 1:1 ratio`)
+	}
+}
+
+func TestScaleoutMinimumMemory(t *testing.T) {
+	template := &cluster.PodTemplate{Name: "worker", Spec: corev1.PodSpec{
+		NodeSelector: map[string]string{"cloud.google.com/compute-class": "Scale-Out"},
+		Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("250m"),
+			corev1.ResourceMemory: resource.MustParse("500M"),
+		}}}},
+	}}
+
+	// The Pod is billed for the 1 GiB minimum of Scale-Out, without another mCPU step for the ratio
+	workload := service.EstimatePodTemplate(template)
+	if workload.ComputeClass != cluster.ComputeClassScaleout || workload.Cpu != 250 || workload.Memory != 1024 {
+		t.Fatalf(`EstimatePodTemplate(...) = %s, %d mCPU, %d MiB doesn't match expected Scale-Out, 250 mCPU and 1024 MiB`, cluster.ComputeClasses[workload.ComputeClass], workload.Cpu, workload.Memory)
+	}
+	// 0.25 vCPU * 0.0722 + 1.024 GB * 0.0079911
+	if workload.CostBreakdown.Cpu+workload.CostBreakdown.Memory != cluster.NewMoney(0.026233) {
+		t.Fatalf(`EstimatePodTemplate(...) costs %s of mCPU and %s of memory, expected 0.026233 in total`, workload.CostBreakdown.Cpu, workload.CostBreakdown.Memory)
+	}
+}
+
+func TestRatioCorrection(t *testing.T) {
+	template := func(cpu string, memory string, gpuModel string) *cluster.PodTemplate {
+		requests := corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse(memory),
+		}
+		selector := map[string]string{}
+		if gpuModel != "" {
+			requests["nvidia.com/gpu"] = resource.MustParse("1")
+			selector["cloud.google.com/gke-accelerator"] = gpuModel
+		}
+		return &cluster.PodTemplate{Name: "app", Spec: corev1.PodSpec{NodeSelector: selector, Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{Requests: requests}}}}}
+	}
+
+	tests := []struct {
+		template   *cluster.PodTemplate
+		class      cluster.ComputeClass
+		wantCpu    int64
+		wantMemory int64
+	}{
+		// 1:10 is above the 6.5 of General-purpose, vCPU is added and rounded to the 50 mCPU step
+		{template("1000m", "10000M", ""), cluster.ComputeClassGeneralPurpose, 1550, 10000},
+		// 1:0.5 is below the 1 of General-purpose, memory is added
+		{template("4000m", "2000M", ""), cluster.ComputeClassGeneralPurpose, 4000, 4000},
+		// GPU Pods are raised to the minimums of their GPU model
+		{template("500m", "1000M", "nvidia-l4"), cluster.ComputeClassGPUPod, 2000, 7000},
+	}
+
+	for _, test := range tests {
+		workload := service.EstimatePodTemplate(test.template)
+		if workload.ComputeClass != test.class || workload.Cpu != test.wantCpu || workload.Memory != test.wantMemory {
+			t.Fatalf(`EstimatePodTemplate(...) = %s, %d mCPU, %d MiB doesn't match expected %s, %d mCPU and %d MiB`, cluster.ComputeClasses[workload.ComputeClass], workload.Cpu, workload.Memory, cluster.ComputeClasses[test.class], test.wantCpu, test.wantMemory)
+		}
+
+		// The requests are corrected as Autopilot does, they are not out of range
+		for _, warning := range workload.Warnings {
+			if warning.Type == cluster.WarningOutOfRange || warning.Type == cluster.WarningNoComputeClass {
+				t.Fatalf(`EstimatePodTemplate(...) warned %q for requests that are corrected`, warning.Message)
+			}
+		}
+	}
+}
+
+func TestAdjustResources(t *testing.T) {
+	tests := []struct {
+		class      cluster.ComputeClass
+		cpu        int64
+		memory     int64
+		wantCpu    int64
+		wantMemory int64
+	}{
+		// Below the minimums
+		{cluster.ComputeClassGeneralPurpose, 10, 20, 50, 52},
+		{cluster.ComputeClassBalanced, 100, 300, 250, 512},
+		// Rounded up to the mCPU step, then memory raised to the 1:1 ratio
+		{cluster.ComputeClassBalanced, 600, 600, 750, 750},
+		// Scale-out has a fixed 1:4 ratio
+		{cluster.ComputeClassScaleout, 1000, 1000, 1000, 4000},
+		{cluster.ComputeClassScaleout, 1000, 8000, 2000, 8000},
+		// The 1 GiB minimum of Scale-out is applied after the ratio, without another mCPU step
+		{cluster.ComputeClassScaleout, 250, 500, 250, 1024},
+		// More memory than 6.5 per vCPU adds vCPU
+		{cluster.ComputeClassGeneralPurpose, 1000, 13000, 2000, 13000},
+		// Performance Pods are billed by the node
+		{cluster.ComputeClassPerformance, 10, 20, 10, 20},
+		// Rounded to the mCPU step of the class only
+		{cluster.ComputeClassGeneralPurpose, 1618, 1700, 1650, 1700},
+		{cluster.ComputeClassPerformance, 1618, 1700, 1618, 1700},
+	}
+
+	for _, test := range tests {
+		cpu, memory := service.AdjustResources(test.class, "", test.cpu, test.memory)
+		if cpu != test.wantCpu || memory != test.wantMemory {
+			t.Fatalf(`AdjustResources(%s, %d, %d) = %d, %d doesn't match expected %d, %d`, cluster.ComputeClasses[test.class], test.cpu, test.memory, cpu, memory, test.wantCpu, test.wantMemory)
+		}
+	}
+
+	// GPU Pods are raised to the minimums of their GPU model
+	if cpu, memory := service.AdjustResources(cluster.ComputeClassGPUPod, "nvidia-l4", 500, 1000); cpu != 2000 || memory != 7000 {
+		t.Fatalf(`AdjustResources(GPU Pod, nvidia-l4, 500, 1000) = %d, %d doesn't match expected 2000, 7000`, cpu, memory)
+	}
+
+	cfg, err := ini.Load(defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Section("limits").Key("performance_mcpu_step").SetValue("1000")
+	stepService := service
+	stepService.Config = cfg
+	if cpu, _ := stepService.AdjustResources(cluster.ComputeClassPerformance, "", 1618, 1700); cpu != 2000 {
+		t.Fatalf(`AdjustResources(Performance, 1618, 1700) with a 1000 mCPU step = %d doesn't match expected 2000`, cpu)
+	}
+
+	// The minimums of the classes are read from [limits] too
+	cfg.Section("limits").Key("balanced_mcpu_min").SetValue("500")
+	cfg.Section("limits").Key("scaleout_memory_min").SetValue("2048")
+	if cpu, memory := stepService.AdjustResources(cluster.ComputeClassBalanced, "", 100, 300); cpu != 500 || memory != 512 {
+		t.Fatalf(`AdjustResources(Balanced, 100, 300) with a 500 mCPU minimum = %d, %d doesn't match expected 500, 512`, cpu, memory)
+	}
+	if cpu, memory := stepService.AdjustResources(cluster.ComputeClassScaleout, "", 250, 500); cpu != 250 || memory != 2048 {
+		t.Fatalf(`AdjustResources(Scale-out, 250, 500) with a 2 GiB minimum = %d, %d doesn't match expected 250, 2048`, cpu, memory)
+	}
+}
+
+func TestInClusterContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		values := map[string]string{
+			"/computeMetadata/v1/project/project-id":                   "project",
+			"/computeMetadata/v1/instance/attributes/cluster-location": "us-central1",
+			"/computeMetadata/v1/instance/attributes/cluster-name":     "prod",
+		}
+		value, ok := values[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Metadata-Flavor", "Google")
+		fmt.Fprint(w, value)
+	}))
+	defer server.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	// The cluster is read from the metadata server of the node instead of a kubeconfig context
+	clusterContext, err := cluster.GetInClusterContext()
+	if err != nil || strings.Join(clusterContext, "_") != "gke_project_us-central1_prod" {
+		t.Fatalf(`GetInClusterContext() = %v, %v doesn't match expected gke, project, us-central1, prod`, clusterContext, err)
+	}
+
+	// Without a kubeconfig file a pod falls back to the credentials of its service account
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("KUBECONFIG", "")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	if _, path, err := cluster.GetKubeConfig(); err == nil || path != "" || !strings.Contains(err.Error(), "in-cluster") {
+		t.Fatalf(`GetKubeConfig() = %q, %v, expected the in-cluster config without a service account token`, path, err)
+	}
+}
+
+func TestAddKubectlFlags(t *testing.T) {
+	defer func() { cluster.KubeConfigPath, cluster.KubeContext = "", "" }()
+
+	flags := flag.NewFlagSet("kubectl-autopilot_cost", flag.ContinueOnError)
+	namespace, selector := addKubectlFlags(flags)
+	if err := flags.Parse([]string{"--context", "gke_project_us-central1_prod", "-n", "shop", "--namespace=team-*", "--exclude-namespace", "*-test", "-l", "app=shop,tier!=batch", "--kubeconfig=/tmp/config"}); err != nil {
+		t.Fatalf(`Parse(kubectl flags) = %v`, err)
+	}
+
+	if cluster.KubeContext != "gke_project_us-central1_prod" || cluster.KubeConfigPath != "/tmp/config" || strings.Join(namespace.Include, ",") != "shop,team-*" || strings.Join(namespace.Exclude, ",") != "*-test" {
+		t.Fatalf(`addKubectlFlags(...) = %q, %q, %v doesn't match expected gke_project_us-central1_prod, /tmp/config, shop,team-* without *-test`, cluster.KubeContext, cluster.KubeConfigPath, *namespace)
+	}
+
+	if *selector != "app=shop,tier!=batch" {
+		t.Errorf("unexpected label selector %q", *selector)
+	}
+
+	tests := []struct {
+		namespace string
+		want      bool
+	}{
+		{"shop", true},
+		{"team-payments", true},
+		{"team-payments-test", false},
+		{"default", false},
+		{"kube-system", false},
+	}
+	for _, test := range tests {
+		if got := namespace.Matches(test.namespace); got != test.want {
+			t.Errorf("Matches(%q) = %t, want %t", test.namespace, got, test.want)
+		}
+	}
+	withSystem := cluster.NamespaceFilter{Include: []string{"shop"}, Exclude: []string{"gmp-system"}, IncludeSystem: true}
+	if !withSystem.Matches("kube-system") || withSystem.Matches("gmp-system") || withSystem.ListNamespace() != "" || withSystem.FieldSelector() != "" {
+		t.Errorf("-include-system should list and select the system namespaces that are not excluded")
+	}
+
+	if namespace.ListNamespace() != "" || (cluster.NamespaceFilter{Include: []string{"shop"}}).ListNamespace() != "shop" {
+		t.Errorf("pods of globs have to be listed in all namespaces, of a single namespace in the namespace")
+	}
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse([]string{"--exclude-namespace", "team-["}); err == nil {
+		t.Errorf("invalid namespace patterns should be rejected")
+	}
+	if err := flags.Parse([]string{"--selector", "app in (shop"}); err == nil {
+		t.Errorf("invalid label selectors should be rejected")
+	}
+
+	currentContext, err := cluster.GetCurrentContext()
+	if err != nil || strings.Join(currentContext, "/") != "gke/project/us-central1/prod" {
+		t.Fatalf(`GetCurrentContext() = %v, %v doesn't match the --context flag`, currentContext, err)
+	}
+}
+
+func TestNamespaceFilter(t *testing.T) {
+	tests := []struct {
+		filter    cluster.NamespaceFilter
+		namespace string
+		want      bool
+	}{
+		// Everything but the system namespaces by default
+		{cluster.NamespaceFilter{}, "shop", true},
+		{cluster.NamespaceFilter{}, "kube-system", false},
+		// Globs include and exclude, exclusions win
+		{cluster.NamespaceFilter{Include: []string{"team-*"}}, "team-a", true},
+		{cluster.NamespaceFilter{Include: []string{"team-*"}}, "shop", false},
+		{cluster.NamespaceFilter{Include: []string{"team-*"}, Exclude: []string{"*-test"}}, "team-a-test", false},
+		// System namespaces need -include-system or their name, not a glob
+		{cluster.NamespaceFilter{Include: []string{"kube-*"}}, "kube-system", false},
+		{cluster.NamespaceFilter{Include: []string{"kube-system"}}, "kube-system", true},
+		{cluster.NamespaceFilter{Include: []string{"kube-system"}}, "gmp-system", false},
+		{cluster.NamespaceFilter{IncludeSystem: true}, "gmp-system", true},
+		{cluster.NamespaceFilter{Include: []string{"kube-system"}, Exclude: []string{"kube-system"}}, "kube-system", false},
+	}
+	for _, test := range tests {
+		if got := test.filter.Matches(test.namespace); got != test.want {
+			t.Errorf("%+v.Matches(%q) = %t, want %t", test.filter, test.namespace, got, test.want)
+		}
+	}
+
+	// A system namespace named on its own is listed in the namespace, without the field selector skipping it
+	kubeSystem := cluster.NamespaceFilter{Include: []string{"kube-system"}}
+	if kubeSystem.ListNamespace() != "kube-system" || strings.Contains(kubeSystem.FieldSelector(), "kube-system") || !kubeSystem.SelectsSystem() {
+		t.Errorf("-namespace kube-system lists %q with %q", kubeSystem.ListNamespace(), kubeSystem.FieldSelector())
+	}
+	if (cluster.NamespaceFilter{Include: []string{"shop"}}).SelectsSystem() {
+		t.Errorf("-namespace shop shouldn't select system namespaces")
+	}
+
+	// The label selector is applied when the pods are listed
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop", Labels: map[string]string{"app": "shop", "tier": "web"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "batch-1", Namespace: "shop", Labels: map[string]string{"app": "shop", "tier": "batch"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "blog-1", Namespace: "blog", Labels: map[string]string{"app": "blog"}}},
+	)
+	var keys []string
+	err := cluster.EachPod(context.Background(), client, "", metav1.ListOptions{LabelSelector: "app=shop,tier!=batch"}, func(pod *corev1.Pod) error {
+		keys = append(keys, cluster.PodKey(pod.Namespace, pod.Name))
+		return nil
+	})
+	if err != nil || strings.Join(keys, ",") != "shop/web-1" {
+		t.Errorf("EachPod(app=shop,tier!=batch) = %v, %v, expected shop/web-1", keys, err)
+	}
+}
+
+func TestDoctorChecks(t *testing.T) {
+	var checkedPermissions []string
+	newEnv := func(kubeConfig *rest.Config, kubeConfigPath string, contextName string) doctorEnv {
+		return doctorEnv{
+			getKubeConfig:       func() (*rest.Config, string, error) { return kubeConfig, kubeConfigPath, nil },
+			getCurrentContext:   func() ([]string, error) { return strings.Split(contextName, "_"), nil },
+			getInClusterContext: func() ([]string, error) { return []string{"gke", "project", "us-central1", "in-cluster"}, nil },
+			lookPath:            func(file string) (string, error) { return "", fmt.Errorf("%s not found", file) },
+			findCredentials:     func(ctx context.Context) error { return nil },
+			grantedPermissions: func(ctx context.Context, project string, permissions []string) ([]string, error) {
+				checkedPermissions = permissions
+				return permissions, nil
+			},
+			serviceState:    func(ctx context.Context, project string, name string) (string, error) { return "ENABLED", nil },
+			checkMetricsAPI: func(kubeConfig *rest.Config) error { return nil },
+		}
+	}
+	runChecks := func(options doctorOptions, env doctorEnv) (names []string, failed []string) {
+		for _, check := range getDoctorChecks(context.Background(), options, env) {
+			names = append(names, check.name)
+			if err := check.run(); err != nil {
+				failed = append(failed, check.name)
+			}
+		}
+		return names, failed
+	}
+
+	// In a pod there is no kube config file and no credential plugin
+	options := doctorOptions{metricsSource: "metrics-server"}
+	if _, failed := runChecks(options, newEnv(&rest.Config{}, "", "")); len(failed) > 0 {
+		t.Errorf("in-cluster checks failed: %v", failed)
+	}
+
+	// The plugin of a kube config context has to be installed
+	pluginConfig := &rest.Config{ExecProvider: &clientcmdapi.ExecConfig{Command: "gke-gcloud-auth-plugin"}}
+	if _, failed := runChecks(options, newEnv(pluginConfig, "/home/user/.kube/config", "gke_project_us-central1_prod")); strings.Join(failed, ",") != "Kubernetes credential plugin" {
+		t.Errorf("checks without the credential plugin failed %v, expected only the plugin check", failed)
+	}
+
+	// Clusters that don't run on GKE have any context and skip the checks of the project
+	names, failed := runChecks(doctorOptions{targetRegion: "us-east4", metricsSource: "prometheus"}, newEnv(&rest.Config{}, "/home/user/.kube/config", "arn:aws:eks:us-east-1:123:cluster/prod"))
+	if len(failed) > 0 || strings.Join(names, ",") != "Kubernetes config,Kubernetes credential plugin,Application Default Credentials" {
+		t.Errorf("-target-region checks = %v, failed %v", names, failed)
+	}
+
+	// The permissions follow the flags of the run
+	options = doctorOptions{skipGKECheck: true, existingCapacity: true, metricsSource: "monitoring"}
+	if _, failed := runChecks(options, newEnv(&rest.Config{}, "/home/user/.kube/config", "gke_project_us-central1_prod")); len(failed) > 0 {
+		t.Errorf("checks failed: %v", failed)
+	}
+	want := "container.nodes.list,container.pods.list,compute.commitments.list,compute.reservations.list,monitoring.timeSeries.list"
+	if strings.Join(checkedPermissions, ",") != want {
+		t.Errorf("checked permissions %v, expected %s", checkedPermissions, want)
+	}
+}
+
+func TestServeEstimate(t *testing.T) {
+	server := &estimateServer{cfg: ini.Empty(), namespaces: cluster.NamespaceFilter{Include: []string{"default"}}}
+
+	options, err := parseServeOptions(server.cfg, url.Values{"sizing-mode": {"requests"}, "group-by-owner": {"true"}, "skip-gke-check": {"true"}}, server.namespaces, server.selector)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if options.sizingMode != calculator.SIZING_REQUESTS || !options.groupByOwner || !options.skipGKECheck || strings.Join(options.namespaces.Include, ",") != "default" || options.samples != 1 {
+		t.Errorf("unexpected options: %+v", options)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodPost, "/v1/estimate", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/v1/estimate?sizing-mode=cheapest", http.StatusBadRequest},
+		{http.MethodGet, "/v1/estimate?all-spot=maybe", http.StatusBadRequest},
+		{http.MethodGet, "/v1/estimate?context=gke_other_us-central1_prod", http.StatusForbidden},
+		{http.MethodGet, "/healthz", http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		server.handler().ServeHTTP(recorder, httptest.NewRequest(test.method, test.target, nil))
+		if recorder.Code != test.status {
+			t.Errorf("%s %s: got status %d, expected %d", test.method, test.target, recorder.Code, test.status)
+		}
+	}
+
+	// Only the listed contexts can be selected
+	server.contexts = []string{"gke_project_us-central1_prod"}
+	if !server.allowContext("") || !server.allowContext("gke_project_us-central1_prod") || server.allowContext("gke_other_us-central1_prod") {
+		t.Errorf("allowContext doesn't match the allow-list %v", server.contexts)
+	}
+
+	// A scrape doesn't wait for an estimate, the latest ones are exported right away
+	server.warmUp.Do(func() {})
+	server.remember(&clusterReport{Context: "gke_project_us-central1_prod", Name: "prod"})
+	server.mutex.Lock()
+	recorder := httptest.NewRecorder()
+	server.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	server.mutex.Unlock()
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `context="gke_project_us-central1_prod"`) {
+		t.Errorf("GET /metrics = %d %s, expected the latest estimate", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	report := &clusterReport{
+		Context: "gke_project_us-central1_prod",
+		Name:    "prod",
+		Region:  "us-central1",
+		workloads: []cluster.Workload{
+			{Name: "web-2", Namespace: "shop", Cost: cluster.NewMoney(0.25), ComputeClass: cluster.ComputeClassBalanced},
+			{Name: "api \"1\"", Namespace: "shop", Cost: cluster.NewMoney(0.5)},
+		},
+		comparison: calculator.StandardComparison{StandardCost: cluster.NewMoney(2), AutopilotCost: cluster.NewMoney(1.5), Savings: cluster.NewMoney(0.5)},
+	}
+
+	var output strings.Builder
+	writeMetrics(&output, []*clusterReport{report})
+	metrics := output.String()
+
+	expected := []string{
+		"# TYPE autopilot_estimated_workload_cost_hourly gauge\n" +
+			`autopilot_estimated_workload_cost_hourly{context="gke_project_us-central1_prod",namespace="shop",workload="api \"1\"",compute_class="General-purpose"} 0.5` + "\n" +
+			`autopilot_estimated_workload_cost_hourly{context="gke_project_us-central1_prod",namespace="shop",workload="web-2",compute_class="Balanced"} 0.25`,
+		`autopilot_estimated_cluster_cost_hourly{context="gke_project_us-central1_prod",cluster="prod",region="us-central1"} 1.5`,
+		`autopilot_standard_cluster_cost_hourly{context="gke_project_us-central1_prod",cluster="prod",region="us-central1"} 2`,
+		`autopilot_estimated_workloads{context="gke_project_us-central1_prod",cluster="prod",region="us-central1"} 2`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("metrics are missing %q:\n%s", line, metrics)
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	tests := []struct {
+		hourlyCost     float64
+		maxHourlyCost  float64
+		maxMonthlyCost float64
+		exceeded       bool
+	}{
+		{1.5, 0, 0, false},
+		{1.5, 2, 0, false},
+		{1.5, 1, 0, true},
+		// 1.5 an hour is 1095 a month
+		{1.5, 0, 1000, true},
+		{1.5, 2, 1100, false},
+	}
+
+	for _, test := range tests {
+		summary := checkBudget(cluster.NewMoney(test.hourlyCost), false, test.maxHourlyCost, test.maxMonthlyCost)
+		if summary.Exceeded != test.exceeded {
+			t.Errorf("checkBudget(%v, false, %v, %v) exceeded %v, expected %v", test.hourlyCost, test.maxHourlyCost, test.maxMonthlyCost, summary.Exceeded, test.exceeded)
+		}
+	}
+
+	// Skipped contexts, no reports at all or an interrupted estimate make the gate fail closed
+	incompleteTests := []struct {
+		reports    []*clusterReport
+		contexts   int
+		incomplete bool
+	}{
+		{[]*clusterReport{{}, {}}, 2, false},
+		{[]*clusterReport{{}}, 2, true},
+		{nil, 1, true},
+		{[]*clusterReport{{}, {partial: true}}, 2, true},
+	}
+
+	for i, test := range incompleteTests {
+		if got := isIncomplete(test.reports, test.contexts); got != test.incomplete {
+			t.Errorf("isIncomplete(#%d) = %v, expected %v", i, got, test.incomplete)
+		}
+	}
+
+	if summary := checkBudget(0, true, 2, 0); summary.Exceeded || !summary.Incomplete {
+		t.Errorf("checkBudget(0, true, 2, 0) = %+v, expected an incomplete summary below the threshold", summary)
+	}
+}
+
+func TestSendNotification(t *testing.T) {
+	report := &clusterReport{Name: "prod", comparison: calculator.StandardComparison{StandardCost: cluster.NewMoney(2), AutopilotCost: cluster.NewMoney(1.5), Savings: cluster.NewMoney(0.5), SavingsPercent: 25}}
+	for i := 0; i < 7; i++ {
+		report.workloads = append(report.workloads, cluster.Workload{Name: fmt.Sprintf("web-%d", i), Namespace: "shop", Cost: cluster.NewMoney(float64(i) / 10)})
+	}
+
+	var message webhookMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("unexpected body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := sendNotification(server.URL, []*clusterReport{report}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(message.Text, "Top 5 workloads") || !strings.Contains(message.Text, "shop/web-6") || strings.Contains(message.Text, "shop/web-1 ") {
+		t.Errorf("unexpected notification:\n%s", message.Text)
+	}
+	if !strings.Contains(message.Text, "(25.0%)") {
+		t.Errorf("notification is missing the savings:\n%s", message.Text)
+	}
+}
+
+func TestParseGCSPath(t *testing.T) {
+	tests := []struct {
+		location string
+		bucket   string
+		prefix   string
+		err      bool
+	}{
+		{"gs://reports", "reports", "", false},
+		{"gs://reports/", "reports", "", false},
+		{"gs://reports/autopilot/daily", "reports", "autopilot/daily/", false},
+		{"gs://reports/autopilot/", "reports", "autopilot/", false},
+		{"gs:///autopilot/", "", "", true},
+		{"s3://reports/", "", "", true},
+	}
+
+	for _, test := range tests {
+		bucket, prefix, err := parseGCSPath(test.location)
+		if (err != nil) != test.err || bucket != test.bucket || prefix != test.prefix {
+			t.Errorf("parseGCSPath(%q) = %q, %q, %v, expected %q, %q, error %v", test.location, bucket, prefix, err, test.bucket, test.prefix, test.err)
+		}
+	}
+
+	runTime := time.Date(2023, 7, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	if name := getUploadObjectName("autopilot/", runTime, ".json"); name != "autopilot/autopilot-estimate-20230701T120000Z.json" {
+		t.Errorf("unexpected object name %q", name)
+	}
+}
+
+func TestClassSpotWorkloads(t *testing.T) {
+	// The workload runs on Spot by the priority of its compute class, the node is on-demand
+	nodes := map[string]cluster.Node{
+		"node-1": {Name: "node-1", Workloads: []cluster.Workload{{
+			Name:          "batch",
+			Namespace:     "default",
+			Spot:          true,
+			ComputeClass:  cluster.ComputeClassGeneralPurpose,
+			Cost:          cluster.NewMoney(0.5),
+			CostBreakdown: cluster.CostBreakdown{Cpu: cluster.NewMoney(0.3), Memory: cluster.NewMoney(0.2)},
+		}}},
+	}
+
+	cfg := ini.Empty()
+	cfg.Section("discounts").Key("oneyear_commit").SetValue("0.8")
+	cfg.Section("discounts").Key("oneyear_flex_commit").SetValue("0.72")
+	discountService := calculator.PricingService{Config: cfg}
+
+	// Spot prices are not discounted again by commitments
+	if cost := discountService.GetCommittedCost(nodes, calculator.CommitOneYear); cost != cluster.NewMoney(0.5) {
+		t.Fatalf(`GetCommittedCost(...) = %s doesn't match expected the Spot price of 0.5`, cost)
+	}
+	if scenario := discountService.GetCoverageScenario(nodes, calculator.CommitOneYear, 1); scenario.Savings != 0 {
+		t.Fatalf(`GetCoverageScenario(...).Savings = %s doesn't match expected 0`, scenario.Savings)
+	}
+
+	rows := getExportRows(time.Now(), "project", "prod", "us-central1", nodes)
+	if spot := rows[0].values()[9]; spot != true {
+		t.Fatalf(`exportRow.values()[spot] = %v, expected true for a Spot compute class`, spot)
+	}
+}
+
+func TestExportRows(t *testing.T) {
+	runTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	nodes := map[string]cluster.Node{
+		"node-1": {Name: "node-1", Spot: true, Workloads: []cluster.Workload{{
+			Name:              "web-1",
+			Namespace:         "shop",
+			Owner:             cluster.Owner{Kind: "Deployment", Name: "web"},
+			Labels:            map[string]string{"tier": "frontend", "app": "web"},
+			Cpu:               500,
+			Memory:            2048,
+			Storage:           1024,
+			AcceleratorType:   "nvidia-l4",
+			AcceleratorAmount: 1,
+			ComputeClass:      cluster.ComputeClassGPUPod,
+			Cost:              cluster.NewMoney(0.5),
+			EffectiveCost:     cluster.NewMoney(0.4),
+			Warnings: []cluster.Warning{
+				{Type: cluster.WarningOutOfRange, Workload: "web-1"},
+				{Type: cluster.WarningSandbox, Workload: "web-1"},
+				{Type: cluster.WarningOutOfRange, Workload: "web-1"},
+			},
+		}}},
+	}
+
+	rows := getExportRows(runTime, "project", "prod", "us-central1", nodes)
+	if len(rows) != 1 {
+		t.Fatalf("getExportRows(...) = %d rows, expected 1", len(rows))
+	}
+
+	values := rows[0].values()
+	if len(values) != len(exportSchema) {
+		t.Fatalf("exportRow.values() = %d values, expected one per column of exportSchema (%d)", len(values), len(exportSchema))
+	}
+	want := []interface{}{"2024-03-01T12:00:00Z", "project", "prod", "us-central1", "shop", "web-1", "Deployment", "web", "node-1", true, cluster.ComputeClasses[cluster.ComputeClassGPUPod], int64(500), int64(2048), int64(1024), "nvidia-l4", int64(1), 0.5, 0.4, 365.0, "app=web,tier=frontend", "out-of-range;sandbox"}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Errorf("exportRow.values()[%s] = %v, expected %v", exportSchema[i].Name, values[i], want[i])
+		}
+	}
+
+	// The insert ID is the same for the same row, so a retried insert is deduplicated
+	if rows[0].insertId() != getExportRows(runTime, "project", "prod", "us-central1", nodes)[0].insertId() || rows[0].insertId() == getExportRows(runTime.Add(time.Hour), "project", "prod", "us-central1", nodes)[0].insertId() {
+		t.Errorf("exportRow.insertId() isn't unique per workload and run")
+	}
+
+	var buffer bytes.Buffer
+	if err := renderExportCsv(&buffer, rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "run_time,project,cluster,") {
+		t.Fatalf("renderExportCsv(...) = %q, expected a header and a row", buffer.String())
+	}
+	if want := `2024-03-01T12:00:00Z,project,prod,us-central1,shop,web-1,Deployment,web,node-1,true,GPU Pod,500,2048,1024,nvidia-l4,1,0.5,0.4,365,"app=web,tier=frontend",out-of-range;sandbox`; lines[1] != want {
+		t.Errorf("renderExportCsv(...) row = %q, expected %q", lines[1], want)
+	}
+}
+
+func TestLookerStudioUrl(t *testing.T) {
+	link, err := getLookerStudioUrl("my-project.costs.workloads")
+	if err != nil {
+		t.Fatalf(`getLookerStudioUrl(...) failed: %v`, err)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf(`getLookerStudioUrl(...) = %s isn't a url: %v`, link, err)
+	}
+
+	params := parsed.Query()
+	query := params.Get("ds.ds0.sql")
+	if parsed.Host != "lookerstudio.google.com" || params.Get("ds.ds0.connector") != "bigQuery" || params.Get("ds.ds0.projectId") != "my-project" ||
+		!strings.Contains(query, "FROM `my-project.costs.workloads`") {
+		t.Fatalf(`getLookerStudioUrl(...) = %s doesn't match expected custom query of my-project.costs.workloads`, link)
+	}
+
+	// The data source has every column of the export
+	for _, field := range exportSchema {
+		if !strings.Contains(query, "  "+field.Name+",") && !strings.Contains(query, "  "+field.Name+"\n") {
+			t.Fatalf(`Looker Studio query doesn't select the %s column of the export`, field.Name)
+		}
+	}
+
+	if _, err := getLookerStudioUrl("workloads"); err == nil {
+		t.Fatalf(`getLookerStudioUrl(workloads) should fail without a project and dataset`)
+	}
+}
+
+func TestGetTotalRows(t *testing.T) {
+	report := &clusterReport{
+		Project:    "project",
+		Name:       "prod",
+		Region:     "us-central1",
+		workloads:  []cluster.Workload{{Name: "web"}},
+		samples:    []cluster.Sample{{Timestamp: time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)}},
+		comparison: calculator.StandardComparison{StandardCost: cluster.NewMoney(2), AutopilotCost: cluster.NewMoney(1.5), Savings: cluster.NewMoney(0.5), SavingsPercent: 25},
+	}
+
+	rows := getTotalRows([]*clusterReport{report})
+	if len(rows) != 1 || len(rows[0]) != len(totalsHeader) {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	if rows[0][0] != "2023-07-01T12:00:00Z" || rows[0][4] != 1 || rows[0][6] != 1.5 || !almostEqual(rows[0][9].(float64), 1.5*calculator.HOURS_PER_MONTH) {
+		t.Errorf("unexpected row %v", rows[0])
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	web := func(hash string, cost float64) cluster.Workload {
+		return cluster.Workload{
+			Name:      "web-" + hash + "-abcde",
+			Namespace: "shop",
+			Owner:     cluster.Owner{Kind: "ReplicaSet", Name: "web-" + hash},
+			Labels:    map[string]string{"pod-template-hash": hash},
+			Cost:      cluster.NewMoney(cost),
+		}
+	}
+	save := func(comparison calculator.StandardComparison, workloads ...cluster.Workload) savedReport {
+		contents, err := json.Marshal(jsonReport{
+			Context:    "prod",
+			Currency:   "USD",
+			Nodes:      map[string]cluster.Node{"node": {Name: "node", Workloads: workloads}},
+			Comparison: comparison,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var report savedReport
+		if err := json.Unmarshal(contents, &report); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return report
+	}
+
+	oldReport := save(calculator.StandardComparison{StandardCost: cluster.NewMoney(2), AutopilotCost: cluster.NewMoney(1)},
+		web("5d8f", 0.1), web("5d8f", 0.1), cluster.Workload{Name: "cache-0", Namespace: "shop", Owner: cluster.Owner{Kind: "StatefulSet", Name: "cache"}, Cost: cluster.NewMoney(0.3)})
+	// The Deployment rolled out a new ReplicaSet and scaled up, the cache was replaced by a queue
+	newReport := save(calculator.StandardComparison{StandardCost: cluster.NewMoney(2), AutopilotCost: cluster.NewMoney(1.25)},
+		web("7c9b", 0.1), web("7c9b", 0.1), web("7c9b", 0.1), cluster.Workload{Name: "queue", Namespace: "shop", Cost: cluster.NewMoney(0.05)})
+
+	diff := diffReports(oldReport, newReport)
+
+	expected := []workloadDiff{
+		{Workload: "prod shop/StatefulSet/cache", Status: DiffRemoved, OldReplicas: 1, OldCost: cluster.NewMoney(0.3), Delta: cluster.NewMoney(-0.3)},
+		{Workload: "prod shop/Deployment/web", Status: DiffChanged, OldReplicas: 2, NewReplicas: 3, OldCost: cluster.NewMoney(0.2), NewCost: cluster.NewMoney(0.3), Delta: cluster.NewMoney(0.1)},
+		{Workload: "prod shop/queue", Status: DiffAdded, NewReplicas: 1, NewCost: cluster.NewMoney(0.05), Delta: cluster.NewMoney(0.05)},
+	}
+	if len(diff.Workloads) != len(expected) {
+		t.Fatalf("got %d workloads, expected %d: %+v", len(diff.Workloads), len(expected), diff.Workloads)
+	}
+	for i := range expected {
+		if diff.Workloads[i] != expected[i] {
+			t.Errorf("workload %d is %+v, expected %+v", i, diff.Workloads[i], expected[i])
+		}
+	}
+	if diff.AutopilotDelta != cluster.NewMoney(0.25) || diff.StandardDelta != 0 {
+		t.Errorf("unexpected total deltas %v, %v", diff.AutopilotDelta, diff.StandardDelta)
+	}
+}
+
+func TestJSONReportSchema(t *testing.T) {
+	x := service
+	report := &clusterReport{
+		Context:        "gke_project_us-central1_prod",
+		Name:           "prod",
+		Project:        "project",
+		Region:         "us-central1",
+		pricingService: &x,
+		nodes: map[string]cluster.Node{
+			"b": {Name: "b", Workloads: []cluster.Workload{{Name: "web-1", Namespace: "shop", Cost: cluster.NewMoney(0.5)}}},
+			"a": {Name: "a", Workloads: []cluster.Workload{
+				{Name: "web-0", Namespace: "shop", Cost: cluster.NewMoney(0.5)},
+				{Name: "train", Namespace: "ml", Cost: cluster.NewMoney(2), ComputeClass: cluster.ComputeClassAccelerator},
+			}},
+		},
+	}
+
+	document := report.jsonReport(runOptions{})
+	if document.SchemaVersion != JSON_SCHEMA_VERSION || document.Cluster == nil || document.Cluster.Project != "project" || document.Pricing == nil {
+		t.Fatalf("unexpected report metadata: %+v", document)
+	}
+
+	names := []string{}
+	for _, workload := range document.Workloads {
+		names = append(names, workload.Namespace+"/"+workload.Name)
+	}
+	if strings.Join(names, ",") != "ml/train,shop/web-0,shop/web-1" {
+		t.Errorf("unexpected workloads %v", names)
+	}
+
+	expected := []classTotal{
+		{ComputeClass: "General-purpose", Workloads: 2, Cost: cluster.NewMoney(1)},
+		{ComputeClass: "Accelerator", Workloads: 1, Cost: cluster.NewMoney(2)},
+	}
+	if len(document.ClassTotals) != len(expected) || document.ClassTotals[0] != expected[0] || document.ClassTotals[1] != expected[1] {
+		t.Errorf("unexpected class totals %+v", document.ClassTotals)
+	}
+	if len(document.Discounts) != 2 {
+		t.Errorf("unexpected discount scenarios %+v", document.Discounts)
+	}
+}
+
+func TestFleetReport(t *testing.T) {
+	kubeConfig := `apiVersion: v1
+kind: Config
+contexts:
+- name: gke_project_us-central1_prod
+  context: {cluster: prod, user: prod}
+- name: gke_project_europe-west4_staging
+  context: {cluster: staging, user: staging}
+- name: minikube
+  context: {cluster: minikube, user: minikube}
+`
+	path := t.TempDir() + "/config"
+	os.WriteFile(path, []byte(kubeConfig), 0644)
+	cluster.KubeConfigPath = path
+	defer func() { cluster.KubeConfigPath = "" }()
+
+	// -all-contexts estimates the GKE contexts only, in a stable order
+	contexts, err := cluster.ListGKEContexts()
+	if err != nil || strings.Join(contexts, ",") != "gke_project_europe-west4_staging,gke_project_us-central1_prod" {
+		t.Fatalf(`ListGKEContexts() = %v, %v doesn't match expected the staging and prod contexts`, contexts, err)
+	}
+
+	reports := []*clusterReport{
+		{Context: contexts[0], Name: "staging", comparison: calculator.StandardComparison{StandardCost: cluster.NewMoney(1), AutopilotCost: cluster.NewMoney(0.5), Unpriced: []string{"node-x"}}},
+		{Context: contexts[1], Name: "prod", comparison: calculator.StandardComparison{StandardCost: cluster.NewMoney(3), AutopilotCost: cluster.NewMoney(2.5), Unpriced: []string{}}},
+	}
+	total := getFleetTotal(reports)
+	if total.StandardCost != cluster.NewMoney(4) || total.AutopilotCost != cluster.NewMoney(3) || total.Savings != cluster.NewMoney(1) || total.SavingsPercent != 25 || len(total.Unpriced) != 1 {
+		t.Fatalf(`getFleetTotal(...) = %+v doesn't match expected 4, 3 and 25%% savings`, total)
+	}
+
+	// Several clusters are reported with a section each and the roll-up
+	fleet, ok := getJSONDocument(reports, runOptions{}, false).(fleetReport)
+	if !ok || len(fleet.Clusters) != 2 || fleet.Clusters[1].Cluster.Name != "prod" || fleet.Total.AutopilotCost != total.AutopilotCost {
+		t.Fatalf(`getJSONDocument(...) = %+v doesn't match expected a fleet report of both clusters`, fleet)
+	}
+	if _, ok := getJSONDocument(reports[:1], runOptions{}, false).(jsonReport); !ok {
+		t.Fatalf(`getJSONDocument(...) of a single cluster is not a cluster report`)
+	}
+}
+
+func TestPricingRegionOverride(t *testing.T) {
+	report := newClusterReport(&estimator.Report{Name: "prod", Region: "us-central1", PricingRegion: "europe-west4", PricingService: &calculator.PricingService{}})
+	document := report.jsonReport(runOptions{})
+	if document.Cluster.Region != "us-central1" || document.Cluster.PricingRegion != "europe-west4" || document.Pricing.Region != "europe-west4" {
+		t.Errorf("unexpected regions of cluster %+v and pricing %q", document.Cluster, document.Pricing.Region)
+	}
+
+	// Without an override the report only has the region of the cluster
+	report = newClusterReport(&estimator.Report{Name: "prod", Region: "us-central1", PricingRegion: "us-central1", PricingService: &calculator.PricingService{}})
+	document = report.jsonReport(runOptions{})
+	if document.Cluster.PricingRegion != "" || document.Pricing.Region != "us-central1" {
+		t.Errorf("unexpected regions of cluster %+v and pricing %q", document.Cluster, document.Pricing.Region)
+	}
+}
+
+func TestRenderPlainTable(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	renderPlainTable([]table.Column{{Title: "Workload", Width: 40}, {Title: "Pods", Width: 8}}, []table.Row{{"shop/web", "2→3"}, {"total"}})
+	os.Stdout = stdout
+	writer.Close()
+
+	output, _ := io.ReadAll(reader)
+	expected := "+----------+------+\n" +
+		"| Workload | Pods |\n" +
+		"+----------+------+\n" +
+		"| shop/web | 2→3  |\n" +
+		"| total    |      |\n" +
+		"+----------+------+\n"
+	if string(output) != expected {
+		t.Errorf("got table:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+func TestMarkdownOutput(t *testing.T) {
+	markdownOutput = true
+	defer func() { markdownOutput = false }()
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	renderTable([]table.Column{{Title: "Workload", Width: 40}, {Title: "Labels", Width: 20}}, []table.Row{{"shop/web", "tier=a|b"}})
+	os.Stdout = stdout
+	writer.Close()
+
+	// Pipes in the cells are escaped so they don't split the columns
+	output, _ := io.ReadAll(reader)
+	expected := "| Workload | Labels |\n" +
+		"| --- | --- |\n" +
+		"| shop/web | tier=a\\|b |\n" +
+		"\n"
+	if string(output) != expected {
+		t.Errorf("got table:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	// Headings and notes are Markdown instead of terminal colors
+	if heading := pinkTextStyle.Render("Cluster prod"); heading != "## Cluster prod\n" {
+		t.Errorf("pinkTextStyle.Render(...) = %q, expected a Markdown heading", heading)
+	}
+	if note := redTextStyle.Render("Unpriced nodes"); note != "> Unpriced nodes\n" {
+		t.Errorf("redTextStyle.Render(...) = %q, expected a Markdown quote", note)
+	}
+}
+
+func TestWatchClusters(t *testing.T) {
+	cfg, _ := ini.Load(defaultConfig)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stdout, plain := os.Stdout, plainOutput
+	os.Stdout, plainOutput = writer, true
+	defer func() { os.Stdout, plainOutput = stdout, plain }()
+
+	// A failing round doesn't stop the watch, it returns once the context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		watchClusters(ctx, cfg, []string{"gke_project_us-central1_prod"}, runOptions{metricsSource: "unknown"}, 10*time.Millisecond, 0, false, "")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf(`watchClusters(...) didn't return after the context was cancelled`)
+	}
+
+	// Every round redraws the reports and tells when the next one is due
+	report := newClusterReport(&estimator.Report{Name: "prod", Region: "us-central1", PricingService: &service})
+	printWatchRound([]*clusterReport{report, report}, runOptions{}, 10*time.Minute)
+	os.Stdout = stdout
+	writer.Close()
+	output, _ := io.ReadAll(reader)
+
+	if strings.Contains(string(output), "\033[H") {
+		t.Errorf("plain output of the watch clears the terminal:\n%s", output)
+	}
+	for _, expected := range []string{`Cluster "prod"`, "Fleet of 2 clusters", "Updated at", "next estimate at"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("watch output doesn't contain %q:\n%s", expected, output)
+		}
+	}
+}
+
+func TestWorkloadTableTotals(t *testing.T) {
+	nodes := map[string]cluster.Node{
+		"on-demand": {Name: "on-demand", Workloads: []cluster.Workload{{Name: "web", Cost: cluster.NewMoney(0.3)}}},
+		"spot":      {Name: "spot", Spot: true, Workloads: []cluster.Workload{{Name: "batch", Cost: cluster.NewMoney(0.2)}}},
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stdout, plain := os.Stdout, plainOutput
+	os.Stdout, plainOutput = writer, true
+	DisplayWorkloadTable(nodes, workloadOrder{}, 0, cluster.NewMoney(0.41), cluster.NewMoney(0.35), cluster.NewMoney(0.1), false)
+	os.Stdout, plainOutput = stdout, plain
+	writer.Close()
+	output, _ := io.ReadAll(reader)
+
+	// Workloads on Spot nodes are part of the total, next to the cluster fee
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.Contains(line, "Total cost per cluster per hour") && !strings.Contains(line, "0.6") {
+			t.Errorf("total row %q doesn't match expected 0.6 for both workloads and the fee", line)
+		}
+	}
+	if !strings.Contains(string(output), "Total cost per cluster per hour") {
+		t.Errorf("workload table has no total row:\n%s", output)
+	}
+}
+
+func TestBrowseModel(t *testing.T) {
+	report := &clusterReport{Name: "prod", nodes: map[string]cluster.Node{
+		"node": {Name: "node", Workloads: []cluster.Workload{
+			{Name: "web-0", Namespace: "shop", Cpu: 500, Cost: cluster.NewMoney(0.1)},
+			{Name: "db-0", Namespace: "shop", Cpu: 250, Cost: cluster.NewMoney(0.3)},
+			{Name: "train", Namespace: "ml", Cpu: 1000, Cost: cluster.NewMoney(0.2)},
+		}},
+	}}
+
+	update := func(m tea.Model, keys ...string) tea.Model {
+		for _, key := range keys {
+			msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+			if key == "enter" {
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			}
+			m, _ = m.Update(msg)
+		}
+		return m
+	}
+	names := func(m tea.Model) string {
+		var names []string
+		for _, workload := range m.(browseModel).shown {
+			names = append(names, workload.Workload.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	var m tea.Model = newBrowseModel([]*clusterReport{report})
+	if got := names(m); got != "db-0,train,web-0" {
+		t.Errorf("workloads are not sorted by price: %s", got)
+	}
+
+	// Sorting by mCPU, the sixth column, keeps the descending order
+	m = update(m, "s", "s", "s", "s", "s", "s")
+	if got := names(m); got != "train,web-0,db-0" {
+		t.Errorf("workloads are not sorted by mCPU: %s", got)
+	}
+	m = update(m, "r")
+	if got := names(m); got != "db-0,web-0,train" {
+		t.Errorf("workloads are not sorted by ascending mCPU: %s", got)
+	}
+
+	m = update(m, "/", "s", "h", "o", "p", "enter")
+	if got := names(m); got != "db-0,web-0" {
+		t.Errorf("workloads are not filtered by namespace: %s", got)
+	}
+
+	m = update(m, "m")
+	if !strings.Contains(m.View(), "per month") || m.(browseModel).table.Rows()[0][len(browseColumns)] != cluster.NewMoney(0.3*calculator.HOURS_PER_MONTH).String() {
+		t.Errorf("prices are not monthly:\n%s", m.View())
+	}
+}
+
+func TestProgressIndicator(t *testing.T) {
+	var output strings.Builder
+	p := &progressIndicator{output: &output, done: make(chan struct{})}
+	p.Step("Fetching prices of us-central1")
+
+	stopped := make(chan struct{})
+	go func() {
+		p.spin(p.done)
+		close(stopped)
+	}()
+	time.Sleep(3 * spinner.MiniDot.FPS)
+	close(p.done)
+	<-stopped
+
+	if !strings.Contains(output.String(), "Fetching prices of us-central1 (") {
+		t.Errorf("unexpected progress output %q", output.String())
+	}
+
+	// Log messages start on a cleared line
+	output.Reset()
+	p.Write([]byte("message\n"))
+	if output.String() != "\r\033[Kmessage\n" {
+		t.Errorf("unexpected log output %q", output.String())
+	}
+}
+
+func TestSortWorkloads(t *testing.T) {
+	nodes := map[string]cluster.Node{
+		"node-a": {Name: "node-a", Workloads: []cluster.Workload{
+			{Name: "web", Namespace: "shop", Cost: cluster.NewMoney(0.2), Cpu: 500, Memory: 512},
+			{Name: "db", Namespace: "shop", Cost: cluster.NewMoney(0.5), Cpu: 250, Memory: 2048},
+		}},
+		"node-b": {Name: "node-b", Workloads: []cluster.Workload{
+			{Name: "agent", Namespace: "monitoring", Cost: cluster.NewMoney(0.1), Cpu: 1000, Memory: 256},
+		}},
+	}
+
+	tests := []struct {
+		sortBy string
+		want   []string
+	}{
+		{"node", []string{"web", "db", "agent"}},
+		{"cost", []string{"db", "web", "agent"}},
+		{"cost:asc", []string{"agent", "web", "db"}},
+		{"mcpu", []string{"agent", "web", "db"}},
+		{"memory", []string{"db", "web", "agent"}},
+		{"namespace", []string{"agent", "web", "db"}},
+		{"name:desc", []string{"web", "db", "agent"}},
+	}
+
+	for _, test := range tests {
+		order, err := parseSortBy(test.sortBy)
+		if err != nil {
+			t.Fatalf("parseSortBy(%q) failed: %v", test.sortBy, err)
+		}
+
+		var names []string
+		for _, workload := range sortWorkloads(nodes, order) {
+			names = append(names, workload.Workload.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(test.want, ",") {
+			t.Errorf("-sort-by %s lists %v, want %v", test.sortBy, names, test.want)
+		}
+	}
+
+	for _, sortBy := range []string{"price", "cost:up"} {
+		if _, err := parseSortBy(sortBy); err == nil {
+			t.Errorf("parseSortBy(%q) should fail", sortBy)
+		}
+	}
+}
+
+func TestClusterSortWorkloads(t *testing.T) {
+	// Pods with the same name are ordered by namespace before node
+	workloads := []cluster.Workload{
+		{Name: "web", Namespace: "shop", Node_name: "node-a"},
+		{Name: "web", Namespace: "blog", Node_name: "node-b"},
+		{Name: "api", Namespace: "shop", Node_name: "node-c"},
+		{Name: "web", Namespace: "blog", Node_name: "node-a"},
+	}
+	cluster.SortWorkloads(workloads)
+
+	var names []string
+	for _, workload := range workloads {
+		names = append(names, workload.Name+"/"+workload.Namespace+"/"+workload.Node_name)
+	}
+	want := "api/shop/node-c,web/blog/node-a,web/blog/node-b,web/shop/node-a"
+	if strings.Join(names, ",") != want {
+		t.Fatalf(`SortWorkloads(...) = %v doesn't match expected %s`, names, want)
+	}
+}
+
+func TestTopWorkloads(t *testing.T) {
+	var workloads []nodeWorkload
+	for i, cost := range []float64{0.1, 0.4, 0.2, 0.3} {
+		workloads = append(workloads, nodeWorkload{Workload: cluster.Workload{Name: fmt.Sprintf("workload-%d", i), Cost: cluster.NewMoney(cost)}})
+	}
+
+	costliest, others := topWorkloads(workloads, 2)
+	if len(costliest) != 2 || costliest[0].Workload.Name != "workload-1" || costliest[1].Workload.Name != "workload-3" {
+		t.Errorf("unexpected costliest workloads %v", costliest)
+	}
+	if len(others) != 2 || others[0].Workload.Name != "workload-0" || others[1].Workload.Name != "workload-2" {
+		t.Errorf("unexpected other workloads %v", others)
+	}
+
+	if costliest, others := topWorkloads(workloads, 0); len(costliest) != 4 || len(others) != 0 {
+		t.Errorf("-top 0 should keep all workloads")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(dir)
+
+	// Without config.ini the built-in configuration is used
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() without config.ini failed: %v", err)
+	}
+	if cfg.Section("limits").Key("generalpurpose_mcpu_max").MustInt64(0) != 30000 || cfg.Section("fees").Key("cluster_fee").MustFloat64(0) != 0.1 {
+		t.Errorf("built-in configuration is missing")
+	}
+
+	// config.ini only overrides the keys it sets
+	if err := os.WriteFile("config.ini", []byte("[fees]\ncluster_fee = 0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() with config.ini failed: %v", err)
+	}
+	if cfg.Section("fees").Key("cluster_fee").MustFloat64(0) != 0.2 || cfg.Section("limits").Key("generalpurpose_mcpu_max").MustInt64(0) != 30000 {
+		t.Errorf("config.ini should override the built-in configuration")
+	}
+}
+
+func TestConfigOverrides(t *testing.T) {
+	cfg, err := ini.Load(defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applyEnvOverrides(cfg, []string{
+		"AUTOPILOT_CALC_FEES_CLUSTER_FEE=0.2",
+		"AUTOPILOT_CALC_MACHINE_FAMILIES_E2=balanced",
+		"AUTOPILOT_CALC_GCE_SKU=ABCD-1234-EF56",
+		"AUTOPILOT_CALC_RATIOS_BALANCED_MAX=7",
+		"HOME=/root",
+	})
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	addConfigFlags(flags, cfg)
+	if err := flags.Parse([]string{"-set", "ratios.balanced_max=9", "-set", "discounts.oneyear_commit_scaleout=0.75"}); err != nil {
+		t.Fatalf("Parse(-set) failed: %v", err)
+	}
+
+	tests := []struct {
+		section string
+		key     string
+		want    string
+	}{
+		{"fees", "cluster_fee", "0.2"},
+		{"machine_families", "e2", "balanced"},
+		{"", "gce_sku", "ABCD-1234-EF56"},
+		{"ratios", "balanced_max", "9"},
+		{"discounts", "oneyear_commit_scaleout", "0.75"},
+		{"spot", "reschedule_minutes", "5"},
+	}
+	for _, test := range tests {
+		if got := cfg.Section(test.section).Key(test.key).String(); got != test.want {
+			t.Errorf("%s = %q, want %q", configKeyName(test.section, test.key), got, test.want)
+		}
+	}
+
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse([]string{"-set", "fees.cluster_fee"}); err == nil {
+		t.Errorf("-set without a value should fail")
+	}
+}
+
+func TestConfigLoaders(t *testing.T) {
+	documents := map[string]string{
+		"config.yaml": "currency: USD\ngce_arm64_prefix: [t2a-, c4a-]\nfees:\n  cluster_fee: 0.2\n  free_tier: true\nnotifications:\n  webhook_url:\n",
+		"config.toml": "currency = \"USD\"\ngce_arm64_prefix = [\"t2a-\", \"c4a-\"]\n\n[fees]\ncluster_fee = 0.2\nfree_tier = true\n\n[notifications]\nwebhook_url = \"\"\n",
+		"config.ini":  "currency = USD\ngce_arm64_prefix = t2a-,c4a-\n\n[fees]\ncluster_fee = 0.2\nfree_tier = true\n\n[notifications]\nwebhook_url =\n",
+	}
+
+	for _, file := range configFiles {
+		document, ok := documents[file.Name]
+		if !ok {
+			continue
+		}
+
+		values, err := file.Loader([]byte(document))
+		if err != nil {
+			t.Fatalf("loading %s failed: %v", file.Name, err)
+		}
+		if values[""]["gce_arm64_prefix"] != "t2a-,c4a-" || values["fees"]["cluster_fee"] != "0.2" || values["fees"]["free_tier"] != "true" || values["notifications"]["webhook_url"] != "" {
+			t.Errorf("unexpected values of %s: %v", file.Name, values)
+		}
+	}
+
+	if _, err := loadYamlConfig([]byte("fees:\n  cluster:\n    fee: 0.1\n")); err == nil {
+		t.Errorf("nested sections should be rejected")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cfg, err := ini.Load(defaultConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := validateConfig(cfg); len(problems.Errors) > 0 || len(problems.Warnings) > 0 {
+		t.Fatalf("built-in configuration has problems: %+v", problems)
+	}
+
+	cfg.Section("limits").Key("scaleout_mcpu_max").SetValue("lots")
+	cfg.Section("ratios").Key("balanced_min").SetValue("9")
+	cfg.Section("ratios").Key("generalpurpose_min").SetValue("0")
+	cfg.Section("machine_families").Key("c4").SetValue("compute")
+	cfg.Section("fees").Key("cluster_fee").SetValue("")
+	cfg.Section("discounts").Key("oneyear_commit").SetValue("1.2")
+	cfg.Section("limit").Key("balanced_mcpu_max").SetValue("1000")
+	cfg.Section("spot").Key("reschedule_minute").SetValue("5")
+
+	problems := validateConfig(cfg)
+	wantErrors := []string{
+		"discounts.oneyear_commit: 1.2 is out of range",
+		"fees.cluster_fee: is missing a value",
+		"limits.scaleout_mcpu_max: \"lots\" is not a whole number",
+		"machine_families.c4: \"compute\" is not a compute class",
+		"ratios.balanced_min: 9 is above balanced_max of 8",
+		"ratios.generalpurpose_min: 0 is out of range",
+	}
+	if len(problems.Errors) != len(wantErrors) {
+		t.Fatalf("unexpected errors %q", problems.Errors)
+	}
+	for i, want := range wantErrors {
+		if !strings.HasPrefix(problems.Errors[i], want) {
+			t.Errorf("error %q doesn't start with %q", problems.Errors[i], want)
+		}
+	}
+
+	wantWarnings := "unknown key spot.reschedule_minute is ignored,unknown section [limit] is ignored"
+	if strings.Join(problems.Warnings, ",") != wantWarnings {
+		t.Errorf("unexpected warnings %q", problems.Warnings)
+	}
+
+	// The built-in prices are in USD and have to be set again for another currency
+	cfg, _ = ini.Load(defaultConfig)
+	cfg.Section("").Key("currency").SetValue("EUR")
+	cfg.Section("fees").Key("cluster_fee").SetValue("0.09")
+	wantWarnings = "egress.internet_price is the built-in price in USD, set it in EUR too"
+	if warnings := currencyWarnings(cfg); strings.Join(warnings, ",") != wantWarnings {
+		t.Errorf("currencyWarnings(EUR) = %q, expected %q", warnings, wantWarnings)
+	}
+}
+
+func TestFindServiceIds(t *testing.T) {
+	services := []*cloudbilling.Service{
+		{Name: "services/6F81-5844-456A", DisplayName: "Compute Engine", ServiceId: "6F81-5844-456A"},
+		{Name: "services/95FF-2EF5-5EA1", DisplayName: "Cloud Storage", ServiceId: "95FF-2EF5-5EA1"},
+		{Name: "services/CCD8-9BF1-090E", DisplayName: "Kubernetes Engine", ServiceId: "CCD8-9BF1-090E"},
+	}
+
+	ids := calculator.FindServiceIds(services)
+	if len(ids) != 2 || ids["autopilot"] != "CCD8-9BF1-090E" || ids["gce"] != "6F81-5844-456A" {
+		t.Errorf("unexpected service IDs %v", ids)
+	}
+}
+
+func TestApplySku(t *testing.T) {
+	gcePricing := calculator.GCEPriceList{}
+	skus := []struct {
+		sku   *cloudbilling.Sku
+		price float64
+	}{
+		{&cloudbilling.Sku{SkuId: "A", Description: "N2 Instance Core running in Americas", Category: &cloudbilling.Category{ResourceFamily: "Compute", ResourceGroup: "N2Standard", UsageType: "OnDemand"}}, 0.03},
+		{&cloudbilling.Sku{SkuId: "B", Description: "Spot Preemptible N2 Instance Core running in Americas", Category: &cloudbilling.Category{ResourceFamily: "Compute", ResourceGroup: "N2Standard", UsageType: "Preemptible"}}, 0.01},
+		{&cloudbilling.Sku{SkuId: "C", Description: "Commitment v1: Cpu in Americas for 3 Year", Category: &cloudbilling.Category{ResourceFamily: "Compute", ResourceGroup: "CPU", UsageType: "Commit3Yr"}}, 0.015},
+		// The commitment of a family doesn't override the N1 one
+		{&cloudbilling.Sku{SkuId: "F", Description: "Commitment v1: N2D AMD Ram in Americas for 1 Year", Category: &cloudbilling.Category{ResourceFamily: "Compute", ResourceGroup: "RAM", UsageType: "Commit1Yr"}}, 0.0012},
+		// Only the category tells the Spot SKU apart
+		{&cloudbilling.Sku{SkuId: "D", Description: "T2A Arm Instance Ram running in Americas", Category: &cloudbilling.Category{ResourceFamily: "Compute", ResourceGroup: "RAM", UsageType: "Preemptible"}}, 0.002},
+		// Disks are not machines
+		{&cloudbilling.Sku{SkuId: "E", Description: "N2 Instance Core in a disk family", Category: &cloudbilling.Category{ResourceFamily: "Storage", UsageType: "OnDemand"}}, 1},
+	}
+	for _, test := range skus {
+		gcePricing.ApplySku(test.sku, test.price)
+	}
+	if gcePricing.N2CpuPrice != 0.03 || gcePricing.SpotN2CpuPrice != 0.01 || gcePricing.CommitmentThreeYearCpuPrice != 0.015 || gcePricing.SpotT2AMemoryPrice != 0.002 ||
+		gcePricing.CommitmentOneYearN2DMemoryPrice != 0.0012 || gcePricing.CommitmentOneYearMemoryPrice != 0 {
+		t.Errorf("unexpected GCE prices %+v", gcePricing)
+	}
+
+	autopilotPricing := calculator.AutopilotPriceList{}
+	autopilotPricing.ApplySku(&cloudbilling.Sku{Description: "Autopilot Scale-Out Arm Spot Pod mCPU Requests (us-central1)"}, 0.01)
+	autopilotPricing.ApplySku(&cloudbilling.Sku{Description: "Autopilot Scale-Out Arm Pod mCPU Requests (us-central1)", Category: &cloudbilling.Category{UsageType: "OnDemand"}}, 0.03)
+	if autopilotPricing.SpotArmCpuScaleoutPrice != 0.01 || autopilotPricing.CpuArmScaleoutPrice != 0.03 {
+		t.Errorf("unexpected Autopilot prices %+v", autopilotPricing)
+	}
+
+	if autopilotPricing.ApplySku(&cloudbilling.Sku{Description: "Autopilot Pod Something New (us-central1)"}, 1) {
+		t.Errorf("ApplySku matched an unknown SKU")
+	}
+}
+
+func TestGetSkuPrice(t *testing.T) {
+	sku := &cloudbilling.Sku{PricingInfo: []*cloudbilling.PricingInfo{{PricingExpression: &cloudbilling.PricingExpression{
+		DisplayQuantity: 1,
+		TieredRates: []*cloudbilling.TierRate{
+			{StartUsageAmount: 0, UnitPrice: &cloudbilling.Money{}},
+			{StartUsageAmount: 1, UnitPrice: &cloudbilling.Money{Nanos: 10000000}},
+			{StartUsageAmount: 10240, UnitPrice: &cloudbilling.Money{Nanos: 8000000}},
+		},
+	}}}}
+
+	// The free tier is skipped
+	if price, ok := calculator.GetSkuPrice(sku); !ok || !almostEqual(price, 0.01) {
+		t.Errorf("GetSkuPrice(tiered sku) = %v, %v doesn't match expected 0.01", price, ok)
+	}
+
+	if _, ok := calculator.GetSkuPrice(&cloudbilling.Sku{}); ok {
+		t.Errorf("GetSkuPrice(sku without rates) returned a price")
+	}
+}
+
+func TestUsageReport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	before := usage.GetReport()
+	client := &http.Client{Transport: usage.CountTransport(usage.Prometheus)(http.DefaultTransport)}
+	for i := 0; i < 3; i++ {
+		response, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf(`Get(...) failed: %v`, err)
+		}
+		response.Body.Close()
+	}
+	usage.Count(usage.Billing)
+
+	done := usage.Phase("pricing")
+	done()
+
+	report := usage.GetReport()
+	if calls := report.Calls[usage.Prometheus] - before.Calls[usage.Prometheus]; calls != 3 {
+		t.Fatalf(`GetReport().Calls[prometheus] went up by %d, expected 3`, calls)
+	}
+	if calls := report.Calls[usage.Billing] - before.Calls[usage.Billing]; calls != 1 {
+		t.Fatalf(`GetReport().Calls[billing] went up by %d, expected 1`, calls)
+	}
+	if len(report.Phases) != len(before.Phases)+1 || report.Phases[len(report.Phases)-1].Name != "pricing" {
+		t.Fatalf(`GetReport().Phases = %+v doesn't end with the pricing phase`, report.Phases)
+	}
+
+	// The report is a copy and its APIs are sorted
+	report.Calls[usage.GKE] = 100
+	if usage.GetReport().Calls[usage.GKE] == 100 {
+		t.Fatalf(`GetReport() returned the recorded calls instead of a copy`)
+	}
+	apis := report.APIs()
+	if !sort.SliceIsSorted(apis, func(i, j int) bool { return apis[i] < apis[j] }) {
+		t.Fatalf(`Report.APIs() = %v is not sorted`, apis)
+	}
+}
+
+func TestGetClusterNodesOfOtherClouds(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "gke", Labels: map[string]string{
+				"cloud.google.com/gke-nodepool":    "default-pool",
+				"cloud.google.com/gke-spot":        "true",
+				"beta.kubernetes.io/instance-type": "e2-standard-4",
+				"topology.kubernetes.io/region":    "us-central1",
+			}},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "eks", Labels: map[string]string{
+				"eks.amazonaws.com/capacityType":   "SPOT",
+				"node.kubernetes.io/instance-type": "m5.xlarge",
+				"topology.kubernetes.io/zone":      "us-east-1a",
+			}},
+			Status: corev1.NodeStatus{Capacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("3"),
+				corev1.ResourceMemory: resource.MustParse("15.5Gi"),
+			}},
+		},
+	)
+
+	nodes, err := cluster.GetClusterNodes(context.Background(), client)
+	if err != nil {
+		t.Fatalf("GetClusterNodes() returned error %v", err)
+	}
+	if node := nodes["gke"]; node.InstanceType != "e2-standard-4" || !node.Spot || node.Region != "us-central1" {
+		t.Errorf("unexpected GKE node %+v", node)
+	}
+	// The EKS node is compared with the N2 custom machine of its size
+	if node := nodes["eks"]; node.InstanceType != "n2-custom-4-15872" || !node.Spot || node.Region != "us-east-1a" {
+		t.Errorf("unexpected EKS node %+v", node)
+	}
+}
+
+func TestEachPod(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "blog"}},
+	)
+
+	var keys []string
+	err := cluster.EachPod(context.Background(), client, "", metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		keys = append(keys, cluster.PodKey(pod.Namespace, pod.Name))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachPod() returned error %v", err)
+	}
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "blog/web-1,shop/web-1" {
+		t.Errorf("unexpected pods %v", keys)
+	}
+
+	// Errors of the callback stop the listing
+	if err := cluster.EachPod(context.Background(), client, "", metav1.ListOptions{}, func(pod *corev1.Pod) error { return fmt.Errorf("stop") }); err == nil || err.Error() != "stop" {
+		t.Errorf("EachPod() = %v, expected the error of the callback", err)
+	}
+}
+
+func TestGetPricingFromSkus(t *testing.T) {
+	rate := func(nanos int64) []*cloudbilling.PricingInfo {
+		return []*cloudbilling.PricingInfo{{PricingExpression: &cloudbilling.PricingExpression{DisplayQuantity: 1, TieredRates: []*cloudbilling.TierRate{{UnitPrice: &cloudbilling.Money{Nanos: nanos}}}}}}
+	}
+	skus := []*cloudbilling.Sku{
+		{Description: "Autopilot Pod mCPU Requests (us-central1)", ServiceRegions: []string{"us-central1"}, PricingInfo: rate(44450)},
+		{Description: "Autopilot Pod mCPU Requests (europe-west1)", ServiceRegions: []string{"europe-west1"}, PricingInfo: rate(48900)},
+		{Description: "E2 Instance Core running in Americas", ServiceRegions: []string{"us-central1"}, PricingInfo: rate(21811590)},
+	}
+
+	// The same SKUs price every region
+	usPricing := calculator.GetAutopilotPricing(skus, "us-central1", nil)
+	euPricing := calculator.GetAutopilotPricing(skus, "europe-west1", nil)
+	if !almostEqual(usPricing.CpuPrice, 0.00004445) || !almostEqual(euPricing.CpuPrice, 0.0000489) {
+		t.Errorf("unexpected CPU prices %v and %v", usPricing.CpuPrice, euPricing.CpuPrice)
+	}
+
+	gcePricing := calculator.GetGCEPricing(skus, "us-central1", nil)
+	if !almostEqual(gcePricing.E2CpuPrice, 0.02181159) || gcePricing.Region != "us-central1" {
+		t.Errorf("unexpected GCE prices %+v", gcePricing)
+	}
+}
+
+func TestCommittedUseSkus(t *testing.T) {
+	rate := func(nanos int64) []*cloudbilling.PricingInfo {
+		return []*cloudbilling.PricingInfo{{PricingExpression: &cloudbilling.PricingExpression{DisplayQuantity: 1, TieredRates: []*cloudbilling.TierRate{{UnitPrice: &cloudbilling.Money{Nanos: nanos}}}}}}
+	}
+	sku := func(description string, usageType string, nanos int64) *cloudbilling.Sku {
+		return &cloudbilling.Sku{Description: description, ServiceRegions: []string{"us-central1"}, Category: &cloudbilling.Category{UsageType: usageType}, PricingInfo: rate(nanos)}
+	}
+	skus := []*cloudbilling.Sku{
+		sku("Autopilot Balanced Pod mCPU Requests (us-central1)", calculator.USAGE_ON_DEMAND, 50000),
+		sku("Commitment v1: Autopilot Balanced Pod mCPU Requests in us-central1 for 1 Year", calculator.USAGE_ONE_YEAR, 36000),
+		sku("Autopilot Scale-Out x86 Pod mCPU Requests (us-central1)", calculator.USAGE_ON_DEMAND, 40000),
+		sku("Commitment v1: Autopilot Scale-Out x86 Pod mCPU Requests in us-central1 for 3 Year", calculator.USAGE_THREE_YEAR, 20000),
+	}
+
+	// The committed use SKUs are the commitment prices of their class and term, not on-demand prices
+	pricing := calculator.GetAutopilotPricing(skus, "us-central1", nil)
+	if !almostEqual(pricing.CpuBalancedPrice, 0.00005) || !almostEqual(pricing.CommitmentOneYearCpuBalancedPrice, 0.000036) || !almostEqual(pricing.CommitmentThreeYearCpuScaleoutPrice, 0.00002) || pricing.CommitmentThreeYearCpuBalancedPrice != 0 {
+		t.Fatalf(`GetAutopilotPricing(...) = %+v doesn't match expected the Balanced 1 year and Scale-Out 3 year commitments`, pricing)
+	}
+
+	// Each class gets the discount of its own SKUs, disks are not covered
+	commitService := calculator.PricingService{AutopilotPricing: pricing, Config: ini.Empty()}
+	balanced := cluster.Workload{ComputeClass: cluster.ComputeClassBalanced, CostBreakdown: cluster.CostBreakdown{Cpu: cluster.NewMoney(1), Disk: cluster.NewMoney(0.5)}}
+	if cost := commitService.GetCommittedWorkloadCost(balanced, calculator.CommitOneYear); cost != cluster.NewMoney(1.22) {
+		t.Fatalf(`GetCommittedWorkloadCost(Balanced, oneyear) = %s doesn't match expected 1.22`, cost)
+	}
+	scaleout := cluster.Workload{ComputeClass: cluster.ComputeClassScaleout, CostBreakdown: cluster.CostBreakdown{Cpu: cluster.NewMoney(1)}}
+	if cost := commitService.GetCommittedWorkloadCost(scaleout, calculator.CommitThreeYear); cost != cluster.NewMoney(0.5) {
+		t.Fatalf(`GetCommittedWorkloadCost(Scale-Out, threeyear) = %s doesn't match expected 0.5`, cost)
+	}
+	// Without a SKU or a configured discount the cost stays
+	if cost := commitService.GetCommittedWorkloadCost(scaleout, calculator.CommitOneYear); cost != cluster.NewMoney(1) {
+		t.Fatalf(`GetCommittedWorkloadCost(Scale-Out, oneyear) = %s doesn't match expected 1`, cost)
+	}
+}
+
+func TestSkipGKECheck(t *testing.T) {
+	versions := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		versions++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"gitVersion": "v1.30.2-gke.1000"}`)
+	}))
+	defer server.Close()
+
+	kubeConfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster: {server: "` + server.URL + `"}
+contexts:
+- name: gke_project_us-central1_prod
+  context: {cluster: prod, user: prod}
+users:
+- name: prod
+  user: {}
+`
+	path := t.TempDir() + "/config"
+	os.WriteFile(path, []byte(kubeConfig), 0644)
+	cluster.KubeConfigPath = path
+	defer func() { cluster.KubeConfigPath = "" }()
+	// Without credentials every Google API fails right away
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", t.TempDir()+"/missing.json")
+
+	cfg, _ := ini.Load(defaultConfig)
+	e := estimator.New()
+
+	// The GKE API is asked first, the cluster isn't reached
+	e.Configure(cfg, estimator.Options{Context: "gke_project_us-central1_prod"})
+	if _, err := e.Estimate(context.Background()); err == nil || !strings.Contains(err.Error(), "GKE client") || versions != 0 {
+		t.Fatalf(`Estimate() = %v after %d version requests, expected the GKE check to fail`, err, versions)
+	}
+
+	// Skipping the check reads the version from the cluster and goes on with the prices
+	e.Configure(cfg, estimator.Options{Context: "gke_project_us-central1_prod", SkipGKECheck: true})
+	if _, err := e.Estimate(context.Background()); err == nil || !strings.Contains(err.Error(), "pricing service") || versions != 1 {
+		t.Fatalf(`Estimate() = %v after %d version requests, expected the GKE check to be skipped`, err, versions)
+	}
+}
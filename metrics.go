@@ -0,0 +1,98 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// clusterMetrics are the per cluster gauges of the Prometheus exporter.
+var clusterMetrics = []struct {
+	Name  string
+	Help  string
+	Value func(report *clusterReport) float64
+}{
+	{"autopilot_estimated_cluster_cost_hourly", "Estimated hourly cost of the cluster in Autopilot.", func(report *clusterReport) float64 {
+		return report.comparison.AutopilotCost.Float64()
+	}},
+	{"autopilot_standard_cluster_cost_hourly", "Hourly cost of the nodes of the Standard cluster.", func(report *clusterReport) float64 {
+		return report.comparison.StandardCost.Float64()
+	}},
+	{"autopilot_estimated_savings_hourly", "Hourly savings of the cluster in Autopilot, negative if Autopilot costs more.", func(report *clusterReport) float64 {
+		return report.comparison.Savings.Float64()
+	}},
+	{"autopilot_cluster_fee_hourly", "Hourly fee of the Autopilot cluster.", func(report *clusterReport) float64 {
+		return report.clusterFee.Float64()
+	}},
+	{"autopilot_estimated_workloads", "Number of workloads in the estimate.", func(report *clusterReport) float64 {
+		return float64(len(report.workloads))
+	}},
+	{"autopilot_estimate_timestamp_seconds", "Time of the cluster sample the estimate is based on.", func(report *clusterReport) float64 {
+		if len(report.samples) == 0 {
+			return 0
+		}
+		return float64(report.samples[len(report.samples)-1].Timestamp.Unix())
+	}},
+}
+
+// writeMetrics writes the estimates of the clusters in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, reports []*clusterReport) {
+	fmt.Fprintln(w, "# HELP autopilot_estimated_workload_cost_hourly Estimated hourly cost of the workload in Autopilot.")
+	fmt.Fprintln(w, "# TYPE autopilot_estimated_workload_cost_hourly gauge")
+	for _, report := range reports {
+		workloads := append(report.workloads[:0:0], report.workloads...)
+		sort.Slice(workloads, func(i, j int) bool {
+			if workloads[i].Namespace != workloads[j].Namespace {
+				return workloads[i].Namespace < workloads[j].Namespace
+			}
+			return workloads[i].Name < workloads[j].Name
+		})
+
+		for _, workload := range workloads {
+			writeMetric(w, "autopilot_estimated_workload_cost_hourly", workload.Cost.Float64(),
+				"context", report.Context, "namespace", workload.Namespace, "workload", workload.Name, "compute_class", cluster.ComputeClasses[workload.ComputeClass])
+		}
+	}
+
+	for _, metric := range clusterMetrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", metric.Name, metric.Help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", metric.Name)
+		for _, report := range reports {
+			writeMetric(w, metric.Name, metric.Value(report), "context", report.Context, "cluster", report.Name, "region", report.Region)
+		}
+	}
+}
+
+// writeMetric writes a single sample, labels are given as name and value pairs.
+func writeMetric(w io.Writer, name string, value float64, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1])))
+	}
+
+	fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
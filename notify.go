@@ -0,0 +1,102 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+// NOTIFICATION_TOP_WORKLOADS is the number of most expensive workloads listed in a notification.
+const NOTIFICATION_TOP_WORKLOADS = 5
+
+// webhookMessage is the body of a Slack incoming webhook, other chat tools accept the same format.
+type webhookMessage struct {
+	Text string `json:"text"`
+}
+
+// getNotificationText summarizes the reports: the Autopilot total, the savings against Standard
+// and the most expensive workloads.
+func getNotificationText(reports []*clusterReport) string {
+	total := getFleetTotal(reports)
+
+	names := []string{}
+	type rankedWorkload struct {
+		Context  string
+		Workload cluster.Workload
+	}
+	workloads := []rankedWorkload{}
+	for _, report := range reports {
+		names = append(names, report.Name)
+		for _, workload := range report.workloads {
+			workloads = append(workloads, rankedWorkload{Context: report.Name, Workload: workload})
+		}
+	}
+	sort.SliceStable(workloads, func(i, j int) bool {
+		return workloads[i].Workload.Cost > workloads[j].Workload.Cost
+	})
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "*Autopilot cost estimate for %s*\n", strings.Join(names, ", "))
+	fmt.Fprintf(&text, "Autopilot: %s, %s\n", perHour(total.AutopilotCost), perMonth(total.AutopilotCost.Mul(calculator.HOURS_PER_MONTH)))
+	fmt.Fprintf(&text, "Standard: %s, %s\n", perHour(total.StandardCost), perMonth(total.StandardCost.Mul(calculator.HOURS_PER_MONTH)))
+	fmt.Fprintf(&text, "Savings: %s (%.1f%%)\n", perMonth(total.Savings.Mul(calculator.HOURS_PER_MONTH)), total.SavingsPercent)
+
+	if len(workloads) > NOTIFICATION_TOP_WORKLOADS {
+		workloads = workloads[:NOTIFICATION_TOP_WORKLOADS]
+	}
+	if len(workloads) > 0 {
+		fmt.Fprintf(&text, "Top %d workloads:\n", len(workloads))
+	}
+	for _, ranked := range workloads {
+		workload := ranked.Workload
+		name := workload.Namespace + "/" + workload.Name
+		if len(reports) > 1 {
+			name = ranked.Context + " " + name
+		}
+		fmt.Fprintf(&text, "• %s (%s): %s\n", name, cluster.ComputeClasses[workload.ComputeClass], perMonth(workload.Cost.Mul(calculator.HOURS_PER_MONTH)))
+	}
+
+	return text.String()
+}
+
+// sendNotification posts the summary of the reports to a Slack compatible webhook URL.
+func sendNotification(webhookUrl string, reports []*clusterReport) error {
+	contents, err := json.Marshal(webhookMessage{Text: getNotificationText(reports)})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Post(webhookUrl, "application/json", bytes.NewReader(contents))
+	if err != nil {
+		return fmt.Errorf("error posting notification: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("error posting notification: webhook returned %s", response.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,542 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package estimator maps the workloads of a GKE Standard cluster to Autopilot and prices them, so
+// other Go tools can embed the calculator without running the binary:
+//
+//	e := estimator.New()
+//	if err := e.Configure(cfg, estimator.Options{Context: "gke_my-project_us-central1_my-cluster"}); err != nil {
+//		return err
+//	}
+//	report, err := e.Estimate(ctx)
+package estimator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/location"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/retry"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	container "google.golang.org/api/container/v1"
+	"google.golang.org/api/option"
+	"gopkg.in/ini.v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// Sources of the container usage.
+const (
+	METRICS_SERVER = "metrics-server"
+	MONITORING     = "monitoring"
+	PROMETHEUS     = "prometheus"
+)
+
+// IN_CLUSTER names a cluster of another cloud the estimate runs in, it has no kubeconfig context.
+const IN_CLUSTER = "in-cluster"
+
+// Options change how a cluster is estimated. The zero value estimates the current kubeconfig context
+// from metrics-server by the maximum of the requests and the usage.
+type Options struct {
+	// Context is the kubeconfig context of the cluster, empty for the current context or the cluster the tool runs in
+	Context string
+	// PricingRegion prices the cluster as if it ran in another region, eg. europe-west4, empty for the region of the cluster
+	PricingRegion string
+	// TargetRegion estimates a cluster that doesn't run on GKE, eg. on EKS, AKS or on-prem, as if it was
+	// migrated to Autopilot in the region. The GKE API isn't called, so any kubeconfig context works.
+	TargetRegion string
+	// SkipGKECheck doesn't ask the GKE API for the cluster, for users without the container.clusters.get
+	// permission or to re-validate the bill of a cluster that already runs in Autopilot
+	SkipGKECheck bool
+
+	AmortizeFee      bool
+	AllSpot          bool
+	SpotOverhead     float64
+	IncludeCompleted bool
+	ExistingCapacity bool
+	// Samples is the number of times the cluster is sampled, SampleInterval apart
+	Samples           int
+	SampleInterval    time.Duration
+	GroupByOwner      bool
+	HPAMax            bool
+	ProrateJobs       bool
+	LoadBalancers     bool
+	FreeTier          bool
+	CudCoverage       float64
+	MetricsSource     string
+	PrometheusUrl     string
+	SizingMode        string
+	SidecarMode       string
+	MetricsWindow     time.Duration
+	MetricsPercentile float64
+	RecommendClasses  bool
+	Namespaces        cluster.NamespaceFilter
+	Selector          string
+
+	// Progress is called with every step of the estimate, eg. "Fetching prices of us-central1"
+	Progress func(step string)
+}
+
+// Report is the estimate of a single cluster.
+type Report struct {
+	Context string
+	Name    string
+	Project string
+	Region  string
+	Status  string
+	Version string
+	// PricingRegion is the region of the prices, the region of the cluster unless Options.PricingRegion is set
+	PricingRegion string
+
+	PricingService *calculator.PricingService
+	// Fee of the Autopilot cluster, StandardFee is the fee of the current cluster
+	ClusterFee  cluster.Money
+	StandardFee cluster.Money
+
+	Nodes            map[string]cluster.Node
+	Workloads        []cluster.Workload
+	SystemWorkloads  []cluster.Workload
+	Owners           []cluster.OwnerCost
+	Autoscalers      []cluster.AutoscalerCost
+	LoadBalancers    []cluster.LoadBalancer
+	LoadBalancerCost cluster.Money
+	Samples          []cluster.Sample
+	SpotScenario     *calculator.SpotScenario
+	Coverage         []calculator.CoverageScenario
+	Recommendations  []calculator.ClassRecommendation
+	Existing         *calculator.ExistingCapacity
+	Comparison       calculator.StandardComparison
+
+	// Partial is set if the context was cancelled after the first sample, the report covers the samples
+	// taken until then and leaves out the optional steps that call the APIs, eg. the load balancers
+	Partial bool
+}
+
+// Estimator estimates a cluster with a configuration and options.
+type Estimator struct {
+	config  *ini.File
+	options Options
+}
+
+// New returns an Estimator, it has to be configured before the first estimate.
+func New() *Estimator {
+	return &Estimator{}
+}
+
+// Configure sets the configuration, eg. the prices and limits of config.ini, and the options of the estimates.
+func (e *Estimator) Configure(cfg *ini.File, options Options) error {
+	if cfg == nil {
+		return fmt.Errorf("estimator needs a configuration")
+	}
+
+	switch options.MetricsSource {
+	case "":
+		options.MetricsSource = METRICS_SERVER
+	case METRICS_SERVER, MONITORING:
+	case PROMETHEUS:
+		if options.PrometheusUrl == "" {
+			return fmt.Errorf("metrics source prometheus needs a Prometheus URL")
+		}
+	default:
+		return fmt.Errorf("unsupported metrics source %q, use metrics-server, monitoring or prometheus", options.MetricsSource)
+	}
+
+	switch options.SizingMode {
+	case "":
+		options.SizingMode = calculator.SIZING_MAX
+	case calculator.SIZING_REQUESTS, calculator.SIZING_USAGE, calculator.SIZING_MAX:
+	default:
+		return fmt.Errorf("unsupported sizing mode %q, use requests, usage or max", options.SizingMode)
+	}
+
+	switch options.SidecarMode {
+	case "":
+		options.SidecarMode = calculator.SIDECARS_INCLUDE
+	case calculator.SIDECARS_INCLUDE, calculator.SIDECARS_EXCLUDE, calculator.SIDECARS_SEPARATE:
+	default:
+		return fmt.Errorf("unsupported sidecar mode %q, use include, exclude or separate", options.SidecarMode)
+	}
+
+	// Clusters of other clouds have neither a project for Cloud Monitoring nor commitments to take into account
+	if options.TargetRegion != "" && options.MetricsSource == MONITORING {
+		return fmt.Errorf("metrics source monitoring needs a GKE cluster, use metrics-server or prometheus with a target region")
+	}
+	if options.TargetRegion != "" && options.ExistingCapacity {
+		return fmt.Errorf("existing capacity needs a GKE cluster, it can't be used with a target region")
+	}
+
+	if options.Samples < 1 {
+		options.Samples = 1
+	}
+
+	e.config = cfg
+	e.options = options
+	return nil
+}
+
+// GetPricingSKUs returns the configured billing service IDs, empty ones are looked up in the catalog.
+func GetPricingSKUs(cfg *ini.File) map[string]string {
+	return map[string]string{
+		"autopilot": cfg.Section("").Key("autopilot_sku").String(),
+		"gce":       cfg.Section("").Key("gce_sku").String(),
+	}
+}
+
+func (e *Estimator) step(step string) {
+	if e.options.Progress != nil {
+		e.options.Progress(step)
+	}
+}
+
+// describeGKECluster identifies the GKE cluster of the context and reads its status and version from the GKE API,
+// or from the cluster with Options.SkipGKECheck.
+func describeGKECluster(ctx context.Context, clientset kubernetes.Interface, kubeConfigPath string, options Options) (*Report, error) {
+	var err error
+
+	// Extract the information out of kube config file
+	currentContext := strings.Split(options.Context, "_")
+	if kubeConfigPath == "" {
+		currentContext, err = cluster.GetInClusterContext()
+		if err != nil {
+			return nil, fmt.Errorf("error getting GKE context: %v", err)
+		}
+	} else if options.Context == "" {
+		currentContext, err = cluster.GetCurrentContext()
+		if err != nil {
+			return nil, fmt.Errorf("error getting GKE context: %v", err)
+		}
+	}
+	if len(currentContext) != 4 {
+		return nil, fmt.Errorf("context %q is not a GKE context, set a target region to estimate clusters of other clouds", strings.Join(currentContext, "_"))
+	}
+
+	report := &Report{
+		Context: strings.Join(currentContext, "_"),
+		Name:    currentContext[3],
+		Region:  currentContext[2],
+		Project: currentContext[1],
+	}
+
+	if options.SkipGKECheck {
+		report.Status = "RUNNING"
+		report.Version, err = getServerVersion(clientset)
+		if err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	httpClient, err := retry.HTTPClient(ctx, usage.GKE, container.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing GKE client: %v", err)
+	}
+	svc, err := container.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("error initializing GKE client: %v", err)
+	}
+
+	clusterLocation := fmt.Sprintf("projects/%s/locations/%s/clusters/%s", report.Project, report.Region, report.Name)
+
+	usage.Count(usage.GKE)
+	clusterObject, err := svc.Projects.Locations.Clusters.Get(clusterLocation).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error getting GKE cluster information: %s, %v", report.Name, err)
+	}
+
+	if clusterObject.Autopilot != nil && clusterObject.Autopilot.Enabled {
+		return nil, fmt.Errorf("%s is already an Autopilot cluster, `aborting`, skip the GKE check to estimate it anyway", report.Name)
+	}
+	report.Status = clusterObject.Status
+	report.Version = clusterObject.CurrentMasterVersion
+
+	return report, nil
+}
+
+// describeCluster identifies a cluster of another cloud or on-prem by its kubeconfig context, it is estimated
+// as if it ran in the target region. The version is read from the cluster, as there is no GKE API to ask.
+func describeCluster(ctx context.Context, clientset kubernetes.Interface, kubeConfigPath string, options Options) (*Report, error) {
+	name := options.Context
+	if kubeConfigPath == "" {
+		name = IN_CLUSTER
+	} else if name == "" {
+		currentContext, err := cluster.GetCurrentContext()
+		if err != nil {
+			return nil, fmt.Errorf("error getting kubernetes context: %v", err)
+		}
+		name = strings.Join(currentContext, "_")
+	}
+
+	version, err := getServerVersion(clientset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		Context: name,
+		Name:    name,
+		Region:  location.Region(options.TargetRegion),
+		// The API server answered, which is what a running GKE cluster reports
+		Status:  "RUNNING",
+		Version: version,
+	}, nil
+}
+
+// getServerVersion reads the version of the cluster from its API server, eg. 1.27.3-gke.100, when the GKE API isn't asked.
+func getServerVersion(clientset kubernetes.Interface) (string, error) {
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("error getting kubernetes version: %v", err)
+	}
+
+	return strings.TrimPrefix(version.GitVersion, "v"), nil
+}
+
+// sampleWorkloads prices the workloads of the cluster Options.Samples times, the report keeps those of the
+// last sample. Once the context is cancelled the samples taken so far make a Partial report.
+func (e *Estimator) sampleWorkloads(ctx context.Context, report *Report, clientset kubernetes.Interface) error {
+	options := e.options
+	for i := 0; i < options.Samples; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(options.SampleInterval):
+			}
+		}
+
+		// Nodes come and go between samples, so they are listed every time
+		e.step(fmt.Sprintf("Listing nodes of %s", report.Name))
+		var workloads []cluster.Workload
+		nodes, err := cluster.GetClusterNodes(ctx, clientset)
+		if err == nil {
+			e.step(fmt.Sprintf("Reading pod metrics and pricing the workloads of %s", report.Name))
+			workloads, err = report.PricingService.PopulateWorkloads(ctx, nodes)
+		}
+		if err != nil {
+			// The samples taken so far still make a report
+			if ctx.Err() != nil && len(report.Samples) > 0 {
+				logging.Warn("Estimate of %s was interrupted, the report covers the first %d of %d samples", report.Name, len(report.Samples), options.Samples)
+				report.Partial = true
+				break
+			}
+			return fmt.Errorf("error estimating the workloads of %s: %v", report.Name, err)
+		}
+		report.Nodes, report.Workloads = nodes, workloads
+		report.SystemWorkloads = report.PricingService.SystemWorkloads
+
+		report.Samples = append(report.Samples, cluster.NewSample(time.Now(), report.Workloads))
+	}
+
+	return nil
+}
+
+// Estimate maps all workloads of the cluster to Autopilot and compares the cost with the current cluster.
+// Cancelling the context stops the calls in flight, see Report.Partial for what is reported after that.
+func (e *Estimator) Estimate(ctx context.Context) (*Report, error) {
+	if e.config == nil {
+		return nil, fmt.Errorf("estimator is not configured")
+	}
+	cfg, options := e.config, e.options
+
+	setupDone := usage.Phase("setup")
+	e.step("Connecting to the cluster")
+
+	// Setting up kube configurations
+	kubeConfig, kubeConfigPath, err := cluster.GetKubeConfigForContext(options.Context)
+	if err != nil {
+		return nil, fmt.Errorf("error getting kubernetes config: %v", err)
+	}
+
+	// Calls to metrics-server are counted apart from the rest of the Kubernetes API
+	metricsConfig := rest.CopyConfig(kubeConfig)
+	kubeConfig.Wrap(usage.CountTransport(usage.Kubernetes))
+	metricsConfig.Wrap(usage.CountTransport(usage.Metrics))
+	kubeConfig.Wrap(retry.Transport(usage.Kubernetes))
+	metricsConfig.Wrap(retry.Transport(usage.Metrics))
+
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error setting kubernetes config: %v", err)
+	}
+
+	metricsClientset, err := metricsv.NewForConfig(metricsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error setting kubernetes metrics config: %v", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error setting kubernetes config: %v", err)
+	}
+
+	var report *Report
+	if options.TargetRegion != "" {
+		report, err = describeCluster(ctx, clientset, kubeConfigPath, options)
+	} else {
+		report, err = describeGKECluster(ctx, clientset, kubeConfigPath, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.PricingRegion = location.Region(report.Region)
+	if options.PricingRegion != "" {
+		report.PricingRegion = location.Region(options.PricingRegion)
+	}
+	setupDone()
+
+	pricingDone := usage.Phase("pricing")
+	e.step(fmt.Sprintf("Fetching prices of %s", report.PricingRegion))
+	report.PricingService, err = calculator.NewService(ctx, GetPricingSKUs(cfg), report.PricingRegion, clientset, metricsClientset, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing pricing service: %v", err)
+	}
+	// A typo in the region would price every workload at 0
+	if (options.PricingRegion != "" || options.TargetRegion != "") && report.PricingService.AutopilotPricing.CpuPrice == 0 {
+		return nil, fmt.Errorf("no Autopilot prices found for region %q", report.PricingRegion)
+	}
+	report.PricingService.IncludeCompletedPods = options.IncludeCompleted
+	report.PricingService.SizingMode = options.SizingMode
+	report.PricingService.SidecarMode = options.SidecarMode
+	report.PricingService.Namespaces = options.Namespaces
+	report.PricingService.LabelSelector = options.Selector
+	report.PricingService.Bursting = cfg.Section("bursting").Key("enabled").MustBool(true)
+
+	// Without the permission to list them, pods of custom compute classes are classed by their resources
+	report.PricingService.ComputeClasses, err = cluster.ListComputeClasses(ctx, dynamicClient)
+	if err != nil {
+		logging.Warn("Custom compute classes can't be read, their workloads are classed by their resources: %v", err)
+	}
+
+	if options.ProrateJobs {
+		report.PricingService.JobRuns, err = cluster.GetJobRuns(ctx, clientset, time.Now())
+		if err != nil {
+			logging.Warn("Jobs and CronJobs can't be read, their pods are priced as running all month: %v", err)
+		}
+	}
+	pricingDone()
+
+	if options.MetricsSource != METRICS_SERVER {
+		historyDone := usage.Phase("usage history")
+		e.step("Reading the usage history")
+		if options.MetricsSource == PROMETHEUS {
+			report.PricingService.UsageHistory, err = cluster.GetPrometheusUsage(ctx, options.PrometheusUrl, report.Name, options.MetricsWindow, options.MetricsPercentile)
+		} else {
+			report.PricingService.UsageHistory, err = cluster.GetMonitoringUsage(ctx, report.Project, report.Region, report.Name, options.MetricsWindow, options.MetricsPercentile)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting usage history: %v", err)
+		}
+		historyDone()
+	}
+
+	workloadsDone := usage.Phase("workloads")
+	if err := e.sampleWorkloads(ctx, report, clientset); err != nil {
+		return nil, err
+	}
+	workloadsDone()
+	e.step(fmt.Sprintf("Calculating the estimate of %s", report.Name))
+
+	// Steps that call the APIs are skipped once the estimate is interrupted
+	if ctx.Err() != nil {
+		report.Partial = true
+		options.LoadBalancers, options.GroupByOwner, options.AllSpot, options.ExistingCapacity = false, false, false, false
+		options.HPAMax = false
+	}
+
+	if options.LoadBalancers {
+		loadBalancers, err := cluster.GetLoadBalancers(ctx, clientset, dynamicClient)
+		if err != nil {
+			return nil, err
+		}
+		report.LoadBalancers, report.LoadBalancerCost = report.PricingService.PriceLoadBalancers(loadBalancers)
+	}
+
+	if options.GroupByOwner {
+		report.Owners, err = cluster.GetOwnerCosts(ctx, clientset, report.Workloads)
+		if err != nil {
+			return nil, fmt.Errorf("error getting workload owners: %v", err)
+		}
+	}
+
+	if options.HPAMax {
+		// Autoscalers target the controller users manage, eg. a Deployment instead of its ReplicaSets
+		owners := report.Owners
+		if !options.GroupByOwner {
+			owners, err = cluster.GetOwnerCosts(ctx, clientset, report.Workloads)
+			if err != nil {
+				return nil, fmt.Errorf("error getting workload owners: %v", err)
+			}
+		}
+
+		report.Autoscalers, err = cluster.GetAutoscalerCosts(ctx, clientset, owners)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cluster_fee, err := cfg.Section("fees").Key("cluster_fee").Float64()
+	if err != nil {
+		cluster_fee = calculator.CLUSTER_FEE
+	}
+	report.StandardFee, report.ClusterFee = calculator.GetClusterFees(cluster_fee, report.Region, options.FreeTier)
+
+	if options.AmortizeFee {
+		calculator.AmortizeClusterFee(report.Nodes, report.ClusterFee)
+	}
+
+	if options.AllSpot {
+		preemptionOverhead := options.SpotOverhead
+		if preemptionOverhead < 0 {
+			preemptionOverhead = report.PricingService.GetPreemptionOverhead(report.Nodes, time.Now())
+		}
+
+		scenario := report.PricingService.GetSpotScenario(report.Nodes, preemptionOverhead)
+		blockers, err := cluster.GetSpotBlockers(ctx, clientset, report.Workloads)
+		if err != nil {
+			return nil, err
+		}
+		scenario.SetSpotBlockers(blockers)
+		report.SpotScenario = &scenario
+	}
+
+	if options.RecommendClasses {
+		report.Recommendations = report.PricingService.GetClassRecommendations(report.Nodes)
+	}
+
+	if options.CudCoverage > 0 {
+		for _, term := range []string{calculator.CommitOneYear, calculator.CommitThreeYear} {
+			report.Coverage = append(report.Coverage, report.PricingService.GetCoverageScenario(report.Nodes, term, options.CudCoverage))
+		}
+	}
+
+	if options.ExistingCapacity {
+		commitmentsDone := usage.Phase("commitments")
+		capacity, err := report.PricingService.GetExistingCapacity(ctx, report.Project, report.Region)
+		if err != nil {
+			return nil, fmt.Errorf("error getting existing commitments and reservations: %v", err)
+		}
+		report.Existing = &capacity
+		commitmentsDone()
+	}
+
+	report.Comparison = report.PricingService.CompareWithStandard(report.Nodes, report.StandardFee, report.ClusterFee, report.Existing)
+
+	return report, nil
+}
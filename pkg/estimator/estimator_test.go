@@ -0,0 +1,114 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package estimator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"gopkg.in/ini.v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func TestEstimatorConfigure(t *testing.T) {
+	e := New()
+	if _, err := e.Estimate(context.Background()); err == nil {
+		t.Errorf("Estimate() of an unconfigured estimator didn't fail")
+	}
+
+	tests := []struct {
+		options Options
+		valid   bool
+	}{
+		{Options{}, true},
+		{Options{MetricsSource: MONITORING, SizingMode: calculator.SIZING_REQUESTS}, true},
+		{Options{MetricsSource: PROMETHEUS}, false},
+		{Options{MetricsSource: "statsd"}, false},
+		{Options{SizingMode: "limits"}, false},
+		{Options{TargetRegion: "us-central1"}, true},
+		{Options{TargetRegion: "us-central1", MetricsSource: MONITORING}, false},
+		{Options{TargetRegion: "us-central1", ExistingCapacity: true}, false},
+	}
+	for _, test := range tests {
+		if err := e.Configure(ini.Empty(), test.options); (err == nil) != test.valid {
+			t.Errorf("Configure(%+v) = %v, expected valid %v", test.options, err, test.valid)
+		}
+	}
+
+	if err := e.Configure(nil, Options{}); err == nil {
+		t.Errorf("Configure() without a configuration didn't fail")
+	}
+}
+
+func TestConfigureDefaults(t *testing.T) {
+	e := New()
+	if err := e.Configure(ini.Empty(), Options{Samples: -1}); err != nil {
+		t.Fatalf("Configure() = %v, expected the zero options to be valid", err)
+	}
+	if e.options.MetricsSource != METRICS_SERVER || e.options.SizingMode != calculator.SIZING_MAX || e.options.SidecarMode != calculator.SIDECARS_INCLUDE || e.options.Samples != 1 {
+		t.Fatalf("Configure() = %+v, expected metrics-server, max sizing, sidecars included and 1 sample", e.options)
+	}
+
+	// A failed configuration keeps the previous one
+	if err := e.Configure(ini.Empty(), Options{SidecarMode: "hide"}); err == nil || !strings.Contains(err.Error(), "sidecar mode") {
+		t.Fatalf("Configure(SidecarMode: hide) = %v, expected an unsupported sidecar mode", err)
+	}
+	if e.options.SidecarMode != calculator.SIDECARS_INCLUDE {
+		t.Fatalf("Configure() with an invalid sidecar mode changed the options to %+v", e.options)
+	}
+}
+
+func TestSampleWorkloadsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	// The fake clientset doesn't watch the context, the API server would fail the call
+	client.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return ctx.Err() != nil, nil, ctx.Err()
+	})
+	service := &calculator.PricingService{Config: ini.Empty()}
+	service.SetClientsets(client, metricsfake.NewSimpleClientset())
+
+	// Cancelled while pricing the first of 3 samples an hour apart, the wait for the second is cut short
+	e := New()
+	e.Configure(ini.Empty(), Options{Samples: 3, SampleInterval: time.Hour, Progress: func(step string) {
+		if strings.HasPrefix(step, "Reading pod metrics") {
+			cancel()
+		}
+	}})
+
+	report := &Report{Name: "prod", PricingService: service}
+	if err := e.sampleWorkloads(ctx, report, client); err != nil {
+		t.Fatalf("sampleWorkloads() = %v, expected a partial report", err)
+	}
+	if !report.Partial || len(report.Samples) != 1 || len(report.Nodes) != 1 {
+		t.Fatalf("sampleWorkloads() = partial %v with %d samples and %d nodes, expected a partial report of the first sample", report.Partial, len(report.Samples), len(report.Nodes))
+	}
+
+	// Without a sample there is nothing to report
+	report = &Report{Name: "prod", PricingService: service}
+	if err := e.sampleWorkloads(ctx, report, client); err == nil || report.Partial {
+		t.Fatalf("sampleWorkloads() = %v, partial %v, expected cancelling before the first sample to fail", err, report.Partial)
+	}
+}
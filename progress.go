@@ -0,0 +1,118 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"github.com/charmbracelet/bubbles/spinner"
+	"golang.org/x/term"
+)
+
+// progressIndicator shows a spinner with the current step of the run and the number of API calls
+// so far on stderr, so paging through the billing catalog or a big cluster gives feedback.
+type progressIndicator struct {
+	mutex   sync.Mutex
+	output  io.Writer
+	step    string
+	running bool
+	done    chan struct{}
+}
+
+var progress = &progressIndicator{output: os.Stderr}
+
+// Start draws the spinner until Stop. It does nothing if stderr is not a terminal, eg. in CI logs, or with -quiet.
+func (p *progressIndicator) Start() {
+	if !term.IsTerminal(int(os.Stderr.Fd())) || plainOutput || !logging.Enabled(logging.LevelInfo) {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.running {
+		return
+	}
+	p.running = true
+	p.done = make(chan struct{})
+
+	// Log messages clear the spinner line before they are written
+	log.SetOutput(p)
+
+	go p.spin(p.done)
+}
+
+func (p *progressIndicator) spin(done chan struct{}) {
+	frames := spinner.MiniDot.Frames
+	ticker := time.NewTicker(spinner.MiniDot.FPS)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		calls := 0
+		for _, count := range usage.GetReport().Calls {
+			calls += count
+		}
+
+		p.mutex.Lock()
+		if p.step != "" {
+			fmt.Fprintf(p.output, "\r\033[K%s %s (%d API calls)", frames[frame%len(frames)], p.step, calls)
+		}
+		p.mutex.Unlock()
+	}
+}
+
+// Step sets the step of the run the spinner shows, eg. "Fetching prices".
+func (p *progressIndicator) Step(step string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.step = step
+}
+
+// Stop clears the spinner, so the report starts on an empty line.
+func (p *progressIndicator) Stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.running {
+		return
+	}
+
+	close(p.done)
+	p.running = false
+	p.step = ""
+	fmt.Fprint(p.output, "\r\033[K")
+	log.SetOutput(p.output)
+}
+
+// Write clears the spinner line before a log message.
+func (p *progressIndicator) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	fmt.Fprint(p.output, "\r\033[K")
+	return p.output.Write(data)
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+)
+
+//go:embed templates/report.html
+var htmlReportTemplate string
+
+// htmlReport is the data of the HTML report, one section per cluster.
+type htmlReport struct {
+	Generated time.Time
+	Currency  string
+	Symbol    string
+	Clusters  []htmlCluster
+}
+
+type htmlCluster struct {
+	Name    string
+	Project string
+	Region  string
+	Status  string
+	Version string
+	// PricingRegion is set when the cluster was priced as if it ran in another region
+	PricingRegion string
+
+	Nodes      []cluster.Node
+	Namespaces []cluster.NamespaceCost
+	Comparison calculator.StandardComparison
+	Warnings   []cluster.Warning
+
+	// Autopilot cost of the cluster including the cluster fee, on-demand and with commit discounts
+	Total     cluster.Money
+	OneYear   cluster.Money
+	ThreeYear cluster.Money
+}
+
+// writeHtmlReport writes a self-contained HTML file with the estimate of every cluster,
+// eg. to attach it to a migration proposal.
+func writeHtmlReport(path string, reports []*clusterReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return renderHtmlReport(file, reports)
+}
+
+func renderHtmlReport(w io.Writer, reports []*clusterReport) error {
+	report := htmlReport{
+		Generated: time.Now(),
+		Currency:  cluster.DisplayCurrency,
+		Symbol:    cluster.CurrencySymbol(),
+	}
+
+	for _, clusterReport := range reports {
+		var totalCost cluster.Money
+		for _, workload := range clusterReport.workloads {
+			totalCost += workload.Cost
+		}
+
+		report.Clusters = append(report.Clusters, htmlCluster{
+			Name:          clusterReport.Name,
+			Project:       clusterReport.Project,
+			Region:        clusterReport.Region,
+			Status:        clusterReport.Status,
+			Version:       clusterReport.Version,
+			PricingRegion: clusterReport.pricingRegion,
+			Nodes:         cluster.SortedNodes(clusterReport.nodes),
+			Namespaces:    cluster.GetNamespaceCosts(clusterReport.nodes),
+			Comparison:    clusterReport.comparison,
+			Warnings:      cluster.CollectWarnings(clusterReport.workloads),
+			Total:         totalCost + clusterReport.clusterFee,
+			OneYear:       clusterReport.pricingService.GetCommittedCost(clusterReport.nodes, calculator.CommitOneYear) + clusterReport.clusterFee,
+			ThreeYear:     clusterReport.pricingService.GetCommittedCost(clusterReport.nodes, calculator.CommitThreeYear) + clusterReport.clusterFee,
+		})
+	}
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"monthly": func(amount cluster.Money) cluster.Money {
+			return amount.Mul(calculator.HOURS_PER_MONTH)
+		},
+		"computeClass": func(class cluster.ComputeClass) string {
+			return cluster.ComputeClasses[class]
+		},
+		"join": strings.Join,
+	}).Parse(htmlReportTemplate)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.Execute(w, report)
+}
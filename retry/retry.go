@@ -0,0 +1,193 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry retries the calls to Cloud and Kubernetes APIs that fail with transient
+// errors, eg. an exhausted quota or an overloaded server, and limits the rate of calls,
+// so paging through the billing catalog or a big cluster doesn't fail a whole run.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+	"golang.org/x/oauth2/google"
+	"golang.org/x/time/rate"
+)
+
+const (
+	MAX_ATTEMPTS = 5
+	BASE_DELAY   = 500 * time.Millisecond
+	MAX_DELAY    = 30 * time.Second
+)
+
+// Calls per second of the Google APIs, below their default quotas. The Kubernetes clients have their own limit.
+var rateLimits = map[usage.API]rate.Limit{
+	usage.Billing: 10,
+	usage.GKE:     10,
+}
+
+var (
+	limitersMutex sync.Mutex
+	limiters      = make(map[usage.API]*rate.Limiter)
+)
+
+// getLimiter returns the limiter shared by all clients of the API, nil if the API is not limited.
+func getLimiter(api usage.API) *rate.Limiter {
+	limit, ok := rateLimits[api]
+	if !ok {
+		return nil
+	}
+
+	limitersMutex.Lock()
+	defer limitersMutex.Unlock()
+
+	if limiters[api] == nil {
+		limiters[api] = rate.NewLimiter(limit, int(limit))
+	}
+	return limiters[api]
+}
+
+// Retryable checks if the call can be repeated: it failed on the network, was throttled (429) or hit a
+// server error (5xx), and didn't run out of time.
+func Retryable(ctx context.Context, response *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Backoff returns the delay before the next attempt: it doubles with every attempt up to MAX_DELAY, with
+// jitter so parallel clients don't retry in lockstep. A Retry-After of the server is the lower bound.
+func Backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := MAX_DELAY
+	if attempt < 16 {
+		delay = BASE_DELAY << (attempt - 1)
+	}
+	if delay > MAX_DELAY {
+		delay = MAX_DELAY
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+
+	if retryAfter > delay {
+		return retryAfter
+	}
+	return delay
+}
+
+// getRetryAfter reads the Retry-After header in seconds, 0 if there is none.
+func getRetryAfter(response *http.Response) time.Duration {
+	if response == nil {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+type retryingTransport struct {
+	api       usage.API
+	limiter   *rate.Limiter
+	transport http.RoundTripper
+}
+
+func (t retryingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+
+	// A request body can only be sent again if it can be recreated
+	replayable := request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		if t.limiter != nil {
+			if err := t.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		attemptRequest := request
+		if attempt > 1 {
+			attemptRequest = request.Clone(ctx)
+			if request.GetBody != nil {
+				body, err := request.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptRequest.Body = body
+			}
+		}
+
+		response, err := t.transport.RoundTrip(attemptRequest)
+		if attempt == MAX_ATTEMPTS || !replayable || !Retryable(ctx, response, err) {
+			return response, err
+		}
+
+		delay := Backoff(attempt, getRetryAfter(response))
+		// Give up right away if the deadline passes before the next attempt
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return response, err
+		}
+
+		reason := fmt.Sprint(err)
+		if response != nil {
+			reason = response.Status
+			response.Body.Close()
+		}
+		logging.Debug("Retrying %s call %s %s in %v after %s", t.api, request.Method, request.URL.Path, delay.Round(time.Millisecond), reason)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Transport wraps an HTTP transport to retry the transient failures of calls to the API and limit their
+// rate, eg. for the WrapTransport of a Kubernetes client config.
+func Transport(api usage.API) func(http.RoundTripper) http.RoundTripper {
+	return func(transport http.RoundTripper) http.RoundTripper {
+		return retryingTransport{api: api, limiter: getLimiter(api), transport: transport}
+	}
+}
+
+// HTTPClient returns a client with the application default credentials that retries the calls to the
+// Google API, for option.WithHTTPClient.
+func HTTPClient(ctx context.Context, api usage.API, scopes ...string) (*http.Client, error) {
+	client, err := google.DefaultClient(ctx, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting default credentials: %v", err)
+	}
+
+	client.Transport = Transport(api)(client.Transport)
+	return client, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
+)
+
+func TestRetryTransport(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if calls == 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(usage.Kubernetes)(http.DefaultTransport)}
+	response, err := client.Get(server.URL)
+	if err != nil || response.StatusCode != http.StatusOK || calls != 3 {
+		t.Fatalf("Get() = %v, %v after %d calls, expected 200 after 3 calls", response, err, calls)
+	}
+	response.Body.Close()
+
+	// Client errors are not retried
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+	})
+	calls = 0
+	response, err = client.Get(server.URL)
+	if err != nil || response.StatusCode != http.StatusForbidden || calls != 1 {
+		t.Fatalf("Get() = %v, %v after %d calls, expected 403 after 1 call", response, err, calls)
+	}
+	response.Body.Close()
+
+	for attempt := 1; attempt < 20; attempt++ {
+		if delay := Backoff(attempt, 0); delay < BASE_DELAY/2 || delay > MAX_DELAY {
+			t.Errorf("Backoff(%d) = %v is out of range", attempt, delay)
+		}
+	}
+	if delay := Backoff(1, time.Minute); delay != time.Minute {
+		t.Errorf("Backoff(1, 1m) = %v doesn't honor Retry-After", delay)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		status   int
+		err      error
+		expected bool
+	}{
+		{context.Background(), 0, errors.New("connection reset"), true},
+		{context.Background(), http.StatusTooManyRequests, nil, true},
+		{context.Background(), http.StatusBadGateway, nil, true},
+		{context.Background(), http.StatusNotFound, nil, false},
+		{context.Background(), http.StatusOK, nil, false},
+		// Out of time, nothing is retried
+		{cancelled, http.StatusServiceUnavailable, nil, false},
+	}
+	for _, test := range tests {
+		var response *http.Response
+		if test.err == nil {
+			response = &http.Response{StatusCode: test.status}
+		}
+		if got := Retryable(test.ctx, response, test.err); got != test.expected {
+			t.Errorf("Retryable(%d, %v) = %v, expected %v", test.status, test.err, got, test.expected)
+		}
+	}
+}
+
+func TestRetryBody(t *testing.T) {
+	calls := 0
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body := make([]byte, 16)
+		n, _ := r.Body.Read(body)
+		bodies = append(bodies, string(body[:n]))
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(usage.Kubernetes)(http.DefaultTransport)}
+
+	// The body is sent again with the retry
+	response, err := client.Post(server.URL, "text/plain", strings.NewReader("rows"))
+	if err != nil || response.StatusCode != http.StatusOK || strings.Join(bodies, ",") != "rows,rows" {
+		t.Fatalf("Post() = %v, %v with bodies %q, expected 200 after sending the body twice", response, err, bodies)
+	}
+	response.Body.Close()
+
+	// A body that can't be recreated isn't retried
+	calls, bodies = 0, nil
+	request, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("rows"))
+	request.GetBody = nil
+	response, err = client.Do(request)
+	if err != nil || response.StatusCode != http.StatusServiceUnavailable || calls != 1 {
+		t.Fatalf("Do() = %v, %v after %d calls, expected 503 after 1 call", response, err, calls)
+	}
+	response.Body.Close()
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{"", 0},
+		{"-1", 0},
+		// HTTP dates are not used by the Google APIs
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, test := range tests {
+		response := &http.Response{Header: http.Header{"Retry-After": []string{test.header}}}
+		if got := getRetryAfter(response); got != test.expected {
+			t.Errorf("getRetryAfter(%q) = %v, expected %v", test.header, got, test.expected)
+		}
+	}
+	if got := getRetryAfter(nil); got != 0 {
+		t.Errorf("getRetryAfter(nil) = %v, expected 0", got)
+	}
+
+	// Only the Google APIs are limited, and all their clients share the limiter
+	if getLimiter(usage.Kubernetes) != nil || getLimiter(usage.Billing) == nil || getLimiter(usage.Billing) != getLimiter(usage.Billing) {
+		t.Errorf("getLimiter() doesn't limit the Google APIs only")
+	}
+}
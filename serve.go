@@ -0,0 +1,290 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"gopkg.in/ini.v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// apiError is the body of a failed API request.
+type apiError struct {
+	Error string
+}
+
+// estimateServer answers the estimate API with the same JSON report as the -json flag.
+type estimateServer struct {
+	cfg        *ini.File
+	namespaces cluster.NamespaceFilter
+	selector   string
+	// contexts are the kubeconfig contexts the context query parameter may select
+	contexts []string
+
+	// Estimates run one at a time, the kubeconfig flags and API usage counters are shared by the process
+	mutex sync.Mutex
+	// latest is the last estimate of every context, exported on /metrics
+	latest      map[string]*clusterReport
+	latestMutex sync.Mutex
+	// warmUp estimates the current context once in the background if /metrics is scraped before any estimate
+	warmUp sync.Once
+}
+
+// RunServe serves the estimate of the clusters of the kubeconfig over HTTP, eg. `serve -addr :8080`.
+// The API has no authentication, so it only listens on localhost unless -addr says otherwise.
+func RunServe(cfg *ini.File, args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addrFlag := flags.String("addr", "localhost:8080", "Address the API listens on, eg. :8080 for all interfaces. The API has no authentication, anyone who can reach it can estimate the clusters of the kubeconfig")
+	contextsFlag := flags.String("contexts", "", "Comma separated kubeconfig contexts the context query parameter may select, without it only the -context or current context is estimated")
+	namespaceFlag, selectorFlag := addKubectlFlags(flags)
+	addConfigFlags(flags, cfg)
+	logging.AddFlags(flags)
+	flags.Parse(args)
+
+	if err := checkConfig(cfg); err != nil {
+		return err
+	}
+
+	server := &estimateServer{cfg: cfg, namespaces: *namespaceFlag, selector: *selectorFlag}
+	if *contextsFlag != "" {
+		server.contexts = strings.Split(*contextsFlag, ",")
+	}
+
+	logging.Info("Serving the estimate API on %s.", *addrFlag)
+	return http.ListenAndServe(*addrFlag, server.handler())
+}
+
+func (server *estimateServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/estimate", server.handleEstimate)
+	mux.HandleFunc("/metrics", server.handleMetrics)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	return mux
+}
+
+// handleEstimate estimates the cluster of the context query parameter, or of the current context.
+func (server *estimateServer) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{Error: fmt.Sprintf("method %s is not allowed, use GET", r.Method)})
+		return
+	}
+
+	query := r.URL.Query()
+	options, err := parseServeOptions(server.cfg, query, server.namespaces, server.selector)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+
+	contextName := query.Get("context")
+	if !server.allowContext(contextName) {
+		writeJSON(w, http.StatusForbidden, apiError{Error: fmt.Sprintf("context %q is not allowed, serve it with -contexts", contextName)})
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	report, err := estimateCluster(r.Context(), server.cfg, contextName, options)
+	if r.Context().Err() != nil {
+		// The client is gone, the partial estimate is neither sent nor kept for /metrics
+		return
+	}
+	if err != nil {
+		logging.Error("Error estimating context %q: %v", contextName, err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+		return
+	}
+	server.remember(report)
+
+	writeJSON(w, http.StatusOK, report.jsonReport(options))
+}
+
+// allowContext reports if a request may estimate the context, the current context always can.
+func (server *estimateServer) allowContext(contextName string) bool {
+	if contextName == "" {
+		return true
+	}
+	for _, allowed := range server.contexts {
+		if contextName == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleMetrics exports the latest estimate of every context for Prometheus. Before any estimate
+// was requested, the current context is estimated in the background and the scrape gets no samples.
+func (server *estimateServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	server.latestMutex.Lock()
+	names := make([]string, 0, len(server.latest))
+	for name := range server.latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reports := make([]*clusterReport, 0, len(names))
+	for _, name := range names {
+		reports = append(reports, server.latest[name])
+	}
+	server.latestMutex.Unlock()
+
+	if len(reports) == 0 {
+		server.warmUp.Do(func() { go server.estimateCurrentContext() })
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, reports)
+}
+
+// estimateCurrentContext estimates the current context for /metrics, waiting for the estimates in flight.
+func (server *estimateServer) estimateCurrentContext() {
+	options, _ := parseServeOptions(server.cfg, url.Values{}, server.namespaces, server.selector)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	report, err := estimateCluster(context.Background(), server.cfg, "", options)
+	if err != nil {
+		logging.Error("Error estimating the current context: %v", err)
+		return
+	}
+	server.remember(report)
+}
+
+func (server *estimateServer) remember(report *clusterReport) {
+	server.latestMutex.Lock()
+	defer server.latestMutex.Unlock()
+
+	if server.latest == nil {
+		server.latest = make(map[string]*clusterReport)
+	}
+	server.latest[report.Context] = report
+}
+
+// parseServeOptions reads the run options of an API request from its query parameters,
+// which are named like the flags, eg. sizing-mode=requests&group-by-owner=true.
+func parseServeOptions(cfg *ini.File, query url.Values, namespaces cluster.NamespaceFilter, selector string) (runOptions, error) {
+	options := runOptions{
+		spotOverhead:      -1,
+		samples:           1,
+		sampleInterval:    time.Minute,
+		freeTier:          cfg.Section("fees").Key("free_tier").MustBool(false),
+		metricsSource:     "metrics-server",
+		sizingMode:        calculator.SIZING_MAX,
+		sidecarMode:       calculator.SIDECARS_INCLUDE,
+		metricsPercentile: 95,
+		groupByLabel:      query.Get("group-by-label"),
+		region:            query.Get("region"),
+		targetRegion:      query.Get("target-region"),
+		namespaces:        namespaces,
+		selector:          selector,
+	}
+
+	if value := query.Get("selector"); value != "" {
+		if _, err := labels.Parse(value); err != nil {
+			return options, fmt.Errorf("invalid label selector %q: %v", value, err)
+		}
+		options.selector = value
+	}
+
+	// Namespaces of the request replace the included ones of the server and add to the excluded ones
+	for _, pattern := range append(query["namespace"], query["exclude-namespace"]...) {
+		if err := cluster.ValidateNamespacePattern(pattern); err != nil {
+			return options, err
+		}
+	}
+	if values := query["namespace"]; len(values) > 0 {
+		options.namespaces.Include = values
+	}
+	if values := query["exclude-namespace"]; len(values) > 0 {
+		options.namespaces.Exclude = append(append([]string{}, namespaces.Exclude...), values...)
+	}
+
+	if value := query.Get("sizing-mode"); value != "" {
+		switch value {
+		case calculator.SIZING_REQUESTS, calculator.SIZING_USAGE, calculator.SIZING_MAX:
+			options.sizingMode = value
+		default:
+			return options, fmt.Errorf("unsupported sizing mode %q, use requests, usage or max", value)
+		}
+	}
+
+	if value := query.Get("sidecars"); value != "" {
+		switch value {
+		case calculator.SIDECARS_INCLUDE, calculator.SIDECARS_EXCLUDE, calculator.SIDECARS_SEPARATE:
+			options.sidecarMode = value
+		default:
+			return options, fmt.Errorf("unsupported sidecar mode %q, use include, exclude or separate", value)
+		}
+	}
+
+	flags := map[string]*bool{
+		"all-spot":          &options.allSpot,
+		"amortize-fee":      &options.amortizeFee,
+		"include-completed": &options.includeCompleted,
+		"group-by-owner":    &options.groupByOwner,
+		"hpa-max":           &options.hpaMax,
+		"prorate-jobs":      &options.prorateJobs,
+		"load-balancers":    &options.loadBalancers,
+		"recommend-classes": &options.recommendClasses,
+		"include-system":    &options.namespaces.IncludeSystem,
+		"skip-gke-check":    &options.skipGKECheck,
+	}
+	for name, flag := range flags {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return options, fmt.Errorf("error parsing %s: %v", name, err)
+		}
+		*flag = parsed
+	}
+
+	return options, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, document interface{}) {
+	contents, err := json.MarshalIndent(document, "", "    ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(contents)
+}
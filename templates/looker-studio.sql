@@ -0,0 +1,28 @@
+-- Custom query of the Looker Studio data source over the table of -export-bigquery.
+-- One row per workload per run, with the latest run of every cluster flagged so
+-- scorecards and tables show the current cost while time series use every run.
+SELECT
+  run_time,
+  DATE(run_time) AS run_date,
+  run_time = MAX(run_time) OVER (PARTITION BY project, cluster, region) AS latest_run,
+  project,
+  cluster,
+  region,
+  namespace,
+  workload,
+  owner_kind,
+  owner_name,
+  node,
+  spot,
+  compute_class,
+  mcpu,
+  memory_mib,
+  storage_mib,
+  accelerator_type,
+  accelerator_count,
+  hourly_cost,
+  effective_hourly_cost,
+  monthly_cost,
+  labels,
+  warnings
+FROM `{{.Table}}`
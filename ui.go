@@ -17,9 +17,15 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/calculator"
 	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/cluster"
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/usage"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -27,12 +33,37 @@ import (
 
 var (
 	baseStyle      = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderForeground(lipgloss.Color("240"))
-	pinkTextStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("225")).Background(lipgloss.Color("128"))
-	blueTextStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("225")).Background(lipgloss.Color("32"))
-	redTextStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("225")).Background(lipgloss.Color("160"))
-	greenTextStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("25")).Background(lipgloss.Color("192"))
+	pinkTextStyle  = textStyle{lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("225")).Background(lipgloss.Color("128")), "## %s"}
+	blueTextStyle  = textStyle{lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("225")).Background(lipgloss.Color("32")), "**%s**"}
+	redTextStyle   = textStyle{lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("225")).Background(lipgloss.Color("160")), "> %s"}
+	greenTextStyle = textStyle{lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("25")).Background(lipgloss.Color("192")), "**%s**"}
 )
 
+// markdownOutput renders tables as GitHub-flavored Markdown and text without colors,
+// so the output can be pasted into issues, wikis or PR descriptions.
+var markdownOutput bool
+
+// plainOutput renders tables as ASCII and text without colors, so the output stays readable when
+// it is piped to a file or shown in CI logs. It is enabled by -plain or the NO_COLOR environment variable.
+var plainOutput = os.Getenv("NO_COLOR") != ""
+
+// textStyle is a terminal style together with its Markdown equivalent.
+type textStyle struct {
+	style    lipgloss.Style
+	markdown string
+}
+
+func (s textStyle) Render(text string) string {
+	if plainOutput && !markdownOutput {
+		return text
+	}
+	if markdownOutput {
+		// Markdown needs a blank line to end a paragraph
+		return fmt.Sprintf(s.markdown, text) + "\n"
+	}
+	return s.style.Render(text)
+}
+
 type tableModel struct {
 	table table.Model
 }
@@ -48,18 +79,84 @@ func (m tableModel) View() string {
 	return baseStyle.Render(m.table.View()) + "\n"
 }
 
-func DisplayNodeTable(nodes map[string]cluster.Node) {
-	columns := []table.Column{
-		{Title: "Name", Width: 55},
-		{Title: "Type", Width: 15},
-		{Title: "Region", Width: 20},
-		{Title: "Accelerator", Width: 25},
-		{Title: "Spot?", Width: 10},
+// renderMarkdownTable prints the table as a GitHub-flavored Markdown table.
+func renderMarkdownTable(columns []table.Column, rows []table.Row) {
+	escape := strings.NewReplacer("|", "\\|")
+
+	titles := make([]string, len(columns))
+	separators := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = escape.Replace(column.Title)
+		separators[i] = "---"
 	}
 
-	var rows []table.Row
-	for _, node := range nodes {
-		rows = append(rows, table.Row{node.Name, node.InstanceType, node.Region, node.Accelerator, strconv.FormatBool(node.Spot)})
+	fmt.Printf("| %s |\n", strings.Join(titles, " | "))
+	fmt.Printf("| %s |\n", strings.Join(separators, " | "))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = escape.Replace(cell)
+		}
+		fmt.Printf("| %s |\n", strings.Join(cells, " | "))
+	}
+	fmt.Println()
+}
+
+// renderPlainTable prints the table with ASCII borders, the columns are as wide as their longest cell.
+func renderPlainTable(columns []table.Column, rows []table.Row) {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column.Title)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(widths) && utf8.RuneCountInString(cell) > widths[i] {
+				widths[i] = utf8.RuneCountInString(cell)
+			}
+		}
+	}
+
+	separators := make([]string, len(columns))
+	for i, width := range widths {
+		separators[i] = strings.Repeat("-", width+2)
+	}
+	separator := "+" + strings.Join(separators, "+") + "+"
+
+	printRow := func(cells []string) {
+		padded := make([]string, len(columns))
+		for i := range columns {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			padded[i] = " " + cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)) + " "
+		}
+		fmt.Printf("|%s|\n", strings.Join(padded, "|"))
+	}
+
+	titles := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = column.Title
+	}
+
+	fmt.Println(separator)
+	printRow(titles)
+	fmt.Println(separator)
+	for _, row := range rows {
+		printRow(row)
+	}
+	fmt.Println(separator)
+}
+
+// renderTable draws the table once and returns right away.
+func renderTable(columns []table.Column, rows []table.Row) {
+	if markdownOutput {
+		renderMarkdownTable(columns, rows)
+		return
+	}
+	if plainOutput {
+		renderPlainTable(columns, rows)
+		return
 	}
 
 	tbl := table.New(
@@ -89,75 +186,749 @@ func DisplayNodeTable(nodes map[string]cluster.Node) {
 	}
 }
 
-func DisplayWorkloadTable(nodes map[string]cluster.Node, oneYearDiscount float64, threeYearDiscount float64, clusterFee float64) {
+// summaryRow builds a row with the label in the first column and the values
+// right aligned to the last columns.
+func summaryRow(columns []table.Column, label string, values ...string) table.Row {
+	row := make(table.Row, len(columns))
+	row[0] = label
+	copy(row[len(columns)-len(values):], values)
+
+	return row
+}
+
+// priceUnit is the column unit of prices in the display currency, eg. $/H.
+func priceUnit(period string) string {
+	return cluster.CurrencySymbol() + "/" + period
+}
+
+// perHour formats an hourly amount with its unit, eg. 0.1234 $/h.
+func perHour(amount cluster.Money) string {
+	return fmt.Sprintf("%s %s/h", amount, cluster.CurrencySymbol())
+}
+
+// perMonth formats a monthly amount with its unit, eg. 90.0820 $/month.
+func perMonth(amount cluster.Money) string {
+	return fmt.Sprintf("%s %s/month", amount, cluster.CurrencySymbol())
+}
+
+// projection is a period hourly prices are projected to, eg. a 730 hour month.
+type projection struct {
+	Period string
+	Unit   string
+	Hours  float64
+}
+
+var availableProjections = []projection{
+	{Period: "day", Unit: "Day", Hours: calculator.HOURS_PER_DAY},
+	{Period: "month", Unit: "Month", Hours: calculator.HOURS_PER_MONTH},
+	{Period: "year", Unit: "Year", Hours: calculator.HOURS_PER_YEAR},
+}
+
+// projections are the periods shown next to the hourly prices, selected with -projection.
+var projections []projection
+
+// timeBasis is the period of the prices of the tables and the report, by the hour unless selected with -time-basis.
+var timeBasis = projection{Period: "hour", Unit: "H", Hours: 1}
+
+// parseTimeBasis selects the time basis of hour, day or month.
+func parseTimeBasis(value string) (projection, error) {
+	if value == "hour" {
+		return projection{Period: "hour", Unit: "H", Hours: 1}, nil
+	}
+	for _, available := range availableProjections {
+		if available.Period == value && available.Period != "year" {
+			return available, nil
+		}
+	}
+
+	return projection{}, fmt.Errorf("unsupported time basis %q, use hour, day or month", value)
+}
+
+// inBasis formats an hourly amount in the time basis, eg. the monthly price with -time-basis month.
+func inBasis(amount cluster.Money) string {
+	return amount.Mul(timeBasis.Hours).String()
+}
+
+// perPeriod formats an hourly amount in the time basis with its unit, eg. 0.1234 $/h or 90.0820 $/month.
+func perPeriod(amount cluster.Money) string {
+	if timeBasis.Hours == 1 {
+		return perHour(amount)
+	}
+	return fmt.Sprintf("%s %s/%s", amount.Mul(timeBasis.Hours), cluster.CurrencySymbol(), timeBasis.Period)
+}
+
+// parseProjections selects the projections of a comma separated list of periods, eg. month,year.
+func parseProjections(value string) ([]projection, error) {
+	selected := []projection{}
+	for _, period := range strings.Split(value, ",") {
+		period = strings.TrimSpace(period)
+		if period == "" || period == "hour" {
+			continue
+		}
+
+		found := false
+		for _, available := range availableProjections {
+			if available.Period == period {
+				selected = append(selected, available)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unsupported projection %q, use day, month or year", period)
+		}
+	}
+
+	return selected, nil
+}
+
+// projectionColumns returns a column per selected projection, eg. Price $/Month.
+func projectionColumns(title string) []table.Column {
+	columns := []table.Column{}
+	for _, projection := range projections {
+		if projection.Period == timeBasis.Period {
+			continue
+		}
+		columns = append(columns, table.Column{Title: title + " " + priceUnit(projection.Unit), Width: 14})
+	}
+	return columns
+}
+
+// projectedValues returns the hourly amount projected to every selected period.
+func projectedValues(amount cluster.Money) []string {
+	values := []string{}
+	for _, projection := range projections {
+		if projection.Period == timeBasis.Period {
+			continue
+		}
+		values = append(values, amount.Mul(projection.Hours).String())
+	}
+	return values
+}
+
+func DisplayNodeTable(nodes map[string]cluster.Node) {
+	columns := []table.Column{
+		{Title: "Name", Width: 55},
+		{Title: "Type", Width: 15},
+		{Title: "Region", Width: 20},
+		{Title: "Accelerator", Width: 25},
+		{Title: "Spot?", Width: 10},
+		{Title: "Standard " + priceUnit(timeBasis.Unit), Width: 12},
+	}
+
+	var rows []table.Row
+	for _, node := range cluster.SortedNodes(nodes) {
+		rows = append(rows, table.Row{node.Name, node.InstanceType, node.Region, node.Accelerator, strconv.FormatBool(node.Spot), inBasis(node.StandardCost)})
+	}
+
+	renderTable(columns, rows)
+}
+
+// nodeWorkload is a workload with the node it runs on.
+type nodeWorkload struct {
+	Node     cluster.Node
+	Workload cluster.Workload
+}
+
+// sortWorkloads lists the workloads of all nodes in the order, by node they run on by default.
+func sortWorkloads(nodes map[string]cluster.Node, order workloadOrder) []nodeWorkload {
+	workloads := []nodeWorkload{}
+	for _, node := range cluster.SortedNodes(nodes) {
+		for _, workload := range node.Workloads {
+			workloads = append(workloads, nodeWorkload{Node: node, Workload: workload})
+		}
+	}
+
+	var less func(a, b nodeWorkload) bool
+	switch order.Field {
+	case "namespace":
+		less = func(a, b nodeWorkload) bool { return a.Workload.Namespace < b.Workload.Namespace }
+	case "name":
+		less = func(a, b nodeWorkload) bool { return a.Workload.Name < b.Workload.Name }
+	case "cost":
+		less = func(a, b nodeWorkload) bool { return a.Workload.Cost < b.Workload.Cost }
+	case "mcpu":
+		less = func(a, b nodeWorkload) bool { return a.Workload.Cpu < b.Workload.Cpu }
+	case "memory":
+		less = func(a, b nodeWorkload) bool { return a.Workload.Memory < b.Workload.Memory }
+	default:
+		if order.Descending {
+			for i, j := 0, len(workloads)-1; i < j; i, j = i+1, j-1 {
+				workloads[i], workloads[j] = workloads[j], workloads[i]
+			}
+		}
+		return workloads
+	}
+
+	// Workloads that are equal in the field keep their order by node
+	sort.SliceStable(workloads, func(i, j int) bool {
+		if order.Descending {
+			return less(workloads[j], workloads[i])
+		}
+		return less(workloads[i], workloads[j])
+	})
+
+	return workloads
+}
+
+// topWorkloads splits the workloads into the top costliest ones and the others, both in their order.
+// With top 0 all workloads are kept.
+func topWorkloads(workloads []nodeWorkload, top int) ([]nodeWorkload, []nodeWorkload) {
+	if top <= 0 || len(workloads) <= top {
+		return workloads, nil
+	}
+
+	indexes := make([]int, len(workloads))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return workloads[indexes[i]].Workload.Cost > workloads[indexes[j]].Workload.Cost
+	})
+
+	kept := make(map[int]bool, top)
+	for _, index := range indexes[:top] {
+		kept[index] = true
+	}
+
+	var costliest, others []nodeWorkload
+	for i, workload := range workloads {
+		if kept[i] {
+			costliest = append(costliest, workload)
+		} else {
+			others = append(others, workload)
+		}
+	}
+
+	return costliest, others
+}
+
+func DisplayWorkloadTable(nodes map[string]cluster.Node, order workloadOrder, top int, oneYearCost cluster.Money, threeYearCost cluster.Money, clusterFee cluster.Money, amortized bool) {
 	columns := []table.Column{
 		{Title: "Node", Width: 55},
 		{Title: "Workload", Width: 40},
 		{Title: "Containers", Width: 10},
 		{Title: "Spot", Width: 10},
+		{Title: "Burstable", Width: 10},
 		{Title: "mCPU", Width: 10},
 		{Title: "Memory MiB", Width: 10},
 		{Title: "Storage MiB", Width: 12},
 		{Title: "Compute Class", Width: 13},
-		{Title: "Price $/H", Width: 10},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+	}
+	if amortized {
+		columns = append(columns, table.Column{Title: "Effective " + priceUnit(timeBasis.Unit), Width: 13})
 	}
+	columns = append(columns, projectionColumns("Price")...)
 
 	var rows []table.Row
-	totalCost := 0.0 // Cluster fee is fixed amount
-	totalCostSpot := 0.0
+	var totalCost cluster.Money // Cluster fee is fixed amount
 
-	for _, node := range nodes {
-		for _, workload := range node.Workloads {
-			// Nodes on spot don't amount for 1 or 3 year commit discounts
-			if node.Spot {
-				totalCostSpot += workload.Cost
-			} else {
-				totalCost += workload.Cost
-			}
-			rows = append(rows,
-				table.Row{
-					node.Name,
-					workload.Name,
-					strconv.Itoa(workload.Containers),
-					strconv.FormatBool(node.Spot),
-					strconv.FormatInt(workload.Cpu, 10),
-					strconv.FormatInt(workload.Memory, 10),
-					strconv.FormatInt(workload.Storage, 10),
-					cluster.ComputeClasses[workload.ComputeClass],
-					strconv.FormatFloat(workload.Cost, 'G', 7, 64),
-				},
-			)
-		}
-	}
-
-	rows = append(rows, table.Row{"Total cost per cluster per hour", "", "", "", "", "", "", "", strconv.FormatFloat(totalCost+clusterFee, 'G', 7, 64)})
-	rows = append(rows, table.Row{"... 1 year commit", "", "", "", "", "", "", "", strconv.FormatFloat((totalCostSpot+totalCost*oneYearDiscount)+clusterFee, 'G', 7, 64)})
-	rows = append(rows, table.Row{"... with 3 year commit", "", "", "", "", "", "", "", strconv.FormatFloat((totalCostSpot+totalCost*threeYearDiscount)+clusterFee, 'G', 7, 64)})
+	workloads, others := topWorkloads(sortWorkloads(nodes, order), top)
+	for _, nodeWorkload := range workloads {
+		node, workload := nodeWorkload.Node, nodeWorkload.Workload
+		totalCost += workload.Cost
+		name := workload.Name
+		if workload.Sandboxed {
+			name += " [sandbox]"
+		}
 
-	tbl := table.New(
-		table.WithColumns(columns),
-		table.WithRows(rows),
-		table.WithFocused(false),
-		table.WithHeight(len(rows)),
-	)
+		row := table.Row{
+			node.Name,
+			name,
+			strconv.Itoa(workload.Containers),
+			strconv.FormatBool(node.Spot || workload.Spot),
+			strconv.FormatBool(workload.Burstable),
+			strconv.FormatInt(workload.Cpu, 10),
+			strconv.FormatInt(workload.Memory, 10),
+			strconv.FormatInt(workload.Storage, 10),
+			cluster.ComputeClasses[workload.ComputeClass],
+			inBasis(workload.Cost),
+		}
+		if amortized {
+			row = append(row, inBasis(workload.EffectiveCost))
+		}
+		row = append(row, projectedValues(workload.Cost)...)
+		rows = append(rows, row)
+	}
 
-	stl := table.DefaultStyles()
+	// The workloads beyond -top are summed up in a single row
+	if len(others) > 0 {
+		var containers int
+		var cpu, memory, storage int64
+		var cost, effectiveCost cluster.Money
+		for _, nodeWorkload := range others {
+			containers += nodeWorkload.Workload.Containers
+			cpu += nodeWorkload.Workload.Cpu
+			memory += nodeWorkload.Workload.Memory
+			storage += nodeWorkload.Workload.Storage
+			cost += nodeWorkload.Workload.Cost
+			effectiveCost += nodeWorkload.Workload.EffectiveCost
+		}
+		totalCost += cost
 
-	stl.Header = stl.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("255")).
-		BorderBottom(true).
-		Bold(false)
-	stl.Selected = stl.Selected.
-		Foreground(lipgloss.Color("255")).
-		//	Background(lipgloss.Color("57")).
-		Bold(false)
-	tbl.SetStyles(stl)
+		row := table.Row{
+			fmt.Sprintf("Other workloads (%d)", len(others)),
+			"",
+			strconv.Itoa(containers),
+			"",
+			"",
+			strconv.FormatInt(cpu, 10),
+			strconv.FormatInt(memory, 10),
+			strconv.FormatInt(storage, 10),
+			"",
+			inBasis(cost),
+		}
+		if amortized {
+			row = append(row, inBasis(effectiveCost))
+		}
+		row = append(row, projectedValues(cost)...)
+		rows = append(rows, row)
+	}
 
-	program := tea.NewProgram(tableModel{tbl})
-	_, err := program.Run()
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+	totalRow := func(label string, total cluster.Money) table.Row {
+		// With an amortized fee the effective total matches the regular one
+		values := []string{inBasis(total)}
+		if amortized {
+			values = append(values, inBasis(total))
+		}
+		return summaryRow(columns, label, append(values, projectedValues(total)...)...)
+	}
+
+	rows = append(rows, totalRow("Total cost per cluster per "+timeBasis.Period, totalCost+clusterFee))
+	rows = append(rows, totalRow("... 1 year commit", oneYearCost+clusterFee))
+	rows = append(rows, totalRow("... with 3 year commit", threeYearCost+clusterFee))
+
+	renderTable(columns, rows)
+}
+
+func DisplayNamespaceTable(namespaces []cluster.NamespaceCost) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 40},
+		{Title: "Workloads", Width: 10},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Effective " + priceUnit(timeBasis.Unit), Width: 13},
+	}
+	columns = append(columns, projectionColumns("Effective")...)
+
+	var rows []table.Row
+	for _, namespace := range namespaces {
+		row := table.Row{
+			namespace.Namespace,
+			strconv.Itoa(namespace.Workloads),
+			inBasis(namespace.Cost),
+			inBasis(namespace.EffectiveCost),
+		}
+		rows = append(rows, append(row, projectedValues(namespace.EffectiveCost)...))
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayLabelTable(label string, values []cluster.LabelCost, amortized bool) {
+	columns := []table.Column{
+		{Title: label, Width: 40},
+		{Title: "Workloads", Width: 10},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+	}
+	if amortized {
+		columns = append(columns, table.Column{Title: "Effective " + priceUnit(timeBasis.Unit), Width: 13})
+	}
+	columns = append(columns, projectionColumns("Price")...)
+
+	costValues := func(cost cluster.Money, effectiveCost cluster.Money) []string {
+		// With an amortized fee the projections include the share of the fee
+		if amortized {
+			return append([]string{inBasis(cost), inBasis(effectiveCost)}, projectedValues(effectiveCost)...)
+		}
+		return append([]string{inBasis(cost)}, projectedValues(cost)...)
+	}
+
+	var rows []table.Row
+	var totalCost, totalEffectiveCost cluster.Money
+	for _, value := range values {
+		totalCost += value.Cost
+		totalEffectiveCost += value.EffectiveCost
+		rows = append(rows, append(table.Row{value.Value, strconv.Itoa(value.Workloads)}, costValues(value.Cost, value.EffectiveCost)...))
+	}
+	rows = append(rows, summaryRow(columns, "Total", costValues(totalCost, totalEffectiveCost)...))
+
+	renderTable(columns, rows)
+}
+
+func DisplayLoadBalancerTable(loadBalancers []cluster.LoadBalancer) {
+	columns := []table.Column{
+		{Title: "Kind", Width: 10},
+		{Title: "Namespace", Width: 30},
+		{Title: "Name", Width: 40},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+	}
+	columns = append(columns, projectionColumns("Price")...)
+
+	var rows []table.Row
+	for _, loadBalancer := range loadBalancers {
+		row := table.Row{loadBalancer.Kind, loadBalancer.Namespace, loadBalancer.Name, inBasis(loadBalancer.Cost)}
+		rows = append(rows, append(row, projectedValues(loadBalancer.Cost)...))
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplaySpotTable(workloads []calculator.SpotWorkload) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 30},
+		{Title: "Name", Width: 40},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Spot " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Savings " + priceUnit(timeBasis.Unit), Width: 12},
+		{Title: "Spot-safe", Width: 50},
+	}
+
+	var rows []table.Row
+	for _, workload := range workloads {
+		safe := "yes"
+		if !workload.SpotSafe {
+			safe = "no: " + strings.Join(workload.Blockers, ", ")
+		}
+
+		rows = append(rows, table.Row{
+			workload.Namespace,
+			workload.Name,
+			inBasis(workload.Cost),
+			inBasis(workload.SpotCost),
+			inBasis(workload.Savings),
+			safe,
+		})
 	}
+
+	renderTable(columns, rows)
+}
+
+func DisplaySystemTable(workloads []cluster.Workload) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 20},
+		{Title: "Name", Width: 50},
+		{Title: "mCPU", Width: 10},
+		{Title: "Memory MiB", Width: 10},
+		{Title: "Storage MiB", Width: 12},
+		{Title: "Compute Class", Width: 13},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+	}
+
+	var rows []table.Row
+	var total cluster.Money
+	for _, workload := range workloads {
+		total += workload.Cost
+		rows = append(rows, table.Row{
+			workload.Namespace,
+			workload.Name,
+			strconv.FormatInt(workload.Cpu, 10),
+			strconv.FormatInt(workload.Memory, 10),
+			strconv.FormatInt(workload.Storage, 10),
+			cluster.ComputeClasses[workload.ComputeClass],
+			inBasis(workload.Cost),
+		})
+	}
+	rows = append(rows, summaryRow(columns, "Total, not billed", inBasis(total)))
+
+	renderTable(columns, rows)
+}
+
+func DisplayAdjustmentTable(workloads []cluster.Workload) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 30},
+		{Title: "Name", Width: 40},
+		{Title: "Compute class", Width: 16},
+		{Title: "Requested mCPU", Width: 15},
+		{Title: "Billed mCPU", Width: 12},
+		{Title: "Requested MiB", Width: 14},
+		{Title: "Billed MiB", Width: 11},
+	}
+
+	var rows []table.Row
+	for _, workload := range workloads {
+		rows = append(rows, table.Row{
+			workload.Namespace,
+			workload.Name,
+			cluster.ComputeClasses[workload.ComputeClass],
+			strconv.FormatInt(workload.RequestedCpu, 10),
+			strconv.FormatInt(workload.Cpu, 10),
+			strconv.FormatInt(workload.RequestedMemory, 10),
+			strconv.FormatInt(workload.Memory, 10),
+		})
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayRecommendationTable(recommendations []calculator.ClassRecommendation) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 30},
+		{Title: "Name", Width: 40},
+		{Title: "Mapped class", Width: 16},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Cheapest class", Width: 16},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Savings " + priceUnit(timeBasis.Unit), Width: 12},
+	}
+	columns = append(columns, projectionColumns("Savings")...)
+
+	var rows []table.Row
+	for _, recommendation := range recommendations {
+		row := table.Row{
+			recommendation.Namespace,
+			recommendation.Name,
+			cluster.ComputeClasses[recommendation.Current],
+			inBasis(recommendation.CurrentCost),
+			cluster.ComputeClasses[recommendation.Cheapest],
+			inBasis(recommendation.CheapestCost),
+			inBasis(recommendation.Savings),
+		}
+		rows = append(rows, append(row, projectedValues(recommendation.Savings)...))
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayCoverageTable(scenarios []calculator.CoverageScenario, clusterFee cluster.Money) {
+	columns := []table.Column{
+		{Title: "Term", Width: 10},
+		{Title: "Discount", Width: 10},
+		{Title: "Committed " + priceUnit(timeBasis.Unit), Width: 14},
+		{Title: "On-demand " + priceUnit(timeBasis.Unit), Width: 14},
+		{Title: "Total " + priceUnit(timeBasis.Unit), Width: 12},
+		{Title: "Savings " + priceUnit(timeBasis.Unit), Width: 12},
+	}
+	columns = append(columns, projectionColumns("Total")...)
+
+	terms := map[string]string{calculator.CommitOneYear: "1 year", calculator.CommitThreeYear: "3 years"}
+
+	var rows []table.Row
+	for _, scenario := range scenarios {
+		total := scenario.Cost + clusterFee
+		row := table.Row{
+			terms[scenario.Term],
+			fmt.Sprintf("%.0f%%", (1-scenario.Discount)*100),
+			inBasis(scenario.CommittedCost),
+			inBasis(scenario.OnDemandCost),
+			inBasis(total),
+			inBasis(scenario.Savings),
+		}
+		rows = append(rows, append(row, projectedValues(total)...))
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayOwnerTable(owners []cluster.OwnerCost) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 30},
+		{Title: "Kind", Width: 12},
+		{Title: "Name", Width: 40},
+		{Title: "Replicas", Width: 10},
+		{Title: "mCPU", Width: 10},
+		{Title: "Memory MiB", Width: 10},
+		{Title: "Storage MiB", Width: 12},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+	}
+	columns = append(columns, projectionColumns("Price")...)
+
+	var rows []table.Row
+	for _, owner := range owners {
+		row := table.Row{
+			owner.Namespace,
+			owner.Owner.Kind,
+			owner.Owner.Name,
+			strconv.Itoa(owner.Replicas),
+			strconv.FormatInt(owner.Cpu, 10),
+			strconv.FormatInt(owner.Memory, 10),
+			strconv.FormatInt(owner.Storage, 10),
+			inBasis(owner.Cost),
+		}
+		rows = append(rows, append(row, projectedValues(owner.Cost)...))
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayAutoscalerTable(autoscalers []cluster.AutoscalerCost) {
+	columns := []table.Column{
+		{Title: "Namespace", Width: 30},
+		{Title: "Autoscaler", Width: 30},
+		{Title: "Target", Width: 40},
+		{Title: "Replicas", Width: 10},
+		{Title: "Min-Max", Width: 10},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Max Price " + priceUnit(timeBasis.Unit), Width: 14},
+	}
+	columns = append(columns, projectionColumns("Max Price")...)
+
+	var rows []table.Row
+	for _, autoscaler := range autoscalers {
+		row := table.Row{
+			autoscaler.Namespace,
+			autoscaler.Name,
+			autoscaler.Target.Kind + "/" + autoscaler.Target.Name,
+			strconv.Itoa(autoscaler.Replicas),
+			fmt.Sprintf("%d-%d", autoscaler.MinReplicas, autoscaler.MaxReplicas),
+			inBasis(autoscaler.Cost),
+			inBasis(autoscaler.MaxCost),
+		}
+		rows = append(rows, append(row, projectedValues(autoscaler.MaxCost)...))
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayComparisonTable(comparison calculator.StandardComparison) {
+	columns := []table.Column{
+		{Title: "Mode", Width: 20},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 12},
+	}
+
+	// Budgets are monthly, so the month is shown unless other periods are selected
+	comparisonProjections := []projection{}
+	for _, projection := range projections {
+		if projection.Period != timeBasis.Period {
+			comparisonProjections = append(comparisonProjections, projection)
+		}
+	}
+	if len(comparisonProjections) == 0 && timeBasis.Period != "month" {
+		comparisonProjections = availableProjections[1:2]
+	}
+	for _, projection := range comparisonProjections {
+		columns = append(columns, table.Column{Title: "Price " + priceUnit(projection.Unit), Width: 14})
+	}
+
+	row := func(label string, amount cluster.Money) table.Row {
+		row := table.Row{label, inBasis(amount)}
+		for _, projection := range comparisonProjections {
+			row = append(row, amount.Mul(projection.Hours).String())
+		}
+		return row
+	}
+
+	rows := []table.Row{
+		row("GKE Standard", comparison.StandardCost),
+		row("GKE Autopilot", comparison.AutopilotCost),
+		row(fmt.Sprintf("Savings (%.1f%%)", comparison.SavingsPercent), comparison.Savings),
+	}
+
+	renderTable(columns, rows)
+}
+
+func DisplayFleetTable(reports []*clusterReport, total calculator.StandardComparison) {
+	columns := []table.Column{
+		{Title: "Cluster", Width: 40},
+		{Title: "Region", Width: 20},
+		{Title: "Nodes", Width: 6},
+		{Title: "Workloads", Width: 10},
+		{Title: "Standard " + priceUnit(timeBasis.Unit), Width: 12},
+		{Title: "Autopilot " + priceUnit(timeBasis.Unit), Width: 13},
+		{Title: "Savings " + priceUnit(timeBasis.Unit), Width: 12},
+	}
+	columns = append(columns, projectionColumns("Savings")...)
+
+	var rows []table.Row
+	for _, report := range reports {
+		row := table.Row{
+			report.Name,
+			report.Region,
+			strconv.Itoa(len(report.nodes)),
+			strconv.Itoa(len(report.workloads)),
+			inBasis(report.comparison.StandardCost),
+			inBasis(report.comparison.AutopilotCost),
+			inBasis(report.comparison.Savings),
+		}
+		rows = append(rows, append(row, projectedValues(report.comparison.Savings)...))
+	}
+	values := append([]string{inBasis(total.StandardCost), inBasis(total.AutopilotCost), inBasis(total.Savings)}, projectedValues(total.Savings)...)
+	rows = append(rows, summaryRow(columns, fmt.Sprintf("Fleet total (%.1f%% savings)", total.SavingsPercent), values...))
+
+	renderTable(columns, rows)
+}
+
+func DisplayDiffTable(diff reportDiff) {
+	columns := []table.Column{
+		{Title: "Workload", Width: 60},
+		{Title: "Change", Width: 8},
+		{Title: "Pods", Width: 8},
+		{Title: "Old " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "New " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Delta " + priceUnit(timeBasis.Unit), Width: 12},
+	}
+	columns = append(columns, projectionColumns("Delta")...)
+
+	var rows []table.Row
+	for _, workload := range diff.Workloads {
+		row := table.Row{
+			workload.Workload,
+			workload.Status,
+			fmt.Sprintf("%d→%d", workload.OldReplicas, workload.NewReplicas),
+			inBasis(workload.OldCost),
+			inBasis(workload.NewCost),
+			inBasis(workload.Delta),
+		}
+		rows = append(rows, append(row, projectedValues(workload.Delta)...))
+	}
+
+	values := append([]string{inBasis(diff.OldTotal.StandardCost), inBasis(diff.NewTotal.StandardCost), inBasis(diff.StandardDelta)}, projectedValues(diff.StandardDelta)...)
+	rows = append(rows, summaryRow(columns, "GKE Standard total", values...))
+	values = append([]string{inBasis(diff.OldTotal.AutopilotCost), inBasis(diff.NewTotal.AutopilotCost), inBasis(diff.AutopilotDelta)}, projectedValues(diff.AutopilotDelta)...)
+	rows = append(rows, summaryRow(columns, "GKE Autopilot total", values...))
+
+	renderTable(columns, rows)
+}
+
+func DisplayEstimateTable(estimates []podEstimate) {
+	columns := []table.Column{
+		{Title: "Kind", Width: 12},
+		{Title: "Workload", Width: 40},
+		{Title: "Replicas", Width: 10},
+		{Title: "mCPU", Width: 10},
+		{Title: "Memory MiB", Width: 10},
+		{Title: "Storage MiB", Width: 12},
+		{Title: "Compute Class", Width: 13},
+		{Title: "Pod " + priceUnit(timeBasis.Unit), Width: 10},
+		{Title: "Price " + priceUnit(timeBasis.Unit), Width: 10},
+	}
+
+	columns = append(columns, projectionColumns("Price")...)
+
+	var rows []table.Row
+	var totalCost cluster.Money
+	for _, estimate := range estimates {
+		totalCost += estimate.HourlyCost
+		row := table.Row{
+			estimate.Kind,
+			estimate.Workload.Name,
+			strconv.Itoa(int(estimate.Replicas)),
+			strconv.FormatInt(estimate.Workload.Cpu, 10),
+			strconv.FormatInt(estimate.Workload.Memory, 10),
+			strconv.FormatInt(estimate.Workload.Storage, 10),
+			cluster.ComputeClasses[estimate.Workload.ComputeClass],
+			inBasis(estimate.Workload.Cost),
+			inBasis(estimate.HourlyCost),
+		}
+		rows = append(rows, append(row, projectedValues(estimate.HourlyCost)...))
+	}
+	rows = append(rows, summaryRow(columns, "Total cost per "+timeBasis.Period+" (without the cluster fee)", append([]string{inBasis(totalCost)}, projectedValues(totalCost)...)...))
+
+	renderTable(columns, rows)
+}
+
+func DisplayUsageReport(report usage.Report) {
+	columns := []table.Column{
+		{Title: "API / Phase", Width: 20},
+		{Title: "Calls / Time", Width: 15},
+	}
+
+	var rows []table.Row
+	for _, api := range report.APIs() {
+		rows = append(rows, table.Row{string(api), strconv.Itoa(report.Calls[api])})
+	}
+	for _, phase := range report.Phases {
+		rows = append(rows, table.Row{phase.Name, phase.Duration.Round(time.Millisecond).String()})
+	}
+
+	renderTable(columns, rows)
 }
@@ -0,0 +1,106 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	storage "google.golang.org/api/storage/v1"
+)
+
+// uploadObject is a report rendered for -upload.
+type uploadObject struct {
+	Extension   string
+	ContentType string
+	Contents    []byte
+}
+
+// parseGCSPath splits a gs://bucket/path/ location into the bucket and the object name prefix.
+func parseGCSPath(location string) (string, string, error) {
+	if !strings.HasPrefix(location, "gs://") {
+		return "", "", fmt.Errorf("upload location %q is not a gs://bucket/path/ URL", location)
+	}
+
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "gs://"), "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("upload location %q has no bucket", location)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return bucket, prefix, nil
+}
+
+// getUploadObjectName names the objects of a run by its time, eg. reports/autopilot-estimate-20230701T120000Z.json,
+// so scheduled runs don't overwrite each other.
+func getUploadObjectName(prefix string, runTime time.Time, extension string) string {
+	return prefix + "autopilot-estimate-" + runTime.UTC().Format("20060102T150405Z") + extension
+}
+
+// renderUploadObjects renders the JSON, CSV export and HTML reports of the run.
+func renderUploadObjects(reports []*clusterReport, options runOptions, rows []exportRow) ([]uploadObject, error) {
+	contents, err := json.MarshalIndent(getJSONDocument(reports, options, false), "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	var csv bytes.Buffer
+	if err := renderExportCsv(&csv, rows); err != nil {
+		return nil, err
+	}
+
+	var html bytes.Buffer
+	if err := renderHtmlReport(&html, reports); err != nil {
+		return nil, err
+	}
+
+	return []uploadObject{
+		{Extension: ".json", ContentType: "application/json", Contents: contents},
+		{Extension: ".csv", ContentType: "text/csv", Contents: csv.Bytes()},
+		{Extension: ".html", ContentType: "text/html", Contents: html.Bytes()},
+	}, nil
+}
+
+// uploadReports writes the reports of the run to Cloud Storage and returns the URLs of the objects.
+func uploadReports(location string, runTime time.Time, objects []uploadObject) ([]string, error) {
+	bucket, prefix, err := parseGCSPath(location)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := storage.NewService(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize storage service: %v", err)
+	}
+
+	var urls []string
+	for _, object := range objects {
+		name := getUploadObjectName(prefix, runTime, object.Extension)
+		_, err := svc.Objects.Insert(bucket, &storage.Object{Name: name, ContentType: object.ContentType}).
+			Media(bytes.NewReader(object.Contents)).Do()
+		if err != nil {
+			return urls, fmt.Errorf("unable to upload %s: %v", name, err)
+		}
+		urls = append(urls, "gs://"+bucket+"/"+name)
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package usage counts the API calls a run makes and times its phases, so the
+// tool itself can be kept cheap and within quotas.
+package usage
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type API string
+
+const (
+	Kubernetes API = "kubernetes"
+	Metrics    API = "metrics"
+	GKE        API = "gke"
+	Billing    API = "billing"
+	Compute    API = "compute"
+	Monitoring API = "monitoring"
+	Prometheus API = "prometheus"
+)
+
+// PhaseTiming is how long a phase of the run took.
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Report is the API usage of the run so far.
+type Report struct {
+	Calls  map[API]int
+	Phases []PhaseTiming
+}
+
+var (
+	mutex  sync.Mutex
+	calls  = make(map[API]int)
+	phases []PhaseTiming
+)
+
+// Count records a single call to the API.
+func Count(api API) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	calls[api]++
+}
+
+type countingTransport struct {
+	api       API
+	transport http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	Count(t.api)
+	return t.transport.RoundTrip(request)
+}
+
+// CountTransport wraps an HTTP transport to count every request as a call to
+// the API, eg. for the WrapTransport of a Kubernetes client config.
+func CountTransport(api API) func(http.RoundTripper) http.RoundTripper {
+	return func(transport http.RoundTripper) http.RoundTripper {
+		return countingTransport{api: api, transport: transport}
+	}
+}
+
+// Phase starts timing a phase of the run, the returned function ends it.
+func Phase(name string) func() {
+	start := time.Now()
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		phases = append(phases, PhaseTiming{Name: name, Duration: time.Since(start)})
+	}
+}
+
+// GetReport returns a copy of the calls and phase timings recorded so far.
+func GetReport() Report {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	report := Report{
+		Calls:  make(map[API]int, len(calls)),
+		Phases: append([]PhaseTiming{}, phases...),
+	}
+	for api, count := range calls {
+		report.Calls[api] = count
+	}
+
+	return report
+}
+
+// APIs returns the called APIs of the report in a stable order.
+func (report Report) APIs() []API {
+	apis := make([]API, 0, len(report.Calls))
+	for api := range report.Calls {
+		apis = append(apis, api)
+	}
+
+	sort.Slice(apis, func(i, j int) bool {
+		return apis[i] < apis[j]
+	})
+
+	return apis
+}
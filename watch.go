@@ -0,0 +1,128 @@
+// Copyright 2023 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/GoogleCloudPlatform/autopilot-cost-calculator/logging"
+	"gopkg.in/ini.v1"
+)
+
+// watchClusters re-estimates the clusters every interval until the process is stopped. The table is
+// redrawn after every estimate, with -json every estimate is written as a single line document.
+// With a metrics address the latest estimates are also served on /metrics. A positive timeout limits every
+// round, and the watch returns when ctx is cancelled.
+func watchClusters(ctx context.Context, cfg *ini.File, contexts []string, options runOptions, interval time.Duration, timeout time.Duration, jsonOutput bool, metricsAddr string) {
+	server := &estimateServer{cfg: cfg, namespaces: options.namespaces, selector: options.selector}
+
+	serving := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		roundOptions := options
+
+		roundCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			roundCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+
+		progress.Start()
+		var reports []*clusterReport
+		for _, contextName := range contexts {
+			if ctx.Err() != nil {
+				break
+			}
+			report, err := estimateCluster(roundCtx, cfg, contextName, roundOptions)
+			if err != nil {
+				// The next round may succeed, eg. after a transient API error
+				logging.Error("Error estimating context %q: %v", contextName, err)
+				continue
+			}
+			reports = append(reports, report)
+
+			// The free tier credit is per billing account, so it only covers the first cluster
+			roundOptions.freeTier = false
+		}
+		progress.Stop()
+		cancel()
+
+		// An interrupted round is not printed, the previous one stays on the screen
+		if ctx.Err() != nil {
+			return
+		}
+
+		if len(reports) > 0 {
+			server.mutex.Lock()
+			for _, report := range reports {
+				server.remember(report)
+			}
+			server.mutex.Unlock()
+
+			if jsonOutput {
+				contents, _ := json.Marshal(getJSONDocument(reports, options, false))
+				fmt.Printf("%s\n", contents)
+			} else {
+				printWatchRound(reports, options, interval)
+			}
+		}
+
+		// The first round is done before serving, so scrapes don't start an estimate of their own
+		if metricsAddr != "" && !serving {
+			serving = true
+			go func() {
+				logging.Info("Serving metrics on %s.", metricsAddr)
+				log.Fatal(http.ListenAndServe(metricsAddr, server.handler()))
+			}()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// printWatchRound clears the terminal and prints the reports of a round of -watch.
+func printWatchRound(reports []*clusterReport, options runOptions, interval time.Duration) {
+	if !markdownOutput && !plainOutput {
+		fmt.Print("\033[H\033[2J")
+	}
+
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printClusterReport(report, options)
+	}
+
+	if len(reports) > 1 {
+		fmt.Println()
+		fmt.Println(pinkTextStyle.Render(fmt.Sprintf("Fleet of %d clusters", len(reports))))
+		DisplayFleetTable(reports, getFleetTotal(reports))
+	}
+
+	now := time.Now()
+	fmt.Println()
+	fmt.Printf("Updated at %s, next estimate at %s.\n", now.Format(time.Kitchen), now.Add(interval).Format(time.Kitchen))
+}